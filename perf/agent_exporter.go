@@ -0,0 +1,273 @@
+package perf
+
+import (
+	"context"
+	"os"
+	"sync"
+	"time"
+
+	commonpb "github.com/census-instrumentation/opencensus-proto/gen-go/agent/common/v1"
+	agentmetricspb "github.com/census-instrumentation/opencensus-proto/gen-go/agent/metrics/v1"
+	metricspb "github.com/census-instrumentation/opencensus-proto/gen-go/metrics/v1"
+	"github.com/edaniels/golog"
+	"go.opencensus.io/metric/metricdata"
+	"go.opencensus.io/metric/metricexport"
+	"go.uber.org/multierr"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/protobuf/types/known/timestamppb"
+
+	"go.viam.com/utils/perf/statz"
+)
+
+const defaultAgentAddress = "localhost:55678"
+
+// AgentOptions are options for the exporter to an OpenCensus agent or collector.
+type AgentOptions struct {
+	Context context.Context
+	Logger  golog.Logger
+	// Address of the agent. Defaults to localhost:55678.
+	Address string
+	// ServiceName identifies this process to the agent. Defaults to the executable name.
+	ServiceName string
+	// ReportingInterval is how often metrics are pushed. Defaults to 60 seconds.
+	ReportingInterval time.Duration
+	// DialOptions are used to connect to the agent. Defaults to an insecure connection.
+	DialOptions []grpc.DialOption
+}
+
+// NewAgentExporter creates a new exporter that pushes metrics, including all statz metrics, to an
+// OpenCensus agent or collector over gRPC. The connection to the agent is established lazily and
+// re-established after failures, so the agent does not need to be up when the exporter starts.
+func NewAgentExporter(opts AgentOptions) (Exporter, error) {
+	if opts.Context == nil {
+		opts.Context = context.Background()
+	}
+	if opts.Logger == nil {
+		opts.Logger = golog.Global()
+	}
+	if opts.Address == "" {
+		opts.Address = defaultAgentAddress
+	}
+	if opts.ServiceName == "" {
+		opts.ServiceName = os.Args[0]
+	}
+	if opts.ReportingInterval == 0 {
+		opts.ReportingInterval = 60 * time.Second
+	}
+	if len(opts.DialOptions) == 0 {
+		opts.DialOptions = []grpc.DialOption{grpc.WithTransportCredentials(insecure.NewCredentials())}
+	}
+	return &agentExporter{opts: opts}, nil
+}
+
+type agentExporter struct {
+	opts AgentOptions
+
+	mu           sync.Mutex
+	conn         *grpc.ClientConn
+	stream       agentmetricspb.MetricsService_ExportClient
+	streamCancel func()
+	reader       *metricexport.IntervalReader
+}
+
+// Starts the applications stats monitoring. Registers views and starts pushing metrics to the agent.
+func (e *agentExporter) Start() error {
+	if err := registerApplicationViews(); err != nil {
+		return err
+	}
+
+	// Dialing is non-blocking; gRPC reconnects to the agent in the background.
+	conn, err := grpc.DialContext(e.opts.Context, e.opts.Address, e.opts.DialOptions...)
+	if err != nil {
+		return err
+	}
+	reader, err := metricexport.NewIntervalReader(metricexport.NewReader(), e)
+	if err != nil {
+		return multierr.Combine(err, conn.Close())
+	}
+	reader.ReportingInterval = e.opts.ReportingInterval
+
+	e.mu.Lock()
+	e.conn = conn
+	e.reader = reader
+	e.mu.Unlock()
+	return reader.Start()
+}
+
+// Stop all exporting and flush remaining metrics.
+func (e *agentExporter) Stop() {
+	e.mu.Lock()
+	reader := e.reader
+	e.mu.Unlock()
+	if reader != nil {
+		// Stop does a final export.
+		reader.Stop()
+	}
+
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	e.resetStream()
+	if e.conn != nil {
+		if err := e.conn.Close(); err != nil {
+			e.opts.Logger.Errorw("failed to close OpenCensus agent connection", "error", err)
+		}
+		e.conn = nil
+	}
+}
+
+// Flush exports every metric recorded so far to the agent.
+func (e *agentExporter) Flush(ctx context.Context) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	return e.ExportMetrics(ctx, readMetrics())
+}
+
+// ExportMetrics implements metricexport.Exporter.
+func (e *agentExporter) ExportMetrics(ctx context.Context, metrics []*metricdata.Metric) error {
+	if len(metrics) == 0 {
+		return nil
+	}
+	req := &agentmetricspb.ExportMetricsServiceRequest{Metrics: make([]*metricspb.Metric, 0, len(metrics))}
+	for _, m := range metrics {
+		req.Metrics = append(req.Metrics, metricToProto(m))
+	}
+
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	if e.conn == nil {
+		return nil
+	}
+	if e.stream == nil {
+		streamCtx, cancel := context.WithCancel(e.opts.Context)
+		stream, err := agentmetricspb.NewMetricsServiceClient(e.conn).Export(streamCtx)
+		if err != nil {
+			cancel()
+			e.opts.Logger.Errorw("failed to open OpenCensus agent metrics stream", "error", err)
+			return err
+		}
+		e.stream = stream
+		e.streamCancel = cancel
+		// The agent expects the node to be identified on the first message of a stream.
+		req.Node = &commonpb.Node{ServiceInfo: &commonpb.ServiceInfo{Name: e.opts.ServiceName}}
+	}
+	if err := e.stream.Send(req); err != nil {
+		// Drop the stream so the next export reconnects.
+		e.resetStream()
+		e.opts.Logger.Errorw("failed to export metrics to OpenCensus agent", "error", err)
+		return err
+	}
+	return nil
+}
+
+// resetStream closes the current stream, if any. The caller must hold mu.
+func (e *agentExporter) resetStream() {
+	if e.stream == nil {
+		return
+	}
+	//nolint:errcheck
+	e.stream.CloseSend()
+	e.streamCancel()
+	e.stream = nil
+	e.streamCancel = nil
+}
+
+// metricUnit returns the unit of the metric. OpenCensus only keeps the units "1", "ms", and "By"
+// when reading views as metrics, so the units of statz metrics, such as "ns", come from statz.
+func metricUnit(d metricdata.Descriptor) string {
+	if u, ok := statz.MetricUnit(d.Name); ok {
+		return string(u)
+	}
+	return string(d.Unit)
+}
+
+func metricToProto(m *metricdata.Metric) *metricspb.Metric {
+	labelKeys := make([]*metricspb.LabelKey, 0, len(m.Descriptor.LabelKeys))
+	for _, k := range m.Descriptor.LabelKeys {
+		labelKeys = append(labelKeys, &metricspb.LabelKey{Key: k.Key, Description: k.Description})
+	}
+	timeseries := make([]*metricspb.TimeSeries, 0, len(m.TimeSeries))
+	for _, ts := range m.TimeSeries {
+		labelValues := make([]*metricspb.LabelValue, 0, len(ts.LabelValues))
+		for _, v := range ts.LabelValues {
+			labelValues = append(labelValues, &metricspb.LabelValue{Value: v.Value, HasValue: v.Present})
+		}
+		points := make([]*metricspb.Point, 0, len(ts.Points))
+		for _, p := range ts.Points {
+			if point := pointToProto(p); point != nil {
+				points = append(points, point)
+			}
+		}
+		timeseries = append(timeseries, &metricspb.TimeSeries{
+			StartTimestamp: timestamppb.New(ts.StartTime),
+			LabelValues:    labelValues,
+			Points:         points,
+		})
+	}
+	return &metricspb.Metric{
+		MetricDescriptor: &metricspb.MetricDescriptor{
+			Name:        m.Descriptor.Name,
+			Description: m.Descriptor.Description,
+			Unit:        metricUnit(m.Descriptor),
+			Type:        metricTypeToProto(m.Descriptor.Type),
+			LabelKeys:   labelKeys,
+		},
+		Timeseries: timeseries,
+	}
+}
+
+func metricTypeToProto(t metricdata.Type) metricspb.MetricDescriptor_Type {
+	switch t {
+	case metricdata.TypeGaugeInt64:
+		return metricspb.MetricDescriptor_GAUGE_INT64
+	case metricdata.TypeGaugeFloat64:
+		return metricspb.MetricDescriptor_GAUGE_DOUBLE
+	case metricdata.TypeGaugeDistribution:
+		return metricspb.MetricDescriptor_GAUGE_DISTRIBUTION
+	case metricdata.TypeCumulativeInt64:
+		return metricspb.MetricDescriptor_CUMULATIVE_INT64
+	case metricdata.TypeCumulativeFloat64:
+		return metricspb.MetricDescriptor_CUMULATIVE_DOUBLE
+	case metricdata.TypeCumulativeDistribution:
+		return metricspb.MetricDescriptor_CUMULATIVE_DISTRIBUTION
+	case metricdata.TypeSummary:
+		return metricspb.MetricDescriptor_SUMMARY
+	default:
+		return metricspb.MetricDescriptor_UNSPECIFIED
+	}
+}
+
+// pointToProto converts p or returns nil for values the agent exporter does not support (summaries).
+func pointToProto(p metricdata.Point) *metricspb.Point {
+	point := &metricspb.Point{Timestamp: timestamppb.New(p.Time)}
+	switch v := p.Value.(type) {
+	case int64:
+		point.Value = &metricspb.Point_Int64Value{Int64Value: v}
+	case float64:
+		point.Value = &metricspb.Point_DoubleValue{DoubleValue: v}
+	case *metricdata.Distribution:
+		buckets := make([]*metricspb.DistributionValue_Bucket, 0, len(v.Buckets))
+		for _, b := range v.Buckets {
+			buckets = append(buckets, &metricspb.DistributionValue_Bucket{Count: b.Count})
+		}
+		var bucketOptions *metricspb.DistributionValue_BucketOptions
+		if v.BucketOptions != nil {
+			bucketOptions = &metricspb.DistributionValue_BucketOptions{
+				Type: &metricspb.DistributionValue_BucketOptions_Explicit_{
+					Explicit: &metricspb.DistributionValue_BucketOptions_Explicit{Bounds: v.BucketOptions.Bounds},
+				},
+			}
+		}
+		point.Value = &metricspb.Point_DistributionValue{DistributionValue: &metricspb.DistributionValue{
+			Count:                 v.Count,
+			Sum:                   v.Sum,
+			SumOfSquaredDeviation: v.SumOfSquaredDeviation,
+			BucketOptions:         bucketOptions,
+			Buckets:               buckets,
+		}}
+	default:
+		return nil
+	}
+	return point
+}
@@ -0,0 +1,176 @@
+package perf
+
+import (
+	"context"
+	"net"
+	"testing"
+	"time"
+
+	agentmetricspb "github.com/census-instrumentation/opencensus-proto/gen-go/agent/metrics/v1"
+	"github.com/edaniels/golog"
+	"go.opencensus.io/metric/metricdata"
+	"go.viam.com/test"
+	"google.golang.org/grpc"
+
+	"go.viam.com/utils/perf/statz"
+	"go.viam.com/utils/perf/statz/units"
+)
+
+type fakeAgent struct {
+	agentmetricspb.UnimplementedMetricsServiceServer
+	requests chan *agentmetricspb.ExportMetricsServiceRequest
+}
+
+func (a *fakeAgent) Export(stream agentmetricspb.MetricsService_ExportServer) error {
+	for {
+		req, err := stream.Recv()
+		if err != nil {
+			return err
+		}
+		a.requests <- req
+	}
+}
+
+var agentTestCounter = statz.NewCounter0("perf/test/agent_counter", statz.MetricConfig{
+	Description: "A counter exported to the agent",
+	Unit:        units.Dimensionless,
+})
+
+var agentTestFlushCounter = statz.NewCounter0("perf/test/agent_flush_counter", statz.MetricConfig{
+	Description: "A counter flushed to the agent",
+	Unit:        units.Dimensionless,
+})
+
+var agentTestNanoseconds = statz.NewNanosecondsDistribution0("perf/test/agent_nanoseconds", statz.MetricConfig{
+	Description: "A nanosecond distribution exported to the agent",
+	Unit:        units.Nanoseconds,
+}, statz.Distribution{})
+
+func TestAgentExporter(t *testing.T) {
+	logger := golog.NewTestLogger(t)
+
+	listener, err := net.Listen("tcp", "localhost:0")
+	test.That(t, err, test.ShouldBeNil)
+	agent := &fakeAgent{requests: make(chan *agentmetricspb.ExportMetricsServiceRequest, 100)}
+	grpcServer := grpc.NewServer()
+	agentmetricspb.RegisterMetricsServiceServer(grpcServer, agent)
+	errChan := make(chan error)
+	go func() {
+		errChan <- grpcServer.Serve(listener)
+	}()
+	defer func() {
+		grpcServer.Stop()
+		test.That(t, <-errChan, test.ShouldBeNil)
+	}()
+
+	exporter, err := NewAgentExporter(AgentOptions{
+		Logger:            logger,
+		Address:           listener.Addr().String(),
+		ServiceName:       "agent-test",
+		ReportingInterval: 10 * time.Millisecond,
+	})
+	test.That(t, err, test.ShouldBeNil)
+	test.That(t, exporter.Start(), test.ShouldBeNil)
+	defer exporter.Stop()
+
+	agentTestCounter.IncBy(3)
+
+	receive := func() *agentmetricspb.ExportMetricsServiceRequest {
+		t.Helper()
+		select {
+		case req := <-agent.requests:
+			return req
+		case <-time.After(10 * time.Second):
+			t.Fatal("timed out waiting for metrics")
+			return nil
+		}
+	}
+
+	first := receive()
+	test.That(t, first.Node, test.ShouldNotBeNil)
+	test.That(t, first.Node.ServiceInfo.Name, test.ShouldEqual, "agent-test")
+
+	for req := first; ; req = receive() {
+		var found bool
+		for _, m := range req.Metrics {
+			if m.MetricDescriptor.Name != "perf/test/agent_counter" {
+				continue
+			}
+			found = true
+			test.That(t, m.Timeseries, test.ShouldHaveLength, 1)
+			test.That(t, m.Timeseries[0].Points[0].GetInt64Value(), test.ShouldEqual, 3)
+		}
+		if found {
+			break
+		}
+	}
+
+	// subsequent requests on the same stream do not repeat the node.
+	test.That(t, receive().Node, test.ShouldBeNil)
+}
+
+func TestAgentExporterFlush(t *testing.T) {
+	logger := golog.NewTestLogger(t)
+
+	listener, err := net.Listen("tcp", "localhost:0")
+	test.That(t, err, test.ShouldBeNil)
+	agent := &fakeAgent{requests: make(chan *agentmetricspb.ExportMetricsServiceRequest, 100)}
+	grpcServer := grpc.NewServer()
+	agentmetricspb.RegisterMetricsServiceServer(grpcServer, agent)
+	errChan := make(chan error)
+	go func() {
+		errChan <- grpcServer.Serve(listener)
+	}()
+	defer func() {
+		grpcServer.Stop()
+		test.That(t, <-errChan, test.ShouldBeNil)
+	}()
+
+	// the reporting interval is too long to export anything during the test.
+	exporter, err := NewAgentExporter(AgentOptions{
+		Logger:            logger,
+		Address:           listener.Addr().String(),
+		ReportingInterval: time.Hour,
+	})
+	test.That(t, err, test.ShouldBeNil)
+	test.That(t, exporter.Start(), test.ShouldBeNil)
+	defer exporter.Stop()
+
+	agentTestFlushCounter.IncBy(2)
+
+	cancelledCtx, cancel := context.WithCancel(context.Background())
+	cancel()
+	test.That(t, Flush(cancelledCtx, exporter), test.ShouldEqual, context.Canceled)
+
+	test.That(t, Flush(context.Background(), exporter), test.ShouldBeNil)
+	select {
+	case req := <-agent.requests:
+		var found bool
+		for _, m := range req.Metrics {
+			if m.MetricDescriptor.Name != "perf/test/agent_flush_counter" {
+				continue
+			}
+			found = true
+			test.That(t, m.Timeseries[0].Points[0].GetInt64Value(), test.ShouldEqual, 2)
+		}
+		test.That(t, found, test.ShouldBeTrue)
+	case <-time.After(10 * time.Second):
+		t.Fatal("timed out waiting for metrics")
+	}
+
+	// exporters that cannot flush only flush batched counters.
+	test.That(t, Flush(context.Background(), NewDevelopmentExporter()), test.ShouldBeNil)
+}
+
+func TestMetricUnit(t *testing.T) {
+	agentTestNanoseconds.ObserveDuration(time.Microsecond)
+	// OpenCensus reports units it does not know as dimensionless.
+	test.That(t, metricUnit(metricdata.Descriptor{
+		Name: "perf/test/agent_nanoseconds",
+		Unit: metricdata.UnitDimensionless,
+	}), test.ShouldEqual, "ns")
+	test.That(t, metricUnit(metricdata.Descriptor{
+		Name: "perf/test/not_statz",
+		Unit: metricdata.UnitBytes,
+	}), test.ShouldEqual, "By")
+}
@@ -0,0 +1,36 @@
+package perf
+
+import (
+	"fmt"
+
+	"go.uber.org/multierr"
+
+	"go.viam.com/utils/perf/statz"
+	"go.viam.com/utils/perf/statz/units"
+)
+
+// A UnitSupporter is an Exporter that only translates some metric units cleanly to its backend.
+// Exporters that are not UnitSupporters are assumed to support every unit.
+type UnitSupporter interface {
+	// SupportsUnit returns whether the unit translates cleanly to the backend.
+	SupportsUnit(unit units.Unit) bool
+}
+
+// CheckExporterUnits checks the units of statz metrics against those supported by each of
+// exporters that is a UnitSupporter, as a diagnostic for when several exporters are configured.
+// See statz.CheckExporterUnits for how mismatches are reported.
+func CheckExporterUnits(exporters ...Exporter) error {
+	var errs error
+	for _, e := range exporters {
+		if supporter, ok := e.(UnitSupporter); ok {
+			errs = multierr.Append(errs, statz.CheckExporterUnits(fmt.Sprintf("%T", e), supporter.SupportsUnit))
+		}
+	}
+	return errs
+}
+
+// SupportsUnit returns whether Cloud Monitoring understands the unit. The units of the units
+// package are given in the form it expects.
+func (e *sdExporter) SupportsUnit(unit units.Unit) bool {
+	return units.Known(unit)
+}
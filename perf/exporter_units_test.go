@@ -0,0 +1,73 @@
+package perf
+
+import (
+	"testing"
+
+	"go.viam.com/test"
+
+	"go.viam.com/utils/perf/statz"
+	"go.viam.com/utils/perf/statz/units"
+)
+
+const furlongs = units.Unit("furlong")
+
+var unitsTestCounter = statz.NewCounter0("perf/test/units_counter", statz.MetricConfig{
+	Description: "A counter with an exotic unit",
+	Unit:        furlongs,
+})
+
+type noFurlongsExporter struct {
+	Exporter
+}
+
+func (e noFurlongsExporter) SupportsUnit(unit units.Unit) bool {
+	return unit != furlongs
+}
+
+func TestCheckExporterUnits(t *testing.T) {
+	unitsTestCounter.Inc()
+
+	// exporters that are not UnitSupporters support every unit.
+	test.That(t, CheckExporterUnits(NewDevelopmentExporter()), test.ShouldBeNil)
+
+	// mismatches only warn by default.
+	test.That(t, CheckExporterUnits(NewDevelopmentExporter(), noFurlongsExporter{}), test.ShouldBeNil)
+	statz.NewCounter0("perf/test/units_counter_warned", statz.MetricConfig{
+		Description: "A counter with an exotic unit",
+		Unit:        furlongs,
+	})
+
+	statz.SetStrictExporterUnits(true)
+	defer statz.SetStrictExporterUnits(false)
+	err := CheckExporterUnits(noFurlongsExporter{})
+	test.That(t, err, test.ShouldNotBeNil)
+	test.That(t, err.Error(), test.ShouldContainSubstring,
+		"metric perf/test/units_counter has unit 'furlong' which exporter perf.noFurlongsExporter does not support")
+
+	// metrics defined after the check fail to register, before their views are registered.
+	test.That(t, func() {
+		statz.NewCounter0("perf/test/units_counter_later", statz.MetricConfig{
+			Description: "A counter with an exotic unit",
+			Unit:        furlongs,
+		})
+	}, test.ShouldPanic)
+	_, ok := statz.MetricUnit("perf/test/units_counter_later")
+	test.That(t, ok, test.ShouldBeFalse)
+	statz.NewCounter0("perf/test/units_counter_supported", statz.MetricConfig{
+		Description: "A counter with a supported unit",
+		Unit:        units.Dimensionless,
+	})
+
+	// lenient registration skips them instead.
+	statz.SetLenientRegistration(true)
+	defer statz.SetLenientRegistration(false)
+	statz.NewCounter0("perf/test/units_counter_lenient", statz.MetricConfig{
+		Description: "A counter with an exotic unit",
+		Unit:        furlongs,
+	})
+	test.That(t, statz.RegistrationErrors(), test.ShouldNotBeNil)
+	test.That(t, statz.RegistrationErrors().Error(), test.ShouldContainSubstring, "metric perf/test/units_counter_lenient has unit 'furlong'")
+
+	test.That(t, (&sdExporter{}).SupportsUnit(units.Milliseconds), test.ShouldBeTrue)
+	test.That(t, (&sdExporter{}).SupportsUnit(furlongs), test.ShouldBeFalse)
+}
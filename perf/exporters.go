@@ -131,6 +131,16 @@ func (e *sdExporter) Stop() {
 	}
 }
 
+// Flush exports every metric recorded so far to Stackdriver and waits for pending spans to be
+// uploaded.
+func (e *sdExporter) Flush(ctx context.Context) error {
+	if err := e.sdExporter.ExportMetrics(ctx, readMetrics()); err != nil {
+		return err
+	}
+	e.sdExporter.Flush()
+	return ctx.Err()
+}
+
 type gaeResource struct {
 	projectID  string // GCP project ID
 	module     string // GAE/Cloud Run app name
@@ -0,0 +1,40 @@
+package perf
+
+import (
+	"context"
+
+	"go.opencensus.io/metric/metricdata"
+	"go.opencensus.io/metric/metricproducer"
+
+	"go.viam.com/utils/perf/statz"
+)
+
+// A Flusher is an Exporter that can export its pending metrics on demand.
+type Flusher interface {
+	// Flush exports the metrics recorded so far without waiting for the next reporting interval.
+	Flush(ctx context.Context) error
+}
+
+// Flush records the pending increments of batched statz counters and, if e is a Flusher, exports
+// every metric recorded so far. It is meant to be deferred in short-lived processes, such as
+// serverless invocations, that may exit before the next reporting interval and would otherwise
+// lose their last increments. Flushing does not reset the reporting interval, so the next
+// periodic export still happens on schedule; some backends, such as Cloud Monitoring, reject
+// points written to the same time series more often than every few seconds, so avoid flushing
+// more often than that. An error is returned if ctx is done before the metrics are exported.
+func Flush(ctx context.Context, e Exporter) error {
+	statz.FlushBatchedCounters()
+	if f, ok := e.(Flusher); ok {
+		return f.Flush(ctx)
+	}
+	return nil
+}
+
+// readMetrics reads the current value of every metric, as a periodic export would.
+func readMetrics() []*metricdata.Metric {
+	var metrics []*metricdata.Metric
+	for _, producer := range metricproducer.GlobalManager().GetAll() {
+		metrics = append(metrics, producer.Read()...)
+	}
+	return metrics
+}
@@ -0,0 +1,68 @@
+package statz
+
+import (
+	"fmt"
+
+	"go.opencensus.io/stats/view"
+
+	"go.viam.com/utils/perf/statz/internal"
+)
+
+// metricAlias is another name a metric is exported under (see MetricConfig.Aliases).
+type metricAlias struct {
+	metric string
+	view   *view.View
+}
+
+// DropMetricAlias stops exporting the metric alias with the given name, such as once dashboards
+// have moved to the new name of a metric. The alias name stays reserved.
+func DropMetricAlias(alias string) error {
+	registry.mu.Lock()
+	a, ok := registry.aliases[alias]
+	delete(registry.aliases, alias)
+	registry.mu.Unlock()
+	if !ok {
+		return fmt.Errorf("no metric alias %s", alias)
+	}
+	view.Unregister(a.view)
+	return nil
+}
+
+// MetricAliases returns the metric each alias that is still exported stands for, by alias.
+func MetricAliases() map[string]string {
+	registry.mu.Lock()
+	defer registry.mu.Unlock()
+	aliases := make(map[string]string, len(registry.aliases))
+	for alias, a := range registry.aliases {
+		aliases[alias] = a.metric
+	}
+	return aliases
+}
+
+// registerMetricAliases registers a view for each alias of the metric that shares its measure,
+// aggregation, and labels so that every recording is also exported under the alias.
+func registerMetricAliases(data *opencensusStatsData, aliases []string) error {
+	for _, alias := range aliases {
+		if err := internal.TryRegisterMetric(alias); err != nil {
+			return err
+		}
+		aliasView := &view.View{
+			Name:        alias,
+			Measure:     data.View.Measure,
+			Description: data.View.Description,
+			Aggregation: data.View.Aggregation,
+			TagKeys:     data.View.TagKeys,
+		}
+		if err := view.Register(aliasView); err != nil {
+			return fmt.Errorf("failed to register the view for alias %s of metric %s: %w", alias, data.View.Name, err)
+		}
+
+		registry.mu.Lock()
+		if registry.aliases == nil {
+			registry.aliases = map[string]*metricAlias{}
+		}
+		registry.aliases[alias] = &metricAlias{metric: data.View.Name, view: aliasView}
+		registry.mu.Unlock()
+	}
+	return nil
+}
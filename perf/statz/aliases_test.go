@@ -0,0 +1,56 @@
+package statz
+
+import (
+	"testing"
+
+	"go.viam.com/test"
+
+	"go.viam.com/utils/perf/statz/statztest"
+	"go.viam.com/utils/perf/statz/units"
+)
+
+func TestMetricAliases(t *testing.T) {
+	counter := NewCounter1[string]("statz/test/renamed_counter", MetricConfig{
+		Description: "A renamed counter",
+		Unit:        units.Dimensionless,
+		Labels: []Label{
+			{Name: "label", Description: "A label."},
+		},
+		Aliases: []string{"statz/test/old_counter"},
+	})
+	recorder := statztest.NewCounterRecorder("statz/test/renamed_counter")
+	aliasRecorder := statztest.NewCounterRecorder("statz/test/old_counter")
+
+	counter.Inc("a")
+	counter.IncBy("a", 2)
+	test.That(t, recorder.Value("label", "a"), test.ShouldEqual, 3)
+	test.That(t, aliasRecorder.Value("label", "a"), test.ShouldEqual, 3)
+	test.That(t, MetricAliases(), test.ShouldContainKey, "statz/test/old_counter")
+	test.That(t, MetricAliases()["statz/test/old_counter"], test.ShouldEqual, "statz/test/renamed_counter")
+	unit, ok := MetricUnit("statz/test/old_counter")
+	test.That(t, ok, test.ShouldBeTrue)
+	test.That(t, unit, test.ShouldEqual, units.Dimensionless)
+
+	test.That(t, DropMetricAlias("statz/test/old_counter"), test.ShouldBeNil)
+	counter.Inc("b")
+	test.That(t, recorder.Value("label", "b"), test.ShouldEqual, 1)
+	test.That(t, aliasRecorder.Value("label", "b"), test.ShouldEqual, 0)
+	test.That(t, MetricAliases(), test.ShouldNotContainKey, "statz/test/old_counter")
+	test.That(t, DropMetricAlias("statz/test/old_counter"), test.ShouldNotBeNil)
+
+	test.That(t, func() {
+		NewCounter0("statz/test/alias_conflict", MetricConfig{
+			Description: "A counter whose alias is taken",
+			Unit:        units.Dimensionless,
+			Aliases:     []string{"statz/test/renamed_counter"},
+		})
+	}, test.ShouldPanic)
+
+	err := ValidateMetricConfig("statz/test/alias_self", MetricConfig{
+		Description: "A counter aliased to itself",
+		Unit:        units.Dimensionless,
+		Aliases:     []string{"statz/test/alias_self"},
+	})
+	test.That(t, err, test.ShouldNotBeNil)
+	test.That(t, err.Error(), test.ShouldContainSubstring, "duplicate alias")
+}
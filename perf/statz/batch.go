@@ -0,0 +1,120 @@
+package statz
+
+import (
+	"context"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/edaniels/golog"
+	"go.opencensus.io/stats"
+)
+
+// batchedCounters holds every batched counter so they can all be flushed on demand.
+var batchedCounters struct {
+	mu       sync.Mutex
+	batchers []*counterBatcher
+}
+
+// FlushBatchedCounters records the pending increments of every counter created with a
+// MetricConfig.BatchInterval. It is useful before shutdown or in tests.
+func FlushBatchedCounters() {
+	batchedCounters.mu.Lock()
+	batchers := batchedCounters.batchers
+	batchedCounters.mu.Unlock()
+	for _, b := range batchers {
+		b.flush()
+	}
+}
+
+// StopBatchedCounters stops the background flushing of every counter created with a
+// MetricConfig.BatchInterval after recording their pending increments. Later increments of
+// those counters are recorded immediately. It is useful on shutdown or at the end of tests.
+func StopBatchedCounters() {
+	batchedCounters.mu.Lock()
+	batchers := batchedCounters.batchers
+	batchedCounters.mu.Unlock()
+	for _, b := range batchers {
+		b.stop()
+	}
+}
+
+// batchedCount accumulates the increments of one combination of label values.
+type batchedCount struct {
+	labels []string
+	n      int64
+}
+
+// counterBatcher accumulates counter increments in atomics keyed by label values and
+// periodically records their sums to OpenCensus, so that hot counters do not contend on
+// stats.Record.
+type counterBatcher struct {
+	data    *opencensusStatsData
+	measure *stats.Int64Measure
+	counts  sync.Map // label key -> *batchedCount
+	// stopped is set to 1 once the batcher is stopped and increments are no longer batched.
+	stopped  int32
+	stopOnce sync.Once
+	done     chan struct{}
+}
+
+func newCounterBatcher(data *opencensusStatsData, measure *stats.Int64Measure, interval time.Duration) *counterBatcher {
+	b := &counterBatcher{data: data, measure: measure, done: make(chan struct{})}
+	batchedCounters.mu.Lock()
+	batchedCounters.batchers = append(batchedCounters.batchers, b)
+	batchedCounters.mu.Unlock()
+
+	go func() {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-b.done:
+				return
+			case <-ticker.C:
+				b.flush()
+			}
+		}
+	}()
+	return b
+}
+
+// stop stops the periodic flushing of the batcher and records its pending increments.
+func (b *counterBatcher) stop() {
+	b.stopOnce.Do(func() {
+		atomic.StoreInt32(&b.stopped, 1)
+		close(b.done)
+	})
+	b.flush()
+}
+
+// add batches an increment. It returns false if the batcher is stopped and the increment
+// should be recorded directly instead.
+func (b *counterBatcher) add(labels []string, n int64) bool {
+	if atomic.LoadInt32(&b.stopped) == 1 {
+		return false
+	}
+	key := strings.Join(labels, "\x00")
+	count, ok := b.counts.Load(key)
+	if !ok {
+		count, _ = b.counts.LoadOrStore(key, &batchedCount{labels: labels})
+	}
+	atomic.AddInt64(&count.(*batchedCount).n, n)
+	return true
+}
+
+func (b *counterBatcher) flush() {
+	b.counts.Range(func(_, value interface{}) bool {
+		count := value.(*batchedCount)
+		n := atomic.SwapInt64(&count.n, 0)
+		if n == 0 {
+			return true
+		}
+		mutations := b.data.labelsToMutations(count.labels)
+		if err := stats.RecordWithTags(context.Background(), mutations, b.measure.M(n)); err != nil {
+			golog.Global().Errorf("failed to write metric %s", err)
+		}
+		return true
+	})
+}
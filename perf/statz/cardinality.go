@@ -0,0 +1,153 @@
+package statz
+
+import (
+	"context"
+	"fmt"
+	"hash/fnv"
+	"math"
+	"math/bits"
+	"sync"
+
+	"github.com/edaniels/golog"
+	"go.opencensus.io/stats"
+	"go.opencensus.io/stats/view"
+)
+
+// cardinalityPrecision is the number of hash bits used to pick a HyperLogLog register. 2^12
+// one byte registers bound the memory of each gauge to 4KiB for a standard error of ~1.6%.
+const cardinalityPrecision = 12
+
+// CardinalityGauge is an int64 gauge of the estimated number of distinct values observed, such
+// as authenticated entities, that would be unbounded as a label. Values are fed into a
+// HyperLogLog estimator so memory is fixed regardless of how many distinct values there are.
+// The estimate only grows for the lifetime of the gauge.
+type CardinalityGauge struct {
+	wrapper *ocCardinalityWrapper
+}
+
+// Observe adds value to the set of distinct values the gauge estimates the size of.
+func (g *CardinalityGauge) Observe(value string) {
+	g.wrapper.observe(context.Background(), value)
+}
+
+// Estimate returns the current estimate of the number of distinct values observed.
+func (g *CardinalityGauge) Estimate() int64 {
+	return g.wrapper.estimate()
+}
+
+//// CardinalityGauge - Create a cardinality gauge at the package level.
+//
+// var distinctUploaders = statz.NewCardinalityGauge("datasync/distinct_uploaders", statz.MetricConfig{
+// 		Description: "The estimated number of distinct uploaders",
+// 		Unit:        units.Dimensionless,
+//  })
+//
+// Usage:
+// distinctUploaders.Observe(uploaderID)
+//
+
+// NewCardinalityGauge creates a new cardinality gauge metric. Cardinality gauges do not
+// support labels.
+func NewCardinalityGauge(name string, cfg MetricConfig) CardinalityGauge {
+	return CardinalityGauge{
+		wrapper: createCardinalityWrapper(name, cfg),
+	}
+}
+
+///// internal
+
+type ocCardinalityWrapper struct {
+	data    *opencensusStatsData
+	measure *stats.Int64Measure
+
+	mu        sync.Mutex
+	registers []uint8
+}
+
+func (w *ocCardinalityWrapper) observe(ctx context.Context, value string) {
+	if w.data.disabled {
+		return
+	}
+	h := fnv.New64a()
+	//nolint:errcheck
+	h.Write([]byte(value))
+	hash := mixHash(h.Sum64())
+
+	idx := hash >> (64 - cardinalityPrecision)
+	// The guard bit bounds the rank when the remaining bits are all zero.
+	rank := uint8(bits.LeadingZeros64(hash<<cardinalityPrecision|1<<(cardinalityPrecision-1)) + 1)
+
+	w.mu.Lock()
+	if rank <= w.registers[idx] {
+		w.mu.Unlock()
+		return
+	}
+	w.registers[idx] = rank
+	estimate := w.estimateLocked()
+	w.mu.Unlock()
+
+	// The estimate only changes when a register does, which quickly becomes rare.
+	if err := stats.RecordWithTags(ctx, nil, w.measure.M(estimate)); err != nil {
+		golog.Global().Errorf("faild to write metric %s", err)
+	}
+}
+
+func (w *ocCardinalityWrapper) estimate() int64 {
+	if w.data.disabled {
+		return 0
+	}
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	return w.estimateLocked()
+}
+
+func (w *ocCardinalityWrapper) estimateLocked() int64 {
+	m := float64(len(w.registers))
+	var sum float64
+	var zeros int
+	for _, r := range w.registers {
+		sum += math.Ldexp(1, -int(r))
+		if r == 0 {
+			zeros++
+		}
+	}
+	alpha := 0.7213 / (1 + 1.079/m)
+	estimate := alpha * m * m / sum
+	// Small cardinalities are better estimated by linear counting of the empty registers.
+	if estimate <= 2.5*m && zeros != 0 {
+		estimate = m * math.Log(m/float64(zeros))
+	}
+	return int64(math.Round(estimate))
+}
+
+// mixHash is the splitmix64 finalizer; it spreads FNV's weak high bits across the whole hash,
+// which HyperLogLog relies on for its register index and rank.
+func mixHash(h uint64) uint64 {
+	h ^= h >> 30
+	h *= 0xbf58476d1ce4e5b9
+	h ^= h >> 27
+	h *= 0x94d049bb133111eb
+	h ^= h >> 31
+	return h
+}
+
+func createCardinalityWrapper(name string, cfg MetricConfig) *ocCardinalityWrapper {
+	if len(cfg.Labels) != 0 {
+		err := fmt.Errorf("metric %s is a cardinality gauge which does not support labels", name)
+		if !lenientRegistration() {
+			golog.Global().Panicf("Failed to register %s", err)
+			return nil
+		}
+		addRegistrationError(err)
+		golog.Global().Errorw("metric registration failed, it will not be recorded", "metric", name, "error", err)
+		return &ocCardinalityWrapper{data: &opencensusStatsData{View: &view.View{Name: name}, disabled: true}}
+	}
+
+	measure := stats.Int64(name, cfg.Description, string(cfg.Unit))
+	ocData := createAndRegisterOpenCensusMetric(name, measure, view.LastValue(), cfg)
+	return &ocCardinalityWrapper{
+		data:      ocData,
+		measure:   measure,
+		registers: make([]uint8, 1<<cardinalityPrecision),
+	}
+}
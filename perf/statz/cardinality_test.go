@@ -0,0 +1,42 @@
+package statz
+
+import (
+	"fmt"
+	"testing"
+
+	"go.viam.com/test"
+
+	"go.viam.com/utils/perf/statz/statztest"
+	"go.viam.com/utils/perf/statz/units"
+)
+
+func TestCardinalityGauge(t *testing.T) {
+	gauge := NewCardinalityGauge("statz/test/cardinality", MetricConfig{
+		Description: "The number of distinct callers",
+		Unit:        units.Dimensionless,
+	})
+	recorder := statztest.NewCounterRecorder("statz/test/cardinality")
+
+	test.That(t, gauge.Estimate(), test.ShouldEqual, 0)
+	for i := 0; i < 3; i++ {
+		gauge.Observe("a")
+		gauge.Observe("b")
+	}
+	test.That(t, gauge.Estimate(), test.ShouldEqual, 2)
+	test.That(t, recorder.Value(), test.ShouldEqual, 2)
+
+	for i := 0; i < 100000; i++ {
+		gauge.Observe(fmt.Sprintf("entity-%d", i))
+	}
+	estimate := gauge.Estimate()
+	test.That(t, estimate, test.ShouldBeBetween, 95000, 105000)
+	test.That(t, recorder.Value(), test.ShouldEqual, estimate)
+
+	test.That(t, func() {
+		NewCardinalityGauge("statz/test/cardinality_labels", MetricConfig{
+			Description: "The number of distinct callers",
+			Unit:        units.Dimensionless,
+			Labels:      []Label{{Name: "label", Description: "A label."}},
+		})
+	}, test.ShouldPanic)
+}
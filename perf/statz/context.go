@@ -0,0 +1,44 @@
+package statz
+
+import (
+	"context"
+
+	"github.com/edaniels/golog"
+	"go.opencensus.io/tag"
+)
+
+// methodTagKey is the OpenCensus tag holding the gRPC method of the call in progress. Metrics
+// declaring a "method" label share it.
+var methodTagKey = tag.MustNewKey("method")
+
+// MethodUnknownLabel is the label value of MethodLabel outside of a gRPC call.
+const MethodUnknownLabel = "unknown"
+
+// ContextWithMethod returns a context whose OpenCensus tags carry the full gRPC method of the
+// call in progress. The rpc server's auth interceptors set it for every call so that metrics
+// recorded by handlers can be labeled by method without re-deriving it.
+func ContextWithMethod(ctx context.Context, fullMethod string) context.Context {
+	taggedCtx, err := tag.New(ctx, tag.Upsert(methodTagKey, fullMethod))
+	if err != nil {
+		golog.Global().Debugw("failed to tag context with method", "method", fullMethod, "error", err)
+		return ctx
+	}
+	return taggedCtx
+}
+
+// MethodFromContext returns the full gRPC method set by ContextWithMethod.
+func MethodFromContext(ctx context.Context) (string, bool) {
+	return tag.FromContext(ctx).Value(methodTagKey)
+}
+
+// MethodLabel returns the full gRPC method set by ContextWithMethod or MethodUnknownLabel,
+// for use as the value of a "method" label.
+//
+// Usage:
+// requestCounter.Inc(statz.MethodLabel(ctx))
+func MethodLabel(ctx context.Context) string {
+	if method, ok := MethodFromContext(ctx); ok {
+		return method
+	}
+	return MethodUnknownLabel
+}
@@ -2,10 +2,13 @@ package statz
 
 import (
 	"context"
+	"fmt"
+	"time"
 
 	"github.com/edaniels/golog"
 	"go.opencensus.io/stats"
 	"go.opencensus.io/stats/view"
+	"go.opencensus.io/tag"
 )
 
 // Counter0 is a incremental int64 counter type with no metric labels.
@@ -23,6 +26,12 @@ func (c *Counter0) IncBy(by int64) {
 	c.wrapper.incBy(context.Background(), labelsToStringSlice(), by)
 }
 
+// LastUpdated returns when the metric was last recorded with the given label values or the zero
+// time if it has not been or MetricConfig.TrackLastUpdated is not set.
+func (c *Counter0) LastUpdated() time.Time {
+	return c.wrapper.data.lastUpdated(labelsToStringSlice())
+}
+
 // Counter1 is a incremental int64 counter type with 1 metric label.
 type Counter1[T1 labelContraint] struct {
 	wrapper *ocCounterWrapper
@@ -38,6 +47,17 @@ func (c *Counter1[T1]) IncBy(v1 T1, by int64) {
 	c.wrapper.incBy(context.Background(), labelsToStringSlice(v1), by)
 }
 
+// Bind returns a handle to the counter of the given label values for hot paths.
+func (c *Counter1[T1]) Bind(v1 T1) BoundCounter {
+	return c.wrapper.bind(labelsToStringSlice(v1))
+}
+
+// LastUpdated returns when the metric was last recorded with the given label values or the zero
+// time if it has not been or MetricConfig.TrackLastUpdated is not set.
+func (c *Counter1[T1]) LastUpdated(v1 T1) time.Time {
+	return c.wrapper.data.lastUpdated(labelsToStringSlice(v1))
+}
+
 // Counter2 is a incremental int64 counter type with 2 metric label.
 type Counter2[T1 labelContraint, T2 labelContraint] struct {
 	wrapper *ocCounterWrapper
@@ -53,6 +73,17 @@ func (c *Counter2[T1, T2]) IncBy(v1 T1, v2 T2, by int64) {
 	c.wrapper.incBy(context.Background(), labelsToStringSlice(v1, v2), by)
 }
 
+// Bind returns a handle to the counter of the given label values for hot paths.
+func (c *Counter2[T1, T2]) Bind(v1 T1, v2 T2) BoundCounter {
+	return c.wrapper.bind(labelsToStringSlice(v1, v2))
+}
+
+// LastUpdated returns when the metric was last recorded with the given label values or the zero
+// time if it has not been or MetricConfig.TrackLastUpdated is not set.
+func (c *Counter2[T1, T2]) LastUpdated(v1 T1, v2 T2) time.Time {
+	return c.wrapper.data.lastUpdated(labelsToStringSlice(v1, v2))
+}
+
 // Counter3 is a incremental int64 counter type with 3 metric label.
 type Counter3[T1 labelContraint, T2 labelContraint, T3 labelContraint] struct {
 	wrapper *ocCounterWrapper
@@ -68,6 +99,17 @@ func (c *Counter3[T1, T2, T3]) IncBy(v1 T1, v2 T2, v3 T3, by int64) {
 	c.wrapper.incBy(context.Background(), labelsToStringSlice(v1, v2, v3), by)
 }
 
+// Bind returns a handle to the counter of the given label values for hot paths.
+func (c *Counter3[T1, T2, T3]) Bind(v1 T1, v2 T2, v3 T3) BoundCounter {
+	return c.wrapper.bind(labelsToStringSlice(v1, v2, v3))
+}
+
+// LastUpdated returns when the metric was last recorded with the given label values or the zero
+// time if it has not been or MetricConfig.TrackLastUpdated is not set.
+func (c *Counter3[T1, T2, T3]) LastUpdated(v1 T1, v2 T2, v3 T3) time.Time {
+	return c.wrapper.data.lastUpdated(labelsToStringSlice(v1, v2, v3))
+}
+
 // Counter4 is a incremental int64 counter type with 4 metric label.
 type Counter4[T1 labelContraint, T2 labelContraint, T3 labelContraint, T4 labelContraint] struct {
 	wrapper *ocCounterWrapper
@@ -83,15 +125,99 @@ func (c *Counter4[T1, T2, T3, T4]) IncBy(v1 T1, v2 T2, v3 T3, v4 T4, by int64) {
 	c.wrapper.incBy(context.Background(), labelsToStringSlice(v1, v2, v3, v4), by)
 }
 
+// Bind returns a handle to the counter of the given label values for hot paths.
+func (c *Counter4[T1, T2, T3, T4]) Bind(v1 T1, v2 T2, v3 T3, v4 T4) BoundCounter {
+	return c.wrapper.bind(labelsToStringSlice(v1, v2, v3, v4))
+}
+
+// LastUpdated returns when the metric was last recorded with the given label values or the zero
+// time if it has not been or MetricConfig.TrackLastUpdated is not set.
+func (c *Counter4[T1, T2, T3, T4]) LastUpdated(v1 T1, v2 T2, v3 T3, v4 T4) time.Time {
+	return c.wrapper.data.lastUpdated(labelsToStringSlice(v1, v2, v3, v4))
+}
+
+// BoundCounter is a counter with its label values resolved ahead of time by Bind, so that
+// incrementing it skips converting the labels and building their tags. Use it for hot paths that
+// increment the same label values over and over.
+//
+// Example:
+//
+//	uploaded := uploadCounter.Bind("binary")
+//	for _, f := range files {
+//		uploaded.Inc()
+//	}
+type BoundCounter struct {
+	wrapper *ocCounterWrapper
+	labels  []string
+	// ctx carries the tags of the labels; mutations is only set if they could not be built
+	// ahead of time, so that recording reports why.
+	ctx       context.Context
+	mutations []tag.Mutator
+}
+
+// Inc increments counter by 1.
+func (c BoundCounter) Inc() {
+	c.IncBy(1)
+}
+
+// IncBy increments counter by X.
+func (c BoundCounter) IncBy(by int64) {
+	if c.wrapper.data.disabled {
+		return
+	}
+	if c.wrapper.batcher != nil && c.wrapper.batcher.add(c.labels, by) {
+		return
+	}
+	c.wrapper.data.trackUpdate(c.labels)
+	c.wrapper.record(c.ctx, c.mutations, by)
+}
+
 ///// internal
 
 type ocCounterWrapper struct {
 	data    *opencensusStatsData
 	measure *stats.Int64Measure
+	// summed is set for counters aggregated as the sum of their measurements rather than their
+	// count, which lets an increment be recorded as a single measurement.
+	summed bool
+	// batcher is set for counters with a MetricConfig.BatchInterval.
+	batcher *counterBatcher
 }
 
 func (w *ocCounterWrapper) incBy(ctx context.Context, labels []string, incBy int64) {
-	mutations := w.data.labelsToMutations(labels)
+	if w.data.disabled {
+		return
+	}
+	if w.batcher != nil && w.batcher.add(labels, incBy) {
+		return
+	}
+	w.record(ctx, w.data.labelsToMutations(labels), incBy)
+}
+
+// bind resolves the label values for a BoundCounter.
+func (w *ocCounterWrapper) bind(labels []string) BoundCounter {
+	bound := BoundCounter{wrapper: w, labels: labels, ctx: context.Background()}
+	if w.data.disabled {
+		return bound
+	}
+	mutations := w.data.mutations(labels)
+	ctx, err := tag.New(bound.ctx, mutations...)
+	if err != nil {
+		bound.mutations = mutations
+		return bound
+	}
+	bound.ctx = ctx
+	return bound
+}
+
+// record records an increment with the already resolved label mutations.
+func (w *ocCounterWrapper) record(ctx context.Context, mutations []tag.Mutator, incBy int64) {
+	if w.summed {
+		if err := stats.RecordWithTags(ctx, mutations, w.measure.M(incBy)); err != nil {
+			golog.Global().Errorf("faild to write metric %s", err)
+		}
+		return
+	}
 	for i := int64(0); i < incBy; i++ {
 		if err := stats.RecordWithTags(ctx, mutations, w.measure.M(1)); err != nil {
 			golog.Global().Errorf("faild to write metric %s", err)
@@ -99,12 +225,78 @@ func (w *ocCounterWrapper) incBy(ctx context.Context, labels []string, incBy int
 	}
 }
 
-func createCounterWrapper(name string, cfg MetricConfig) *ocCounterWrapper {
+// maxZeroInitializedSeries bounds the number of series a counter initialized to zero creates.
+const maxZeroInitializedSeries = 1000
+
+// labelCombinations returns every combination of values of labels, in order, where the values of
+// each label are its Label.Values or else those of domains, the values known from its type.
+func labelCombinations(name string, labels []Label, domains [][]string) ([][]string, error) {
+	combinations := [][]string{{}}
+	for i, l := range labels {
+		values := l.Values
+		if len(values) == 0 && i < len(domains) {
+			values = domains[i]
+		}
+		if len(values) == 0 {
+			return nil, fmt.Errorf("metric %s cannot be initialized to zero, label %s does not enumerate its values", name, l.Name)
+		}
+		if len(combinations)*len(values) > maxZeroInitializedSeries {
+			return nil, fmt.Errorf("metric %s has more than %d label combinations to initialize to zero", name, maxZeroInitializedSeries)
+		}
+		next := make([][]string, 0, len(combinations)*len(values))
+		for _, c := range combinations {
+			for _, v := range values {
+				next = append(next, append(append([]string(nil), c...), v))
+			}
+		}
+		combinations = next
+	}
+	return combinations, nil
+}
+
+// createCounterWrapper creates and registers a counter. labelDomains holds the values of each
+// label known from its type, if any, for MetricConfig.InitializeToZero.
+func createCounterWrapper(name string, cfg MetricConfig, labelDomains ...[]string) *ocCounterWrapper {
+	var zeroLabels [][]string
+	if cfg.InitializeToZero {
+		combinations, err := labelCombinations(name, cfg.Labels, labelDomains)
+		if err != nil {
+			if !lenientRegistration() {
+				golog.Global().Panicf("Failed to register %s", err)
+				return nil
+			}
+			addRegistrationError(err)
+			golog.Global().Errorw("metric registration failed, it will not be recorded", "metric", name, "error", err)
+			return &ocCounterWrapper{data: &opencensusStatsData{View: &view.View{Name: name}, disabled: true}}
+		}
+		zeroLabels = combinations
+	}
+
 	measure := stats.Int64(name, cfg.Description, string(cfg.Unit))
-	ocData := createAndRegisterOpenCensusMetric(name, measure, view.Count(), cfg)
+	if cfg.BatchInterval <= 0 && !cfg.InitializeToZero {
+		ocData := createAndRegisterOpenCensusMetric(name, measure, view.Count(), cfg)
+		return &ocCounterWrapper{
+			data:    ocData,
+			measure: measure,
+		}
+	}
 
-	return &ocCounterWrapper{
+	// Batched increments are recorded as one measurement of their sum and a count aggregation
+	// cannot hold a zero, so these counters are aggregated as sums.
+	ocData := createAndRegisterOpenCensusMetric(name, measure, view.Sum(), cfg)
+	wrapper := &ocCounterWrapper{
 		data:    ocData,
 		measure: measure,
+		summed:  true,
+	}
+	if ocData.disabled {
+		return wrapper
+	}
+	for _, labels := range zeroLabels {
+		wrapper.incBy(context.Background(), labels, 0)
+	}
+	if cfg.BatchInterval > 0 {
+		wrapper.batcher = newCounterBatcher(ocData, measure, cfg.BatchInterval)
 	}
+	return wrapper
 }
@@ -1,8 +1,12 @@
 package statz
 
 import (
+	"context"
+	"sync"
 	"testing"
+	"time"
 
+	"go.opencensus.io/stats/view"
 	"go.viam.com/test"
 
 	"go.viam.com/utils/perf/statz/statztest"
@@ -53,3 +57,185 @@ func TestCounter(t *testing.T) {
 		test.That(t, recorder.Value("label", "v1", "bool", "false"), test.ShouldEqual, 1)
 	})
 }
+
+type testStatus string
+
+const (
+	testStatusOK     testStatus = "ok"
+	testStatusFailed testStatus = "failed"
+)
+
+type testCode int64
+
+type testFlag bool
+
+func TestEnumLabels(t *testing.T) {
+	counter := NewCounter3[testStatus, testCode, testFlag]("statz/test/enum_counter", MetricConfig{
+		Description: "The number of requests",
+		Unit:        units.Dimensionless,
+		Labels: []Label{
+			{Name: "status", Description: "The status."},
+			{Name: "code", Description: "The code."},
+			{Name: "flag", Description: "The flag."},
+		},
+	})
+	distribution := NewDistribution1[testStatus]("statz/test/enum_distribution", MetricConfig{
+		Description: "The latency of requests",
+		Unit:        units.Milliseconds,
+		Labels: []Label{
+			{Name: "status", Description: "The status."},
+		},
+	}, DistributionFromBounds(0, 10, 50))
+
+	counterRecorder := statztest.NewCounterRecorder("statz/test/enum_counter")
+	distributionRecorder := statztest.NewDistributionRecorder("statz/test/enum_distribution")
+
+	counter.Inc(testStatusOK, testCode(200), testFlag(true))
+	counter.Inc(testStatusFailed, testCode(500), testFlag(false))
+	distribution.Observe(5, testStatusOK)
+
+	test.That(t, counterRecorder.Value("status", "ok", "code", "200", "flag", "true"), test.ShouldEqual, 1)
+	test.That(t, counterRecorder.Value("status", "failed", "code", "500", "flag", "false"), test.ShouldEqual, 1)
+	test.That(t, distributionRecorder.Value("status", "ok").Sum, test.ShouldEqual, 5)
+}
+
+func TestBatchedCounter(t *testing.T) {
+	counter := NewCounter1[string]("statz/test/batched_counter", MetricConfig{
+		Description: "A hot counter",
+		Unit:        units.Dimensionless,
+		Labels: []Label{
+			{Name: "label", Description: "A label."},
+		},
+		BatchInterval: time.Hour,
+	})
+	recorder := statztest.NewCounterRecorder("statz/test/batched_counter")
+
+	var wg sync.WaitGroup
+	for i := 0; i < 10; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for j := 0; j < 100; j++ {
+				counter.Inc("a")
+			}
+			counter.IncBy("b", 5)
+		}()
+	}
+	wg.Wait()
+
+	// nothing is recorded until a flush.
+	test.That(t, recorder.Value("label", "a"), test.ShouldEqual, 0)
+
+	FlushBatchedCounters()
+	test.That(t, recorder.Value("label", "a"), test.ShouldEqual, 1000)
+	test.That(t, recorder.Value("label", "b"), test.ShouldEqual, 50)
+	test.That(t, MetricCardinality("statz/test/batched_counter"), test.ShouldEqual, 2)
+
+	counter.Inc("a")
+	FlushBatchedCounters()
+	FlushBatchedCounters()
+	test.That(t, recorder.Value("label", "a"), test.ShouldEqual, 1001)
+
+	// stopping records pending increments and later ones are recorded immediately.
+	counter.Inc("a")
+	StopBatchedCounters()
+	test.That(t, recorder.Value("label", "a"), test.ShouldEqual, 1002)
+	counter.Inc("a")
+	test.That(t, recorder.Value("label", "a"), test.ShouldEqual, 1003)
+}
+
+func TestBoundCounter(t *testing.T) {
+	counter := NewCounter2[string, bool]("statz/test/bound_counter", MetricConfig{
+		Description: "A bound counter",
+		Unit:        units.Dimensionless,
+		Labels: []Label{
+			{Name: "label", Description: "A label."},
+			{Name: "bool", Description: "Other label"},
+		},
+	})
+	recorder := statztest.NewCounterRecorder("statz/test/bound_counter")
+
+	bound := counter.Bind("a", true)
+	for i := 0; i < 10; i++ {
+		bound.Inc()
+	}
+	bound.IncBy(5)
+	counter.Inc("a", true)
+
+	test.That(t, recorder.Value("label", "a", "bool", "true"), test.ShouldEqual, 16)
+	test.That(t, recorder.Value("label", "a", "bool", "false"), test.ShouldEqual, 0)
+	test.That(t, MetricCardinality("statz/test/bound_counter"), test.ShouldEqual, 1)
+
+	batched := NewCounter1[string]("statz/test/bound_batched_counter", MetricConfig{
+		Description: "A bound batched counter",
+		Unit:        units.Dimensionless,
+		Labels: []Label{
+			{Name: "label", Description: "A label."},
+		},
+		BatchInterval: time.Hour,
+	})
+	batchedRecorder := statztest.NewCounterRecorder("statz/test/bound_batched_counter")
+	batchedBound := batched.Bind("a")
+	batchedBound.Inc()
+	batchedBound.IncBy(2)
+	FlushBatchedCounters()
+	test.That(t, batchedRecorder.Value("label", "a"), test.ShouldEqual, 3)
+}
+
+func TestMethodContext(t *testing.T) {
+	ctx := context.Background()
+	_, ok := MethodFromContext(ctx)
+	test.That(t, ok, test.ShouldBeFalse)
+	test.That(t, MethodLabel(ctx), test.ShouldEqual, MethodUnknownLabel)
+
+	ctx = ContextWithMethod(ctx, "/some.Service/Method")
+	method, ok := MethodFromContext(ctx)
+	test.That(t, ok, test.ShouldBeTrue)
+	test.That(t, method, test.ShouldEqual, "/some.Service/Method")
+
+	counter := NewCounter1[string]("statz/test/method_counter", MetricConfig{
+		Description: "The number of requests",
+		Unit:        units.Dimensionless,
+		Labels: []Label{
+			{Name: "method", Description: "The full gRPC method name."},
+		},
+	})
+	recorder := statztest.NewCounterRecorder("statz/test/method_counter")
+	counter.Inc(MethodLabel(ctx))
+	test.That(t, recorder.Value("method", "/some.Service/Method"), test.ShouldEqual, 1)
+}
+
+func TestCounterInitializeToZero(t *testing.T) {
+	counter := NewCounter2[string, bool]("statz/test/zero_counter", MetricConfig{
+		Description: "The number of requests",
+		Unit:        units.Dimensionless,
+		Labels: []Label{
+			{Name: "type", Description: "The data type.", Values: []string{"file", "binary"}},
+			{Name: "success", Description: "If the upload was successful."},
+		},
+		InitializeToZero: true,
+	})
+	recorder := statztest.NewCounterRecorder("statz/test/zero_counter")
+
+	rows, err := view.RetrieveData("statz/test/zero_counter")
+	test.That(t, err, test.ShouldBeNil)
+	test.That(t, rows, test.ShouldHaveLength, 4)
+	for _, row := range rows {
+		test.That(t, row.Data.(*view.SumData).Value, test.ShouldEqual, 0)
+	}
+
+	counter.Inc("file", true)
+	counter.IncBy("binary", false, 3)
+	test.That(t, recorder.Value("type", "file", "success", "true"), test.ShouldEqual, 1)
+	test.That(t, recorder.Value("type", "binary", "success", "false"), test.ShouldEqual, 3)
+	test.That(t, recorder.Value("type", "file", "success", "false"), test.ShouldEqual, 0)
+
+	test.That(t, func() {
+		NewCounter1[string]("statz/test/zero_counter_unenumerable", MetricConfig{
+			Description:      "The number of requests",
+			Unit:             units.Dimensionless,
+			Labels:           []Label{{Name: "type", Description: "The data type."}},
+			InitializeToZero: true,
+		})
+	}, test.ShouldPanic)
+}
@@ -0,0 +1,121 @@
+package statz
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+
+	"go.opencensus.io/stats/view"
+)
+
+// DeltaRow is the change of one label combination of a metric since the previous snapshot.
+type DeltaRow struct {
+	Labels map[string]string
+	// Count is the increase of a counter or the number of observations of a distribution.
+	Count int64
+	// Sum and BucketCounts are only set for distributions.
+	Sum          float64
+	BucketCounts []int64
+}
+
+type deltaSnapshot struct {
+	count        int64
+	sum          float64
+	bucketCounts []int64
+}
+
+// DeltaSnapshotter reports per interval deltas of cumulative metrics for systems that expect
+// them, by remembering the values of the previous snapshot. Each snapshotter keeps its own
+// state so multiple reporters do not interfere with each other.
+//
+// Usage:
+//
+//	snapshotter := statz.NewDeltaSnapshotter()
+//	for range ticker.C {
+//		rows, err := snapshotter.Delta("datasync/uploaded")
+//		...
+//	}
+type DeltaSnapshotter struct {
+	mu       sync.Mutex
+	previous map[string]deltaSnapshot
+}
+
+// NewDeltaSnapshotter returns a new DeltaSnapshotter whose first snapshot is relative to zero.
+func NewDeltaSnapshotter() *DeltaSnapshotter {
+	return &DeltaSnapshotter{previous: map[string]deltaSnapshot{}}
+}
+
+// Delta returns the change of every label combination of the metric since the previous call
+// for it. Label combinations that did not change are omitted.
+func (s *DeltaSnapshotter) Delta(metricName string) ([]DeltaRow, error) {
+	rows, err := view.RetrieveData(metricName)
+	if err != nil {
+		return nil, fmt.Errorf("failed to retrieve metric %s: %w", metricName, err)
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	type keyedRow struct {
+		key string
+		row DeltaRow
+	}
+	var deltas []keyedRow
+	for _, row := range rows {
+		labels := make(map[string]string, len(row.Tags))
+		keyParts := make([]string, 0, len(row.Tags))
+		for _, t := range row.Tags {
+			labels[t.Key.Name()] = t.Value
+			keyParts = append(keyParts, t.Key.Name()+"="+t.Value)
+		}
+		sort.Strings(keyParts)
+		key := metricName + "{" + strings.Join(keyParts, ",") + "}"
+
+		var current deltaSnapshot
+		switch data := row.Data.(type) {
+		case *view.CountData:
+			current.count = data.Value
+		case *view.SumData:
+			// Batched counters are aggregated as sums.
+			current.count = int64(data.Value)
+		case *view.DistributionData:
+			current.count = data.Count
+			current.sum = data.Sum()
+			current.bucketCounts = append([]int64(nil), data.CountPerBucket...)
+		default:
+			return nil, fmt.Errorf("metric %s has unsupported aggregation %T", metricName, row.Data)
+		}
+
+		prev := s.previous[key]
+		s.previous[key] = current
+		if current.count == prev.count {
+			continue
+		}
+
+		delta := DeltaRow{
+			Labels: labels,
+			Count:  current.count - prev.count,
+			Sum:    current.sum - prev.sum,
+		}
+		if current.bucketCounts != nil {
+			delta.BucketCounts = make([]int64, len(current.bucketCounts))
+			for i, c := range current.bucketCounts {
+				if i < len(prev.bucketCounts) {
+					c -= prev.bucketCounts[i]
+				}
+				delta.BucketCounts[i] = c
+			}
+		}
+		deltas = append(deltas, keyedRow{key, delta})
+	}
+
+	sort.Slice(deltas, func(i, j int) bool {
+		return deltas[i].key < deltas[j].key
+	})
+	result := make([]DeltaRow, 0, len(deltas))
+	for _, d := range deltas {
+		result = append(result, d.row)
+	}
+	return result, nil
+}
@@ -0,0 +1,83 @@
+package statz
+
+import (
+	"testing"
+
+	"go.viam.com/test"
+
+	"go.viam.com/utils/perf/statz/units"
+)
+
+func TestDeltaSnapshotter(t *testing.T) {
+	counter := NewCounter1[string]("statz/test/delta_counter", MetricConfig{
+		Description: "The number of requests",
+		Unit:        units.Dimensionless,
+		Labels: []Label{
+			{Name: "label", Description: "The data type (file|binary|tabular)."},
+		},
+	})
+	distribution := NewDistribution0("statz/test/delta_distribution", MetricConfig{
+		Description: "The latency of the upload",
+		Unit:        units.Milliseconds,
+	}, DistributionFromBounds(0, 10, 50))
+
+	t.Run("counter", func(t *testing.T) {
+		snapshotter := NewDeltaSnapshotter()
+
+		counter.IncBy("label1", 2)
+		counter.Inc("label2")
+		rows, err := snapshotter.Delta("statz/test/delta_counter")
+		test.That(t, err, test.ShouldBeNil)
+		test.That(t, rows, test.ShouldResemble, []DeltaRow{
+			{Labels: map[string]string{"label": "label1"}, Count: 2},
+			{Labels: map[string]string{"label": "label2"}, Count: 1},
+		})
+
+		counter.Inc("label1")
+		rows, err = snapshotter.Delta("statz/test/delta_counter")
+		test.That(t, err, test.ShouldBeNil)
+		test.That(t, rows, test.ShouldResemble, []DeltaRow{
+			{Labels: map[string]string{"label": "label1"}, Count: 1},
+		})
+
+		rows, err = snapshotter.Delta("statz/test/delta_counter")
+		test.That(t, err, test.ShouldBeNil)
+		test.That(t, rows, test.ShouldBeEmpty)
+
+		// a new snapshotter starts from zero.
+		rows, err = NewDeltaSnapshotter().Delta("statz/test/delta_counter")
+		test.That(t, err, test.ShouldBeNil)
+		test.That(t, rows, test.ShouldHaveLength, 2)
+		test.That(t, rows[0].Count, test.ShouldEqual, 3)
+	})
+
+	t.Run("distribution", func(t *testing.T) {
+		snapshotter := NewDeltaSnapshotter()
+
+		distribution.Observe(5)
+		distribution.Observe(100)
+		rows, err := snapshotter.Delta("statz/test/delta_distribution")
+		test.That(t, err, test.ShouldBeNil)
+		test.That(t, rows, test.ShouldHaveLength, 1)
+		test.That(t, rows[0].Count, test.ShouldEqual, 2)
+		test.That(t, rows[0].Sum, test.ShouldAlmostEqual, 105)
+
+		distribution.Observe(20)
+		rows, err = snapshotter.Delta("statz/test/delta_distribution")
+		test.That(t, err, test.ShouldBeNil)
+		test.That(t, rows, test.ShouldHaveLength, 1)
+		test.That(t, rows[0].Count, test.ShouldEqual, 1)
+		test.That(t, rows[0].Sum, test.ShouldAlmostEqual, 20)
+		var bucketTotal int64
+		for _, c := range rows[0].BucketCounts {
+			test.That(t, c, test.ShouldBeBetweenOrEqual, 0, 1)
+			bucketTotal += c
+		}
+		test.That(t, bucketTotal, test.ShouldEqual, 1)
+	})
+
+	t.Run("unknown metric", func(t *testing.T) {
+		_, err := NewDeltaSnapshotter().Delta("statz/test/delta_unknown")
+		test.That(t, err, test.ShouldNotBeNil)
+	})
+}
@@ -2,10 +2,15 @@ package statz
 
 import (
 	"context"
+	"fmt"
+	"math"
+	"time"
 
 	"github.com/edaniels/golog"
 	"go.opencensus.io/stats"
 	"go.opencensus.io/stats/view"
+
+	"go.viam.com/utils/perf/statz/units"
 )
 
 // Distribution contains hisogram buckets for metric of distribution type.
@@ -16,7 +21,47 @@ type Distribution struct {
 // LatencyDistribution is a basic latency distribution.
 var LatencyDistribution = DistributionFromBounds(0, 5, 25, 50, 75, 100, 200, 400, 600, 800, 1000, 2000, 4000, 6000)
 
+// DefaultBucketsForUnit returns a distribution with sensible buckets for the unit: latencies
+// for time units and sizes for bytes and bits. Units without specific defaults get buckets for
+// counts. Distributions created with an empty Distribution use the defaults of their MetricConfig.Unit.
+func DefaultBucketsForUnit(u units.Unit) Distribution {
+	switch u {
+	case units.Milliseconds:
+		return LatencyDistribution
+	case units.Nanoseconds:
+		return DistributionFromBounds(0, 100, 250, 500, 1000, 2500, 5000, 10000, 25000, 50000, 100000, 250000, 500000, 1000000, 10000000)
+	case units.Microseconds:
+		return DistributionFromBounds(0, 50, 100, 250, 500, 1000, 2500, 5000, 10000, 25000, 50000, 100000, 250000, 500000, 1000000)
+	case units.Second:
+		return DistributionFromBounds(0, .005, .01, .025, .05, .1, .25, .5, 1, 2.5, 5, 10, 30, 60)
+	case units.Minute:
+		return DistributionFromBounds(0, .5, 1, 2, 5, 10, 15, 30, 60)
+	case units.Hour:
+		return DistributionFromBounds(0, .25, .5, 1, 2, 4, 8, 12, 24)
+	case units.Day:
+		return DistributionFromBounds(0, 1, 2, 3, 7, 14, 30, 90)
+	case units.Bytes:
+		return DistributionFromBounds(sizeBounds(1)...)
+	case units.Bit:
+		return DistributionFromBounds(sizeBounds(8)...)
+	case units.Dimensionless:
+		fallthrough
+	default:
+		return DistributionFromBounds(0, 1, 2, 5, 10, 20, 50, 100, 200, 500, 1000, 2000, 5000, 10000)
+	}
+}
+
+// sizeBounds returns bounds from 64B to 1GiB in powers of 4, scaled by scale.
+func sizeBounds(scale float64) []float64 {
+	bounds := []float64{0}
+	for b := float64(64); b <= 1<<30; b *= 4 {
+		bounds = append(bounds, b*scale)
+	}
+	return bounds
+}
+
 // DistributionFromBounds create distribution from a list of bounds. Must be incrementing and non-overlapping.
+// Metrics with bounds that are not finite and strictly increasing fail to register.
 func DistributionFromBounds(bounds ...float64) Distribution {
 	return Distribution{
 		buckets: bounds,
@@ -33,6 +78,28 @@ func (c *Distribution0) Observe(v float64) {
 	c.wrapper.observe(context.Background(), labelsToStringSlice(), v)
 }
 
+// ObserveN records count observations of v, such as when merging pre-aggregated data.
+func (c *Distribution0) ObserveN(v float64, count int) {
+	c.wrapper.observeN(context.Background(), labelsToStringSlice(), v, count)
+}
+
+// ObserveIfAbove records an observation of the metric only if v is at least threshold. This is
+// meant for tail focused metrics whose fast path is too frequent to record; the distribution
+// intentionally under counts its buckets below threshold and its count and sum only cover the
+// recorded observations.
+func (c *Distribution0) ObserveIfAbove(v, threshold float64) {
+	if v < threshold {
+		return
+	}
+	c.Observe(v)
+}
+
+// LastUpdated returns when the metric was last recorded with the given label values or the zero
+// time if it has not been or MetricConfig.TrackLastUpdated is not set.
+func (c *Distribution0) LastUpdated() time.Time {
+	return c.wrapper.data.lastUpdated(labelsToStringSlice())
+}
+
 // Distribution1 is a float64 histogram metic. Good for latencies.
 type Distribution1[T1 labelContraint] struct {
 	wrapper *ocDistributionWrapper
@@ -43,6 +110,26 @@ func (c *Distribution1[T1]) Observe(v float64, l1 T1) {
 	c.wrapper.observe(context.Background(), labelsToStringSlice(l1), v)
 }
 
+// ObserveN records count observations of v, such as when merging pre-aggregated data.
+func (c *Distribution1[T1]) ObserveN(v float64, count int, l1 T1) {
+	c.wrapper.observeN(context.Background(), labelsToStringSlice(l1), v, count)
+}
+
+// ObserveIfAbove records an observation of the metric only if v is at least threshold. See
+// Distribution0.ObserveIfAbove for how this distorts the distribution.
+func (c *Distribution1[T1]) ObserveIfAbove(v, threshold float64, l1 T1) {
+	if v < threshold {
+		return
+	}
+	c.Observe(v, l1)
+}
+
+// LastUpdated returns when the metric was last recorded with the given label values or the zero
+// time if it has not been or MetricConfig.TrackLastUpdated is not set.
+func (c *Distribution1[T1]) LastUpdated(l1 T1) time.Time {
+	return c.wrapper.data.lastUpdated(labelsToStringSlice(l1))
+}
+
 // Distribution2 is a float64 histogram metic. Good for latencies.
 type Distribution2[T1 labelContraint, T2 labelContraint] struct {
 	wrapper *ocDistributionWrapper
@@ -53,6 +140,26 @@ func (c *Distribution2[T1, T2]) Observe(v float64, l1 T1, l2 T2) {
 	c.wrapper.observe(context.Background(), labelsToStringSlice(l1, l2), v)
 }
 
+// ObserveN records count observations of v, such as when merging pre-aggregated data.
+func (c *Distribution2[T1, T2]) ObserveN(v float64, count int, l1 T1, l2 T2) {
+	c.wrapper.observeN(context.Background(), labelsToStringSlice(l1, l2), v, count)
+}
+
+// ObserveIfAbove records an observation of the metric only if v is at least threshold. See
+// Distribution0.ObserveIfAbove for how this distorts the distribution.
+func (c *Distribution2[T1, T2]) ObserveIfAbove(v, threshold float64, l1 T1, l2 T2) {
+	if v < threshold {
+		return
+	}
+	c.Observe(v, l1, l2)
+}
+
+// LastUpdated returns when the metric was last recorded with the given label values or the zero
+// time if it has not been or MetricConfig.TrackLastUpdated is not set.
+func (c *Distribution2[T1, T2]) LastUpdated(l1 T1, l2 T2) time.Time {
+	return c.wrapper.data.lastUpdated(labelsToStringSlice(l1, l2))
+}
+
 // Distribution3 is a float64 histogram metic. Good for latencies.
 type Distribution3[T1 labelContraint, T2 labelContraint, T3 labelContraint] struct {
 	wrapper *ocDistributionWrapper
@@ -63,6 +170,26 @@ func (c *Distribution3[T1, T2, T3]) Observe(v float64, l1 T1, l2 T2, l3 T3) {
 	c.wrapper.observe(context.Background(), labelsToStringSlice(l1, l2, l3), v)
 }
 
+// ObserveN records count observations of v, such as when merging pre-aggregated data.
+func (c *Distribution3[T1, T2, T3]) ObserveN(v float64, count int, l1 T1, l2 T2, l3 T3) {
+	c.wrapper.observeN(context.Background(), labelsToStringSlice(l1, l2, l3), v, count)
+}
+
+// ObserveIfAbove records an observation of the metric only if v is at least threshold. See
+// Distribution0.ObserveIfAbove for how this distorts the distribution.
+func (c *Distribution3[T1, T2, T3]) ObserveIfAbove(v, threshold float64, l1 T1, l2 T2, l3 T3) {
+	if v < threshold {
+		return
+	}
+	c.Observe(v, l1, l2, l3)
+}
+
+// LastUpdated returns when the metric was last recorded with the given label values or the zero
+// time if it has not been or MetricConfig.TrackLastUpdated is not set.
+func (c *Distribution3[T1, T2, T3]) LastUpdated(l1 T1, l2 T2, l3 T3) time.Time {
+	return c.wrapper.data.lastUpdated(labelsToStringSlice(l1, l2, l3))
+}
+
 // Distribution4 is a float64 histogram metic. Good for latencies.
 type Distribution4[T1 labelContraint, T2 labelContraint, T3 labelContraint, T4 labelContraint] struct {
 	wrapper *ocDistributionWrapper
@@ -73,6 +200,106 @@ func (c *Distribution4[T1, T2, T3, T4]) Observe(v float64, l1 T1, l2 T2, l3 T3,
 	c.wrapper.observe(context.Background(), labelsToStringSlice(l1, l2, l3, l4), v)
 }
 
+// ObserveN records count observations of v, such as when merging pre-aggregated data.
+func (c *Distribution4[T1, T2, T3, T4]) ObserveN(v float64, count int, l1 T1, l2 T2, l3 T3, l4 T4) {
+	c.wrapper.observeN(context.Background(), labelsToStringSlice(l1, l2, l3, l4), v, count)
+}
+
+// ObserveIfAbove records an observation of the metric only if v is at least threshold. See
+// Distribution0.ObserveIfAbove for how this distorts the distribution.
+func (c *Distribution4[T1, T2, T3, T4]) ObserveIfAbove(v, threshold float64, l1 T1, l2 T2, l3 T3, l4 T4) {
+	if v < threshold {
+		return
+	}
+	c.Observe(v, l1, l2, l3, l4)
+}
+
+// LastUpdated returns when the metric was last recorded with the given label values or the zero
+// time if it has not been or MetricConfig.TrackLastUpdated is not set.
+func (c *Distribution4[T1, T2, T3, T4]) LastUpdated(l1 T1, l2 T2, l3 T3, l4 T4) time.Time {
+	return c.wrapper.data.lastUpdated(labelsToStringSlice(l1, l2, l3, l4))
+}
+
+// Number is any integer or floating point type that can be observed by a distribution.
+type Number interface {
+	~int | ~int8 | ~int16 | ~int32 | ~int64 |
+		~uint | ~uint8 | ~uint16 | ~uint32 | ~uint64 | ~uintptr |
+		~float32 | ~float64
+}
+
+// ObserveValue0 records an observation of v, converted to a float64, to d. Since methods cannot
+// have type parameters it is a function, so that integer values need no conversion at the call
+// site. Integers beyond 2^53 lose precision as they do when converted explicitly.
+func ObserveValue0[N Number](d *Distribution0, v N) {
+	d.Observe(float64(v))
+}
+
+// ObserveValue1 is ObserveValue0 for a Distribution1.
+func ObserveValue1[N Number, T1 labelContraint](d *Distribution1[T1], v N, l1 T1) {
+	d.Observe(float64(v), l1)
+}
+
+// ObserveValue2 is ObserveValue0 for a Distribution2.
+func ObserveValue2[N Number, T1 labelContraint, T2 labelContraint](d *Distribution2[T1, T2], v N, l1 T1, l2 T2) {
+	d.Observe(float64(v), l1, l2)
+}
+
+// ObserveValue3 is ObserveValue0 for a Distribution3.
+func ObserveValue3[N Number, T1 labelContraint, T2 labelContraint, T3 labelContraint](
+	d *Distribution3[T1, T2, T3], v N, l1 T1, l2 T2, l3 T3,
+) {
+	d.Observe(float64(v), l1, l2, l3)
+}
+
+// ObserveValue4 is ObserveValue0 for a Distribution4.
+func ObserveValue4[N Number, T1 labelContraint, T2 labelContraint, T3 labelContraint, T4 labelContraint](
+	d *Distribution4[T1, T2, T3, T4], v N, l1 T1, l2 T2, l3 T3, l4 T4,
+) {
+	d.Observe(float64(v), l1, l2, l3, l4)
+}
+
+// DistributionN is a float64 histogram metic with labels only known at runtime. Good for
+// metrics whose labels are built programmatically.
+type DistributionN struct {
+	wrapper *ocDistributionWrapper
+}
+
+// Observe records an observation of the metric. All labels declared in the MetricConfig must
+// be present in labelValues and no others; otherwise nothing is recorded and an error is returned.
+func (c *DistributionN) Observe(v float64, labelValues map[string]string) error {
+	if c.wrapper.data.disabled {
+		return nil
+	}
+	labels, err := c.wrapper.data.labelsFromMap(labelValues)
+	if err != nil {
+		return err
+	}
+	c.wrapper.observe(context.Background(), labels, v)
+	return nil
+}
+
+// ObserveValues is Observe with label values of any type, which are encoded with the Label.Encode
+// of their label or else fmt.Sprint.
+func (c *DistributionN) ObserveValues(v float64, labelValues map[string]interface{}) error {
+	if c.wrapper.data.disabled {
+		return nil
+	}
+	return c.Observe(v, c.wrapper.data.encodeLabelValues(labelValues))
+}
+
+// ObserveN records count observations of v with the same label requirements as Observe.
+func (c *DistributionN) ObserveN(v float64, count int, labelValues map[string]string) error {
+	if c.wrapper.data.disabled {
+		return nil
+	}
+	labels, err := c.wrapper.data.labelsFromMap(labelValues)
+	if err != nil {
+		return err
+	}
+	c.wrapper.observeN(context.Background(), labels, v, count)
+	return nil
+}
+
 ///// internal
 
 type ocDistributionWrapper struct {
@@ -81,13 +308,58 @@ type ocDistributionWrapper struct {
 }
 
 func (w *ocDistributionWrapper) observe(ctx context.Context, labels []string, value float64) {
+	if w.data.disabled {
+		return
+	}
 	mutations := w.data.labelsToMutations(labels)
 	if err := stats.RecordWithTags(ctx, mutations, w.measure.M(value)); err != nil {
 		golog.Global().Errorf("faild to write metric %s", err)
 	}
 }
 
+// maxMeasurementsPerRecord bounds the measurements passed to one stats.Record call by observeN.
+const maxMeasurementsPerRecord = 1024
+
+// observeN records count observations of value. OpenCensus has no weighted measurements so
+// each observation is still recorded, but many at a time to avoid the per call overhead.
+func (w *ocDistributionWrapper) observeN(ctx context.Context, labels []string, value float64, count int) {
+	if w.data.disabled || count <= 0 {
+		return
+	}
+	mutations := w.data.labelsToMutations(labels)
+	batchSize := count
+	if batchSize > maxMeasurementsPerRecord {
+		batchSize = maxMeasurementsPerRecord
+	}
+	measurements := make([]stats.Measurement, batchSize)
+	for i := range measurements {
+		measurements[i] = w.measure.M(value)
+	}
+	for remaining := count; remaining > 0; remaining -= batchSize {
+		n := batchSize
+		if remaining < n {
+			n = remaining
+		}
+		if err := stats.RecordWithTags(ctx, mutations, measurements[:n]...); err != nil {
+			golog.Global().Errorf("faild to write metric %s", err)
+			return
+		}
+	}
+}
+
 func createocDistributionWrapper(name string, distributions Distribution, cfg MetricConfig) *ocDistributionWrapper {
+	if len(distributions.buckets) == 0 {
+		distributions = DefaultBucketsForUnit(cfg.Unit)
+	}
+	if err := validateDistributionBounds(name, distributions.buckets); err != nil {
+		if !lenientRegistration() {
+			golog.Global().Panicf("Failed to register %s", err)
+			return nil
+		}
+		addRegistrationError(err)
+		golog.Global().Errorw("metric registration failed, it will not be recorded", "metric", name, "error", err)
+		return &ocDistributionWrapper{data: &opencensusStatsData{View: &view.View{Name: name}, disabled: true}}
+	}
 	measure := stats.Float64(name, cfg.Description, string(cfg.Unit))
 	ocData := createAndRegisterOpenCensusMetric(name, measure, view.Distribution(distributions.buckets...), cfg)
 
@@ -96,3 +368,18 @@ func createocDistributionWrapper(name string, distributions Distribution, cfg Me
 		measure: measure,
 	}
 }
+
+// validateDistributionBounds ensures the bucket bounds are finite and strictly increasing. OpenCensus
+// does not reject unsorted or duplicate bounds, which silently produces broken histograms.
+func validateDistributionBounds(name string, bounds []float64) error {
+	for i, b := range bounds {
+		if math.IsNaN(b) || math.IsInf(b, 0) {
+			return fmt.Errorf("metric %s has invalid distribution bound %v at index %d; bounds must be finite", name, b, i)
+		}
+		if i > 0 && b <= bounds[i-1] {
+			return fmt.Errorf("metric %s has invalid distribution bound %v at index %d; bounds must be strictly increasing but it follows %v",
+				name, b, i, bounds[i-1])
+		}
+	}
+	return nil
+}
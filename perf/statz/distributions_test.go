@@ -1,7 +1,10 @@
 package statz
 
 import (
+	"fmt"
+	"math"
 	"testing"
+	"time"
 
 	"go.viam.com/test"
 
@@ -35,3 +38,254 @@ func TestDistribution(t *testing.T) {
 		test.That(t, recorder.Value("label", "label2").Buckets[2].Count, test.ShouldEqual, 1)
 	})
 }
+
+func TestDistributionN(t *testing.T) {
+	distributionN := NewDistributionN("statz/test/distributionN", MetricConfig{
+		Description: "The latency of the upload",
+		Unit:        units.Milliseconds,
+		Labels: []Label{
+			{Name: "label", Description: "The data type (file|binary|tabular)."},
+			{Name: "other", Description: "Other label"},
+		},
+	}, DistributionFromBounds(0, 10, 50))
+
+	recorder := statztest.NewDistributionRecorder("statz/test/distributionN")
+
+	err := distributionN.Observe(5, map[string]string{"label": "v1", "other": "v2"})
+	test.That(t, err, test.ShouldBeNil)
+	test.That(t, recorder.Value("label", "v1", "other", "v2").Sum, test.ShouldEqual, 5)
+
+	err = distributionN.Observe(5, map[string]string{"label": "v1"})
+	test.That(t, err, test.ShouldNotBeNil)
+	test.That(t, err.Error(), test.ShouldContainSubstring, "missing label values for: other")
+
+	err = distributionN.Observe(5, map[string]string{"label": "v1", "other": "v2", "extra": "v3"})
+	test.That(t, err, test.ShouldNotBeNil)
+	test.That(t, err.Error(), test.ShouldContainSubstring, "undeclared labels: extra")
+
+	test.That(t, recorder.Value("label", "v1", "other", "v2").Count, test.ShouldEqual, 1)
+}
+
+func TestDistributionNObserveValues(t *testing.T) {
+	type version struct {
+		major, minor int
+	}
+	distributionN := NewDistributionN("statz/test/distributionN_values", MetricConfig{
+		Description: "The latency of the upload",
+		Unit:        units.Milliseconds,
+		Labels: []Label{
+			{Name: "version", Description: "The client version.", Encode: func(v interface{}) string {
+				ver := v.(version)
+				return fmt.Sprintf("v%d.%d", ver.major, ver.minor)
+			}},
+			{Name: "retries", Description: "The number of retries."},
+		},
+	}, DistributionFromBounds(0, 10, 50))
+
+	recorder := statztest.NewDistributionRecorder("statz/test/distributionN_values")
+
+	err := distributionN.ObserveValues(5, map[string]interface{}{"version": version{1, 2}, "retries": 3})
+	test.That(t, err, test.ShouldBeNil)
+	test.That(t, recorder.Value("version", "v1.2", "retries", "3").Sum, test.ShouldEqual, 5)
+
+	err = distributionN.ObserveValues(5, map[string]interface{}{"version": version{1, 2}})
+	test.That(t, err, test.ShouldNotBeNil)
+	test.That(t, err.Error(), test.ShouldContainSubstring, "missing label values for: retries")
+}
+
+func TestUnitDistributions(t *testing.T) {
+	bytesDistribution := NewBytesDistribution1[string]("statz/test/bytes_distribution", MetricConfig{
+		Description: "The size of the upload",
+		Unit:        units.Bytes,
+		Labels: []Label{
+			{Name: "label", Description: "The data type (file|binary|tabular)."},
+		},
+	}, DistributionFromBounds(0, 1024, 4096))
+	msDistribution := NewMillisecondsDistribution0("statz/test/milliseconds_distribution", MetricConfig{
+		Description: "The latency of the upload",
+		Unit:        units.Milliseconds,
+	}, DistributionFromBounds(0, 10, 50))
+
+	bytesRecorder := statztest.NewDistributionRecorder("statz/test/bytes_distribution")
+	msRecorder := statztest.NewDistributionRecorder("statz/test/milliseconds_distribution")
+
+	bytesDistribution.ObserveBytes(2048, "file")
+	msDistribution.ObserveMilliseconds(1500 * time.Microsecond)
+	msDistribution.ObserveMilliseconds(2 * time.Second)
+
+	test.That(t, bytesRecorder.Value("label", "file").Sum, test.ShouldEqual, 2048)
+	test.That(t, msRecorder.Value().Sum, test.ShouldEqual, 2001.5)
+	test.That(t, msRecorder.Value().Count, test.ShouldEqual, 2)
+
+	nsDistribution := NewNanosecondsDistribution1[string]("statz/test/nanoseconds_distribution", MetricConfig{
+		Description: "The latency of the lookup",
+		Unit:        units.Nanoseconds,
+		Labels: []Label{
+			{Name: "label", Description: "The cache (hit|miss)."},
+		},
+	}, Distribution{})
+	nsRecorder := statztest.NewDistributionRecorder("statz/test/nanoseconds_distribution")
+	nsDistribution.ObserveDuration(150*time.Nanosecond, "hit")
+	nsDistribution.ObserveDuration(3*time.Microsecond, "hit")
+	test.That(t, nsRecorder.Value("label", "hit").Sum, test.ShouldEqual, 3150)
+	test.That(t, nsRecorder.Value("label", "hit").Count, test.ShouldEqual, 2)
+	unit, ok := MetricUnit("statz/test/nanoseconds_distribution")
+	test.That(t, ok, test.ShouldBeTrue)
+	test.That(t, unit, test.ShouldEqual, units.Nanoseconds)
+	_, ok = MetricUnit("statz/test/not_registered")
+	test.That(t, ok, test.ShouldBeFalse)
+
+	mismatchedCfg := MetricConfig{
+		Description: "The size of the upload",
+		Unit:        units.Milliseconds,
+	}
+	test.That(t, func() {
+		NewBytesDistribution0("statz/test/bytes_distribution_mismatch", mismatchedCfg, DistributionFromBounds(0, 1024))
+	}, test.ShouldPanic)
+
+	SetLenientRegistration(true)
+	defer SetLenientRegistration(false)
+	mismatched := NewBytesDistribution0("statz/test/bytes_distribution_lenient", mismatchedCfg, DistributionFromBounds(0, 1024))
+	mismatched.ObserveBytes(10)
+	err := RegistrationErrors()
+	test.That(t, err, test.ShouldNotBeNil)
+	test.That(t, err.Error(), test.ShouldContainSubstring,
+		`statz/test/bytes_distribution_lenient is declared in unit "ms" but recorded in unit "By"`)
+	test.That(t, RegisteredMetricNames(), test.ShouldNotContain, "statz/test/bytes_distribution_lenient")
+}
+
+func TestDefaultBucketsForUnit(t *testing.T) {
+	test.That(t, DefaultBucketsForUnit(units.Milliseconds), test.ShouldResemble, LatencyDistribution)
+	bytesBuckets := DefaultBucketsForUnit(units.Bytes).buckets
+	test.That(t, bytesBuckets[1], test.ShouldEqual, 64)
+	test.That(t, bytesBuckets[len(bytesBuckets)-1], test.ShouldEqual, 1<<30)
+	test.That(t, DefaultBucketsForUnit(units.Bit).buckets[1], test.ShouldEqual, 512)
+	for _, u := range []units.Unit{
+		units.Dimensionless, units.Bytes, units.Bit, units.Nanoseconds, units.Milliseconds, units.Microseconds,
+		units.Second, units.Minute, units.Hour, units.Day, units.Unit("unknown"),
+	} {
+		buckets := DefaultBucketsForUnit(u).buckets
+		test.That(t, buckets, test.ShouldNotBeEmpty)
+		for i := 1; i < len(buckets); i++ {
+			test.That(t, buckets[i], test.ShouldBeGreaterThan, buckets[i-1])
+		}
+	}
+
+	distribution := NewDistributionN("statz/test/default_buckets", MetricConfig{
+		Description: "The size of the upload",
+		Unit:        units.Bytes,
+	}, Distribution{})
+	recorder := statztest.NewDistributionRecorder("statz/test/default_buckets")
+	test.That(t, distribution.Observe(100, map[string]string{}), test.ShouldBeNil)
+	test.That(t, recorder.Value().Count, test.ShouldEqual, 1)
+	test.That(t, recorder.Value().Buckets, test.ShouldHaveLength, len(bytesBuckets)+1)
+	test.That(t, recorder.Value().Buckets[2].Count, test.ShouldEqual, 1)
+}
+
+func TestDistributionObserveN(t *testing.T) {
+	distribution := NewDistribution1[string]("statz/test/distribution_observe_n", MetricConfig{
+		Description: "The latency of the upload",
+		Unit:        units.Milliseconds,
+		Labels: []Label{
+			{Name: "label", Description: "The data type (file|binary|tabular)."},
+		},
+	}, DistributionFromBounds(0, 10, 50))
+	recorder := statztest.NewDistributionRecorder("statz/test/distribution_observe_n")
+
+	distribution.ObserveN(20, 2500, "a")
+	distribution.ObserveN(5, 3, "a")
+	distribution.ObserveN(5, 0, "a")
+	distribution.ObserveN(5, -1, "a")
+
+	value := recorder.Value("label", "a")
+	test.That(t, value.Count, test.ShouldEqual, 2503)
+	test.That(t, value.Sum, test.ShouldEqual, 50015)
+	test.That(t, value.Buckets[1].Count, test.ShouldEqual, 3)
+	test.That(t, value.Buckets[2].Count, test.ShouldEqual, 2500)
+
+	distributionN := NewDistributionN("statz/test/distribution_observe_n_map", MetricConfig{
+		Description: "The latency of the upload",
+		Unit:        units.Milliseconds,
+		Labels: []Label{
+			{Name: "label", Description: "The data type (file|binary|tabular)."},
+		},
+	}, DistributionFromBounds(0, 10, 50))
+	recorderN := statztest.NewDistributionRecorder("statz/test/distribution_observe_n_map")
+	test.That(t, distributionN.ObserveN(1, 10, map[string]string{"label": "a"}), test.ShouldBeNil)
+	test.That(t, distributionN.ObserveN(1, 10, map[string]string{}), test.ShouldNotBeNil)
+	test.That(t, recorderN.Value("label", "a").Count, test.ShouldEqual, 10)
+}
+
+func TestDistributionObserveIfAbove(t *testing.T) {
+	distribution := NewDistribution1[string]("statz/test/distribution_if_above", MetricConfig{
+		Description: "The latency of the upload",
+		Unit:        units.Milliseconds,
+		Labels: []Label{
+			{Name: "label", Description: "The data type (file|binary|tabular)."},
+		},
+	}, DistributionFromBounds(0, 10, 50))
+	recorder := statztest.NewDistributionRecorder("statz/test/distribution_if_above")
+
+	for _, v := range []float64{1, 5, 9.9, 10, 100} {
+		distribution.ObserveIfAbove(v, 10, "a")
+	}
+
+	value := recorder.Value("label", "a")
+	test.That(t, value.Count, test.ShouldEqual, 2)
+	test.That(t, value.Sum, test.ShouldEqual, 110)
+	test.That(t, value.Buckets[1].Count, test.ShouldEqual, 0)
+}
+
+func TestObserveValue(t *testing.T) {
+	distribution := NewDistribution1[string]("statz/test/distribution_observe_value", MetricConfig{
+		Description: "The size of the upload",
+		Unit:        units.Bytes,
+		Labels: []Label{
+			{Name: "label", Description: "The data type (file|binary|tabular)."},
+		},
+	}, DistributionFromBounds(0, 10, 50))
+	recorder := statztest.NewDistributionRecorder("statz/test/distribution_observe_value")
+
+	type size uint32
+	ObserveValue1(&distribution, 5, "a")
+	ObserveValue1(&distribution, int64(20), "a")
+	ObserveValue1(&distribution, size(30), "a")
+	ObserveValue1(&distribution, float32(0.5), "a")
+
+	value := recorder.Value("label", "a")
+	test.That(t, value.Count, test.ShouldEqual, 4)
+	test.That(t, value.Sum, test.ShouldEqual, 55.5)
+}
+
+func TestDistributionInvalidBounds(t *testing.T) {
+	cfg := MetricConfig{
+		Description: "A distribution with bad bounds",
+		Unit:        units.Dimensionless,
+	}
+	test.That(t, func() {
+		NewDistribution0("statz/test/distribution_unsorted", cfg, DistributionFromBounds(0, 10, 5))
+	}, test.ShouldPanic)
+
+	SetLenientRegistration(true)
+	defer SetLenientRegistration(false)
+
+	for _, tc := range []struct {
+		name   string
+		bounds []float64
+		errMsg string
+	}{
+		{"statz/test/distribution_unsorted_lenient", []float64{0, 10, 5}, "strictly increasing"},
+		{"statz/test/distribution_duplicate", []float64{0, 10, 10, 20}, "strictly increasing"},
+		{"statz/test/distribution_nan", []float64{0, math.NaN(), 20}, "finite"},
+		{"statz/test/distribution_inf", []float64{0, 10, math.Inf(1)}, "finite"},
+	} {
+		d := NewDistributionN(tc.name, cfg, DistributionFromBounds(tc.bounds...))
+		test.That(t, d.Observe(1, nil), test.ShouldBeNil)
+
+		err := RegistrationErrors()
+		test.That(t, err, test.ShouldNotBeNil)
+		test.That(t, err.Error(), test.ShouldContainSubstring, tc.name+" has invalid distribution bound")
+		test.That(t, err.Error(), test.ShouldContainSubstring, tc.errMsg)
+		test.That(t, RegisteredMetricNames(), test.ShouldNotContain, tc.name)
+	}
+}
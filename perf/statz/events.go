@@ -0,0 +1,67 @@
+package statz
+
+import (
+	"context"
+
+	"github.com/edaniels/golog"
+	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
+)
+
+// EventCounter counts rare but significant events, such as an auth key rotation, and logs each
+// one, so that both the metric and a structured log line come from a single call.
+//
+// Example:
+//
+//	var keyRotations = statz.NewEventCounter("auth/key_rotations", statz.MetricConfig{
+//		Description: "The number of auth key rotations",
+//		Unit:        units.Dimensionless,
+//		Labels: []statz.Label{
+//			{Name: "key_type", Description: "The type of key rotated."},
+//		},
+//	}, logger, zapcore.InfoLevel)
+//
+//	keyRotations.Record("key rotated", "rsa")
+type EventCounter struct {
+	counter *ocCounterWrapper
+	logger  *zap.Logger
+	level   zapcore.Level
+}
+
+// NewEventCounter creates a new event counter metric whose events are logged to logger at level.
+// The global logger is used if logger is nil.
+func NewEventCounter(name string, cfg MetricConfig, logger golog.Logger, level zapcore.Level) EventCounter {
+	if logger == nil {
+		logger = golog.Global()
+	}
+	return EventCounter{
+		counter: createCounterWrapper(name, cfg),
+		logger:  logger.Desugar().WithOptions(zap.AddCallerSkip(1)),
+		level:   level,
+	}
+}
+
+// Record counts one event and logs msg along with the metric name and label values. The label
+// values must be given in the order of the MetricConfig labels. The event is still logged if
+// the metric failed to register.
+func (ec *EventCounter) Record(msg string, labels ...string) {
+	data := ec.counter.data
+	if data.disabled {
+		ec.logger.Log(ec.level, msg, zap.String("metric", data.View.Name))
+		return
+	}
+	if len(labels) != len(data.labelKeys) {
+		golog.Global().Errorf("failed to record event %s: expected %d label values but got %d",
+			data.View.Name, len(data.labelKeys), len(labels))
+		return
+	}
+
+	fields := make([]zap.Field, 0, len(labels)+1)
+	fields = append(fields, zap.String("metric", data.View.Name))
+	for i, l := range labels {
+		fields = append(fields, zap.String(data.labelKeys[i].Name(), l))
+	}
+	ec.logger.Log(ec.level, msg, fields...)
+
+	ec.counter.incBy(context.Background(), labels, 1)
+}
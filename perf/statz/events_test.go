@@ -0,0 +1,36 @@
+package statz
+
+import (
+	"testing"
+
+	"github.com/edaniels/golog"
+	"go.uber.org/zap/zapcore"
+	"go.viam.com/test"
+
+	"go.viam.com/utils/perf/statz/statztest"
+)
+
+func TestEventCounter(t *testing.T) {
+	logger, observedLogs := golog.NewObservedTestLogger(t)
+	ec := NewEventCounter("statz/test/events", MetricConfig{
+		Description: "Test events",
+		Labels: []Label{
+			{Name: "key_type", Description: "The type of key rotated."},
+		},
+	}, logger, zapcore.WarnLevel)
+
+	recorder := statztest.NewCounterRecorder("statz/test/events")
+
+	ec.Record("key rotated", "rsa")
+	ec.Record("key rotated", "rsa")
+	// mismatched labels are dropped
+	ec.Record("key rotated")
+
+	test.That(t, recorder.Value("key_type", "rsa"), test.ShouldEqual, 2)
+
+	logs := observedLogs.FilterMessage("key rotated").All()
+	test.That(t, logs, test.ShouldHaveLength, 2)
+	test.That(t, logs[0].Level, test.ShouldEqual, zapcore.WarnLevel)
+	test.That(t, logs[0].ContextMap()["metric"], test.ShouldEqual, "statz/test/events")
+	test.That(t, logs[0].ContextMap()["key_type"], test.ShouldEqual, "rsa")
+}
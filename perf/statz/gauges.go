@@ -0,0 +1,116 @@
+package statz
+
+import (
+	"context"
+	"time"
+
+	"github.com/edaniels/golog"
+	"go.opencensus.io/stats"
+	"go.opencensus.io/stats/view"
+)
+
+// Gauge0 is an int64 gauge metric with 0 metric labels. Good for current states and levels.
+type Gauge0 struct {
+	wrapper *ocGaugeWrapper
+}
+
+// Set sets the gauge to v.
+func (g *Gauge0) Set(v int64) {
+	g.wrapper.set(context.Background(), labelsToStringSlice(), v)
+}
+
+// LastUpdated returns when the metric was last recorded with the given label values or the zero
+// time if it has not been or MetricConfig.TrackLastUpdated is not set.
+func (g *Gauge0) LastUpdated() time.Time {
+	return g.wrapper.data.lastUpdated(labelsToStringSlice())
+}
+
+// Gauge1 is an int64 gauge metric with 1 metric label. Good for current states and levels.
+type Gauge1[T1 labelContraint] struct {
+	wrapper *ocGaugeWrapper
+}
+
+// Set sets the gauge to v.
+func (g *Gauge1[T1]) Set(v int64, l1 T1) {
+	g.wrapper.set(context.Background(), labelsToStringSlice(l1), v)
+}
+
+// LastUpdated returns when the metric was last recorded with the given label values or the zero
+// time if it has not been or MetricConfig.TrackLastUpdated is not set.
+func (g *Gauge1[T1]) LastUpdated(l1 T1) time.Time {
+	return g.wrapper.data.lastUpdated(labelsToStringSlice(l1))
+}
+
+// Gauge2 is an int64 gauge metric with 2 metric labels. Good for current states and levels.
+type Gauge2[T1 labelContraint, T2 labelContraint] struct {
+	wrapper *ocGaugeWrapper
+}
+
+// Set sets the gauge to v.
+func (g *Gauge2[T1, T2]) Set(v int64, l1 T1, l2 T2) {
+	g.wrapper.set(context.Background(), labelsToStringSlice(l1, l2), v)
+}
+
+// LastUpdated returns when the metric was last recorded with the given label values or the zero
+// time if it has not been or MetricConfig.TrackLastUpdated is not set.
+func (g *Gauge2[T1, T2]) LastUpdated(l1 T1, l2 T2) time.Time {
+	return g.wrapper.data.lastUpdated(labelsToStringSlice(l1, l2))
+}
+
+// Gauge3 is an int64 gauge metric with 3 metric labels. Good for current states and levels.
+type Gauge3[T1 labelContraint, T2 labelContraint, T3 labelContraint] struct {
+	wrapper *ocGaugeWrapper
+}
+
+// Set sets the gauge to v.
+func (g *Gauge3[T1, T2, T3]) Set(v int64, l1 T1, l2 T2, l3 T3) {
+	g.wrapper.set(context.Background(), labelsToStringSlice(l1, l2, l3), v)
+}
+
+// LastUpdated returns when the metric was last recorded with the given label values or the zero
+// time if it has not been or MetricConfig.TrackLastUpdated is not set.
+func (g *Gauge3[T1, T2, T3]) LastUpdated(l1 T1, l2 T2, l3 T3) time.Time {
+	return g.wrapper.data.lastUpdated(labelsToStringSlice(l1, l2, l3))
+}
+
+// Gauge4 is an int64 gauge metric with 4 metric labels. Good for current states and levels.
+type Gauge4[T1 labelContraint, T2 labelContraint, T3 labelContraint, T4 labelContraint] struct {
+	wrapper *ocGaugeWrapper
+}
+
+// Set sets the gauge to v.
+func (g *Gauge4[T1, T2, T3, T4]) Set(v int64, l1 T1, l2 T2, l3 T3, l4 T4) {
+	g.wrapper.set(context.Background(), labelsToStringSlice(l1, l2, l3, l4), v)
+}
+
+// LastUpdated returns when the metric was last recorded with the given label values or the zero
+// time if it has not been or MetricConfig.TrackLastUpdated is not set.
+func (g *Gauge4[T1, T2, T3, T4]) LastUpdated(l1 T1, l2 T2, l3 T3, l4 T4) time.Time {
+	return g.wrapper.data.lastUpdated(labelsToStringSlice(l1, l2, l3, l4))
+}
+
+///// internal
+
+type ocGaugeWrapper struct {
+	data    *opencensusStatsData
+	measure *stats.Int64Measure
+}
+
+func (w *ocGaugeWrapper) set(ctx context.Context, labels []string, value int64) {
+	if w.data.disabled {
+		return
+	}
+	mutations := w.data.labelsToMutations(labels)
+	if err := stats.RecordWithTags(ctx, mutations, w.measure.M(value)); err != nil {
+		golog.Global().Errorf("faild to write metric %s", err)
+	}
+}
+
+func createGaugeWrapper(name string, cfg MetricConfig) *ocGaugeWrapper {
+	measure := stats.Int64(name, cfg.Description, string(cfg.Unit))
+	ocData := createAndRegisterOpenCensusMetric(name, measure, view.LastValue(), cfg)
+	return &ocGaugeWrapper{
+		data:    ocData,
+		measure: measure,
+	}
+}
@@ -0,0 +1,31 @@
+package statz
+
+import (
+	"testing"
+
+	"go.viam.com/test"
+
+	"go.viam.com/utils/perf/statz/statztest"
+	"go.viam.com/utils/perf/statz/units"
+)
+
+func TestGauge(t *testing.T) {
+	gauge := NewGauge1[string]("statz/test/gauge", MetricConfig{
+		Description: "The number of uploads waiting to be sent",
+		Unit:        units.Dimensionless,
+		Labels: []Label{
+			{Name: "label", Description: "The data type (file|binary|tabular)."},
+		},
+	})
+	recorder := statztest.NewGaugeRecorder("statz/test/gauge")
+
+	test.That(t, recorder.Value("label", "file"), test.ShouldEqual, 0)
+	gauge.Set(5, "file")
+	gauge.Set(3, "file")
+	gauge.Set(7, "binary")
+	test.That(t, recorder.Value("label", "file"), test.ShouldEqual, 3)
+	test.That(t, recorder.Value("label", "binary"), test.ShouldEqual, 7)
+
+	gauge.Set(0, "file")
+	test.That(t, recorder.Value("label", "file"), test.ShouldEqual, 0)
+}
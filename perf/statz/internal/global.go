@@ -20,6 +20,18 @@ var state global
 // RegisterMetric validates and registers a statz metric. Must be unique within the application.
 // Panic on any failures to ensure we catch the errors early instead of loosing metrics.
 func RegisterMetric(name string) {
+	if err := registerMetric(name, 5); err != nil {
+		golog.Global().Panic(err)
+	}
+}
+
+// TryRegisterMetric is like RegisterMetric but returns the failure instead of panicking.
+func TryRegisterMetric(name string) error {
+	return registerMetric(name, 6)
+}
+
+// registerMetric records the metric along with the location of the caller callerSkip frames up.
+func registerMetric(name string, callerSkip int) error {
 	state.mu.Lock()
 	defer state.mu.Unlock()
 
@@ -30,16 +42,16 @@ func RegisterMetric(name string) {
 	var caller string
 
 	// Try to help users who define metrics twice by printing what registered the metric.
-	_, file, no, ok := runtime.Caller(4)
+	_, file, no, ok := runtime.Caller(callerSkip)
 	if ok {
 		caller = fmt.Sprintf("%s#%d", file, no)
 	}
 
 	if prev, ok := state.metrics[name]; ok {
-		golog.Global().Panicf(`Metric %s was already defined and is trying to register again. It may be registered at: %s
-			"Statz metrics MUST be globalally unique in the application.`, name, prev)
-		return
+		return fmt.Errorf("metric %s was already defined and is trying to register again, it may be registered at: %s. "+
+			"Statz metrics MUST be globalally unique in the application", name, prev)
 	}
 
 	state.metrics[name] = caller
+	return nil
 }
@@ -12,3 +12,8 @@ func TestRegisterMetric(t *testing.T) {
 
 	test.That(t, func() { RegisterMetric("metric/1") }, test.ShouldPanic)
 }
+
+func TestTryRegisterMetric(t *testing.T) {
+	test.That(t, TryRegisterMetric("metric/2"), test.ShouldBeNil)
+	test.That(t, TryRegisterMetric("metric/2"), test.ShouldNotBeNil)
+}
@@ -1,6 +1,8 @@
 package statz
 
 import (
+	"fmt"
+	"reflect"
 	"strconv"
 
 	"github.com/edaniels/golog"
@@ -11,6 +13,33 @@ import (
 type Label struct {
 	Name        string
 	Description string
+	// Values optionally enumerates every value of the label. Counters need it to be initialized
+	// to zero (see MetricConfig.InitializeToZero) unless the label is a bool.
+	Values []string
+	// Encode optionally encodes the values of the label given to APIs taking arbitrary values,
+	// such as DistributionN.ObserveValues, so that structured values like versions are formatted
+	// the same way at every call site. Without it, values are formatted with fmt.Sprint.
+	Encode func(v interface{}) string
+}
+
+// MergeLabels combines label sets into one, keeping the first occurrence of each label name
+// in order. A label name given twice with different descriptions is an error.
+func MergeLabels(labelSets ...[]Label) ([]Label, error) {
+	var merged []Label
+	seen := make(map[string]string)
+	for _, labels := range labelSets {
+		for _, l := range labels {
+			if desc, ok := seen[l.Name]; ok {
+				if desc != l.Description {
+					return nil, fmt.Errorf("label %q has conflicting descriptions %q and %q", l.Name, desc, l.Description)
+				}
+				continue
+			}
+			seen[l.Name] = l.Description
+			merged = append(merged, l)
+		}
+	}
+	return merged, nil
 }
 
 // const values for true/false to avoid string creation on each metric record.
@@ -23,6 +52,16 @@ type labelContraint interface {
 	~int64 | ~uint64 | ~string | ~bool
 }
 
+// labelDomain returns every value of a label of type T if they can be enumerated from the type
+// alone, which is only the case for bools.
+func labelDomain[T labelContraint]() []string {
+	var zero T
+	if reflect.TypeOf(zero).Kind() == reflect.Bool {
+		return []string{boolValueFalse, boolValueTrue}
+	}
+	return nil
+}
+
 func labelsToStringSlice(vList ...interface{}) []string {
 	strs := make([]string, 0, len(vList))
 	for _, v := range vList {
@@ -44,6 +83,26 @@ func labelToString(v interface{}) string {
 			return boolValueTrue
 		}
 		return boolValueFalse
+	default:
+		// User defined types such as `type Status string` are allowed by the constraints
+		// but do not match the cases above.
+		return namedLabelToString(reflect.ValueOf(v))
+	}
+}
+
+func namedLabelToString(v reflect.Value) string {
+	switch v.Kind() {
+	case reflect.String:
+		return v.String()
+	case reflect.Int64:
+		return strconv.FormatInt(v.Int(), 10)
+	case reflect.Uint64:
+		return strconv.FormatUint(v.Uint(), 10)
+	case reflect.Bool:
+		if v.Bool() {
+			return boolValueTrue
+		}
+		return boolValueFalse
 	default:
 		golog.Global().Fatalf("Invalid type to string, should never happen with the type contraints defined.")
 		return ""
@@ -0,0 +1,99 @@
+package statz
+
+import (
+	"context"
+	"time"
+
+	"github.com/edaniels/golog"
+	"google.golang.org/grpc/status"
+
+	"go.viam.com/utils/perf/statz/units"
+)
+
+// OperationMetrics packages the count, error count, and latency of an operation under
+// identical labels. The metrics are registered as "<name>/count", "<name>/errors", and
+// "<name>/latency" (in milliseconds). Errors are also counted by their gRPC code as
+// "<name>/error_codes" with an additional "code" label.
+//
+// Example:
+//
+//	var uploadOp = statz.NewOperationMetrics("datasync/upload", statz.MetricConfig{
+//		Description: "Uploads of data",
+//		Labels: []statz.Label{
+//			{Name: "type", Description: "The data type (file|binary|tabular)."},
+//		},
+//	}, statz.LatencyDistribution)
+//
+//	err := uploadOp.Measure(ctx, func() error {
+//		return upload()
+//	}, "binary")
+type OperationMetrics struct {
+	count      *ocCounterWrapper
+	errors     *ocCounterWrapper
+	errorCodes *ocCounterWrapper
+	latency    *ocDistributionWrapper
+}
+
+// NewOperationMetrics creates the metrics of an operation. The Unit of cfg is ignored since
+// each underlying metric has its own.
+func NewOperationMetrics(name string, cfg MetricConfig, latency Distribution) OperationMetrics {
+	countCfg := cfg
+	countCfg.Description = "The number of operations: " + cfg.Description
+	countCfg.Unit = units.Dimensionless
+
+	errorsCfg := cfg
+	errorsCfg.Description = "The number of failed operations: " + cfg.Description
+	errorsCfg.Unit = units.Dimensionless
+
+	// the codes are not known up front so these cannot be initialized to zero.
+	errorCodesCfg := errorsCfg
+	errorCodesCfg.Labels = append(append([]Label{}, cfg.Labels...), Label{
+		Name:        "code",
+		Description: "The gRPC code of the error.",
+	})
+	errorCodesCfg.Aliases = nil
+	errorCodesCfg.InitializeToZero = false
+
+	latencyCfg := cfg
+	latencyCfg.Description = "The latency of operations: " + cfg.Description
+	latencyCfg.Unit = units.Milliseconds
+
+	return OperationMetrics{
+		count:      createCounterWrapper(name+"/count", countCfg),
+		errors:     createCounterWrapper(name+"/errors", errorsCfg),
+		errorCodes: createCounterWrapper(name+"/error_codes", errorCodesCfg),
+		latency:    createocDistributionWrapper(name+"/latency", latency, latencyCfg),
+	}
+}
+
+// Record records one operation that took the given duration, counting it as an error when err
+// is not nil. The label values must be given in the order of the MetricConfig labels.
+func (m *OperationMetrics) Record(err error, duration time.Duration, labels ...string) {
+	m.record(context.Background(), err, duration, labels)
+}
+
+// Measure runs fn and records it as one operation, returning its error. The label values must
+// be given in the order of the MetricConfig labels.
+func (m *OperationMetrics) Measure(ctx context.Context, fn func() error, labels ...string) error {
+	start := time.Now()
+	err := fn()
+	m.record(ctx, err, time.Since(start), labels)
+	return err
+}
+
+func (m *OperationMetrics) record(ctx context.Context, err error, duration time.Duration, labels []string) {
+	if m.count.data.disabled {
+		return
+	}
+	if len(labels) != len(m.count.data.labelKeys) {
+		golog.Global().Errorf("failed to record operation %s: expected %d label values but got %d",
+			m.count.data.View.Name, len(m.count.data.labelKeys), len(labels))
+		return
+	}
+	m.count.incBy(ctx, labels, 1)
+	if err != nil {
+		m.errors.incBy(ctx, labels, 1)
+		m.errorCodes.incBy(ctx, append(append([]string{}, labels...), status.Code(err).String()), 1)
+	}
+	m.latency.observe(ctx, labels, float64(duration)/float64(time.Millisecond))
+}
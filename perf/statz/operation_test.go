@@ -0,0 +1,72 @@
+package statz
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"go.viam.com/test"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+
+	"go.viam.com/utils/perf/statz/statztest"
+)
+
+func TestOperationMetrics(t *testing.T) {
+	op := NewOperationMetrics("statz/test/operation", MetricConfig{
+		Description: "Test operations",
+		Labels: []Label{
+			{Name: "label", Description: "The data type (file|binary|tabular)."},
+		},
+	}, DistributionFromBounds(0, 10, 50))
+
+	countRecorder := statztest.NewCounterRecorder("statz/test/operation/count")
+	errorsRecorder := statztest.NewCounterRecorder("statz/test/operation/errors")
+	errorCodesRecorder := statztest.NewCounterRecorder("statz/test/operation/error_codes")
+	latencyRecorder := statztest.NewDistributionRecorder("statz/test/operation/latency")
+
+	op.Record(nil, 5*time.Millisecond, "v1")
+	op.Record(errors.New("whoops"), 20*time.Millisecond, "v1")
+	// mismatched labels are dropped
+	op.Record(nil, 5*time.Millisecond)
+
+	test.That(t, countRecorder.Value("label", "v1"), test.ShouldEqual, 2)
+	test.That(t, errorsRecorder.Value("label", "v1"), test.ShouldEqual, 1)
+	test.That(t, latencyRecorder.Value("label", "v1").Count, test.ShouldEqual, 2)
+	test.That(t, latencyRecorder.Value("label", "v1").Sum, test.ShouldEqual, 25)
+	test.That(t, errorCodesRecorder.Value("label", "v1", "code", "Unknown"), test.ShouldEqual, 1)
+}
+
+func TestOperationMetricsMeasure(t *testing.T) {
+	op := NewOperationMetrics("statz/test/operation_measure", MetricConfig{
+		Description: "Test operations",
+		Labels: []Label{
+			{Name: "label", Description: "The data type (file|binary|tabular)."},
+		},
+	}, DistributionFromBounds(0, 10, 50))
+
+	countRecorder := statztest.NewCounterRecorder("statz/test/operation_measure/count")
+	errorsRecorder := statztest.NewCounterRecorder("statz/test/operation_measure/errors")
+	errorCodesRecorder := statztest.NewCounterRecorder("statz/test/operation_measure/error_codes")
+	latencyRecorder := statztest.NewDistributionRecorder("statz/test/operation_measure/latency")
+
+	ctx := context.Background()
+	err := op.Measure(ctx, func() error {
+		time.Sleep(5 * time.Millisecond)
+		return nil
+	}, "v1")
+	test.That(t, err, test.ShouldBeNil)
+
+	notFoundErr := status.Error(codes.NotFound, "whoops")
+	err = op.Measure(ctx, func() error {
+		return notFoundErr
+	}, "v1")
+	test.That(t, err, test.ShouldEqual, notFoundErr)
+
+	test.That(t, countRecorder.Value("label", "v1"), test.ShouldEqual, 2)
+	test.That(t, errorsRecorder.Value("label", "v1"), test.ShouldEqual, 1)
+	test.That(t, errorCodesRecorder.Value("label", "v1", "code", "NotFound"), test.ShouldEqual, 1)
+	test.That(t, latencyRecorder.Value("label", "v1").Count, test.ShouldEqual, 2)
+	test.That(t, latencyRecorder.Value("label", "v1").Sum, test.ShouldBeGreaterThanOrEqualTo, 5)
+}
@@ -0,0 +1,91 @@
+package statz
+
+import (
+	"sync"
+	"time"
+)
+
+// rateCounterBuckets is the number of buckets the window of a RateCounter is divided into. The
+// rate slides forward one bucket at a time.
+const rateCounterBuckets = 10
+
+// defaultRateWindow is the window of a RateCounter created without a positive one.
+const defaultRateWindow = time.Minute
+
+// RateCounter wraps a counter to also compute the rate of its increments per second over a
+// sliding window in process, such as for autoscaling decisions that cannot wait on the rate of
+// a metrics backend. Use Bind to wrap a counter with labels.
+//
+// Example:
+//
+//	uploads := statz.NewRateCounter(uploadCounter.Bind("binary"), time.Minute)
+//	uploads.Inc()
+//	if uploads.Rate() > 100 {
+//		scaleUp()
+//	}
+type RateCounter struct {
+	counter interface{ IncBy(by int64) }
+	width   time.Duration
+	now     func() time.Time
+
+	mu      sync.Mutex
+	buckets [rateCounterBuckets]rateBucket
+}
+
+type rateBucket struct {
+	start time.Time
+	count int64
+}
+
+// NewRateCounter wraps counter, such as a Counter0 or a BoundCounter, to compute its rate over
+// window. The window defaults to a minute if it is not positive.
+func NewRateCounter(counter interface{ IncBy(by int64) }, window time.Duration) *RateCounter {
+	if window <= 0 {
+		window = defaultRateWindow
+	}
+	width := window / rateCounterBuckets
+	if width <= 0 {
+		width = 1
+	}
+	return &RateCounter{counter: counter, width: width, now: time.Now}
+}
+
+// Inc increments counter by 1.
+func (c *RateCounter) Inc() {
+	c.IncBy(1)
+}
+
+// IncBy increments counter by X.
+func (c *RateCounter) IncBy(by int64) {
+	c.counter.IncBy(by)
+
+	start := c.now().Truncate(c.width)
+	bucket := &c.buckets[(start.UnixNano()/int64(c.width))%rateCounterBuckets]
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if !bucket.start.Equal(start) {
+		*bucket = rateBucket{start: start}
+	}
+	bucket.count += by
+}
+
+// Rate returns the increments per second averaged over the window. The current bucket is only
+// partially elapsed, so the window covered is between one bucket short of the window and the
+// window.
+func (c *RateCounter) Rate() float64 {
+	now := c.now()
+	current := now.Truncate(c.width)
+	oldest := current.Add(-time.Duration(rateCounterBuckets-1) * c.width)
+
+	c.mu.Lock()
+	var count int64
+	for _, bucket := range c.buckets {
+		if !bucket.start.Before(oldest) && !bucket.start.After(current) {
+			count += bucket.count
+		}
+	}
+	c.mu.Unlock()
+
+	elapsed := now.Sub(oldest)
+	return float64(count) / elapsed.Seconds()
+}
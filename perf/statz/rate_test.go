@@ -0,0 +1,47 @@
+package statz
+
+import (
+	"testing"
+	"time"
+
+	"go.viam.com/test"
+
+	"go.viam.com/utils/perf/statz/statztest"
+	"go.viam.com/utils/perf/statz/units"
+)
+
+func TestRateCounter(t *testing.T) {
+	counter := NewCounter1[string]("statz/test/rate_counter", MetricConfig{
+		Description: "A counter",
+		Unit:        units.Dimensionless,
+		Labels: []Label{
+			{Name: "label", Description: "A label."},
+		},
+	})
+	recorder := statztest.NewCounterRecorder("statz/test/rate_counter")
+
+	now := time.Unix(1000, 0)
+	rate := NewRateCounter(counter.Bind("a"), 10*time.Second)
+	rate.now = func() time.Time {
+		return now
+	}
+
+	test.That(t, rate.Rate(), test.ShouldEqual, 0)
+
+	// 10 increments a second for 10 seconds.
+	for i := 0; i < 10; i++ {
+		rate.IncBy(10)
+		now = now.Add(time.Second)
+	}
+	test.That(t, recorder.Value("label", "a"), test.ShouldEqual, 100)
+	// the window covers the last 9 whole seconds and the current one just started.
+	test.That(t, rate.Rate(), test.ShouldEqual, 10)
+
+	// increments slide out of the window.
+	now = now.Add(5 * time.Second)
+	test.That(t, rate.Rate(), test.ShouldAlmostEqual, 40.0/9)
+	now = now.Add(time.Minute)
+	test.That(t, rate.Rate(), test.ShouldEqual, 0)
+	rate.Inc()
+	test.That(t, rate.Rate(), test.ShouldAlmostEqual, 1.0/9)
+}
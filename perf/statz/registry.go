@@ -0,0 +1,334 @@
+package statz
+
+import (
+	"fmt"
+	"os"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/edaniels/golog"
+	"go.uber.org/multierr"
+
+	"go.viam.com/utils/perf/statz/units"
+)
+
+// RegisteredMetric is a metric that was defined in the application along with its config.
+type RegisteredMetric struct {
+	Name   string
+	Config MetricConfig
+}
+
+// envVarLenientRegistration turns on lenient registration from program startup, before any
+// package level metrics are defined.
+const envVarLenientRegistration = "STATZ_LENIENT_REGISTRATION"
+
+// envVarRequireNamespacedNames turns on requiring namespaced metric names from program startup.
+const envVarRequireNamespacedNames = "STATZ_REQUIRE_NAMESPACED_NAMES"
+
+// envVarStrictExporterUnits turns on failing on exporter unit mismatches from program startup.
+const envVarStrictExporterUnits = "STATZ_STRICT_EXPORTER_UNITS"
+
+var registry = struct {
+	mu      sync.Mutex
+	metrics []RegisteredMetric
+	data    map[string]*opencensusStatsData
+	// configs holds the config of each registered metric by name.
+	configs map[string]MetricConfig
+	// aliases holds the view of each metric alias by the alias.
+	aliases map[string]*metricAlias
+	lenient bool
+	errs    error
+	// namespaced requires metric names to have a namespace prefix.
+	namespaced bool
+	// latency is kept outside of OpenCensus since it measures registering with it.
+	latency RegistrationLatency
+	// unitCheckers check the units of metrics against those the configured exporters support.
+	unitCheckers []exporterUnitChecker
+	// strictUnits fails on units that the configured exporters do not support.
+	strictUnits bool
+}{
+	lenient:     os.Getenv(envVarLenientRegistration) == "true",
+	namespaced:  os.Getenv(envVarRequireNamespacedNames) == "true",
+	strictUnits: os.Getenv(envVarStrictExporterUnits) == "true",
+}
+
+// SetLenientRegistration sets whether metrics that fail to register are skipped instead of
+// crashing the program. Skipped metrics record nothing and their failures are available from
+// RegistrationErrors. Since package level metrics are defined during initialization, set the
+// STATZ_LENIENT_REGISTRATION environment variable to "true" to be lenient from startup.
+func SetLenientRegistration(lenient bool) {
+	registry.mu.Lock()
+	defer registry.mu.Unlock()
+	registry.lenient = lenient
+}
+
+// SetRequireNamespacedNames sets whether metric names must have a "/" delimited namespace
+// prefix, such as "datasync/uploaded", in order to avoid collisions of flat names like
+// "requests". Set the STATZ_REQUIRE_NAMESPACED_NAMES environment variable to "true" to
+// require them from startup.
+func SetRequireNamespacedNames(require bool) {
+	registry.mu.Lock()
+	defer registry.mu.Unlock()
+	registry.namespaced = require
+}
+
+// SetStrictExporterUnits sets whether metric units that an exporter checked with
+// CheckExporterUnits does not support are failures rather than warnings. Set the
+// STATZ_STRICT_EXPORTER_UNITS environment variable to "true" to be strict from startup.
+func SetStrictExporterUnits(strict bool) {
+	registry.mu.Lock()
+	defer registry.mu.Unlock()
+	registry.strictUnits = strict
+}
+
+func requireNamespacedNames() bool {
+	registry.mu.Lock()
+	defer registry.mu.Unlock()
+	return registry.namespaced
+}
+
+// RegistrationErrors returns the combined failures of metrics skipped by lenient registration.
+func RegistrationErrors() error {
+	registry.mu.Lock()
+	defer registry.mu.Unlock()
+	return registry.errs
+}
+
+func lenientRegistration() bool {
+	registry.mu.Lock()
+	defer registry.mu.Unlock()
+	return registry.lenient
+}
+
+func addRegistrationError(err error) {
+	registry.mu.Lock()
+	defer registry.mu.Unlock()
+	registry.errs = multierr.Append(registry.errs, err)
+}
+
+func addRegisteredMetric(name string, cfg MetricConfig, data *opencensusStatsData) {
+	registry.mu.Lock()
+	defer registry.mu.Unlock()
+	registry.metrics = append(registry.metrics, RegisteredMetric{Name: name, Config: cfg})
+	if registry.data == nil {
+		registry.data = map[string]*opencensusStatsData{}
+	}
+	registry.data[name] = data
+	if registry.configs == nil {
+		registry.configs = map[string]MetricConfig{}
+	}
+	registry.configs[name] = cfg
+}
+
+// registrationLatencyBounds are the upper bounds of the RegistrationLatency buckets.
+var registrationLatencyBounds = []time.Duration{
+	10 * time.Microsecond,
+	100 * time.Microsecond,
+	time.Millisecond,
+	10 * time.Millisecond,
+	100 * time.Millisecond,
+}
+
+// RegistrationLatency summarizes the time spent registering metrics with OpenCensus.
+type RegistrationLatency struct {
+	Count int64
+	Total time.Duration
+	Max   time.Duration
+	// BucketCounts[i] is the number of registrations that took less than Bounds[i]; the extra
+	// last bucket counts those that took at least the last bound.
+	Bounds       []time.Duration
+	BucketCounts []int64
+}
+
+// RegistrationStats returns how long registering every metric defined so far took, including
+// those that failed lenient registration. It helps quantify the startup cost of many metrics.
+func RegistrationStats() RegistrationLatency {
+	registry.mu.Lock()
+	defer registry.mu.Unlock()
+	stats := registry.latency
+	stats.Bounds = append([]time.Duration(nil), registrationLatencyBounds...)
+	stats.BucketCounts = make([]int64, len(registrationLatencyBounds)+1)
+	copy(stats.BucketCounts, registry.latency.BucketCounts)
+	return stats
+}
+
+func recordRegistrationLatency(d time.Duration) {
+	registry.mu.Lock()
+	defer registry.mu.Unlock()
+	if registry.latency.BucketCounts == nil {
+		registry.latency.BucketCounts = make([]int64, len(registrationLatencyBounds)+1)
+	}
+	registry.latency.Count++
+	registry.latency.Total += d
+	if d > registry.latency.Max {
+		registry.latency.Max = d
+	}
+	bucket := sort.Search(len(registrationLatencyBounds), func(i int) bool {
+		return d < registrationLatencyBounds[i]
+	})
+	registry.latency.BucketCounts[bucket]++
+}
+
+// MetricCardinality returns the number of distinct combinations of label values recorded so far
+// for the metric, which is the number of time series it exports. Unknown metrics have none.
+func MetricCardinality(name string) int {
+	registry.mu.Lock()
+	data, ok := registry.data[name]
+	registry.mu.Unlock()
+	if !ok {
+		return 0
+	}
+	return data.cardinality()
+}
+
+// RegisteredMetrics returns every metric defined so far, sorted by name. Package level metrics
+// register themselves when their package is initialized, so importing a package is enough to
+// make its metrics discoverable.
+func RegisteredMetrics() []RegisteredMetric {
+	registry.mu.Lock()
+	metrics := make([]RegisteredMetric, len(registry.metrics))
+	copy(metrics, registry.metrics)
+	registry.mu.Unlock()
+
+	sort.Slice(metrics, func(i, j int) bool {
+		return metrics[i].Name < metrics[j].Name
+	})
+	return metrics
+}
+
+// MetricUnit returns the unit a registered metric was defined with. Exporters reading views as
+// metrics only get the units OpenCensus knows about ("1", "ms", and "By"), so they can use this
+// to recover the others, such as units.Nanoseconds.
+func MetricUnit(name string) (units.Unit, bool) {
+	registry.mu.Lock()
+	defer registry.mu.Unlock()
+	data, ok := registry.data[name]
+	if alias, isAlias := registry.aliases[name]; isAlias {
+		data, ok = registry.data[alias.metric]
+	}
+	if !ok || data.disabled {
+		return "", false
+	}
+	return units.Unit(data.View.Measure.Unit()), true
+}
+
+// MetricConfigFor returns the config a registered metric, or the metric of an alias, was defined
+// with, such as to document the metrics of a running program. The returned config is a copy
+// that can be modified without affecting the metric.
+func MetricConfigFor(name string) (MetricConfig, bool) {
+	registry.mu.Lock()
+	defer registry.mu.Unlock()
+	if alias, isAlias := registry.aliases[name]; isAlias {
+		name = alias.metric
+	}
+	cfg, ok := registry.configs[name]
+	if !ok {
+		return MetricConfig{}, false
+	}
+	return cfg.clone(), true
+}
+
+// RegisteredMetricNames returns the names of every metric defined so far, sorted.
+func RegisteredMetricNames() []string {
+	metrics := RegisteredMetrics()
+	names := make([]string, 0, len(metrics))
+	for _, m := range metrics {
+		names = append(names, m.Name)
+	}
+	return names
+}
+
+// exporterUnitChecker checks whether the named exporter supports a unit.
+type exporterUnitChecker struct {
+	exporter string
+	supports func(unit units.Unit) bool
+}
+
+// CheckExporterUnits checks the unit of every metric defined so far, and of every metric defined
+// after, against those the named exporter supports, for a unit that one exporter supports may be
+// meaningless to another. Mismatches are logged as warnings. Only if exporter units are strict
+// (see SetStrictExporterUnits) are the mismatches of metrics defined so far returned, and
+// defining a metric with a mismatched unit later fails like any other registration failure.
+func CheckExporterUnits(exporter string, supports func(unit units.Unit) bool) error {
+	registry.mu.Lock()
+	registry.unitCheckers = append(registry.unitCheckers, exporterUnitChecker{exporter: exporter, supports: supports})
+	strict := registry.strictUnits
+	registry.mu.Unlock()
+
+	var errs error
+	for _, m := range RegisteredMetrics() {
+		if !supports(m.Config.Unit) {
+			errs = multierr.Append(errs, unsupportedUnitError(m.Name, m.Config.Unit, exporter))
+		}
+	}
+	if errs == nil {
+		return nil
+	}
+	if !strict {
+		golog.Global().Warnw("metric units will not translate cleanly to an exporter", "exporter", exporter, "error", errs)
+		return nil
+	}
+	return errs
+}
+
+// exporterUnitsError returns why the unit of the metric is not supported by every exporter
+// checked with CheckExporterUnits, if it is not and exporter units are strict. Otherwise a
+// mismatch is only logged as a warning.
+func exporterUnitsError(name string, unit units.Unit) error {
+	registry.mu.Lock()
+	checkers := registry.unitCheckers
+	strict := registry.strictUnits
+	registry.mu.Unlock()
+
+	var errs error
+	for _, checker := range checkers {
+		if !checker.supports(unit) {
+			errs = multierr.Append(errs, unsupportedUnitError(name, unit, checker.exporter))
+		}
+	}
+	if errs != nil && !strict {
+		golog.Global().Warnw("metric unit will not translate cleanly to an exporter", "metric", name, "error", errs)
+		return nil
+	}
+	return errs
+}
+
+func unsupportedUnitError(name string, unit units.Unit, exporter string) error {
+	return fmt.Errorf("metric %s has unit '%s' which exporter %s does not support", name, unit, exporter)
+}
+
+// Validate checks that the metric has a valid name, description, unit, and labels. It is
+// intended for tests that iterate over RegisteredMetrics.
+func (m RegisteredMetric) Validate() error {
+	if err := validateMetricName(m.Name); err != nil {
+		return err
+	}
+	if m.Config.Description == "" {
+		return fmt.Errorf("metric %s is missing a description", m.Name)
+	}
+	if !units.Known(m.Config.Unit) {
+		return fmt.Errorf("metric %s has unknown unit '%s'", m.Name, m.Config.Unit)
+	}
+	seen := make(map[string]bool, len(m.Config.Labels))
+	for _, l := range m.Config.Labels {
+		if err := validateMetricLabel(l); err != nil {
+			return fmt.Errorf("metric %s: %w", m.Name, err)
+		}
+		if seen[l.Name] {
+			return fmt.Errorf("metric %s has duplicate label '%s'", m.Name, l.Name)
+		}
+		seen[l.Name] = true
+	}
+	return nil
+}
+
+// ValidateRegisteredMetrics validates every metric returned by RegisteredMetrics and returns
+// all of the failures.
+func ValidateRegisteredMetrics() error {
+	var errs error
+	for _, m := range RegisteredMetrics() {
+		errs = multierr.Append(errs, m.Validate())
+	}
+	return errs
+}
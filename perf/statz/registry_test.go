@@ -0,0 +1,261 @@
+package statz
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"go.viam.com/test"
+
+	"go.viam.com/utils/perf/statz/statztest"
+	"go.viam.com/utils/perf/statz/units"
+)
+
+func TestRegisteredMetrics(t *testing.T) {
+	NewCounter1[string]("statz/test/registry_counter", MetricConfig{
+		Description: "A registered counter",
+		Unit:        units.Dimensionless,
+		Labels: []Label{
+			{Name: "label", Description: "A label."},
+		},
+	})
+
+	test.That(t, RegisteredMetricNames(), test.ShouldContain, "statz/test/registry_counter")
+
+	var found bool
+	for _, m := range RegisteredMetrics() {
+		if m.Name == "statz/test/registry_counter" {
+			found = true
+			test.That(t, m.Config.Description, test.ShouldEqual, "A registered counter")
+			test.That(t, m.Config.Labels, test.ShouldHaveLength, 1)
+		}
+	}
+	test.That(t, found, test.ShouldBeTrue)
+
+	test.That(t, ValidateRegisteredMetrics(), test.ShouldBeNil)
+}
+
+func TestRegisteredMetricValidate(t *testing.T) {
+	for _, tc := range []struct {
+		name   string
+		metric RegisteredMetric
+		valid  bool
+	}{
+		{"valid", RegisteredMetric{"statz/ok", MetricConfig{Description: "ok", Unit: units.Bytes}}, true},
+		{"no description", RegisteredMetric{"statz/ok", MetricConfig{Unit: units.Bytes}}, false},
+		{"unknown unit", RegisteredMetric{"statz/ok", MetricConfig{Description: "ok", Unit: "furlongs"}}, false},
+		{"bad label", RegisteredMetric{"statz/ok", MetricConfig{
+			Description: "ok",
+			Unit:        units.Bytes,
+			Labels:      []Label{{Name: ""}},
+		}}, false},
+		{"duplicate label", RegisteredMetric{"statz/ok", MetricConfig{
+			Description: "ok",
+			Unit:        units.Bytes,
+			Labels:      []Label{{Name: "a"}, {Name: "a"}},
+		}}, false},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			err := tc.metric.Validate()
+			if tc.valid {
+				test.That(t, err, test.ShouldBeNil)
+			} else {
+				test.That(t, err, test.ShouldNotBeNil)
+			}
+		})
+	}
+}
+
+func TestValidateMetricConfig(t *testing.T) {
+	valid := MetricConfig{Description: "ok", Unit: units.Bytes, Labels: []Label{{Name: "a"}}}
+	test.That(t, ValidateMetricConfig("statz/test/validate_config", valid), test.ShouldBeNil)
+	test.That(t, ValidateMetricConfig(strings.Repeat("a", maxNameLength+1), valid), test.ShouldNotBeNil)
+	test.That(t, ValidateMetricConfig("statz/test/validate_config", MetricConfig{Description: "ok", Unit: "furlongs"}), test.ShouldNotBeNil)
+	test.That(t, ValidateMetricConfig("statz/test/validate_config", MetricConfig{
+		Description: "ok",
+		Unit:        units.Bytes,
+		Labels:      []Label{{Name: "a"}, {Name: "a"}},
+	}), test.ShouldNotBeNil)
+
+	// Validating has no side effects so the metric can still be registered, and validated again after.
+	NewCounter1[string]("statz/test/validate_config", valid)
+	test.That(t, ValidateMetricConfig("statz/test/validate_config", valid), test.ShouldBeNil)
+}
+
+func TestRegistrationStats(t *testing.T) {
+	before := RegistrationStats()
+	test.That(t, before.BucketCounts, test.ShouldHaveLength, len(before.Bounds)+1)
+
+	NewCounter0("statz/test/registration_stats", MetricConfig{Description: "ok", Unit: units.Dimensionless})
+
+	after := RegistrationStats()
+	test.That(t, after.Count, test.ShouldEqual, before.Count+1)
+	test.That(t, after.Total, test.ShouldBeGreaterThan, before.Total)
+	test.That(t, after.Max, test.ShouldBeGreaterThanOrEqualTo, before.Max)
+	var added int64
+	for i, c := range after.BucketCounts {
+		added += c - before.BucketCounts[i]
+	}
+	test.That(t, added, test.ShouldEqual, 1)
+}
+
+func TestLenientRegistration(t *testing.T) {
+	SetLenientRegistration(true)
+	defer SetLenientRegistration(false)
+
+	cfg := MetricConfig{
+		Description: "A lenient counter",
+		Unit:        units.Dimensionless,
+	}
+	good := NewCounter0("statz/test/lenient_good", cfg)
+	dup := NewCounter0("statz/test/lenient_good", cfg)
+	bad := NewCounter0("statz/test/lenient_bad", MetricConfig{
+		Description: "A lenient counter",
+		Unit:        units.Dimensionless,
+		Labels:      []Label{{Name: ""}},
+	})
+
+	err := RegistrationErrors()
+	test.That(t, err, test.ShouldNotBeNil)
+	test.That(t, err.Error(), test.ShouldContainSubstring, "statz/test/lenient_good was already defined")
+	test.That(t, err.Error(), test.ShouldContainSubstring, "statz/test/lenient_bad")
+
+	// the good metric still works and the failed ones are no-ops.
+	recorder := statztest.NewCounterRecorder("statz/test/lenient_good")
+	good.Inc()
+	dup.Inc()
+	bad.Inc()
+	test.That(t, recorder.Value(), test.ShouldEqual, 1)
+	test.That(t, RegisteredMetricNames(), test.ShouldNotContain, "statz/test/lenient_bad")
+}
+
+func TestMetricCardinality(t *testing.T) {
+	counter := NewCounter2[string, bool]("statz/test/cardinality_counter", MetricConfig{
+		Description: "A counter",
+		Unit:        units.Dimensionless,
+		Labels: []Label{
+			{Name: "label", Description: "A label."},
+			{Name: "bool", Description: "Other label."},
+		},
+	})
+
+	test.That(t, MetricCardinality("statz/test/cardinality_counter"), test.ShouldEqual, 0)
+
+	counter.Inc("a", true)
+	counter.Inc("a", true)
+	counter.Inc("a", false)
+	counter.Inc("b", true)
+	test.That(t, MetricCardinality("statz/test/cardinality_counter"), test.ShouldEqual, 3)
+
+	test.That(t, MetricCardinality("statz/test/cardinality_unknown"), test.ShouldEqual, 0)
+}
+
+func TestMetricConfigFor(t *testing.T) {
+	NewCounter1[string]("statz/test/config_for_counter", MetricConfig{
+		Description: "A counter",
+		Unit:        units.Dimensionless,
+		Labels: []Label{
+			{Name: "type", Description: "A type.", Values: []string{"a", "b"}},
+		},
+		Aliases: []string{"statz/test/config_for_counter_old"},
+	})
+
+	cfg, ok := MetricConfigFor("statz/test/config_for_counter")
+	test.That(t, ok, test.ShouldBeTrue)
+	test.That(t, cfg.Description, test.ShouldEqual, "A counter")
+	test.That(t, cfg.Unit, test.ShouldEqual, units.Dimensionless)
+	test.That(t, cfg.Labels, test.ShouldResemble, []Label{
+		{Name: "type", Description: "A type.", Values: []string{"a", "b"}},
+	})
+
+	// the returned config is a copy.
+	cfg.Labels[0].Values[0] = "c"
+	cfg.Aliases[0] = "statz/test/other"
+	cfg, ok = MetricConfigFor("statz/test/config_for_counter_old")
+	test.That(t, ok, test.ShouldBeTrue)
+	test.That(t, cfg.Labels[0].Values, test.ShouldResemble, []string{"a", "b"})
+	test.That(t, cfg.Aliases, test.ShouldResemble, []string{"statz/test/config_for_counter_old"})
+
+	_, ok = MetricConfigFor("statz/test/config_for_unknown")
+	test.That(t, ok, test.ShouldBeFalse)
+}
+
+func TestLastUpdated(t *testing.T) {
+	counter := NewCounter1[string]("statz/test/last_updated_counter", MetricConfig{
+		Description: "A counter",
+		Unit:        units.Dimensionless,
+		Labels: []Label{
+			{Name: "region", Description: "A region."},
+		},
+		TrackLastUpdated: true,
+	})
+	distribution := NewDistribution0("statz/test/last_updated_distribution", MetricConfig{
+		Description:      "A distribution",
+		Unit:             units.Milliseconds,
+		TrackLastUpdated: true,
+	}, Distribution{})
+	untracked := NewCounter0("statz/test/last_updated_untracked_counter", MetricConfig{
+		Description: "A counter",
+		Unit:        units.Dimensionless,
+	})
+
+	test.That(t, counter.LastUpdated("us-east").IsZero(), test.ShouldBeTrue)
+	test.That(t, distribution.LastUpdated().IsZero(), test.ShouldBeTrue)
+
+	before := time.Now()
+	counter.Inc("us-east")
+	counter.Inc("us-west")
+	distribution.Observe(1)
+	east := counter.LastUpdated("us-east")
+	test.That(t, east.Before(before), test.ShouldBeFalse)
+	test.That(t, distribution.LastUpdated().Before(before), test.ShouldBeFalse)
+
+	// only the series that is still recorded moves forward.
+	time.Sleep(10 * time.Millisecond)
+	counter.Bind("us-west").Inc()
+	test.That(t, counter.LastUpdated("us-east"), test.ShouldEqual, east)
+	test.That(t, counter.LastUpdated("us-west").After(east), test.ShouldBeTrue)
+	test.That(t, counter.LastUpdated("eu-central").IsZero(), test.ShouldBeTrue)
+
+	// last updates are only tracked when asked for.
+	untracked.Inc()
+	test.That(t, untracked.LastUpdated().IsZero(), test.ShouldBeTrue)
+	test.That(t, MetricCardinality("statz/test/last_updated_untracked_counter"), test.ShouldEqual, 1)
+}
+
+func TestMergeLabels(t *testing.T) {
+	method := Label{Name: "method", Description: "The method."}
+	code := Label{Name: "code", Description: "The status code."}
+	region := Label{Name: "region", Description: "The region."}
+
+	merged, err := MergeLabels([]Label{method, code}, []Label{region, method}, nil)
+	test.That(t, err, test.ShouldBeNil)
+	test.That(t, merged, test.ShouldResemble, []Label{method, code, region})
+
+	merged, err = MergeLabels()
+	test.That(t, err, test.ShouldBeNil)
+	test.That(t, merged, test.ShouldBeEmpty)
+
+	_, err = MergeLabels([]Label{method}, []Label{{Name: "method", Description: "Something else."}})
+	test.That(t, err, test.ShouldNotBeNil)
+	test.That(t, err.Error(), test.ShouldContainSubstring, `label "method" has conflicting descriptions`)
+}
+
+func TestRequireNamespacedNames(t *testing.T) {
+	test.That(t, validateMetricName("requests"), test.ShouldBeNil)
+
+	SetRequireNamespacedNames(true)
+	defer SetRequireNamespacedNames(false)
+
+	test.That(t, validateMetricName("datasync/uploaded"), test.ShouldBeNil)
+	test.That(t, validateMetricName("rpc/auth/interceptor_requests"), test.ShouldBeNil)
+	for _, name := range []string{"requests", "/requests", "requests/"} {
+		err := validateMetricName(name)
+		test.That(t, err, test.ShouldNotBeNil)
+		test.That(t, err.Error(), test.ShouldContainSubstring, "must have a namespace")
+	}
+
+	test.That(t, func() {
+		NewCounter0("statz_test_flat_counter", MetricConfig{Description: "A flat counter", Unit: units.Dimensionless})
+	}, test.ShouldPanic)
+}
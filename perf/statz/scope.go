@@ -0,0 +1,204 @@
+package statz
+
+import (
+	"context"
+	"fmt"
+	"sync"
+
+	"github.com/edaniels/golog"
+	"go.opencensus.io/stats/view"
+
+	"go.viam.com/utils/perf/statz/units"
+)
+
+// ScopeLabel is a label of a Scope along with the value its metrics are recorded with.
+type ScopeLabel struct {
+	Label
+	Value string
+}
+
+// Scope vends counters, gauges, and distributions for a cohesive set of metrics that share a
+// namespace and label values. Its metrics are named "<namespace>/<name>" and are recorded with
+// the label values of the scope, so callers only pass the values being recorded. Scopes with the
+// same namespace and label names share their metrics, e.g. one scope per tenant.
+//
+// Example:
+//
+//	scope := statz.NewScope("datasync", statz.ScopeLabel{
+//		Label: statz.Label{Name: "type", Description: "The data type (file|binary|tabular)."},
+//		Value: "binary",
+//	})
+//	uploads := scope.Counter("uploads", "The number of uploads")
+//	uploads.Inc()
+type Scope struct {
+	namespace string
+	labels    []Label
+	values    []string
+}
+
+// NewScope creates a scope of metrics under namespace recorded with the given labels.
+func NewScope(namespace string, labels ...ScopeLabel) Scope {
+	scope := Scope{
+		namespace: namespace,
+		labels:    make([]Label, 0, len(labels)),
+		values:    make([]string, 0, len(labels)),
+	}
+	for _, l := range labels {
+		scope.labels = append(scope.labels, l.Label)
+		scope.values = append(scope.values, l.Value)
+	}
+	return scope
+}
+
+// Counter returns the counter "<namespace>/<name>" bound to the label values of the scope.
+func (s Scope) Counter(name, description string) BoundCounter {
+	m := s.metric(name, scopedCounter, func(fullName string, labels []Label) scopedMetric {
+		return scopedMetric{counter: createCounterWrapper(fullName, MetricConfig{
+			Description: description,
+			Unit:        units.Dimensionless,
+			Labels:      labels,
+		})}
+	})
+	if m.counter == nil {
+		m.counter = &ocCounterWrapper{data: disabledScopedData(s.fullName(name))}
+	}
+	return m.counter.bind(s.values)
+}
+
+// ScopedGauge is a gauge of a Scope.
+type ScopedGauge struct {
+	wrapper *ocGaugeWrapper
+	labels  []string
+}
+
+// Set sets the gauge to v.
+func (g ScopedGauge) Set(v int64) {
+	g.wrapper.set(context.Background(), g.labels, v)
+}
+
+// Gauge returns the gauge "<namespace>/<name>" recorded with the label values of the scope.
+func (s Scope) Gauge(name, description string, unit units.Unit) ScopedGauge {
+	m := s.metric(name, scopedGauge, func(fullName string, labels []Label) scopedMetric {
+		return scopedMetric{gauge: createGaugeWrapper(fullName, MetricConfig{
+			Description: description,
+			Unit:        unit,
+			Labels:      labels,
+		})}
+	})
+	if m.gauge == nil {
+		m.gauge = &ocGaugeWrapper{data: disabledScopedData(s.fullName(name))}
+	}
+	return ScopedGauge{wrapper: m.gauge, labels: s.values}
+}
+
+// ScopedDistribution is a distribution of a Scope.
+type ScopedDistribution struct {
+	wrapper *ocDistributionWrapper
+	labels  []string
+}
+
+// Observe records an observation of the metric.
+func (d ScopedDistribution) Observe(v float64) {
+	d.wrapper.observe(context.Background(), d.labels, v)
+}
+
+// Distribution returns the distribution "<namespace>/<name>" recorded with the label values of
+// the scope. An empty distribution uses the defaults of the unit.
+func (s Scope) Distribution(name, description string, unit units.Unit, distribution Distribution) ScopedDistribution {
+	m := s.metric(name, scopedDistribution, func(fullName string, labels []Label) scopedMetric {
+		return scopedMetric{distribution: createocDistributionWrapper(fullName, distribution, MetricConfig{
+			Description: description,
+			Unit:        unit,
+			Labels:      labels,
+		})}
+	})
+	if m.distribution == nil {
+		m.distribution = &ocDistributionWrapper{data: disabledScopedData(s.fullName(name))}
+	}
+	return ScopedDistribution{wrapper: m.distribution, labels: s.values}
+}
+
+///// internal
+
+type scopedKind string
+
+const (
+	scopedCounter      scopedKind = "counter"
+	scopedGauge        scopedKind = "gauge"
+	scopedDistribution scopedKind = "distribution"
+)
+
+// scopedMetric is a metric vended by a Scope; only the wrapper of its kind is set.
+type scopedMetric struct {
+	kind         scopedKind
+	labels       []Label
+	counter      *ocCounterWrapper
+	gauge        *ocGaugeWrapper
+	distribution *ocDistributionWrapper
+}
+
+// scopedMetrics holds the metrics vended by every Scope by name so that scopes with the same
+// namespace share them instead of registering them again.
+var scopedMetrics = struct {
+	mu     sync.Mutex
+	byName map[string]scopedMetric
+}{byName: map[string]scopedMetric{}}
+
+func (s Scope) fullName(name string) string {
+	return s.namespace + "/" + name
+}
+
+// metric returns the metric of the scope with the given name, creating it if no scope has yet.
+// A metric that exists as another kind or with other labels fails registration, in which case
+// the returned metric has no wrapper.
+func (s Scope) metric(
+	name string, kind scopedKind, create func(fullName string, labels []Label) scopedMetric,
+) scopedMetric {
+	fullName := s.fullName(name)
+
+	scopedMetrics.mu.Lock()
+	defer scopedMetrics.mu.Unlock()
+	if m, ok := scopedMetrics.byName[fullName]; ok {
+		if m.kind == kind && sameLabelNames(m.labels, s.labels) {
+			return m
+		}
+		err := fmt.Errorf("metric %s is already a %s with labels %v", fullName, m.kind, labelNames(m.labels))
+		if !lenientRegistration() {
+			golog.Global().Panicf("Failed to register %s", err)
+			return scopedMetric{}
+		}
+		addRegistrationError(err)
+		golog.Global().Errorw("metric registration failed, it will not be recorded", "metric", fullName, "error", err)
+		return scopedMetric{}
+	}
+
+	m := create(fullName, s.labels)
+	m.kind = kind
+	m.labels = s.labels
+	scopedMetrics.byName[fullName] = m
+	return m
+}
+
+func disabledScopedData(name string) *opencensusStatsData {
+	return &opencensusStatsData{View: &view.View{Name: name}, disabled: true}
+}
+
+func sameLabelNames(a, b []Label) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if a[i].Name != b[i].Name {
+			return false
+		}
+	}
+	return true
+}
+
+func labelNames(labels []Label) []string {
+	names := make([]string, 0, len(labels))
+	for _, l := range labels {
+		names = append(names, l.Name)
+	}
+	return names
+}
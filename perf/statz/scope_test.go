@@ -0,0 +1,52 @@
+package statz
+
+import (
+	"testing"
+
+	"go.viam.com/test"
+
+	"go.viam.com/utils/perf/statz/statztest"
+	"go.viam.com/utils/perf/statz/units"
+)
+
+func TestScope(t *testing.T) {
+	tenantLabel := Label{Name: "tenant", Description: "The tenant of the request."}
+	scopeA := NewScope("statz/test/scope", ScopeLabel{Label: tenantLabel, Value: "a"})
+	scopeB := NewScope("statz/test/scope", ScopeLabel{Label: tenantLabel, Value: "b"})
+
+	counterRecorder := statztest.NewCounterRecorder("statz/test/scope/requests")
+	gaugeRecorder := statztest.NewGaugeRecorder("statz/test/scope/in_flight")
+	distributionRecorder := statztest.NewDistributionRecorder("statz/test/scope/latency")
+
+	requestsA := scopeA.Counter("requests", "The number of requests")
+	requestsA.Inc()
+	requestsA.IncBy(2)
+	// scopes of the same namespace share their metrics.
+	scopeB.Counter("requests", "The number of requests").Inc()
+	test.That(t, counterRecorder.Value("tenant", "a"), test.ShouldEqual, 3)
+	test.That(t, counterRecorder.Value("tenant", "b"), test.ShouldEqual, 1)
+
+	scopeA.Gauge("in_flight", "The number of requests in flight", units.Dimensionless).Set(4)
+	test.That(t, gaugeRecorder.Value("tenant", "a"), test.ShouldEqual, 4)
+
+	latency := scopeB.Distribution("latency", "The latency of requests", units.Milliseconds, Distribution{})
+	latency.Observe(10)
+	latency.Observe(20)
+	test.That(t, distributionRecorder.Value("tenant", "b").Count, test.ShouldEqual, 2)
+	test.That(t, distributionRecorder.Value("tenant", "b").Sum, test.ShouldEqual, 30)
+
+	test.That(t, func() {
+		scopeA.Gauge("requests", "The number of requests", units.Dimensionless)
+	}, test.ShouldPanic)
+	test.That(t, func() {
+		NewScope("statz/test/scope").Counter("requests", "The number of requests")
+	}, test.ShouldPanic)
+
+	SetLenientRegistration(true)
+	defer SetLenientRegistration(false)
+	NewScope("statz/test/scope").Counter("requests", "The number of requests").Inc()
+	err := RegistrationErrors()
+	test.That(t, err, test.ShouldNotBeNil)
+	test.That(t, err.Error(), test.ShouldContainSubstring, "statz/test/scope/requests is already a counter with labels [tenant]")
+	test.That(t, counterRecorder.Value(), test.ShouldEqual, 0)
+}
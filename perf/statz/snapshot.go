@@ -0,0 +1,132 @@
+package statz
+
+import (
+	"encoding/json"
+	"sort"
+	"strings"
+
+	"go.opencensus.io/stats/view"
+)
+
+// metricSnapshot is the JSON form of the current values of a metric.
+type metricSnapshot struct {
+	Name        string        `json:"name"`
+	Type        string        `json:"type"`
+	Description string        `json:"description"`
+	Unit        string        `json:"unit"`
+	Bounds      []float64     `json:"bounds,omitempty"`
+	Rows        []rowSnapshot `json:"rows"`
+}
+
+// rowSnapshot is the JSON form of the value of one combination of label values of a metric.
+// Value is set for counters and gauges while Count, Sum, and BucketCounts are set for distributions.
+type rowSnapshot struct {
+	Labels       map[string]string `json:"labels"`
+	Value        *float64          `json:"value,omitempty"`
+	Count        *int64            `json:"count,omitempty"`
+	Sum          *float64          `json:"sum,omitempty"`
+	BucketCounts []int64           `json:"bucket_counts,omitempty"`
+}
+
+// SnapshotJSON returns the current values of every registered metric as JSON, sorted by name.
+// Each metric has its type (counter, gauge, or distribution), description, unit, and a row per
+// combination of label values recorded so far. It works without any exporter configured, which
+// makes it useful for debugging and debug endpoints. Metrics skipped by lenient registration
+// are left out.
+func SnapshotJSON() ([]byte, error) {
+	registry.mu.Lock()
+	views := make([]*view.View, 0, len(registry.data))
+	for _, data := range registry.data {
+		if data.disabled {
+			continue
+		}
+		views = append(views, data.View)
+	}
+	registry.mu.Unlock()
+
+	sort.Slice(views, func(i, j int) bool {
+		return views[i].Name < views[j].Name
+	})
+
+	snapshots := make([]metricSnapshot, 0, len(views))
+	for _, v := range views {
+		rows, err := view.RetrieveData(v.Name)
+		if err != nil {
+			return nil, err
+		}
+		snapshot := metricSnapshot{
+			Name:        v.Name,
+			Type:        snapshotType(v.Aggregation.Type),
+			Description: v.Description,
+			Unit:        v.Measure.Unit(),
+			Rows:        make([]rowSnapshot, 0, len(rows)),
+		}
+		if v.Aggregation.Type == view.AggTypeDistribution {
+			snapshot.Bounds = v.Aggregation.Buckets
+		}
+		for _, row := range rows {
+			snapshot.Rows = append(snapshot.Rows, snapshotRow(row))
+		}
+		sort.Slice(snapshot.Rows, func(i, j int) bool {
+			return labelsKey(snapshot.Rows[i].Labels) < labelsKey(snapshot.Rows[j].Labels)
+		})
+		snapshots = append(snapshots, snapshot)
+	}
+	return json.Marshal(snapshots)
+}
+
+func snapshotType(aggType view.AggType) string {
+	switch aggType {
+	case view.AggTypeCount, view.AggTypeSum:
+		return "counter"
+	case view.AggTypeLastValue:
+		return "gauge"
+	case view.AggTypeDistribution:
+		return "distribution"
+	case view.AggTypeNone:
+		fallthrough
+	default:
+		return "unknown"
+	}
+}
+
+func snapshotRow(row *view.Row) rowSnapshot {
+	snapshot := rowSnapshot{Labels: make(map[string]string, len(row.Tags))}
+	for _, t := range row.Tags {
+		snapshot.Labels[t.Key.Name()] = t.Value
+	}
+	switch data := row.Data.(type) {
+	case *view.CountData:
+		value := float64(data.Value)
+		snapshot.Value = &value
+	case *view.SumData:
+		value := data.Value
+		snapshot.Value = &value
+	case *view.LastValueData:
+		value := data.Value
+		snapshot.Value = &value
+	case *view.DistributionData:
+		count, sum := data.Count, data.Sum()
+		snapshot.Count = &count
+		snapshot.Sum = &sum
+		snapshot.BucketCounts = data.CountPerBucket
+	}
+	return snapshot
+}
+
+// labelsKey returns a string that orders rows by their sorted label values.
+func labelsKey(labels map[string]string) string {
+	keys := make([]string, 0, len(labels))
+	for k := range labels {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	var sb strings.Builder
+	for _, k := range keys {
+		sb.WriteString(k)
+		sb.WriteByte('=')
+		sb.WriteString(labels[k])
+		sb.WriteByte(0)
+	}
+	return sb.String()
+}
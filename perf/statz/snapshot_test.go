@@ -0,0 +1,57 @@
+package statz
+
+import (
+	"encoding/json"
+	"testing"
+
+	"go.viam.com/test"
+
+	"go.viam.com/utils/perf/statz/units"
+)
+
+func TestSnapshotJSON(t *testing.T) {
+	counter := NewCounter1[string]("statz/test/snapshot_counter", MetricConfig{
+		Description: "A snapshot counter",
+		Unit:        units.Dimensionless,
+		Labels: []Label{
+			{Name: "label", Description: "A label."},
+		},
+	})
+	distribution := NewDistribution0("statz/test/snapshot_distribution", MetricConfig{
+		Description: "A snapshot distribution",
+		Unit:        units.Milliseconds,
+	}, DistributionFromBounds(10, 100))
+
+	counter.Inc("b")
+	counter.IncBy("a", 2)
+	distribution.Observe(5)
+	distribution.Observe(50)
+
+	data, err := SnapshotJSON()
+	test.That(t, err, test.ShouldBeNil)
+
+	var snapshots []metricSnapshot
+	test.That(t, json.Unmarshal(data, &snapshots), test.ShouldBeNil)
+	byName := make(map[string]metricSnapshot, len(snapshots))
+	for _, s := range snapshots {
+		byName[s.Name] = s
+	}
+
+	counterSnapshot := byName["statz/test/snapshot_counter"]
+	test.That(t, counterSnapshot.Type, test.ShouldEqual, "counter")
+	test.That(t, counterSnapshot.Description, test.ShouldEqual, "A snapshot counter")
+	test.That(t, counterSnapshot.Unit, test.ShouldEqual, string(units.Dimensionless))
+	test.That(t, counterSnapshot.Rows, test.ShouldHaveLength, 2)
+	test.That(t, counterSnapshot.Rows[0].Labels, test.ShouldResemble, map[string]string{"label": "a"})
+	test.That(t, *counterSnapshot.Rows[0].Value, test.ShouldEqual, 2)
+	test.That(t, counterSnapshot.Rows[1].Labels, test.ShouldResemble, map[string]string{"label": "b"})
+	test.That(t, *counterSnapshot.Rows[1].Value, test.ShouldEqual, 1)
+
+	distributionSnapshot := byName["statz/test/snapshot_distribution"]
+	test.That(t, distributionSnapshot.Type, test.ShouldEqual, "distribution")
+	test.That(t, distributionSnapshot.Bounds, test.ShouldResemble, []float64{10, 100})
+	test.That(t, distributionSnapshot.Rows, test.ShouldHaveLength, 1)
+	test.That(t, *distributionSnapshot.Rows[0].Count, test.ShouldEqual, 2)
+	test.That(t, *distributionSnapshot.Rows[0].Sum, test.ShouldEqual, 55)
+	test.That(t, distributionSnapshot.Rows[0].BucketCounts, test.ShouldResemble, []int64{1, 1, 0})
+}
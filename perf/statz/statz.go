@@ -4,6 +4,8 @@ package statz
 import (
 	"fmt"
 	"regexp"
+	"strings"
+	"time"
 
 	"github.com/edaniels/golog"
 	"go.opencensus.io/stats"
@@ -18,6 +20,38 @@ type MetricConfig struct {
 	Description string
 	Unit        units.Unit
 	Labels      []Label
+	// BatchInterval, if set on a counter, accumulates increments in memory and records them
+	// every BatchInterval (or on FlushBatchedCounters) instead of on each increment. This
+	// lowers contention on hot counters at the cost of reporting delay. Other metrics ignore it.
+	BatchInterval time.Duration
+	// InitializeToZero, if set on a counter, records a zero for every combination of label values
+	// when it is registered so that its series exist before the first increment, avoiding gaps
+	// and flaky "no data" alerts. Every label must be a bool or enumerate its Label.Values.
+	// Other metrics ignore it.
+	InitializeToZero bool
+	// TrackLastUpdated records when each combination of label values was last recorded, for up
+	// to 10000 combinations, so that stale series can be found with LastUpdated. It costs an
+	// update per recording so it is off by default and LastUpdated reports the zero time.
+	TrackLastUpdated bool
+	// Aliases are other names the metric is also exported under, such as its names before a
+	// rename, so that dashboards and alerts keep working during a migration. Every recording is
+	// exported once per name, which multiplies the cost of the metric; drop the aliases once
+	// migrated by removing them here or at runtime with DropMetricAlias.
+	Aliases []string
+}
+
+// clone returns a copy of the config that shares no slices with it.
+func (cfg MetricConfig) clone() MetricConfig {
+	if cfg.Labels != nil {
+		labels := make([]Label, len(cfg.Labels))
+		for i, l := range cfg.Labels {
+			l.Values = append([]string(nil), l.Values...)
+			labels[i] = l
+		}
+		cfg.Labels = labels
+	}
+	cfg.Aliases = append([]string(nil), cfg.Aliases...)
+	return cfg
 }
 
 const (
@@ -54,21 +88,21 @@ func NewCounter0(name string, cfg MetricConfig) Counter0 {
 // NewCounter1 creates a new counter metric with 1 labels.
 func NewCounter1[T1 labelContraint](name string, cfg MetricConfig) Counter1[T1] {
 	return Counter1[T1]{
-		wrapper: createCounterWrapper(name, cfg),
+		wrapper: createCounterWrapper(name, cfg, labelDomain[T1]()),
 	}
 }
 
 // NewCounter2 creates a new counter metric with 2 labels.
 func NewCounter2[T1, T2 labelContraint](name string, cfg MetricConfig) Counter2[T1, T2] {
 	return Counter2[T1, T2]{
-		wrapper: createCounterWrapper(name, cfg),
+		wrapper: createCounterWrapper(name, cfg, labelDomain[T1](), labelDomain[T2]()),
 	}
 }
 
 // NewCounter3 creates a new counter metric with 3 labels.
 func NewCounter3[T1, T2, T3 labelContraint](name string, cfg MetricConfig) Counter3[T1, T2, T3] {
 	return Counter3[T1, T2, T3]{
-		wrapper: createCounterWrapper(name, cfg),
+		wrapper: createCounterWrapper(name, cfg, labelDomain[T1](), labelDomain[T2](), labelDomain[T3]()),
 	}
 }
 
@@ -77,7 +111,109 @@ func NewCounter4[T1, T2 labelContraint,
 	T3, T4 labelContraint](name string, cfg MetricConfig,
 ) Counter4[T1, T2, T3, T4] {
 	return Counter4[T1, T2, T3, T4]{
-		wrapper: createCounterWrapper(name, cfg),
+		wrapper: createCounterWrapper(name, cfg, labelDomain[T1](), labelDomain[T2](), labelDomain[T3](), labelDomain[T4]()),
+	}
+}
+
+//// Int64 Gauge - Create a gauge at the package level.
+//
+// var queueDepth = statz.NewGauge1[string]("datasync/queue_depth", statz.MetricConfig{
+// 		Description: "The number of uploads waiting to be sent",
+// 		Unit:        units.Dimensionless,
+// 		Labels: []statz.Label{
+// 			{Name: "type", Description: "The data type (file|binary|tabular)."},
+// 		},
+//  })
+//
+// Usage:
+// queueDepth.Set(12, “uploadType”)
+//
+
+// NewGauge0 creates a new gauge metric with 0 labels.
+func NewGauge0(name string, cfg MetricConfig) Gauge0 {
+	return Gauge0{
+		wrapper: createGaugeWrapper(name, cfg),
+	}
+}
+
+// NewGauge1 creates a new gauge metric with 1 label.
+func NewGauge1[T1 labelContraint](name string, cfg MetricConfig) Gauge1[T1] {
+	return Gauge1[T1]{
+		wrapper: createGaugeWrapper(name, cfg),
+	}
+}
+
+// NewGauge2 creates a new gauge metric with 2 labels.
+func NewGauge2[T1, T2 labelContraint](name string, cfg MetricConfig) Gauge2[T1, T2] {
+	return Gauge2[T1, T2]{
+		wrapper: createGaugeWrapper(name, cfg),
+	}
+}
+
+// NewGauge3 creates a new gauge metric with 3 labels.
+func NewGauge3[T1, T2, T3 labelContraint](name string, cfg MetricConfig) Gauge3[T1, T2, T3] {
+	return Gauge3[T1, T2, T3]{
+		wrapper: createGaugeWrapper(name, cfg),
+	}
+}
+
+// NewGauge4 creates a new gauge metric with 4 labels.
+func NewGauge4[T1, T2, T3, T4 labelContraint](name string, cfg MetricConfig) Gauge4[T1, T2, T3, T4] {
+	return Gauge4[T1, T2, T3, T4]{
+		wrapper: createGaugeWrapper(name, cfg),
+	}
+}
+
+//// Int64 Up Down Counter - Create an up down counter at the package level.
+//
+// var openConnections = statz.NewUpDownCounter1[string]("datasync/connections", statz.MetricConfig{
+// 		Description: "The number of connections",
+// 		Unit:        units.Dimensionless,
+// 		Labels: []statz.Label{
+// 			{Name: "type", Description: "The data type (file|binary|tabular)."},
+// 		},
+//  })
+//
+// Usage:
+// openConnections.Inc(“uploadType”)
+// openConnections.Dec(“uploadType”)
+//
+// It is exported as the counters "datasync/connections/up" and "datasync/connections/down";
+// their difference is the number of open connections.
+//
+
+// NewUpDownCounter0 creates a new up down counter metric with 0 labels.
+func NewUpDownCounter0(name string, cfg MetricConfig) UpDownCounter0 {
+	return UpDownCounter0{
+		wrapper: createUpDownCounterWrapper(name, cfg),
+	}
+}
+
+// NewUpDownCounter1 creates a new up down counter metric with 1 label.
+func NewUpDownCounter1[T1 labelContraint](name string, cfg MetricConfig) UpDownCounter1[T1] {
+	return UpDownCounter1[T1]{
+		wrapper: createUpDownCounterWrapper(name, cfg, labelDomain[T1]()),
+	}
+}
+
+// NewUpDownCounter2 creates a new up down counter metric with 2 labels.
+func NewUpDownCounter2[T1, T2 labelContraint](name string, cfg MetricConfig) UpDownCounter2[T1, T2] {
+	return UpDownCounter2[T1, T2]{
+		wrapper: createUpDownCounterWrapper(name, cfg, labelDomain[T1](), labelDomain[T2]()),
+	}
+}
+
+// NewUpDownCounter3 creates a new up down counter metric with 3 labels.
+func NewUpDownCounter3[T1, T2, T3 labelContraint](name string, cfg MetricConfig) UpDownCounter3[T1, T2, T3] {
+	return UpDownCounter3[T1, T2, T3]{
+		wrapper: createUpDownCounterWrapper(name, cfg, labelDomain[T1](), labelDomain[T2](), labelDomain[T3]()),
+	}
+}
+
+// NewUpDownCounter4 creates a new up down counter metric with 4 labels.
+func NewUpDownCounter4[T1, T2, T3, T4 labelContraint](name string, cfg MetricConfig) UpDownCounter4[T1, T2, T3, T4] {
+	return UpDownCounter4[T1, T2, T3, T4]{
+		wrapper: createUpDownCounterWrapper(name, cfg, labelDomain[T1](), labelDomain[T2](), labelDomain[T3](), labelDomain[T4]()),
 	}
 }
 
@@ -137,22 +273,123 @@ func NewDistribution4[T1, T2, T3, T4 labelContraint](name string,
 	}
 }
 
+// NewDistributionN creates a new distribution metric whose label values are provided as a map
+// at observation time. The labels are the ones declared in the MetricConfig. An empty
+// distribution uses the DefaultBucketsForUnit of cfg.Unit.
+func NewDistributionN(name string, cfg MetricConfig, distribution Distribution) DistributionN {
+	return DistributionN{
+		wrapper: createocDistributionWrapper(name, distribution, cfg),
+	}
+}
+
 func createAndRegisterOpenCensusMetric(name string, measure stats.Measure, agg *view.Aggregation, cfg MetricConfig) *opencensusStatsData {
+	start := time.Now()
+	defer func() {
+		recordRegistrationLatency(time.Since(start))
+	}()
+
+	if lenientRegistration() {
+		ocData, err := tryCreateAndRegisterOpenCensusMetric(name, measure, agg, cfg)
+		if err != nil {
+			addRegistrationError(err)
+			golog.Global().Errorw("metric registration failed, it will not be recorded", "metric", name, "error", err)
+			return &opencensusStatsData{View: &view.View{Name: name}, disabled: true}
+		}
+		if err := registerMetricAliases(ocData, cfg.Aliases); err != nil {
+			addRegistrationError(err)
+			golog.Global().Errorw("metric alias registration failed, it will not be recorded", "metric", name, "error", err)
+		}
+		return ocData
+	}
+
 	// Register with statz global
 	internal.RegisterMetric(name)
 
-	if err := validateMetricName(name); err != nil {
-		golog.Global().Panicf("Failed to register metric name not valid: %s", err)
+	if err := exporterUnitsError(name, cfg.Unit); err != nil {
+		golog.Global().Panicf("Failed to register %s", err)
+		return nil
+	}
+
+	if err := validateMetricConfig(name, cfg); err != nil {
+		golog.Global().Panicf("Failed to register %s", err)
 		return nil
 	}
 
+	ocData := newOpenCensusStatsData(name, measure, agg, cfg)
+
+	// Register the views it is imperative that this step exists
+	if err := view.Register(ocData.View); err != nil {
+		golog.Global().Fatalf("Failed to register the views: %v", err)
+	}
+
+	addRegisteredMetric(name, cfg, ocData)
+	if err := registerMetricAliases(ocData, cfg.Aliases); err != nil {
+		golog.Global().Panicf("Failed to register %s", err)
+	}
+	return ocData
+}
+
+// tryCreateAndRegisterOpenCensusMetric is like createAndRegisterOpenCensusMetric but returns any failure.
+func tryCreateAndRegisterOpenCensusMetric(
+	name string, measure stats.Measure, agg *view.Aggregation, cfg MetricConfig,
+) (*opencensusStatsData, error) {
+	if err := internal.TryRegisterMetric(name); err != nil {
+		return nil, err
+	}
+
+	if err := exporterUnitsError(name, cfg.Unit); err != nil {
+		return nil, err
+	}
+
+	if err := validateMetricConfig(name, cfg); err != nil {
+		return nil, err
+	}
+
+	ocData := newOpenCensusStatsData(name, measure, agg, cfg)
+	if err := view.Register(ocData.View); err != nil {
+		return nil, fmt.Errorf("failed to register the view for metric %s: %w", name, err)
+	}
+
+	addRegisteredMetric(name, cfg, ocData)
+	return ocData, nil
+}
+
+// ValidateMetricConfig checks the name and config of a metric as its registration would, along
+// with the description, unit, and label checks of RegisteredMetric.Validate, without
+// registering anything. Since it has no side effects it does not detect a name that is
+// already registered. It is intended for tests or linting metric configs ahead of time.
+func ValidateMetricConfig(name string, cfg MetricConfig) error {
+	if err := validateMetricConfig(name, cfg); err != nil {
+		return err
+	}
+	return RegisteredMetric{Name: name, Config: cfg}.Validate()
+}
+
+func validateMetricConfig(name string, cfg MetricConfig) error {
+	if err := validateMetricName(name); err != nil {
+		return fmt.Errorf("metric name not valid: %w", err)
+	}
+
 	for _, l := range cfg.Labels {
 		if err := validateMetricLabel(l); err != nil {
-			golog.Global().Panicf("Failed to register metric label not valid: %s", err)
-			return nil
+			return fmt.Errorf("metric %s label not valid: %w", name, err)
+		}
+	}
+
+	seenAliases := make(map[string]bool, len(cfg.Aliases))
+	for _, alias := range cfg.Aliases {
+		if err := validateMetricName(alias); err != nil {
+			return fmt.Errorf("metric %s alias not valid: %w", name, err)
+		}
+		if alias == name || seenAliases[alias] {
+			return fmt.Errorf("metric %s has duplicate alias '%s'", name, alias)
 		}
+		seenAliases[alias] = true
 	}
+	return nil
+}
 
+func newOpenCensusStatsData(name string, measure stats.Measure, agg *view.Aggregation, cfg MetricConfig) *opencensusStatsData {
 	tagKeys := tagKeysFromConfig(&cfg)
 
 	// We do this twice to ensure the ordering of the key
@@ -160,7 +397,18 @@ func createAndRegisterOpenCensusMetric(name string, measure stats.Measure, agg *
 	// seems to reorder the TagKeys and we cannot reliably use it.
 	tagKeysForLabels := tagKeysFromConfig(&cfg)
 
-	ocData := &opencensusStatsData{
+	var labelEncoders map[string]func(v interface{}) string
+	for _, l := range cfg.Labels {
+		if l.Encode == nil {
+			continue
+		}
+		if labelEncoders == nil {
+			labelEncoders = map[string]func(v interface{}) string{}
+		}
+		labelEncoders[l.Name] = l.Encode
+	}
+
+	return &opencensusStatsData{
 		View: &view.View{
 			Name:        name,
 			Measure:     measure,
@@ -168,15 +416,10 @@ func createAndRegisterOpenCensusMetric(name string, measure stats.Measure, agg *
 			Aggregation: agg,
 			TagKeys:     tagKeys,
 		},
-		labelKeys: tagKeysForLabels,
-	}
-
-	// Register the views it is imperative that this step exists
-	if err := view.Register(ocData.View); err != nil {
-		golog.Global().Fatalf("Failed to register the views: %v", err)
+		labelKeys:        tagKeysForLabels,
+		labelEncoders:    labelEncoders,
+		trackLastUpdated: cfg.TrackLastUpdated,
 	}
-
-	return ocData
 }
 
 func validateMetricName(name string) error {
@@ -190,6 +433,12 @@ func validateMetricName(name string) error {
 		return fmt.Errorf("metric name '%s' must be valud regex '%s'", name, nameRegex)
 	}
 
+	if requireNamespacedNames() {
+		if idx := strings.Index(name, "/"); idx <= 0 || strings.HasSuffix(name, "/") {
+			return fmt.Errorf("metric name '%s' must have a namespace such as 'namespace/%s'", name, strings.Trim(name, "/"))
+		}
+	}
+
 	return nil
 }
 
@@ -206,3 +455,133 @@ func validateMetricLabel(l Label) error {
 
 	return nil
 }
+
+// NewBytesDistribution0 creates a new distribution metric recorded in bytes. It fails at
+// registration if cfg.Unit is not units.Bytes.
+func NewBytesDistribution0(name string, cfg MetricConfig, distribution Distribution) BytesDistribution0 {
+	return BytesDistribution0{
+		wrapper: createocDistributionWrapperWithUnit(name, distribution, cfg, units.Bytes),
+	}
+}
+
+// NewBytesDistribution1 creates a new distribution metric recorded in bytes with 1 label.
+func NewBytesDistribution1[T1 labelContraint](name string, cfg MetricConfig, distribution Distribution) BytesDistribution1[T1] {
+	return BytesDistribution1[T1]{
+		wrapper: createocDistributionWrapperWithUnit(name, distribution, cfg, units.Bytes),
+	}
+}
+
+// NewBytesDistribution2 creates a new distribution metric recorded in bytes with 2 labels.
+func NewBytesDistribution2[T1, T2 labelContraint](name string,
+	cfg MetricConfig, distribution Distribution,
+) BytesDistribution2[T1, T2] {
+	return BytesDistribution2[T1, T2]{
+		wrapper: createocDistributionWrapperWithUnit(name, distribution, cfg, units.Bytes),
+	}
+}
+
+// NewBytesDistribution3 creates a new distribution metric recorded in bytes with 3 labels.
+func NewBytesDistribution3[T1, T2, T3 labelContraint](name string,
+	cfg MetricConfig, distribution Distribution,
+) BytesDistribution3[T1, T2, T3] {
+	return BytesDistribution3[T1, T2, T3]{
+		wrapper: createocDistributionWrapperWithUnit(name, distribution, cfg, units.Bytes),
+	}
+}
+
+// NewBytesDistribution4 creates a new distribution metric recorded in bytes with 4 labels.
+func NewBytesDistribution4[T1, T2, T3, T4 labelContraint](name string,
+	cfg MetricConfig, distribution Distribution,
+) BytesDistribution4[T1, T2, T3, T4] {
+	return BytesDistribution4[T1, T2, T3, T4]{
+		wrapper: createocDistributionWrapperWithUnit(name, distribution, cfg, units.Bytes),
+	}
+}
+
+// NewMillisecondsDistribution0 creates a new distribution metric recorded from durations in
+// milliseconds. It fails at registration if cfg.Unit is not units.Milliseconds.
+func NewMillisecondsDistribution0(name string, cfg MetricConfig, distribution Distribution) MillisecondsDistribution0 {
+	return MillisecondsDistribution0{
+		wrapper: createocDistributionWrapperWithUnit(name, distribution, cfg, units.Milliseconds),
+	}
+}
+
+// NewMillisecondsDistribution1 creates a new distribution metric recorded in milliseconds with 1 label.
+func NewMillisecondsDistribution1[T1 labelContraint](name string,
+	cfg MetricConfig, distribution Distribution,
+) MillisecondsDistribution1[T1] {
+	return MillisecondsDistribution1[T1]{
+		wrapper: createocDistributionWrapperWithUnit(name, distribution, cfg, units.Milliseconds),
+	}
+}
+
+// NewMillisecondsDistribution2 creates a new distribution metric recorded in milliseconds with 2 labels.
+func NewMillisecondsDistribution2[T1, T2 labelContraint](name string,
+	cfg MetricConfig, distribution Distribution,
+) MillisecondsDistribution2[T1, T2] {
+	return MillisecondsDistribution2[T1, T2]{
+		wrapper: createocDistributionWrapperWithUnit(name, distribution, cfg, units.Milliseconds),
+	}
+}
+
+// NewMillisecondsDistribution3 creates a new distribution metric recorded in milliseconds with 3 labels.
+func NewMillisecondsDistribution3[T1, T2, T3 labelContraint](name string,
+	cfg MetricConfig, distribution Distribution,
+) MillisecondsDistribution3[T1, T2, T3] {
+	return MillisecondsDistribution3[T1, T2, T3]{
+		wrapper: createocDistributionWrapperWithUnit(name, distribution, cfg, units.Milliseconds),
+	}
+}
+
+// NewMillisecondsDistribution4 creates a new distribution metric recorded in milliseconds with 4 labels.
+func NewMillisecondsDistribution4[T1, T2, T3, T4 labelContraint](name string,
+	cfg MetricConfig, distribution Distribution,
+) MillisecondsDistribution4[T1, T2, T3, T4] {
+	return MillisecondsDistribution4[T1, T2, T3, T4]{
+		wrapper: createocDistributionWrapperWithUnit(name, distribution, cfg, units.Milliseconds),
+	}
+}
+
+// NewNanosecondsDistribution0 creates a new distribution metric recorded from durations in
+// nanoseconds. It fails at registration if cfg.Unit is not units.Nanoseconds.
+func NewNanosecondsDistribution0(name string, cfg MetricConfig, distribution Distribution) NanosecondsDistribution0 {
+	return NanosecondsDistribution0{
+		wrapper: createocDistributionWrapperWithUnit(name, distribution, cfg, units.Nanoseconds),
+	}
+}
+
+// NewNanosecondsDistribution1 creates a new distribution metric recorded in nanoseconds with 1 label.
+func NewNanosecondsDistribution1[T1 labelContraint](name string,
+	cfg MetricConfig, distribution Distribution,
+) NanosecondsDistribution1[T1] {
+	return NanosecondsDistribution1[T1]{
+		wrapper: createocDistributionWrapperWithUnit(name, distribution, cfg, units.Nanoseconds),
+	}
+}
+
+// NewNanosecondsDistribution2 creates a new distribution metric recorded in nanoseconds with 2 labels.
+func NewNanosecondsDistribution2[T1, T2 labelContraint](name string,
+	cfg MetricConfig, distribution Distribution,
+) NanosecondsDistribution2[T1, T2] {
+	return NanosecondsDistribution2[T1, T2]{
+		wrapper: createocDistributionWrapperWithUnit(name, distribution, cfg, units.Nanoseconds),
+	}
+}
+
+// NewNanosecondsDistribution3 creates a new distribution metric recorded in nanoseconds with 3 labels.
+func NewNanosecondsDistribution3[T1, T2, T3 labelContraint](name string,
+	cfg MetricConfig, distribution Distribution,
+) NanosecondsDistribution3[T1, T2, T3] {
+	return NanosecondsDistribution3[T1, T2, T3]{
+		wrapper: createocDistributionWrapperWithUnit(name, distribution, cfg, units.Nanoseconds),
+	}
+}
+
+// NewNanosecondsDistribution4 creates a new distribution metric recorded in nanoseconds with 4 labels.
+func NewNanosecondsDistribution4[T1, T2, T3, T4 labelContraint](name string,
+	cfg MetricConfig, distribution Distribution,
+) NanosecondsDistribution4[T1, T2, T3, T4] {
+	return NanosecondsDistribution4[T1, T2, T3, T4]{
+		wrapper: createocDistributionWrapperWithUnit(name, distribution, cfg, units.Nanoseconds),
+	}
+}
@@ -35,6 +35,19 @@ func (r *CounterRecorder) Value(labelKeyValuePairs ...string) int64 {
 	return p.Value.(int64)
 }
 
+type GaugeRecorder struct {
+	recorder
+}
+
+func (r *GaugeRecorder) Value(labelKeyValuePairs ...string) int64 {
+	p, ok := r.getPoint(labelKeyValuePairs...)
+	if !ok {
+		// This is expected before the metric is set the first time.
+		return 0
+	}
+	return p.Value.(int64)
+}
+
 type DistributionRecorder struct {
 	recorder
 }
@@ -63,6 +76,19 @@ func NewCounterRecorder(metricName string) *CounterRecorder {
 	}
 }
 
+func NewGaugeRecorder(metricName string) *GaugeRecorder {
+	metricReader := metricexport.NewReader()
+	exporter := metrictest.NewExporter(metricReader)
+
+	return &GaugeRecorder{
+		recorder: recorder{
+			metricName: metricName,
+			reader:     metricReader,
+			exporter:   exporter,
+		},
+	}
+}
+
 func NewDistributionRecorder(metricName string) *DistributionRecorder {
 	metricReader := metricexport.NewReader()
 	exporter := metrictest.NewExporter(metricReader)
@@ -0,0 +1,187 @@
+package statz
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/edaniels/golog"
+	"go.opencensus.io/stats/view"
+
+	"go.viam.com/utils/perf/statz/units"
+)
+
+// BytesDistribution0 is a histogram metric declared in units.Bytes.
+type BytesDistribution0 struct {
+	wrapper *ocDistributionWrapper
+}
+
+// ObserveBytes records an observation of the metric in bytes.
+func (c *BytesDistribution0) ObserveBytes(v int64) {
+	c.wrapper.observe(context.Background(), labelsToStringSlice(), float64(v))
+}
+
+// BytesDistribution1 is a histogram metric declared in units.Bytes.
+type BytesDistribution1[T1 labelContraint] struct {
+	wrapper *ocDistributionWrapper
+}
+
+// ObserveBytes records an observation of the metric in bytes.
+func (c *BytesDistribution1[T1]) ObserveBytes(v int64, l1 T1) {
+	c.wrapper.observe(context.Background(), labelsToStringSlice(l1), float64(v))
+}
+
+// BytesDistribution2 is a histogram metric declared in units.Bytes.
+type BytesDistribution2[T1 labelContraint, T2 labelContraint] struct {
+	wrapper *ocDistributionWrapper
+}
+
+// ObserveBytes records an observation of the metric in bytes.
+func (c *BytesDistribution2[T1, T2]) ObserveBytes(v int64, l1 T1, l2 T2) {
+	c.wrapper.observe(context.Background(), labelsToStringSlice(l1, l2), float64(v))
+}
+
+// BytesDistribution3 is a histogram metric declared in units.Bytes.
+type BytesDistribution3[T1 labelContraint, T2 labelContraint, T3 labelContraint] struct {
+	wrapper *ocDistributionWrapper
+}
+
+// ObserveBytes records an observation of the metric in bytes.
+func (c *BytesDistribution3[T1, T2, T3]) ObserveBytes(v int64, l1 T1, l2 T2, l3 T3) {
+	c.wrapper.observe(context.Background(), labelsToStringSlice(l1, l2, l3), float64(v))
+}
+
+// BytesDistribution4 is a histogram metric declared in units.Bytes.
+type BytesDistribution4[T1 labelContraint, T2 labelContraint, T3 labelContraint, T4 labelContraint] struct {
+	wrapper *ocDistributionWrapper
+}
+
+// ObserveBytes records an observation of the metric in bytes.
+func (c *BytesDistribution4[T1, T2, T3, T4]) ObserveBytes(v int64, l1 T1, l2 T2, l3 T3, l4 T4) {
+	c.wrapper.observe(context.Background(), labelsToStringSlice(l1, l2, l3, l4), float64(v))
+}
+
+// MillisecondsDistribution0 is a histogram metric declared in units.Milliseconds.
+type MillisecondsDistribution0 struct {
+	wrapper *ocDistributionWrapper
+}
+
+// ObserveMilliseconds records an observation of the metric, converted to milliseconds.
+func (c *MillisecondsDistribution0) ObserveMilliseconds(d time.Duration) {
+	c.wrapper.observe(context.Background(), labelsToStringSlice(), durationToMilliseconds(d))
+}
+
+// MillisecondsDistribution1 is a histogram metric declared in units.Milliseconds.
+type MillisecondsDistribution1[T1 labelContraint] struct {
+	wrapper *ocDistributionWrapper
+}
+
+// ObserveMilliseconds records an observation of the metric, converted to milliseconds.
+func (c *MillisecondsDistribution1[T1]) ObserveMilliseconds(d time.Duration, l1 T1) {
+	c.wrapper.observe(context.Background(), labelsToStringSlice(l1), durationToMilliseconds(d))
+}
+
+// MillisecondsDistribution2 is a histogram metric declared in units.Milliseconds.
+type MillisecondsDistribution2[T1 labelContraint, T2 labelContraint] struct {
+	wrapper *ocDistributionWrapper
+}
+
+// ObserveMilliseconds records an observation of the metric, converted to milliseconds.
+func (c *MillisecondsDistribution2[T1, T2]) ObserveMilliseconds(d time.Duration, l1 T1, l2 T2) {
+	c.wrapper.observe(context.Background(), labelsToStringSlice(l1, l2), durationToMilliseconds(d))
+}
+
+// MillisecondsDistribution3 is a histogram metric declared in units.Milliseconds.
+type MillisecondsDistribution3[T1 labelContraint, T2 labelContraint, T3 labelContraint] struct {
+	wrapper *ocDistributionWrapper
+}
+
+// ObserveMilliseconds records an observation of the metric, converted to milliseconds.
+func (c *MillisecondsDistribution3[T1, T2, T3]) ObserveMilliseconds(d time.Duration, l1 T1, l2 T2, l3 T3) {
+	c.wrapper.observe(context.Background(), labelsToStringSlice(l1, l2, l3), durationToMilliseconds(d))
+}
+
+// MillisecondsDistribution4 is a histogram metric declared in units.Milliseconds.
+type MillisecondsDistribution4[T1 labelContraint, T2 labelContraint, T3 labelContraint, T4 labelContraint] struct {
+	wrapper *ocDistributionWrapper
+}
+
+// ObserveMilliseconds records an observation of the metric, converted to milliseconds.
+func (c *MillisecondsDistribution4[T1, T2, T3, T4]) ObserveMilliseconds(d time.Duration, l1 T1, l2 T2, l3 T3, l4 T4) {
+	c.wrapper.observe(context.Background(), labelsToStringSlice(l1, l2, l3, l4), durationToMilliseconds(d))
+}
+
+// NanosecondsDistribution0 is a histogram metric declared in units.Nanoseconds. Recording in
+// nanoseconds keeps the resolution of operations far shorter than a millisecond.
+type NanosecondsDistribution0 struct {
+	wrapper *ocDistributionWrapper
+}
+
+// ObserveDuration records an observation of the metric in nanoseconds.
+func (c *NanosecondsDistribution0) ObserveDuration(d time.Duration) {
+	c.wrapper.observe(context.Background(), labelsToStringSlice(), float64(d.Nanoseconds()))
+}
+
+// NanosecondsDistribution1 is a histogram metric declared in units.Nanoseconds.
+type NanosecondsDistribution1[T1 labelContraint] struct {
+	wrapper *ocDistributionWrapper
+}
+
+// ObserveDuration records an observation of the metric in nanoseconds.
+func (c *NanosecondsDistribution1[T1]) ObserveDuration(d time.Duration, l1 T1) {
+	c.wrapper.observe(context.Background(), labelsToStringSlice(l1), float64(d.Nanoseconds()))
+}
+
+// NanosecondsDistribution2 is a histogram metric declared in units.Nanoseconds.
+type NanosecondsDistribution2[T1 labelContraint, T2 labelContraint] struct {
+	wrapper *ocDistributionWrapper
+}
+
+// ObserveDuration records an observation of the metric in nanoseconds.
+func (c *NanosecondsDistribution2[T1, T2]) ObserveDuration(d time.Duration, l1 T1, l2 T2) {
+	c.wrapper.observe(context.Background(), labelsToStringSlice(l1, l2), float64(d.Nanoseconds()))
+}
+
+// NanosecondsDistribution3 is a histogram metric declared in units.Nanoseconds.
+type NanosecondsDistribution3[T1 labelContraint, T2 labelContraint, T3 labelContraint] struct {
+	wrapper *ocDistributionWrapper
+}
+
+// ObserveDuration records an observation of the metric in nanoseconds.
+func (c *NanosecondsDistribution3[T1, T2, T3]) ObserveDuration(d time.Duration, l1 T1, l2 T2, l3 T3) {
+	c.wrapper.observe(context.Background(), labelsToStringSlice(l1, l2, l3), float64(d.Nanoseconds()))
+}
+
+// NanosecondsDistribution4 is a histogram metric declared in units.Nanoseconds.
+type NanosecondsDistribution4[T1 labelContraint, T2 labelContraint, T3 labelContraint, T4 labelContraint] struct {
+	wrapper *ocDistributionWrapper
+}
+
+// ObserveDuration records an observation of the metric in nanoseconds.
+func (c *NanosecondsDistribution4[T1, T2, T3, T4]) ObserveDuration(d time.Duration, l1 T1, l2 T2, l3 T3, l4 T4) {
+	c.wrapper.observe(context.Background(), labelsToStringSlice(l1, l2, l3, l4), float64(d.Nanoseconds()))
+}
+
+///// internal
+
+func durationToMilliseconds(d time.Duration) float64 {
+	return float64(d) / float64(time.Millisecond)
+}
+
+// createocDistributionWrapperWithUnit is like createocDistributionWrapper but refuses to register
+// a metric whose declared unit is not the one the caller records in.
+func createocDistributionWrapperWithUnit(
+	name string, distributions Distribution, cfg MetricConfig, unit units.Unit,
+) *ocDistributionWrapper {
+	if cfg.Unit != unit {
+		err := fmt.Errorf("metric %s is declared in unit %q but recorded in unit %q", name, cfg.Unit, unit)
+		if !lenientRegistration() {
+			golog.Global().Panicf("Failed to register %s", err)
+			return nil
+		}
+		addRegistrationError(err)
+		golog.Global().Errorw("metric registration failed, it will not be recorded", "metric", name, "error", err)
+		return &ocDistributionWrapper{data: &opencensusStatsData{View: &view.View{Name: name}, disabled: true}}
+	}
+	return createocDistributionWrapper(name, distributions, cfg)
+}
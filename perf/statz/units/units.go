@@ -10,6 +10,7 @@ const (
 	Dimensionless Unit = "1"
 	Bytes         Unit = "By"
 	Bit           Unit = "bit"
+	Nanoseconds   Unit = "ns"
 	Milliseconds  Unit = "ms"
 	Microseconds  Unit = "us"
 	Second        Unit = "s"
@@ -17,3 +18,21 @@ const (
 	Hour          Unit = "h"
 	Day           Unit = "d"
 )
+
+var known = map[Unit]bool{
+	Dimensionless: true,
+	Bytes:         true,
+	Bit:           true,
+	Nanoseconds:   true,
+	Milliseconds:  true,
+	Microseconds:  true,
+	Second:        true,
+	Minute:        true,
+	Hour:          true,
+	Day:           true,
+}
+
+// Known returns whether the unit is one of the units above.
+func Known(unit Unit) bool {
+	return known[unit]
+}
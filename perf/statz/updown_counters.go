@@ -0,0 +1,203 @@
+package statz
+
+import (
+	"context"
+	"strings"
+	"sync"
+)
+
+// upDownCounterUpSuffix and upDownCounterDownSuffix are appended to the name of an up down counter
+// for its underlying counters of increments and decrements.
+const (
+	upDownCounterUpSuffix   = "/up"
+	upDownCounterDownSuffix = "/down"
+)
+
+// UpDownCounter0 is an int64 counter that can decrease, with no metric labels.
+type UpDownCounter0 struct {
+	wrapper *ocUpDownCounterWrapper
+}
+
+// Inc increments the counter by 1.
+func (c *UpDownCounter0) Inc() {
+	c.Add(1)
+}
+
+// Dec decrements the counter by 1.
+func (c *UpDownCounter0) Dec() {
+	c.Add(-1)
+}
+
+// Add adds delta, which may be negative, to the counter.
+func (c *UpDownCounter0) Add(delta int64) {
+	c.wrapper.add(labelsToStringSlice(), delta)
+}
+
+// Value returns the increments minus the decrements of the counter with the given label values
+// since the program started.
+func (c *UpDownCounter0) Value() int64 {
+	return c.wrapper.value(labelsToStringSlice())
+}
+
+// UpDownCounter1 is an int64 counter that can decrease, with 1 metric label.
+type UpDownCounter1[T1 labelContraint] struct {
+	wrapper *ocUpDownCounterWrapper
+}
+
+// Inc increments the counter by 1.
+func (c *UpDownCounter1[T1]) Inc(v1 T1) {
+	c.Add(v1, 1)
+}
+
+// Dec decrements the counter by 1.
+func (c *UpDownCounter1[T1]) Dec(v1 T1) {
+	c.Add(v1, -1)
+}
+
+// Add adds delta, which may be negative, to the counter.
+func (c *UpDownCounter1[T1]) Add(v1 T1, delta int64) {
+	c.wrapper.add(labelsToStringSlice(v1), delta)
+}
+
+// Value returns the increments minus the decrements of the counter with the given label values
+// since the program started.
+func (c *UpDownCounter1[T1]) Value(v1 T1) int64 {
+	return c.wrapper.value(labelsToStringSlice(v1))
+}
+
+// UpDownCounter2 is an int64 counter that can decrease, with 2 metric labels.
+type UpDownCounter2[T1 labelContraint, T2 labelContraint] struct {
+	wrapper *ocUpDownCounterWrapper
+}
+
+// Inc increments the counter by 1.
+func (c *UpDownCounter2[T1, T2]) Inc(v1 T1, v2 T2) {
+	c.Add(v1, v2, 1)
+}
+
+// Dec decrements the counter by 1.
+func (c *UpDownCounter2[T1, T2]) Dec(v1 T1, v2 T2) {
+	c.Add(v1, v2, -1)
+}
+
+// Add adds delta, which may be negative, to the counter.
+func (c *UpDownCounter2[T1, T2]) Add(v1 T1, v2 T2, delta int64) {
+	c.wrapper.add(labelsToStringSlice(v1, v2), delta)
+}
+
+// Value returns the increments minus the decrements of the counter with the given label values
+// since the program started.
+func (c *UpDownCounter2[T1, T2]) Value(v1 T1, v2 T2) int64 {
+	return c.wrapper.value(labelsToStringSlice(v1, v2))
+}
+
+// UpDownCounter3 is an int64 counter that can decrease, with 3 metric labels.
+type UpDownCounter3[T1 labelContraint, T2 labelContraint, T3 labelContraint] struct {
+	wrapper *ocUpDownCounterWrapper
+}
+
+// Inc increments the counter by 1.
+func (c *UpDownCounter3[T1, T2, T3]) Inc(v1 T1, v2 T2, v3 T3) {
+	c.Add(v1, v2, v3, 1)
+}
+
+// Dec decrements the counter by 1.
+func (c *UpDownCounter3[T1, T2, T3]) Dec(v1 T1, v2 T2, v3 T3) {
+	c.Add(v1, v2, v3, -1)
+}
+
+// Add adds delta, which may be negative, to the counter.
+func (c *UpDownCounter3[T1, T2, T3]) Add(v1 T1, v2 T2, v3 T3, delta int64) {
+	c.wrapper.add(labelsToStringSlice(v1, v2, v3), delta)
+}
+
+// Value returns the increments minus the decrements of the counter with the given label values
+// since the program started.
+func (c *UpDownCounter3[T1, T2, T3]) Value(v1 T1, v2 T2, v3 T3) int64 {
+	return c.wrapper.value(labelsToStringSlice(v1, v2, v3))
+}
+
+// UpDownCounter4 is an int64 counter that can decrease, with 4 metric labels.
+type UpDownCounter4[T1 labelContraint, T2 labelContraint, T3 labelContraint, T4 labelContraint] struct {
+	wrapper *ocUpDownCounterWrapper
+}
+
+// Inc increments the counter by 1.
+func (c *UpDownCounter4[T1, T2, T3, T4]) Inc(v1 T1, v2 T2, v3 T3, v4 T4) {
+	c.Add(v1, v2, v3, v4, 1)
+}
+
+// Dec decrements the counter by 1.
+func (c *UpDownCounter4[T1, T2, T3, T4]) Dec(v1 T1, v2 T2, v3 T3, v4 T4) {
+	c.Add(v1, v2, v3, v4, -1)
+}
+
+// Add adds delta, which may be negative, to the counter.
+func (c *UpDownCounter4[T1, T2, T3, T4]) Add(v1 T1, v2 T2, v3 T3, v4 T4, delta int64) {
+	c.wrapper.add(labelsToStringSlice(v1, v2, v3, v4), delta)
+}
+
+// Value returns the increments minus the decrements of the counter with the given label values
+// since the program started.
+func (c *UpDownCounter4[T1, T2, T3, T4]) Value(v1 T1, v2 T2, v3 T3, v4 T4) int64 {
+	return c.wrapper.value(labelsToStringSlice(v1, v2, v3, v4))
+}
+
+///// internal
+
+// ocUpDownCounterWrapper records an up down counter as two monotonic counters, "<name>/up" of the
+// increments and "<name>/down" of the decrements, whose difference is the value of the counter.
+// The value is also kept in memory so that it can be read back.
+type ocUpDownCounterWrapper struct {
+	ups   *ocCounterWrapper
+	downs *ocCounterWrapper
+
+	mu     sync.Mutex
+	values map[string]int64
+}
+
+func (w *ocUpDownCounterWrapper) add(labels []string, delta int64) {
+	switch {
+	case delta > 0:
+		w.ups.incBy(context.Background(), labels, delta)
+	case delta < 0:
+		w.downs.incBy(context.Background(), labels, -delta)
+	default:
+		return
+	}
+	key := strings.Join(labels, "\x00")
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	w.values[key] += delta
+}
+
+func (w *ocUpDownCounterWrapper) value(labels []string) int64 {
+	key := strings.Join(labels, "\x00")
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	return w.values[key]
+}
+
+// createUpDownCounterWrapper creates and registers the counters of an up down counter. Their
+// descriptions and aliases are those of cfg marked with what they count.
+func createUpDownCounterWrapper(name string, cfg MetricConfig, labelDomains ...[]string) *ocUpDownCounterWrapper {
+	upsCfg := upDownCounterConfig(cfg, "increments", upDownCounterUpSuffix)
+	downsCfg := upDownCounterConfig(cfg, "decrements", upDownCounterDownSuffix)
+	return &ocUpDownCounterWrapper{
+		ups:    createCounterWrapper(name+upDownCounterUpSuffix, upsCfg, labelDomains...),
+		downs:  createCounterWrapper(name+upDownCounterDownSuffix, downsCfg, labelDomains...),
+		values: map[string]int64{},
+	}
+}
+
+func upDownCounterConfig(cfg MetricConfig, counts, suffix string) MetricConfig {
+	cfg.Description = cfg.Description + " (" + counts + ")"
+	if len(cfg.Aliases) != 0 {
+		aliases := make([]string, 0, len(cfg.Aliases))
+		for _, alias := range cfg.Aliases {
+			aliases = append(aliases, alias+suffix)
+		}
+		cfg.Aliases = aliases
+	}
+	return cfg
+}
@@ -0,0 +1,41 @@
+package statz
+
+import (
+	"testing"
+
+	"go.viam.com/test"
+
+	"go.viam.com/utils/perf/statz/statztest"
+	"go.viam.com/utils/perf/statz/units"
+)
+
+func TestUpDownCounter(t *testing.T) {
+	connections := NewUpDownCounter1[string]("statz/test/up_down_counter", MetricConfig{
+		Description: "The number of connections",
+		Unit:        units.Dimensionless,
+		Labels: []Label{
+			{Name: "type", Description: "The connection type."},
+		},
+	})
+	upRecorder := statztest.NewCounterRecorder("statz/test/up_down_counter/up")
+	downRecorder := statztest.NewCounterRecorder("statz/test/up_down_counter/down")
+
+	connections.Inc("grpc")
+	connections.Inc("grpc")
+	connections.Add("grpc", 3)
+	connections.Dec("grpc")
+	connections.Add("grpc", -2)
+	connections.Add("grpc", 0)
+	connections.Dec("webrtc")
+
+	test.That(t, connections.Value("grpc"), test.ShouldEqual, 2)
+	test.That(t, connections.Value("webrtc"), test.ShouldEqual, -1)
+	test.That(t, connections.Value("other"), test.ShouldEqual, 0)
+	test.That(t, upRecorder.Value("type", "grpc"), test.ShouldEqual, 5)
+	test.That(t, downRecorder.Value("type", "grpc"), test.ShouldEqual, 3)
+	test.That(t, downRecorder.Value("type", "webrtc"), test.ShouldEqual, 1)
+
+	cfg, ok := MetricConfigFor("statz/test/up_down_counter/down")
+	test.That(t, ok, test.ShouldBeTrue)
+	test.That(t, cfg.Description, test.ShouldEqual, "The number of connections (decrements)")
+}
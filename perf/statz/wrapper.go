@@ -1,6 +1,13 @@
 package statz
 
 import (
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
 	"github.com/edaniels/golog"
 	"go.opencensus.io/stats/view"
 	"go.opencensus.io/tag"
@@ -10,11 +17,81 @@ import (
 type opencensusStatsData struct {
 	View      *view.View
 	labelKeys []tag.Key
+	// labelEncoders holds the Label.Encode of each label that has one by name.
+	labelEncoders map[string]func(v interface{}) string
+	// disabled is set for metrics that failed lenient registration; nothing is recorded for them.
+	disabled bool
+
+	// seenLabels holds every distinct combination of label values recorded and seenCount
+	// their number.
+	seenLabels sync.Map // label key -> struct{}
+	seenCount  int64
+
+	// trackLastUpdated is set by MetricConfig.TrackLastUpdated.
+	trackLastUpdated bool
+	// lastUpdates holds when, in unix nanoseconds, each combination of label values was last
+	// recorded for up to maxLastUpdatedSeries combinations and lastUpdatesCount their number.
+	lastUpdates      sync.Map // label key -> *int64
+	lastUpdatesCount int64
+}
+
+// maxLastUpdatedSeries bounds the number of combinations of label values of a metric whose last
+// update is tracked. Combinations recorded after the bound is reached are not tracked.
+const maxLastUpdatedSeries = 10000
+
+// trackUpdate remembers the combination of label values so the cardinality of the metric can
+// be reported and, if tracked, when it was recorded.
+func (sd *opencensusStatsData) trackUpdate(labels []string) {
+	key := strings.Join(labels, "\x00")
+	if _, ok := sd.seenLabels.Load(key); !ok {
+		if _, loaded := sd.seenLabels.LoadOrStore(key, struct{}{}); !loaded {
+			atomic.AddInt64(&sd.seenCount, 1)
+		}
+	}
+	if !sd.trackLastUpdated {
+		return
+	}
+	now := time.Now().UnixNano()
+	if last, ok := sd.lastUpdates.Load(key); ok {
+		atomic.StoreInt64(last.(*int64), now)
+		return
+	}
+	if atomic.AddInt64(&sd.lastUpdatesCount, 1) > maxLastUpdatedSeries {
+		atomic.AddInt64(&sd.lastUpdatesCount, -1)
+		return
+	}
+	if last, loaded := sd.lastUpdates.LoadOrStore(key, &now); loaded {
+		atomic.AddInt64(&sd.lastUpdatesCount, -1)
+		atomic.StoreInt64(last.(*int64), now)
+	}
+}
+
+// cardinality returns the number of distinct combinations of label values recorded.
+func (sd *opencensusStatsData) cardinality() int {
+	return int(atomic.LoadInt64(&sd.seenCount))
+}
+
+// lastUpdated returns when the combination of label values was last recorded or the zero time
+// if it has not been or is not tracked.
+func (sd *opencensusStatsData) lastUpdated(labels []string) time.Time {
+	last, ok := sd.lastUpdates.Load(strings.Join(labels, "\x00"))
+	if !ok {
+		return time.Time{}
+	}
+	return time.Unix(0, atomic.LoadInt64(last.(*int64)))
 }
 
 // labelsToMutations creates the opencensus Mutations for each label value already converted to a string. Joins the pre-computed tags
 // with the string values.
 func (sd *opencensusStatsData) labelsToMutations(labels []string) []tag.Mutator {
+	mutations := sd.mutations(labels)
+	sd.trackUpdate(labels)
+	return mutations
+}
+
+// mutations is labelsToMutations without tracking the labels as recorded, for when they are
+// only resolved ahead of time.
+func (sd *opencensusStatsData) mutations(labels []string) []tag.Mutator {
 	if len(labels) != len(sd.labelKeys) {
 		golog.Global().Panic("Should never happen where the label lengths do not match")
 		return []tag.Mutator{}
@@ -29,3 +106,51 @@ func (sd *opencensusStatsData) labelsToMutations(labels []string) []tag.Mutator
 
 	return mutations
 }
+
+// encodeLabelValues encodes the given label values to strings with the encoders of their labels.
+func (sd *opencensusStatsData) encodeLabelValues(labelValues map[string]interface{}) map[string]string {
+	encoded := make(map[string]string, len(labelValues))
+	for name, v := range labelValues {
+		if encode, ok := sd.labelEncoders[name]; ok {
+			encoded[name] = encode(v)
+			continue
+		}
+		encoded[name] = fmt.Sprint(v)
+	}
+	return encoded
+}
+
+// labelsFromMap orders the given label values by the metric's declared labels. It errors if
+// any declared label is missing or any undeclared label is given.
+func (sd *opencensusStatsData) labelsFromMap(labelValues map[string]string) ([]string, error) {
+	labels := make([]string, 0, len(sd.labelKeys))
+	var missing []string
+	for _, k := range sd.labelKeys {
+		v, ok := labelValues[k.Name()]
+		if !ok {
+			missing = append(missing, k.Name())
+			continue
+		}
+		labels = append(labels, v)
+	}
+	if len(missing) != 0 {
+		return nil, fmt.Errorf("metric %s missing label values for: %s", sd.View.Name, strings.Join(missing, ", "))
+	}
+
+	if len(labelValues) != len(sd.labelKeys) {
+		declared := make(map[string]bool, len(sd.labelKeys))
+		for _, k := range sd.labelKeys {
+			declared[k.Name()] = true
+		}
+		var extra []string
+		for name := range labelValues {
+			if !declared[name] {
+				extra = append(extra, name)
+			}
+		}
+		sort.Strings(extra)
+		return nil, fmt.Errorf("metric %s given undeclared labels: %s", sd.View.Name, strings.Join(extra, ", "))
+	}
+
+	return labels, nil
+}
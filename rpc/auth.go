@@ -6,6 +6,7 @@ import (
 	"crypto/subtle"
 	"errors"
 	"fmt"
+	"time"
 
 	"github.com/golang-jwt/jwt/v4"
 	"google.golang.org/grpc/codes"
@@ -27,11 +28,27 @@ type AuthHandler interface {
 	VerifyEntity(ctx context.Context, entity string) (interface{}, error)
 }
 
+// A MultiValueAuthHandler is an AuthHandler whose authentication metadata can have multiple
+// values per key (e.g. several roles). When implemented, AuthenticateMultiValue is used instead
+// of Authenticate and its metadata is available via claims implementing MultiValueClaims,
+// while ContextAuthMetadata has the values joined by commas.
+type MultiValueAuthHandler interface {
+	AuthHandler
+
+	// AuthenticateMultiValue is like Authenticate but returns multi-valued metadata.
+	AuthenticateMultiValue(ctx context.Context, entity, payload string) (map[string][]string, error)
+}
+
 // An AuthenticateToHandler determines if the given entity should be allowed to be
 // authenticated to by the calling entity, accessible via MustContextAuthEntity.
 // The returned auth metadata will be present in ContextAuthMetadata.
 type AuthenticateToHandler func(ctx context.Context, entity string) (map[string]string, error)
 
+// A TokenExtractor finds the access token of a request carried somewhere other than the
+// authorization metadata, such as in a custom framing header or a session established out of
+// band. It returns false if the request carries no token it understands.
+type TokenExtractor func(ctx context.Context) (string, bool)
+
 // TokenVerificationKeyProvider allows an AuthHandler to supply a key needed to peform
 // verification of a JWT. This is helpful when the server itself is not responsible
 // for authentication. For example, this could be for a central auth server
@@ -59,6 +76,24 @@ type Claims interface {
 	GetAuthMetadata() map[string]string
 }
 
+// MultiValueClaims is optionally implemented by custom Claims whose auth metadata can have
+// multiple values per key, such as the metadata returned by a MultiValueAuthHandler.
+type MultiValueClaims interface {
+	// GetAuthMetadataMulti returns the rpc auth metadata based on the jwt claims, including
+	// keys with multiple values.
+	GetAuthMetadataMulti() map[string][]string
+}
+
+// ExpiringClaims is optionally implemented by custom Claims to expose when they expire, for
+// claims whose expiry is not the standard "exp" claim. Time based features such as
+// WithExpiryGracePeriod consult it when present; for other claims the "exp" claim is used.
+// Claims that expire should still fail Valid once expired, with a *jwt.ValidationError of
+// jwt.ValidationErrorExpired for the expiry grace period to apply to them.
+type ExpiringClaims interface {
+	// ExpiresAt returns when the claims expire or false if they do not.
+	ExpiresAt() (time.Time, bool)
+}
+
 // TokenCustomClaimProvider allows an AuthHandler to supply a key needed to peform
 // verification of a JWT. This is helpful when the server itself is not responsible
 // for authentication. For example, this could be for a central auth server
@@ -209,6 +244,18 @@ func MakeEntitiesChecker(forEntities []string) func(ctx context.Context, entitie
 	}
 }
 
+// A RequestAwareVerifier is an AuthHandler that can additionally veto an authenticated request
+// based on the gRPC method being called, allowing method scoped policies for entities. The auth
+// interceptors call VerifyRequest after the request is authenticated with the entity returned
+// by VerifyEntity, which is also in ctx. Methods exempt from authentication are not verified.
+type RequestAwareVerifier interface {
+	AuthHandler
+
+	// VerifyRequest returns nil if entity may call fullMethod. Non status errors are
+	// returned to the client as PermissionDenied.
+	VerifyRequest(ctx context.Context, entity interface{}, fullMethod string) error
+}
+
 // CredentialsType signifies a means of representing a credential. For example,
 // an API key.
 type CredentialsType string
@@ -219,6 +266,25 @@ const (
 	CredentialsTypeAPIKey = CredentialsType("api-key")
 )
 
+// An AuthHandlerChecker reports whether credentials of a type can be authenticated. The Server
+// returned by NewServer implements it.
+type AuthHandlerChecker interface {
+	// HasAuthHandler returns whether credentials of the given type can be authenticated.
+	HasAuthHandler(credType CredentialsType) bool
+}
+
+// ValidateFor returns an error if checker has no auth handler for the credential type. This
+// allows catching misconfigured credential types before the first request is made.
+func (t CredentialsType) ValidateFor(checker AuthHandlerChecker) error {
+	if t == "" {
+		return errors.New("credentials type cannot be empty")
+	}
+	if !checker.HasAuthHandler(t) {
+		return fmt.Errorf("no auth handler for %q", t)
+	}
+	return nil
+}
+
 // Credentials packages up both a type of credential along with its payload which
 // is formatted specific to the type.
 type Credentials struct {
@@ -0,0 +1,45 @@
+package rpc
+
+import (
+	"context"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/metadata"
+
+	rpcpb "go.viam.com/utils/proto/rpc/v1"
+)
+
+// OutgoingContextAuthenticatedTo is for handlers that need to call another service on behalf
+// of their caller. It exchanges the caller's access token found in ctx for one destined for
+// entity by calling AuthenticateTo on conn, which must be a connection to the server that
+// issued the caller's token. The returned context carries the new token as its outgoing
+// authorization and can be used for calls to entity.
+func OutgoingContextAuthenticatedTo(ctx context.Context, conn grpc.ClientConnInterface, entity string) (context.Context, error) {
+	callerToken, err := tokenFromContext(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	authToCtx := outgoingContextWithAuthorization(ctx, callerToken)
+	resp, err := rpcpb.NewExternalAuthServiceClient(conn).AuthenticateTo(authToCtx, &rpcpb.AuthenticateToRequest{
+		Entity: entity,
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return outgoingContextWithAuthorization(ctx, resp.AccessToken), nil
+}
+
+// outgoingContextWithAuthorization returns a context whose outgoing metadata has the given
+// bearer token as its only authorization.
+func outgoingContextWithAuthorization(ctx context.Context, accessToken string) context.Context {
+	md, ok := metadata.FromOutgoingContext(ctx)
+	if ok {
+		md = md.Copy()
+	} else {
+		md = make(metadata.MD)
+	}
+	md.Set(metadataFieldAuthorization, authorizationValuePrefixBearer+accessToken)
+	return metadata.NewOutgoingContext(ctx, md)
+}
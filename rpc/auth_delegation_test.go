@@ -0,0 +1,100 @@
+package rpc
+
+import (
+	"context"
+	"errors"
+	"net"
+	"testing"
+
+	"github.com/edaniels/golog"
+	"github.com/golang-jwt/jwt/v4"
+	"go.viam.com/test"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/grpc/metadata"
+
+	rpcpb "go.viam.com/utils/proto/rpc/v1"
+	"go.viam.com/utils/testutils"
+)
+
+func TestOutgoingContextAuthenticatedTo(t *testing.T) {
+	testutils.SkipUnlessInternet(t)
+	logger := golog.NewTestLogger(t)
+
+	rpcServer, err := NewServer(
+		logger,
+		WithAuthHandler("fake", MakeSimpleAuthHandler([]string{"foo"}, "bar")),
+		WithAuthenticateToHandler(CredentialsType("inter-node"), func(ctx context.Context, entity string) (map[string]string, error) {
+			if entity != "someent" {
+				return nil, errors.New("nope")
+			}
+			return map[string]string{"some": "data"}, nil
+		}),
+	)
+	test.That(t, err, test.ShouldBeNil)
+
+	httpListener, err := net.Listen("tcp", "localhost:0")
+	test.That(t, err, test.ShouldBeNil)
+
+	errChan := make(chan error)
+	go func() {
+		errChan <- rpcServer.Serve(httpListener)
+	}()
+	defer func() {
+		test.That(t, rpcServer.Stop(), test.ShouldBeNil)
+		test.That(t, <-errChan, test.ShouldBeNil)
+	}()
+
+	conn, err := grpc.DialContext(
+		context.Background(),
+		httpListener.Addr().String(),
+		grpc.WithTransportCredentials(insecure.NewCredentials()),
+		grpc.WithBlock(),
+	)
+	test.That(t, err, test.ShouldBeNil)
+	defer func() {
+		test.That(t, conn.Close(), test.ShouldBeNil)
+	}()
+
+	authResp, err := rpcpb.NewAuthServiceClient(conn).Authenticate(context.Background(), &rpcpb.AuthenticateRequest{
+		Entity:      "foo",
+		Credentials: &rpcpb.Credentials{Type: "fake", Payload: "bar"},
+	})
+	test.That(t, err, test.ShouldBeNil)
+
+	// simulate the context of a handler called by foo.
+	incomingCtx := metadata.NewIncomingContext(context.Background(),
+		metadata.Pairs(metadataFieldAuthorization, authorizationValuePrefixBearer+authResp.AccessToken))
+	incomingCtx = metadata.AppendToOutgoingContext(incomingCtx, "other", "value")
+
+	t.Run("authenticated to entity", func(t *testing.T) {
+		outgoingCtx, err := OutgoingContextAuthenticatedTo(incomingCtx, conn, "someent")
+		test.That(t, err, test.ShouldBeNil)
+
+		md, ok := metadata.FromOutgoingContext(outgoingCtx)
+		test.That(t, ok, test.ShouldBeTrue)
+		test.That(t, md.Get("other"), test.ShouldResemble, []string{"value"})
+		authHeader := md.Get(metadataFieldAuthorization)
+		test.That(t, authHeader, test.ShouldHaveLength, 1)
+		test.That(t, authHeader[0], test.ShouldNotEqual, authorizationValuePrefixBearer+authResp.AccessToken)
+
+		var claims JWTClaims
+		_, _, err = jwt.NewParser().ParseUnverified(authHeader[0][len(authorizationValuePrefixBearer):], &claims)
+		test.That(t, err, test.ShouldBeNil)
+		test.That(t, claims.Audience, test.ShouldResemble, jwt.ClaimStrings{"someent"})
+		test.That(t, claims.CredentialsType, test.ShouldEqual, CredentialsType("inter-node"))
+		test.That(t, claims.AuthMetadata, test.ShouldResemble, map[string]string{"some": "data"})
+	})
+
+	t.Run("not allowed entity", func(t *testing.T) {
+		_, err := OutgoingContextAuthenticatedTo(incomingCtx, conn, "otherent")
+		test.That(t, err, test.ShouldNotBeNil)
+		test.That(t, err.Error(), test.ShouldContainSubstring, "nope")
+	})
+
+	t.Run("unauthenticated caller", func(t *testing.T) {
+		_, err := OutgoingContextAuthenticatedTo(context.Background(), conn, "someent")
+		test.That(t, err, test.ShouldNotBeNil)
+		test.That(t, err.Error(), test.ShouldContainSubstring, "authentication required")
+	})
+}
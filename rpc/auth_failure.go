@@ -0,0 +1,95 @@
+package rpc
+
+import (
+	"context"
+	"errors"
+
+	"google.golang.org/genproto/googleapis/rpc/errdetails"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// AuthFailureDomain is the domain of the errdetails.ErrorInfo attached to authentication
+// failures (see WithAuthFailureDetails).
+const AuthFailureDomain = "auth.rpc.viam.com"
+
+// withAuthFailureDetails attaches reason to the status of err if the server is configured to.
+func (ss *simpleServer) withAuthFailureDetails(err error, reason AuthFailureReason) error {
+	if !ss.authFailureDetails || reason == "" {
+		return err
+	}
+	st, ok := status.FromError(err)
+	if !ok {
+		return err
+	}
+	withDetails, detailsErr := st.WithDetails(&errdetails.ErrorInfo{Reason: string(reason), Domain: AuthFailureDomain})
+	if detailsErr != nil {
+		return err
+	}
+	return withDetails.Err()
+}
+
+// AuthFailureReasonFromError returns why a request failed authentication if err is a status
+// error carrying the reason (see WithAuthFailureDetails). Clients can use it to decide whether
+// to refresh a token or to re-authenticate, e.g. on AuthFailureTokenExpired.
+func AuthFailureReasonFromError(err error) (AuthFailureReason, bool) {
+	var authErr *AuthFailureError
+	if errors.As(err, &authErr) {
+		return authErr.Reason, true
+	}
+	st, ok := status.FromError(err)
+	if !ok {
+		return "", false
+	}
+	for _, detail := range st.Details() {
+		if info, ok := detail.(*errdetails.ErrorInfo); ok && info.Domain == AuthFailureDomain {
+			return AuthFailureReason(info.Reason), true
+		}
+	}
+	return "", false
+}
+
+// An AuthFailureError is an error from a request rejected by authentication along with the
+// reason it was rejected. It keeps the gRPC status of the original error.
+type AuthFailureError struct {
+	Reason AuthFailureReason
+	Err    error
+}
+
+func (e *AuthFailureError) Error() string {
+	return e.Err.Error()
+}
+
+// Unwrap returns the original error.
+func (e *AuthFailureError) Unwrap() error {
+	return e.Err
+}
+
+// GRPCStatus returns the gRPC status of the original error.
+func (e *AuthFailureError) GRPCStatus() *status.Status {
+	return status.Convert(e.Err)
+}
+
+// UnaryClientAuthFailureInterceptor returns a client interceptor which turns Unauthenticated
+// errors carrying an auth failure reason into *AuthFailureError so that callers can inspect
+// the reason with errors.As instead of matching error messages.
+func UnaryClientAuthFailureInterceptor() grpc.UnaryClientInterceptor {
+	return func(
+		ctx context.Context,
+		method string,
+		req, reply interface{},
+		cc *grpc.ClientConn,
+		invoker grpc.UnaryInvoker,
+		opts ...grpc.CallOption,
+	) error {
+		err := invoker(ctx, method, req, reply, cc, opts...)
+		if status.Code(err) != codes.Unauthenticated {
+			return err
+		}
+		if reason, ok := AuthFailureReasonFromError(err); ok {
+			return &AuthFailureError{Reason: reason, Err: err}
+		}
+		return err
+	}
+}
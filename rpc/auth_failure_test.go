@@ -0,0 +1,90 @@
+package rpc
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/golang-jwt/jwt/v4"
+	"go.viam.com/test"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+func TestAuthFailureDetails(t *testing.T) {
+	newServer := func(opts ...ServerOption) *simpleServer {
+		return newAuthTestServer(t, append([]ServerOption{
+			WithAuthHandler("fake", MakeSimpleAuthHandler([]string{"foo"}, "bar")),
+		}, opts...)...)
+	}
+	callUnary := func(ss *simpleServer, ctx context.Context) error {
+		_, err := ss.authUnaryInterceptor(ctx, nil, &grpc.UnaryServerInfo{FullMethod: "/some.Service/Method"},
+			func(ctx context.Context, req interface{}) (interface{}, error) {
+				return nil, nil
+			})
+		return err
+	}
+
+	ss := newServer(WithAuthFailureDetails())
+
+	err := callUnary(ss, context.Background())
+	test.That(t, status.Code(err), test.ShouldEqual, codes.Unauthenticated)
+	reason, ok := AuthFailureReasonFromError(err)
+	test.That(t, ok, test.ShouldBeTrue)
+	test.That(t, reason, test.ShouldEqual, AuthFailureMissingCredentials)
+
+	expiredToken, err := jwt.NewWithClaims(jwt.SigningMethodRS256, JWTClaims{
+		RegisteredClaims: jwt.RegisteredClaims{
+			Audience:  jwt.ClaimStrings{"foo"},
+			ExpiresAt: jwt.NewNumericDate(time.Now().Add(-time.Minute)),
+		},
+		CredentialsType: "fake",
+	}).SignedString(ss.authRSAPrivKey)
+	test.That(t, err, test.ShouldBeNil)
+	err = callUnary(ss, tokenContext(expiredToken))
+	test.That(t, status.Code(err), test.ShouldEqual, codes.Unauthenticated)
+	reason, ok = AuthFailureReasonFromError(err)
+	test.That(t, ok, test.ShouldBeTrue)
+	test.That(t, reason, test.ShouldEqual, AuthFailureTokenExpired)
+
+	// the reason is not revealed by default.
+	err = callUnary(newServer(), context.Background())
+	test.That(t, status.Code(err), test.ShouldEqual, codes.Unauthenticated)
+	_, ok = AuthFailureReasonFromError(err)
+	test.That(t, ok, test.ShouldBeFalse)
+
+	_, ok = AuthFailureReasonFromError(errors.New("not a status"))
+	test.That(t, ok, test.ShouldBeFalse)
+}
+
+func TestUnaryClientAuthFailureInterceptor(t *testing.T) {
+	interceptor := UnaryClientAuthFailureInterceptor()
+	invokeWith := func(invokeErr error) error {
+		return interceptor(context.Background(), "/some.Service/Method", nil, nil, nil,
+			func(ctx context.Context, method string, req, reply interface{}, cc *grpc.ClientConn, opts ...grpc.CallOption) error {
+				return invokeErr
+			})
+	}
+
+	test.That(t, invokeWith(nil), test.ShouldBeNil)
+
+	notFound := status.Error(codes.NotFound, "not found")
+	test.That(t, invokeWith(notFound), test.ShouldEqual, notFound)
+
+	noReason := status.Error(codes.Unauthenticated, "unauthenticated")
+	test.That(t, invokeWith(noReason), test.ShouldEqual, noReason)
+
+	ss := &simpleServer{authFailureDetails: true}
+	withReason := ss.withAuthFailureDetails(status.Error(codes.Unauthenticated, "unauthenticated: expired"), AuthFailureTokenExpired)
+	err := invokeWith(withReason)
+	var authErr *AuthFailureError
+	test.That(t, errors.As(err, &authErr), test.ShouldBeTrue)
+	test.That(t, authErr.Reason, test.ShouldEqual, AuthFailureTokenExpired)
+	test.That(t, status.Code(err), test.ShouldEqual, codes.Unauthenticated)
+	test.That(t, err.Error(), test.ShouldContainSubstring, "unauthenticated: expired")
+	reason, ok := AuthFailureReasonFromError(err)
+	test.That(t, ok, test.ShouldBeTrue)
+	test.That(t, reason, test.ShouldEqual, AuthFailureTokenExpired)
+}
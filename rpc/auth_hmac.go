@@ -0,0 +1,57 @@
+package rpc
+
+import (
+	"github.com/golang-jwt/jwt/v4"
+	"github.com/pkg/errors"
+)
+
+// HMACSecrets are the shared secrets of HS256-family JWTs, keyed by the kid naming them in
+// token headers. Tokens are signed with the current secret and verified with any of them, so
+// secrets can be rotated without downtime: add a new secret, make it current, and remove the
+// prior one once the tokens signed with it have expired.
+type HMACSecrets struct {
+	// CurrentKeyID is the kid of the secret new tokens are signed with.
+	CurrentKeyID string
+	// Secrets holds every secret tokens may be verified with by kid, including the current one.
+	Secrets map[string][]byte
+}
+
+// Sign returns a token for claims signed with the current secret and naming it by kid.
+func (s HMACSecrets) Sign(claims jwt.Claims) (string, error) {
+	secret, ok := s.Secrets[s.CurrentKeyID]
+	if !ok {
+		return "", errors.Errorf("no HMAC secret for current key id %q", s.CurrentKeyID)
+	}
+	token := jwt.NewWithClaims(jwt.SigningMethodHS256, claims)
+	token.Header["kid"] = s.CurrentKeyID
+	return token.SignedString(secret)
+}
+
+// WithHMACTokenVerificationSecrets returns an AuthHandler that verifies HS256-family JWTs with the
+// secret of secrets named by their kid. Tokens without a kid are only verified if there is a
+// single secret.
+func WithHMACTokenVerificationSecrets(handler AuthHandler, secrets HMACSecrets) AuthHandler {
+	byKeyID := make(map[string][]byte, len(secrets.Secrets))
+	for kid, secret := range secrets.Secrets {
+		byKeyID[kid] = secret
+	}
+	return WithTokenVerificationKeyProvider(handler, func(token *jwt.Token) (interface{}, error) {
+		if _, ok := token.Method.(*jwt.SigningMethodHMAC); !ok {
+			return nil, errors.Errorf("unexpected signing method %q", token.Method.Alg())
+		}
+		kid, _ := token.Header["kid"].(string)
+		if kid == "" {
+			if len(byKeyID) != 1 {
+				return nil, errors.New("token is missing kid and there are multiple HMAC secrets")
+			}
+			for _, secret := range byKeyID {
+				return secret, nil
+			}
+		}
+		secret, ok := byKeyID[kid]
+		if !ok {
+			return nil, errors.Errorf("unknown key id %q", kid)
+		}
+		return secret, nil
+	})
+}
@@ -0,0 +1,72 @@
+package rpc
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/rsa"
+	"testing"
+
+	"github.com/golang-jwt/jwt/v4"
+	"go.viam.com/test"
+)
+
+func TestWithHMACTokenVerificationSecrets(t *testing.T) {
+	newProvider := func(secrets HMACSecrets) TokenVerificationKeyProvider {
+		handler := WithHMACTokenVerificationSecrets(MakeFuncAuthHandler(
+			func(ctx context.Context, entity, payload string) (map[string]string, error) {
+				return nil, errInvalidCredentials
+			},
+			func(ctx context.Context, entity string) (interface{}, error) {
+				return entity, nil
+			},
+		), secrets)
+		provider, ok := handler.(TokenVerificationKeyProvider)
+		test.That(t, ok, test.ShouldBeTrue)
+		return provider
+	}
+	claims := jwt.RegisteredClaims{Audience: jwt.ClaimStrings{"someent"}}
+
+	before := HMACSecrets{CurrentKeyID: "old", Secrets: map[string][]byte{"old": []byte("old secret")}}
+	oldToken, err := before.Sign(claims)
+	test.That(t, err, test.ShouldBeNil)
+
+	// rotating to a new secret keeps accepting tokens signed with the prior one.
+	after := HMACSecrets{CurrentKeyID: "new", Secrets: map[string][]byte{
+		"old": []byte("old secret"),
+		"new": []byte("new secret"),
+	}}
+	newToken, err := after.Sign(claims)
+	test.That(t, err, test.ShouldBeNil)
+
+	provider := newProvider(after)
+	_, err = jwt.Parse(oldToken, provider.TokenVerificationKey)
+	test.That(t, err, test.ShouldBeNil)
+	_, err = jwt.Parse(newToken, provider.TokenVerificationKey)
+	test.That(t, err, test.ShouldBeNil)
+
+	// once the prior secret is removed, its tokens are rejected.
+	_, err = jwt.Parse(oldToken, newProvider(HMACSecrets{CurrentKeyID: "new", Secrets: map[string][]byte{
+		"new": []byte("new secret"),
+	}}).TokenVerificationKey)
+	test.That(t, err, test.ShouldNotBeNil)
+	test.That(t, err.Error(), test.ShouldContainSubstring, `unknown key id "old"`)
+
+	noKeyID, err := jwt.NewWithClaims(jwt.SigningMethodHS256, claims).SignedString([]byte("old secret"))
+	test.That(t, err, test.ShouldBeNil)
+	_, err = jwt.Parse(noKeyID, newProvider(before).TokenVerificationKey)
+	test.That(t, err, test.ShouldBeNil)
+	_, err = jwt.Parse(noKeyID, provider.TokenVerificationKey)
+	test.That(t, err, test.ShouldNotBeNil)
+	test.That(t, err.Error(), test.ShouldContainSubstring, "token is missing kid")
+
+	privKey, err := rsa.GenerateKey(rand.Reader, 2048)
+	test.That(t, err, test.ShouldBeNil)
+	rsaToken, err := jwt.NewWithClaims(jwt.SigningMethodRS256, claims).SignedString(privKey)
+	test.That(t, err, test.ShouldBeNil)
+	_, err = jwt.Parse(rsaToken, provider.TokenVerificationKey)
+	test.That(t, err, test.ShouldNotBeNil)
+	test.That(t, err.Error(), test.ShouldContainSubstring, "unexpected signing method")
+
+	_, err = HMACSecrets{CurrentKeyID: "missing"}.Sign(claims)
+	test.That(t, err, test.ShouldNotBeNil)
+}
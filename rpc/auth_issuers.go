@@ -0,0 +1,59 @@
+package rpc
+
+import (
+	"encoding/json"
+
+	"github.com/golang-jwt/jwt/v4"
+	"github.com/pkg/errors"
+)
+
+// WithIssuerTokenVerificationKeyProviders returns an AuthHandler that verifies JWTs with the
+// provider mapped to by the token's "iss" claim. This is helpful when federating across several
+// identity providers, each with its own issuer and keys, such as by mapping each issuer to
+// WithOIDCDiscovery. Tokens without an issuer or with an unmapped one are rejected.
+func WithIssuerTokenVerificationKeyProviders(
+	handler AuthHandler,
+	providers map[string]TokenVerificationKeyProvider,
+) AuthHandler {
+	byIssuer := make(map[string]TokenVerificationKeyProvider, len(providers))
+	for issuer, provider := range providers {
+		byIssuer[issuer] = provider
+	}
+	return WithTokenVerificationKeyProvider(handler, func(token *jwt.Token) (interface{}, error) {
+		issuer, err := tokenIssuer(token.Claims)
+		if err != nil {
+			return nil, err
+		}
+		if issuer == "" {
+			return nil, errors.New("token is missing issuer")
+		}
+		provider, ok := byIssuer[issuer]
+		if !ok {
+			return nil, errors.Errorf("unknown token issuer %q", issuer)
+		}
+		return provider.TokenVerificationKey(token)
+	})
+}
+
+// tokenIssuer returns the "iss" claim of claims, which may be of any type, such as custom claims
+// embedding jwt.RegisteredClaims, as long as the claim is serialized as "iss".
+func tokenIssuer(claims jwt.Claims) (string, error) {
+	switch c := claims.(type) {
+	case jwt.MapClaims:
+		issuer, _ := c["iss"].(string)
+		return issuer, nil
+	case *jwt.RegisteredClaims:
+		return c.Issuer, nil
+	case jwt.RegisteredClaims:
+		return c.Issuer, nil
+	}
+	claimsJSON, err := json.Marshal(claims)
+	if err != nil {
+		return "", errors.Wrap(err, "cannot determine token issuer")
+	}
+	var registered jwt.RegisteredClaims
+	if err := json.Unmarshal(claimsJSON, &registered); err != nil {
+		return "", errors.Wrap(err, "cannot determine token issuer")
+	}
+	return registered.Issuer, nil
+}
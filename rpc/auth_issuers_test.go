@@ -0,0 +1,74 @@
+package rpc
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/rsa"
+	"testing"
+
+	"github.com/golang-jwt/jwt/v4"
+	"go.viam.com/test"
+)
+
+func TestWithIssuerTokenVerificationKeyProviders(t *testing.T) {
+	privKey1, err := rsa.GenerateKey(rand.Reader, 2048)
+	test.That(t, err, test.ShouldBeNil)
+	privKey2, err := rsa.GenerateKey(rand.Reader, 2048)
+	test.That(t, err, test.ShouldBeNil)
+
+	verifyEntity := func(ctx context.Context, entity string) (interface{}, error) {
+		return entity, nil
+	}
+	handler := WithIssuerTokenVerificationKeyProviders(
+		MakeFuncAuthHandler(
+			func(ctx context.Context, entity, payload string) (map[string]string, error) {
+				return nil, errInvalidCredentials
+			},
+			verifyEntity,
+		),
+		map[string]TokenVerificationKeyProvider{
+			"https://idp1": WithPublicKeyProvider(verifyEntity, &privKey1.PublicKey).(TokenVerificationKeyProvider),
+			"https://idp2": WithPublicKeyProvider(verifyEntity, &privKey2.PublicKey).(TokenVerificationKeyProvider),
+		},
+	)
+	provider, ok := handler.(TokenVerificationKeyProvider)
+	test.That(t, ok, test.ShouldBeTrue)
+
+	parseWith := func(privKey *rsa.PrivateKey, issuer string) error {
+		token := jwt.NewWithClaims(jwt.SigningMethodRS256, jwt.RegisteredClaims{
+			Issuer:   issuer,
+			Audience: jwt.ClaimStrings{"someent"},
+		})
+		tokenString, err := token.SignedString(privKey)
+		test.That(t, err, test.ShouldBeNil)
+		_, err = jwt.Parse(tokenString, provider.TokenVerificationKey)
+		return err
+	}
+
+	test.That(t, parseWith(privKey1, "https://idp1"), test.ShouldBeNil)
+	test.That(t, parseWith(privKey2, "https://idp2"), test.ShouldBeNil)
+
+	// each issuer's tokens only verify with its own keys.
+	err = parseWith(privKey2, "https://idp1")
+	test.That(t, err, test.ShouldNotBeNil)
+	test.That(t, err.Error(), test.ShouldContainSubstring, "verification error")
+
+	err = parseWith(privKey1, "https://idp3")
+	test.That(t, err, test.ShouldNotBeNil)
+	test.That(t, err.Error(), test.ShouldContainSubstring, `unknown token issuer "https://idp3"`)
+
+	err = parseWith(privKey1, "")
+	test.That(t, err, test.ShouldNotBeNil)
+	test.That(t, err.Error(), test.ShouldContainSubstring, "token is missing issuer")
+
+	// the issuer of custom claims is read too.
+	tokenString, err := jwt.NewWithClaims(jwt.SigningMethodRS256, JWTClaims{
+		RegisteredClaims: jwt.RegisteredClaims{
+			Issuer:   "https://idp2",
+			Audience: jwt.ClaimStrings{"someent"},
+		},
+	}).SignedString(privKey2)
+	test.That(t, err, test.ShouldBeNil)
+	_, err = jwt.ParseWithClaims(tokenString, &JWTClaims{}, provider.TokenVerificationKey)
+	test.That(t, err, test.ShouldBeNil)
+}
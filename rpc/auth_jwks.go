@@ -0,0 +1,67 @@
+package rpc
+
+import (
+	"crypto/rsa"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"math/big"
+	"net/http"
+)
+
+// rsaKeyID returns the RFC 7638 thumbprint of key for use as a kid.
+func rsaKeyID(key *rsa.PublicKey) string {
+	jwk := jwkFromRSAPublicKey(key, "")
+	// The members must be in lexicographic order with no whitespace.
+	thumbprintInput := `{"e":"` + jwk.E + `","kty":"RSA","n":"` + jwk.N + `"}`
+	sum := sha256.Sum256([]byte(thumbprintInput))
+	return base64.RawURLEncoding.EncodeToString(sum[:])
+}
+
+func jwkFromRSAPublicKey(key *rsa.PublicKey, kid string) jsonWebKey {
+	return jsonWebKey{
+		Kty: "RSA",
+		Kid: kid,
+		Use: "sig",
+		N:   base64.RawURLEncoding.EncodeToString(key.N.Bytes()),
+		E:   base64.RawURLEncoding.EncodeToString(big.NewInt(int64(key.E)).Bytes()),
+	}
+}
+
+// authJWKS returns the keys tokens issued or accepted by the server are verified with.
+func (ss *simpleServer) authJWKS() jsonWebKeySet {
+	keySet := jsonWebKeySet{Keys: []jsonWebKey{}}
+	if ss.authRSAPrivKey != nil {
+		keySet.Keys = append(keySet.Keys, jwkFromRSAPublicKey(&ss.authRSAPrivKey.PublicKey, rsaKeyID(&ss.authRSAPrivKey.PublicKey)))
+	}
+	for _, key := range ss.authRSAVerificationKeys {
+		keySet.Keys = append(keySet.Keys, jwkFromRSAPublicKey(key, rsaKeyID(key)))
+	}
+	return keySet
+}
+
+// A JWKSServer serves the public keys that tokens it issues are verified with. The Server
+// returned by NewServer implements it.
+type JWKSServer interface {
+	// JWKSHandler returns a handler serving the public keys that tokens issued by this server
+	// are verified with as a JSON Web Key Set, so that other services can verify them. The
+	// kid of each key is its RFC 7638 thumbprint.
+	JWKSHandler() http.Handler
+}
+
+// ensure simpleServer implements JWKSServer.
+var _ JWKSServer = (*simpleServer)(nil)
+
+// JWKSHandler returns a handler serving the server's public verification keys as a JWKS.
+func (ss *simpleServer) JWKSHandler() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodGet {
+			w.WriteHeader(http.StatusMethodNotAllowed)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		if err := json.NewEncoder(w).Encode(ss.authJWKS()); err != nil {
+			ss.logger.Errorw("failed to write JWKS", "error", err)
+		}
+	})
+}
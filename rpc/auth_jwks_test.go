@@ -0,0 +1,62 @@
+package rpc
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/rsa"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/golang-jwt/jwt/v4"
+	"go.viam.com/test"
+	"google.golang.org/grpc/metadata"
+
+	rpcpb "go.viam.com/utils/proto/rpc/v1"
+)
+
+func TestServerJWKSHandler(t *testing.T) {
+	currentKey, err := rsa.GenerateKey(rand.Reader, 2048)
+	test.That(t, err, test.ShouldBeNil)
+	previousKey, err := rsa.GenerateKey(rand.Reader, 2048)
+	test.That(t, err, test.ShouldBeNil)
+
+	ss := newAuthTestServer(t,
+		WithAuthHandler("fake", MakeSimpleAuthHandler([]string{"foo"}, "bar")),
+		WithAuthRSAPrivateKey(currentKey),
+		WithAuthRSAVerificationKeys(&previousKey.PublicKey),
+	)
+
+	var jwksServer JWKSServer = ss
+	recorder := httptest.NewRecorder()
+	jwksServer.JWKSHandler().ServeHTTP(recorder, httptest.NewRequest(http.MethodGet, "/jwks", nil))
+	test.That(t, recorder.Code, test.ShouldEqual, http.StatusOK)
+	test.That(t, recorder.Header().Get("Content-Type"), test.ShouldEqual, "application/json")
+
+	var keySet jsonWebKeySet
+	test.That(t, json.Unmarshal(recorder.Body.Bytes(), &keySet), test.ShouldBeNil)
+	test.That(t, keySet.Keys, test.ShouldHaveLength, 2)
+	for i, expected := range []*rsa.PublicKey{&currentKey.PublicKey, &previousKey.PublicKey} {
+		key, err := rsaPublicKeyFromJWK(keySet.Keys[i])
+		test.That(t, err, test.ShouldBeNil)
+		test.That(t, key.Equal(expected), test.ShouldBeTrue)
+		test.That(t, keySet.Keys[i].Kid, test.ShouldEqual, rsaKeyID(expected))
+		test.That(t, keySet.Keys[i].Use, test.ShouldEqual, "sig")
+	}
+	test.That(t, keySet.Keys[0].Kid, test.ShouldNotEqual, keySet.Keys[1].Kid)
+
+	// issued tokens name the current key.
+	resp, err := ss.Authenticate(
+		metadata.NewIncomingContext(context.Background(), metadata.MD{}),
+		&rpcpb.AuthenticateRequest{Entity: "foo", Credentials: &rpcpb.Credentials{Type: "fake", Payload: "bar"}},
+	)
+	test.That(t, err, test.ShouldBeNil)
+	token, _, err := jwt.NewParser().ParseUnverified(resp.AccessToken, jwt.MapClaims{})
+	test.That(t, err, test.ShouldBeNil)
+	test.That(t, token.Header["kid"], test.ShouldEqual, keySet.Keys[0].Kid)
+
+	recorder = httptest.NewRecorder()
+	jwksServer.JWKSHandler().ServeHTTP(recorder, httptest.NewRequest(http.MethodPost, "/jwks", nil))
+	test.That(t, recorder.Code, test.ShouldEqual, http.StatusMethodNotAllowed)
+}
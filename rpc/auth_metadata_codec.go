@@ -0,0 +1,92 @@
+package rpc
+
+import (
+	"bytes"
+	"compress/flate"
+	"encoding/base64"
+	"encoding/json"
+	"io"
+
+	"github.com/pkg/errors"
+)
+
+// An AuthMetadataCodec encodes the auth metadata of the tokens a server issues into the
+// rpc_auth_md_enc claim instead of the rpc_auth_md claim (see WithAuthMetadataCodec), such as
+// to compact large metadata. Every server verifying the tokens must use the same codec.
+type AuthMetadataCodec interface {
+	EncodeAuthMetadata(authMD map[string]string) (string, error)
+	DecodeAuthMetadata(encoded string) (map[string]string, error)
+}
+
+// DeflateAuthMetadataCodec is an AuthMetadataCodec that compresses the JSON of auth metadata with
+// DEFLATE and encodes it as unpadded base64url. It only pays off for large or repetitive metadata
+// since the token is base64url encoded as a whole too.
+var DeflateAuthMetadataCodec AuthMetadataCodec = deflateAuthMetadataCodec{}
+
+// maxDecodedAuthMetadataBytes bounds the decompressed size of auth metadata so that a small
+// token cannot expand into a huge allocation.
+const maxDecodedAuthMetadataBytes = 1 << 20
+
+type deflateAuthMetadataCodec struct{}
+
+func (deflateAuthMetadataCodec) EncodeAuthMetadata(authMD map[string]string) (string, error) {
+	md, err := json.Marshal(authMD)
+	if err != nil {
+		return "", err
+	}
+	var buf bytes.Buffer
+	w, err := flate.NewWriter(&buf, flate.BestCompression)
+	if err != nil {
+		return "", err
+	}
+	if _, err := w.Write(md); err != nil {
+		return "", err
+	}
+	if err := w.Close(); err != nil {
+		return "", err
+	}
+	return base64.RawURLEncoding.EncodeToString(buf.Bytes()), nil
+}
+
+func (deflateAuthMetadataCodec) DecodeAuthMetadata(encoded string) (map[string]string, error) {
+	compressed, err := base64.RawURLEncoding.DecodeString(encoded)
+	if err != nil {
+		return nil, err
+	}
+	r := flate.NewReader(bytes.NewReader(compressed))
+	defer func() {
+		//nolint:errcheck
+		r.Close()
+	}()
+	md, err := io.ReadAll(io.LimitReader(r, maxDecodedAuthMetadataBytes+1))
+	if err != nil {
+		return nil, err
+	}
+	if len(md) > maxDecodedAuthMetadataBytes {
+		return nil, errors.Errorf("auth metadata exceeds %d bytes when decoded", maxDecodedAuthMetadataBytes)
+	}
+	var authMD map[string]string
+	if err := json.Unmarshal(md, &authMD); err != nil {
+		return nil, err
+	}
+	return authMD, nil
+}
+
+// decodeAuthMetadata decodes the rpc_auth_md_enc claim of claims, if it has one, into its auth
+// metadata with the codec of the server.
+func (ss *simpleServer) decodeAuthMetadata(claims Claims) error {
+	jwtClaims, ok := claims.(*JWTClaims)
+	if !ok || jwtClaims.EncodedAuthMetadata == "" {
+		return nil
+	}
+	if ss.authMDCodec == nil {
+		return errors.New("token has encoded auth metadata but no codec is configured")
+	}
+	authMD, err := ss.authMDCodec.DecodeAuthMetadata(jwtClaims.EncodedAuthMetadata)
+	if err != nil {
+		return errors.Wrap(err, "failed to decode auth metadata")
+	}
+	jwtClaims.AuthMetadata = authMD
+	jwtClaims.EncodedAuthMetadata = ""
+	return nil
+}
@@ -0,0 +1,82 @@
+package rpc
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/rsa"
+	"fmt"
+	"strings"
+	"testing"
+
+	"github.com/edaniels/golog"
+	"go.viam.com/test"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+
+	rpcpb "go.viam.com/utils/proto/rpc/v1"
+)
+
+func TestDeflateAuthMetadataCodec(t *testing.T) {
+	authMD := map[string]string{"role": "admin", "org": strings.Repeat("org-1234,", 20)}
+	encoded, err := DeflateAuthMetadataCodec.EncodeAuthMetadata(authMD)
+	test.That(t, err, test.ShouldBeNil)
+	decoded, err := DeflateAuthMetadataCodec.DecodeAuthMetadata(encoded)
+	test.That(t, err, test.ShouldBeNil)
+	test.That(t, decoded, test.ShouldResemble, authMD)
+
+	_, err = DeflateAuthMetadataCodec.DecodeAuthMetadata("not base64!")
+	test.That(t, err, test.ShouldNotBeNil)
+}
+
+func TestServerAuthMetadataCodec(t *testing.T) {
+	logger := golog.NewTestLogger(t)
+	privKey, err := rsa.GenerateKey(rand.Reader, 2048)
+	test.That(t, err, test.ShouldBeNil)
+
+	authMD := make(map[string]string)
+	for i := 0; i < 20; i++ {
+		authMD[fmt.Sprintf("location_%d", i)] = fmt.Sprintf("robot-part-main-%d.location.example.com", i)
+	}
+	newServer := func(opts ...ServerOption) *simpleServer {
+		return newAuthTestServer(t, append([]ServerOption{
+			WithAuthRSAPrivateKey(privKey),
+			WithAuthHandler("fake", MakeFuncAuthHandler(func(ctx context.Context, entity, payload string) (map[string]string, error) {
+				return authMD, nil
+			}, func(ctx context.Context, entity string) (interface{}, error) {
+				return entity, nil
+			})),
+		}, opts...)...)
+	}
+	authenticate := func(ss *simpleServer) string {
+		resp, err := ss.Authenticate(metadata.NewIncomingContext(context.Background(), metadata.MD{}), &rpcpb.AuthenticateRequest{
+			Entity:      "foo",
+			Credentials: &rpcpb.Credentials{Type: "fake", Payload: "something"},
+		})
+		test.That(t, err, test.ShouldBeNil)
+		return resp.GetAccessToken()
+	}
+
+	plainServer := newServer()
+	codecServer := newServer(WithAuthMetadataCodec(DeflateAuthMetadataCodec))
+	plainToken := authenticate(plainServer)
+	codecToken := authenticate(codecServer)
+	test.That(t, len(codecToken), test.ShouldBeLessThan, len(plainToken))
+
+	authCtx, _, err := codecServer.ensureAuthedForMethod(tokenContext(codecToken), "/some.Service/Method")
+	test.That(t, err, test.ShouldBeNil)
+	test.That(t, ContextAuthMetadata(authCtx), test.ShouldResemble, authMD)
+
+	// plain tokens are still understood by servers with a codec.
+	authCtx, _, err = codecServer.ensureAuthedForMethod(tokenContext(plainToken), "/some.Service/Method")
+	test.That(t, err, test.ShouldBeNil)
+	test.That(t, ContextAuthMetadata(authCtx), test.ShouldResemble, authMD)
+
+	// but encoded tokens are not by servers without one.
+	_, err = plainServer.ensureAuthed(tokenContext(codecToken))
+	test.That(t, status.Code(err), test.ShouldEqual, codes.Unauthenticated)
+	test.That(t, err.Error(), test.ShouldContainSubstring, "no codec is configured")
+
+	_, err = NewServer(logger, WithAuthMetadataCodec(nil), WithDisableMulticastDNS())
+	test.That(t, err, test.ShouldNotBeNil)
+}
@@ -0,0 +1,199 @@
+package rpc
+
+import (
+	"context"
+	"crypto/rsa"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"math/big"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/golang-jwt/jwt/v4"
+	"github.com/pkg/errors"
+)
+
+const (
+	oidcDiscoveryPath    = "/.well-known/openid-configuration"
+	oidcDiscoveryTimeout = 10 * time.Second
+)
+
+// WithOIDCDiscovery returns an AuthHandler that verifies JWTs issued by the OIDC provider at
+// issuerURL. The provider's discovery document (issuerURL + /.well-known/openid-configuration)
+// is fetched lazily to find its issuer and jwks_uri; tokens must be RS256-family signed by one of
+// the published keys and carry a matching "iss" claim. The discovery document and keys are cached
+// for cacheTTL before being fetched again. After repeated failures to fetch them, the provider
+// is not contacted for a cooldown and tokens needing it are rejected as Unavailable.
+func WithOIDCDiscovery(handler AuthHandler, issuerURL string, cacheTTL time.Duration) AuthHandler {
+	issuerURL = strings.TrimSuffix(issuerURL, "/")
+	provider := &oidcKeyProvider{
+		issuerURL: issuerURL,
+		cacheTTL:  cacheTTL,
+		client:    http.DefaultClient,
+		breaker:   newCircuitBreaker(issuerURL, verificationBreakerFailureThreshold, verificationBreakerCooldown),
+	}
+	return WithTokenVerificationKeyProvider(handler, provider.TokenVerificationKey)
+}
+
+type oidcDiscoveryDocument struct {
+	Issuer  string `json:"issuer"`
+	JWKSURI string `json:"jwks_uri"`
+}
+
+type jsonWebKey struct {
+	Kty string `json:"kty"`
+	Kid string `json:"kid"`
+	Use string `json:"use"`
+	N   string `json:"n"`
+	E   string `json:"e"`
+}
+
+type jsonWebKeySet struct {
+	Keys []jsonWebKey `json:"keys"`
+}
+
+type oidcKeyProvider struct {
+	issuerURL string
+	cacheTTL  time.Duration
+	client    *http.Client
+	breaker   *circuitBreaker
+
+	mu        sync.Mutex
+	issuer    string
+	keys      map[string]*rsa.PublicKey
+	fetchedAt time.Time
+}
+
+// TokenVerificationKey checks the token's issuer against the discovered one and returns
+// the published key matching the token's kid.
+func (p *oidcKeyProvider) TokenVerificationKey(token *jwt.Token) (interface{}, error) {
+	if _, ok := token.Method.(*jwt.SigningMethodRSA); !ok {
+		return nil, fmt.Errorf("unexpected signing method %q", token.Method.Alg())
+	}
+
+	issuer, keys, err := p.discovered()
+	if err != nil {
+		return nil, err
+	}
+
+	claims, ok := token.Claims.(jwt.MapClaims)
+	if !ok || !claims.VerifyIssuer(issuer, true) {
+		return nil, errors.New("unexpected token issuer")
+	}
+
+	kid, _ := token.Header["kid"].(string)
+	if kid == "" && len(keys) > 1 {
+		return nil, errors.New("token is missing kid and provider has multiple keys")
+	}
+	key, ok := keys[kid]
+	if !ok && kid == "" {
+		for _, onlyKey := range keys {
+			key, ok = onlyKey, true
+		}
+	}
+	if !ok {
+		return nil, fmt.Errorf("unknown key id %q", kid)
+	}
+	return key, nil
+}
+
+// discovered returns the cached issuer and keys, refreshing them if the cache expired.
+func (p *oidcKeyProvider) discovered() (string, map[string]*rsa.PublicKey, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if p.keys != nil && time.Since(p.fetchedAt) < p.cacheTTL {
+		return p.issuer, p.keys, nil
+	}
+
+	if err := p.breaker.allow(); err != nil {
+		return "", nil, err
+	}
+	issuer, keys, err := p.fetch()
+	p.breaker.record(err)
+	if err != nil {
+		return "", nil, err
+	}
+
+	p.issuer = issuer
+	p.keys = keys
+	p.fetchedAt = time.Now()
+	return p.issuer, p.keys, nil
+}
+
+// fetch fetches the discovery document and the keys it points to from the provider.
+func (p *oidcKeyProvider) fetch() (string, map[string]*rsa.PublicKey, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), oidcDiscoveryTimeout)
+	defer cancel()
+
+	var doc oidcDiscoveryDocument
+	if err := p.getJSON(ctx, p.issuerURL+oidcDiscoveryPath, &doc); err != nil {
+		return "", nil, errors.Wrap(err, "failed to fetch OIDC discovery document")
+	}
+	if strings.TrimSuffix(doc.Issuer, "/") != p.issuerURL {
+		return "", nil, fmt.Errorf("OIDC discovery issuer %q does not match %q", doc.Issuer, p.issuerURL)
+	}
+	if doc.JWKSURI == "" {
+		return "", nil, errors.New("OIDC discovery document is missing jwks_uri")
+	}
+
+	var keySet jsonWebKeySet
+	if err := p.getJSON(ctx, doc.JWKSURI, &keySet); err != nil {
+		return "", nil, errors.Wrap(err, "failed to fetch JWKS")
+	}
+	keys := make(map[string]*rsa.PublicKey, len(keySet.Keys))
+	for _, jwk := range keySet.Keys {
+		if jwk.Kty != "RSA" || (jwk.Use != "" && jwk.Use != "sig") {
+			continue
+		}
+		key, err := rsaPublicKeyFromJWK(jwk)
+		if err != nil {
+			return "", nil, err
+		}
+		keys[jwk.Kid] = key
+	}
+	if len(keys) == 0 {
+		return "", nil, errors.New("JWKS contains no usable RSA signing keys")
+	}
+	return doc.Issuer, keys, nil
+}
+
+func (p *oidcKeyProvider) getJSON(ctx context.Context, url string, into interface{}) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+	if err != nil {
+		return err
+	}
+	resp, err := p.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer func() {
+		//nolint:errcheck
+		resp.Body.Close()
+	}()
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("unexpected status %d from %s", resp.StatusCode, url)
+	}
+	return json.NewDecoder(resp.Body).Decode(into)
+}
+
+func rsaPublicKeyFromJWK(jwk jsonWebKey) (*rsa.PublicKey, error) {
+	nBytes, err := base64.RawURLEncoding.DecodeString(jwk.N)
+	if err != nil {
+		return nil, errors.Wrapf(err, "invalid modulus for key %q", jwk.Kid)
+	}
+	eBytes, err := base64.RawURLEncoding.DecodeString(jwk.E)
+	if err != nil {
+		return nil, errors.Wrapf(err, "invalid exponent for key %q", jwk.Kid)
+	}
+	e := new(big.Int).SetBytes(eBytes)
+	if !e.IsInt64() || e.Int64() <= 1 || e.Int64() > 1<<31-1 {
+		return nil, fmt.Errorf("invalid exponent for key %q", jwk.Kid)
+	}
+	return &rsa.PublicKey{
+		N: new(big.Int).SetBytes(nBytes),
+		E: int(e.Int64()),
+	}, nil
+}
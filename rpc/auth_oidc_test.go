@@ -0,0 +1,93 @@
+package rpc
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/rsa"
+	"encoding/base64"
+	"encoding/json"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/golang-jwt/jwt/v4"
+	"go.viam.com/test"
+)
+
+func TestWithOIDCDiscovery(t *testing.T) {
+	privKey, err := rsa.GenerateKey(rand.Reader, 2048)
+	test.That(t, err, test.ShouldBeNil)
+
+	var discoveryFetches int32
+	mux := http.NewServeMux()
+	httpServer := httptest.NewServer(mux)
+	defer httpServer.Close()
+	mux.HandleFunc("/.well-known/openid-configuration", func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&discoveryFetches, 1)
+		json.NewEncoder(w).Encode(map[string]string{
+			"issuer":   httpServer.URL,
+			"jwks_uri": httpServer.URL + "/jwks",
+		})
+	})
+	mux.HandleFunc("/jwks", func(w http.ResponseWriter, r *http.Request) {
+		json.NewEncoder(w).Encode(map[string]interface{}{
+			"keys": []map[string]string{{
+				"kty": "RSA",
+				"kid": "key1",
+				"use": "sig",
+				"n":   base64.RawURLEncoding.EncodeToString(privKey.PublicKey.N.Bytes()),
+				"e":   base64.RawURLEncoding.EncodeToString(big.NewInt(int64(privKey.PublicKey.E)).Bytes()),
+			}},
+		})
+	})
+
+	handler := WithOIDCDiscovery(MakeFuncAuthHandler(
+		func(ctx context.Context, entity, payload string) (map[string]string, error) {
+			return nil, errInvalidCredentials
+		},
+		func(ctx context.Context, entity string) (interface{}, error) {
+			return entity, nil
+		},
+	), httpServer.URL, time.Hour)
+	provider, ok := handler.(TokenVerificationKeyProvider)
+	test.That(t, ok, test.ShouldBeTrue)
+
+	parseWithProvider := func(provider TokenVerificationKeyProvider, issuer, kid string) error {
+		token := jwt.NewWithClaims(jwt.SigningMethodRS256, jwt.RegisteredClaims{
+			Issuer:   issuer,
+			Audience: jwt.ClaimStrings{"someent"},
+		})
+		if kid != "" {
+			token.Header["kid"] = kid
+		}
+		tokenString, err := token.SignedString(privKey)
+		test.That(t, err, test.ShouldBeNil)
+		_, err = jwt.Parse(tokenString, provider.TokenVerificationKey)
+		return err
+	}
+	parseWith := func(issuer, kid string) error {
+		return parseWithProvider(provider, issuer, kid)
+	}
+
+	test.That(t, parseWith(httpServer.URL, "key1"), test.ShouldBeNil)
+	test.That(t, parseWith(httpServer.URL, ""), test.ShouldBeNil)
+
+	err = parseWith("https://someone.else", "key1")
+	test.That(t, err, test.ShouldNotBeNil)
+	test.That(t, err.Error(), test.ShouldContainSubstring, "unexpected token issuer")
+
+	err = parseWith(httpServer.URL, "key2")
+	test.That(t, err, test.ShouldNotBeNil)
+	test.That(t, err.Error(), test.ShouldContainSubstring, "unknown key id")
+
+	// discovery document is cached
+	test.That(t, atomic.LoadInt32(&discoveryFetches), test.ShouldEqual, 1)
+
+	badHandler := WithOIDCDiscovery(handler, httpServer.URL+"/other", time.Hour)
+	err = parseWithProvider(badHandler.(TokenVerificationKeyProvider), httpServer.URL+"/other", "key1")
+	test.That(t, err, test.ShouldNotBeNil)
+	test.That(t, err.Error(), test.ShouldContainSubstring, "failed to fetch OIDC discovery document")
+}
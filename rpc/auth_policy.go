@@ -0,0 +1,173 @@
+package rpc
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"os"
+	"regexp"
+	"strings"
+	"sync"
+
+	"github.com/pkg/errors"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// defaultAuthPolicyMetadataKey is the auth metadata key holding the scopes of an entity when an
+// AuthPolicy document does not name one.
+const defaultAuthPolicyMetadataKey = "scopes"
+
+// authPolicyMethodPattern matches the full gRPC method names of an AuthPolicy, where the method
+// may be "*" for every method of the service.
+var authPolicyMethodPattern = regexp.MustCompile(`^/[^/]+/([^/*]+|\*)$`)
+
+// An AuthPolicy authorizes authenticated requests by the scopes (or roles) each method requires,
+// as defined by a JSON document managed separately from code. It is set with WithAuthPolicy and
+// can be reloaded at any time, such as on SIGHUP, without restarting the server.
+//
+// Example document:
+//
+//	{
+//		"metadata_key": "roles",
+//		"scopes": ["robots:read", "robots:write"],
+//		"methods": {
+//			"/proto.api.robot.v1.RobotService/GetStatus": ["robots:read"],
+//			"/proto.api.robot.v1.RobotService/*": ["robots:write"]
+//		}
+//	}
+//
+// The scopes of an entity are the comma separated values of its auth metadata under
+// metadata_key, which defaults to "scopes". A request must have every scope listed for its
+// method, or for its service's "*" if the method is not listed. Requests to methods not listed
+// either way are denied unless default_allow is true. Every method must be a full gRPC method
+// name and every scope it lists must be declared in scopes.
+type AuthPolicy struct {
+	mu    sync.RWMutex
+	rules authPolicyRules
+}
+
+type authPolicyDocument struct {
+	MetadataKey  string              `json:"metadata_key"`
+	Scopes       []string            `json:"scopes"`
+	DefaultAllow bool                `json:"default_allow"`
+	Methods      map[string][]string `json:"methods"`
+}
+
+type authPolicyRules struct {
+	metadataKey  string
+	defaultAllow bool
+	methods      map[string][]string
+}
+
+// NewAuthPolicy parses and validates an AuthPolicy document.
+func NewAuthPolicy(doc []byte) (*AuthPolicy, error) {
+	var policy AuthPolicy
+	if err := policy.Reload(doc); err != nil {
+		return nil, err
+	}
+	return &policy, nil
+}
+
+// LoadAuthPolicyFile reads an AuthPolicy document from the file at path.
+func LoadAuthPolicyFile(path string) (*AuthPolicy, error) {
+	var policy AuthPolicy
+	if err := policy.ReloadFile(path); err != nil {
+		return nil, err
+	}
+	return &policy, nil
+}
+
+// Reload replaces the policy with the one of doc. If doc is invalid, the policy is left as is.
+func (p *AuthPolicy) Reload(doc []byte) error {
+	rules, err := parseAuthPolicy(doc)
+	if err != nil {
+		return err
+	}
+	p.mu.Lock()
+	p.rules = rules
+	p.mu.Unlock()
+	return nil
+}
+
+// ReloadFile replaces the policy with the one in the file at path. If it is invalid, the policy
+// is left as is.
+func (p *AuthPolicy) ReloadFile(path string) error {
+	//nolint:gosec
+	doc, err := os.ReadFile(path)
+	if err != nil {
+		return errors.Wrap(err, "failed to read auth policy")
+	}
+	return p.Reload(doc)
+}
+
+func parseAuthPolicy(doc []byte) (authPolicyRules, error) {
+	var parsed authPolicyDocument
+	decoder := json.NewDecoder(bytes.NewReader(doc))
+	// a misspelled field would otherwise silently loosen the policy.
+	decoder.DisallowUnknownFields()
+	if err := decoder.Decode(&parsed); err != nil {
+		return authPolicyRules{}, errors.Wrap(err, "failed to parse auth policy")
+	}
+
+	declared := make(map[string]bool, len(parsed.Scopes))
+	for _, scope := range parsed.Scopes {
+		if scope == "" || strings.Contains(scope, ",") {
+			return authPolicyRules{}, errors.Errorf("invalid auth policy scope %q", scope)
+		}
+		declared[scope] = true
+	}
+	methods := make(map[string][]string, len(parsed.Methods))
+	for method, scopes := range parsed.Methods {
+		if !authPolicyMethodPattern.MatchString(method) {
+			return authPolicyRules{}, errors.Errorf("invalid auth policy method %q; expected /<service>/<method>", method)
+		}
+		for _, scope := range scopes {
+			if !declared[scope] {
+				return authPolicyRules{}, errors.Errorf("auth policy method %q requires undeclared scope %q", method, scope)
+			}
+		}
+		methods[method] = scopes
+	}
+
+	metadataKey := parsed.MetadataKey
+	if metadataKey == "" {
+		metadataKey = defaultAuthPolicyMetadataKey
+	}
+	return authPolicyRules{
+		metadataKey:  metadataKey,
+		defaultAllow: parsed.DefaultAllow,
+		methods:      methods,
+	}, nil
+}
+
+// authorize returns a PermissionDenied error if the request in ctx, carrying the auth metadata of
+// the entity, lacks a scope required by fullMethod.
+func (p *AuthPolicy) authorize(ctx context.Context, fullMethod string) error {
+	p.mu.RLock()
+	rules := p.rules
+	p.mu.RUnlock()
+
+	required, ok := rules.methods[fullMethod]
+	if !ok {
+		service := fullMethod[:strings.LastIndex(fullMethod, "/")+1]
+		required, ok = rules.methods[service+"*"]
+	}
+	if !ok {
+		if rules.defaultAllow {
+			return nil
+		}
+		return status.Errorf(codes.PermissionDenied, "%s is not allowed by the auth policy", fullMethod)
+	}
+
+	has := make(map[string]bool)
+	for _, scope := range strings.Split(ContextAuthMetadata(ctx)[rules.metadataKey], ",") {
+		has[strings.TrimSpace(scope)] = true
+	}
+	for _, scope := range required {
+		if !has[scope] {
+			return status.Errorf(codes.PermissionDenied, "%s requires scope %q", fullMethod, scope)
+		}
+	}
+	return nil
+}
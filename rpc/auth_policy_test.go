@@ -0,0 +1,102 @@
+package rpc
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/edaniels/golog"
+	"github.com/golang-jwt/jwt/v4"
+	"go.viam.com/test"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+const testAuthPolicy = `{
+	"metadata_key": "roles",
+	"scopes": ["reader", "writer"],
+	"methods": {
+		"/svc.v1.Service/Get": ["reader"],
+		"/svc.v1.Service/*": ["reader", "writer"]
+	}
+}`
+
+func TestNewAuthPolicy(t *testing.T) {
+	_, err := NewAuthPolicy([]byte(testAuthPolicy))
+	test.That(t, err, test.ShouldBeNil)
+
+	for _, tc := range []struct {
+		doc string
+		err string
+	}{
+		{`{"methods": {"Get": []}}`, `invalid auth policy method "Get"`},
+		{`{"methods": {"/svc.v1.Service/Get/More": []}}`, "invalid auth policy method"},
+		{`{"methods": {"/svc.v1.Service/Get": ["admin"]}}`, `requires undeclared scope "admin"`},
+		{`{"scopes": ["a,b"]}`, `invalid auth policy scope "a,b"`},
+		{`{"default_alow": true}`, "unknown field"},
+		{`not json`, "failed to parse auth policy"},
+	} {
+		_, err := NewAuthPolicy([]byte(tc.doc))
+		test.That(t, err, test.ShouldNotBeNil)
+		test.That(t, err.Error(), test.ShouldContainSubstring, tc.err)
+	}
+}
+
+func TestServerAuthPolicy(t *testing.T) {
+	logger := golog.NewTestLogger(t)
+	policyPath := filepath.Join(t.TempDir(), "policy.json")
+	test.That(t, os.WriteFile(policyPath, []byte(testAuthPolicy), 0o600), test.ShouldBeNil)
+	policy, err := LoadAuthPolicyFile(policyPath)
+	test.That(t, err, test.ShouldBeNil)
+
+	ss := newAuthTestServer(t,
+		WithAuthHandler("fake", MakeSimpleAuthHandler([]string{"foo"}, "something")),
+		WithAuthPolicy(policy),
+	)
+
+	tokenCtx := func(roles string) context.Context {
+		token, err := jwt.NewWithClaims(jwt.SigningMethodRS256, JWTClaims{
+			RegisteredClaims: jwt.RegisteredClaims{
+				Audience:  jwt.ClaimStrings{"foo"},
+				ExpiresAt: jwt.NewNumericDate(time.Now().Add(time.Hour)),
+			},
+			CredentialsType: CredentialsType("fake"),
+			AuthMetadata:    map[string]string{"roles": roles},
+		}).SignedString(ss.authRSAPrivKey)
+		test.That(t, err, test.ShouldBeNil)
+		return tokenContext(token)
+	}
+	authorize := func(roles, method string) (AuthFailureReason, error) {
+		_, reason, err := ss.ensureAuthedForMethod(tokenCtx(roles), method)
+		return reason, err
+	}
+
+	_, err = authorize("reader", "/svc.v1.Service/Get")
+	test.That(t, err, test.ShouldBeNil)
+	_, err = authorize("reader,writer", "/svc.v1.Service/Put")
+	test.That(t, err, test.ShouldBeNil)
+
+	reason, err := authorize("reader", "/svc.v1.Service/Put")
+	test.That(t, status.Code(err), test.ShouldEqual, codes.PermissionDenied)
+	test.That(t, err.Error(), test.ShouldContainSubstring, `requires scope "writer"`)
+	test.That(t, reason, test.ShouldEqual, AuthFailureRequestRejected)
+
+	// unlisted methods are denied by default.
+	_, err = authorize("reader,writer", "/other.v1.Service/Get")
+	test.That(t, status.Code(err), test.ShouldEqual, codes.PermissionDenied)
+
+	// reloading applies to subsequent requests and invalid documents keep the current policy.
+	test.That(t, os.WriteFile(policyPath, []byte(`{"default_allow": true}`), 0o600), test.ShouldBeNil)
+	test.That(t, policy.ReloadFile(policyPath), test.ShouldBeNil)
+	_, err = authorize("", "/svc.v1.Service/Put")
+	test.That(t, err, test.ShouldBeNil)
+
+	test.That(t, policy.Reload([]byte(`{"methods": {"Put": []}}`)), test.ShouldNotBeNil)
+	_, err = authorize("", "/svc.v1.Service/Put")
+	test.That(t, err, test.ShouldBeNil)
+
+	_, err = NewServer(logger, WithAuthPolicy(nil))
+	test.That(t, err, test.ShouldNotBeNil)
+}
@@ -0,0 +1,70 @@
+package rpc
+
+import (
+	"context"
+	"crypto/x509"
+
+	"github.com/edaniels/golog"
+	"github.com/pkg/errors"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// An Authenticator authenticates credentials exactly as a Server would but without any gRPC
+// transport, metadata, or peer plumbing. It is meant for focused tests of AuthHandlers, custom
+// claims, key providers, and TLS auth handlers.
+type Authenticator struct {
+	ss *simpleServer
+}
+
+// NewAuthenticator returns an Authenticator configured by the authentication related
+// ServerOptions (e.g. WithAuthHandler, WithTLSAuthHandler, WithAuthRSAPrivateKey); the rest are
+// ignored. Like NewAuthInterceptors, it does not issue tokens, so internally signed tokens are
+// only accepted if WithAuthRSAPrivateKey or WithAuthRSAVerificationKeys is given the key of the
+// server that issued them.
+func NewAuthenticator(logger golog.Logger, opts ...ServerOption) (*Authenticator, error) {
+	var sOpts serverOptions
+	for _, opt := range opts {
+		if err := opt.apply(&sOpts); err != nil {
+			return nil, err
+		}
+	}
+	if sOpts.unauthenticated {
+		return nil, errors.New("cannot create an authenticator that is unauthenticated")
+	}
+	if err := sOpts.validateAuth(); err != nil {
+		return nil, err
+	}
+	authRSAPrivKey, err := sOpts.authRSAPrivateKeyOrGenerate()
+	if err != nil {
+		return nil, err
+	}
+
+	ss := &simpleServer{logger: logger}
+	ss.setAuth(&sOpts, authRSAPrivKey)
+	return &Authenticator{ss: ss}, nil
+}
+
+// AuthenticateToken authenticates a request made with the given access token. On success it
+// returns the context a handler would be called with, carrying the auth entity (see
+// MustContextAuthEntity), claims, and auth metadata. On failure the error is an *AuthFailureError.
+// ctx stands in for the request's context, e.g. for proof of possession metadata.
+func (a *Authenticator) AuthenticateToken(ctx context.Context, token string) (context.Context, error) {
+	authCtx, authEntity, _, reason, err := a.ss.authenticateToken(ctx, token)
+	if err != nil {
+		return nil, &AuthFailureError{Reason: reason, Err: err}
+	}
+	return ContextWithAuthEntity(authCtx, authEntity), nil
+}
+
+// AuthenticateCertificate authenticates a request made with the given verified client
+// certificate and no token, via the handler of WithTLSAuthHandler. It otherwise behaves like
+// AuthenticateToken.
+func (a *Authenticator) AuthenticateCertificate(ctx context.Context, cert *x509.Certificate) (context.Context, error) {
+	authCtx, authEntity, _, reason, err := a.ss.authenticateCert(ctx, cert,
+		status.Error(codes.Unauthenticated, "authentication required"))
+	if err != nil {
+		return nil, &AuthFailureError{Reason: reason, Err: err}
+	}
+	return ContextWithAuthEntity(authCtx, authEntity), nil
+}
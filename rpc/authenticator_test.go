@@ -0,0 +1,95 @@
+package rpc
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/x509"
+	"errors"
+	"testing"
+
+	"github.com/edaniels/golog"
+	"github.com/golang-jwt/jwt/v4"
+	"go.viam.com/test"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+func TestAuthenticatorToken(t *testing.T) {
+	logger := golog.NewTestLogger(t)
+	privKey, err := rsa.GenerateKey(rand.Reader, generatedRSAKeyBits)
+	test.That(t, err, test.ShouldBeNil)
+
+	authenticator, err := NewAuthenticator(
+		logger,
+		WithAuthRSAPrivateKey(privKey),
+		WithAuthHandler("fake", MakeSimpleAuthHandler([]string{"foo"}, "something")),
+	)
+	test.That(t, err, test.ShouldBeNil)
+
+	signedToken := func(entity string, key *rsa.PrivateKey) string {
+		token, err := jwt.NewWithClaims(jwt.SigningMethodRS256, JWTClaims{
+			RegisteredClaims: jwt.RegisteredClaims{
+				Audience: jwt.ClaimStrings{entity},
+			},
+			CredentialsType: CredentialsType("fake"),
+			AuthMetadata:    map[string]string{"tenant": "a"},
+		}).SignedString(key)
+		test.That(t, err, test.ShouldBeNil)
+		return token
+	}
+
+	ctx, err := authenticator.AuthenticateToken(context.Background(), signedToken("foo", privKey))
+	test.That(t, err, test.ShouldBeNil)
+	test.That(t, MustContextAuthEntity(ctx), test.ShouldEqual, "foo")
+	test.That(t, ContextAuthMetadata(ctx), test.ShouldResemble, map[string]string{"tenant": "a"})
+
+	_, err = authenticator.AuthenticateToken(context.Background(), signedToken("bar", privKey))
+	test.That(t, status.Code(err), test.ShouldEqual, codes.Unauthenticated)
+	reason, ok := AuthFailureReasonFromError(err)
+	test.That(t, ok, test.ShouldBeTrue)
+	test.That(t, reason, test.ShouldEqual, AuthFailureEntityVerification)
+
+	otherKey, err := rsa.GenerateKey(rand.Reader, generatedRSAKeyBits)
+	test.That(t, err, test.ShouldBeNil)
+	_, err = authenticator.AuthenticateToken(context.Background(), signedToken("foo", otherKey))
+	test.That(t, status.Code(err), test.ShouldEqual, codes.Unauthenticated)
+	reason, ok = AuthFailureReasonFromError(err)
+	test.That(t, ok, test.ShouldBeTrue)
+	test.That(t, reason, test.ShouldEqual, AuthFailureInvalidToken)
+
+	_, err = NewAuthenticator(logger, WithUnauthenticated())
+	test.That(t, err, test.ShouldNotBeNil)
+}
+
+func TestAuthenticatorCertificate(t *testing.T) {
+	logger := golog.NewTestLogger(t)
+	authenticator, err := NewAuthenticator(
+		logger,
+		WithTLSAuthHandler([]string{"foo"}, func(ctx context.Context, entities ...string) (interface{}, error) {
+			for _, entity := range entities {
+				if entity == "bad" {
+					return nil, errors.New("bad entity")
+				}
+			}
+			return entities, nil
+		}),
+	)
+	test.That(t, err, test.ShouldBeNil)
+
+	ctx, err := authenticator.AuthenticateCertificate(context.Background(), &x509.Certificate{DNSNames: []string{"foo"}})
+	test.That(t, err, test.ShouldBeNil)
+	test.That(t, MustContextAuthEntity(ctx), test.ShouldResemble, []string{"foo"})
+
+	_, err = authenticator.AuthenticateCertificate(context.Background(), &x509.Certificate{DNSNames: []string{"bar"}})
+	test.That(t, status.Code(err), test.ShouldEqual, codes.Unauthenticated)
+	reason, ok := AuthFailureReasonFromError(err)
+	test.That(t, ok, test.ShouldBeTrue)
+	test.That(t, reason, test.ShouldEqual, AuthFailureMissingCredentials)
+
+	_, err = authenticator.AuthenticateCertificate(context.Background(), &x509.Certificate{DNSNames: []string{"foo", "bad"}})
+	test.That(t, err, test.ShouldNotBeNil)
+	reason, ok = AuthFailureReasonFromError(err)
+	test.That(t, ok, test.ShouldBeTrue)
+	test.That(t, reason, test.ShouldEqual, AuthFailureEntityVerification)
+}
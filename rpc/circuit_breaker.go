@@ -0,0 +1,83 @@
+package rpc
+
+import (
+	"sync"
+	"time"
+
+	"github.com/pkg/errors"
+
+	"go.viam.com/utils/perf/statz"
+	"go.viam.com/utils/perf/statz/units"
+)
+
+const (
+	// verificationBreakerFailureThreshold is the number of consecutive failures of an external
+	// verification backend after which it is no longer called for verificationBreakerCooldown.
+	verificationBreakerFailureThreshold = 5
+	verificationBreakerCooldown         = 30 * time.Second
+)
+
+// errVerificationBackendUnavailable is returned instead of calling an external verification
+// backend while its circuit breaker is open. Requests failing with it are rejected as Unavailable.
+var errVerificationBackendUnavailable = errors.New("verification backend is unavailable")
+
+var verificationBreakerOpen = statz.NewGauge1[string]("rpc/auth/verification_breaker_open", statz.MetricConfig{
+	Description: "Whether the circuit breaker of an external verification backend is open (1) or closed (0).",
+	Unit:        units.Dimensionless,
+	Labels: []statz.Label{
+		{Name: "backend", Description: "The backend, such as the issuer URL of an OIDC provider."},
+	},
+})
+
+// A circuitBreaker protects a flaky backend, and the requests waiting on it, by failing fast for
+// a cooldown after repeated consecutive failures. Once the cooldown passes, calls are let through
+// again; the first failure reopens the breaker while a success closes it.
+type circuitBreaker struct {
+	backend          string
+	failureThreshold int
+	cooldown         time.Duration
+
+	mu        sync.Mutex
+	failures  int
+	openUntil time.Time
+}
+
+func newCircuitBreaker(backend string, failureThreshold int, cooldown time.Duration) *circuitBreaker {
+	verificationBreakerOpen.Set(0, backend)
+	return &circuitBreaker{
+		backend:          backend,
+		failureThreshold: failureThreshold,
+		cooldown:         cooldown,
+	}
+}
+
+// allow returns errVerificationBackendUnavailable if the breaker is open.
+func (b *circuitBreaker) allow() error {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if time.Now().Before(b.openUntil) {
+		return errors.Wrapf(errVerificationBackendUnavailable, "too many failures from %s", b.backend)
+	}
+	return nil
+}
+
+// record records the outcome of a call to the backend that allow let through.
+func (b *circuitBreaker) record(err error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if err == nil {
+		if b.failures >= b.failureThreshold {
+			verificationBreakerOpen.Set(0, b.backend)
+		}
+		b.failures = 0
+		b.openUntil = time.Time{}
+		return
+	}
+	b.failures++
+	if b.failures >= b.failureThreshold {
+		if b.failures == b.failureThreshold {
+			verificationBreakerOpen.Set(1, b.backend)
+		}
+		b.openUntil = time.Now().Add(b.cooldown)
+	}
+}
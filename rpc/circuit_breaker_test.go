@@ -0,0 +1,83 @@
+package rpc
+
+import (
+	"crypto/rand"
+	"crypto/rsa"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/golang-jwt/jwt/v4"
+	"go.viam.com/test"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+
+	"go.viam.com/utils/perf/statz/statztest"
+)
+
+func TestCircuitBreaker(t *testing.T) {
+	recorder := statztest.NewGaugeRecorder("rpc/auth/verification_breaker_open")
+	breaker := newCircuitBreaker("test-backend", 2, 50*time.Millisecond)
+	errBackend := errors.New("whoops")
+
+	test.That(t, breaker.allow(), test.ShouldBeNil)
+	breaker.record(errBackend)
+	test.That(t, breaker.allow(), test.ShouldBeNil)
+	test.That(t, recorder.Value("backend", "test-backend"), test.ShouldEqual, 0)
+	breaker.record(errBackend)
+	err := breaker.allow()
+	test.That(t, errors.Is(err, errVerificationBackendUnavailable), test.ShouldBeTrue)
+	test.That(t, recorder.Value("backend", "test-backend"), test.ShouldEqual, 1)
+
+	// after the cooldown a probe is let through and its failure reopens the breaker right away.
+	time.Sleep(60 * time.Millisecond)
+	test.That(t, breaker.allow(), test.ShouldBeNil)
+	breaker.record(errBackend)
+	test.That(t, errors.Is(breaker.allow(), errVerificationBackendUnavailable), test.ShouldBeTrue)
+
+	// while a successful probe closes it.
+	time.Sleep(60 * time.Millisecond)
+	test.That(t, breaker.allow(), test.ShouldBeNil)
+	breaker.record(nil)
+	test.That(t, recorder.Value("backend", "test-backend"), test.ShouldEqual, 0)
+	breaker.record(errBackend)
+	test.That(t, breaker.allow(), test.ShouldBeNil)
+}
+
+func TestServerAuthVerificationBackendUnavailable(t *testing.T) {
+	privKey, err := rsa.GenerateKey(rand.Reader, 2048)
+	test.That(t, err, test.ShouldBeNil)
+
+	var fetches int32
+	httpServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&fetches, 1)
+		w.WriteHeader(http.StatusServiceUnavailable)
+	}))
+	defer httpServer.Close()
+
+	ss := newAuthTestServer(t,
+		WithAuthHandler("oidc", WithOIDCDiscovery(MakeSimpleAuthHandler([]string{"foo"}, "bar"), httpServer.URL, time.Hour)),
+	)
+
+	tokenString, err := jwt.NewWithClaims(jwt.SigningMethodRS256, jwt.MapClaims{
+		"iss":            httpServer.URL,
+		"aud":            "foo",
+		"rpc_creds_type": "oidc",
+	}).SignedString(privKey)
+	test.That(t, err, test.ShouldBeNil)
+	ctx := tokenContext(tokenString)
+
+	for i := 0; i < verificationBreakerFailureThreshold; i++ {
+		_, err = ss.ensureAuthed(ctx)
+		test.That(t, status.Code(err), test.ShouldEqual, codes.Unauthenticated)
+	}
+	test.That(t, atomic.LoadInt32(&fetches), test.ShouldEqual, verificationBreakerFailureThreshold)
+
+	_, err = ss.ensureAuthed(ctx)
+	test.That(t, status.Code(err), test.ShouldEqual, codes.Unavailable)
+	test.That(t, err.Error(), test.ShouldContainSubstring, "verification backend is unavailable")
+	test.That(t, atomic.LoadInt32(&fetches), test.ShouldEqual, verificationBreakerFailureThreshold)
+}
@@ -3,8 +3,11 @@ package rpc
 import (
 	"context"
 	"errors"
+	"time"
 
 	"github.com/pion/webrtc/v3"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
 )
 
 type ctxKey int
@@ -16,6 +19,10 @@ const (
 	ctxKeyAuthMetadata
 	ctxKeyAuthEntity
 	ctxKeyAuthClaims // all jwt claims
+	ctxKeyAuthTokenHeader
+	ctxKeyAuthTokenIssuedAt
+	ctxKeyAuthCredentialsType
+	ctxKeyTLSAuthMetadata
 )
 
 // contextWithHost attaches a host name to the given context.
@@ -65,9 +72,31 @@ func contextWithAuthMetadata(ctx context.Context, authMD map[string]string) cont
 	return context.WithValue(ctx, ctxKeyAuthMetadata, authMD)
 }
 
-// ContextAuthMetadata returns authentication metadata. It may be nil if the value was never set.
+// ContextAuthMetadata returns the authentication metadata of the token that authenticated the
+// request. It is available to the handlers of authenticated methods as well as to AuthHandlers
+// verifying an entity. It may be nil if the value was never set.
 func ContextAuthMetadata(ctx context.Context) map[string]string {
-	authMD := ctx.Value(ctxKeyAuthMetadata)
+	authMD, _ := ContextAuthMetadataOK(ctx)
+	return authMD
+}
+
+// ContextAuthMetadataOK is like ContextAuthMetadata but also returns whether the metadata was
+// set, which distinguishes an unauthenticated request from one authenticated without metadata.
+func ContextAuthMetadataOK(ctx context.Context) (map[string]string, bool) {
+	authMD, ok := ctx.Value(ctxKeyAuthMetadata).(map[string]string)
+	return authMD, ok
+}
+
+// contextWithTLSAuthMetadata attaches the authentication metadata of a request authenticated via
+// TLS to the given context.
+func contextWithTLSAuthMetadata(ctx context.Context, authMD map[string]string) context.Context {
+	return context.WithValue(ctx, ctxKeyTLSAuthMetadata, authMD)
+}
+
+// contextTLSAuthMetadata returns the authentication metadata of a request authenticated via TLS.
+// It is nil for requests authenticated otherwise.
+func contextTLSAuthMetadata(ctx context.Context) map[string]string {
+	authMD := ctx.Value(ctxKeyTLSAuthMetadata)
 	if authMD == nil {
 		return nil
 	}
@@ -79,7 +108,8 @@ func contextWithAuthClaims(ctx context.Context, claims Claims) context.Context {
 	return context.WithValue(ctx, ctxKeyAuthClaims, claims)
 }
 
-// ContextAuthClaims returns authentication jwt claims.
+// ContextAuthClaims returns authentication jwt claims, after any WithAuthClaimsRedactor is applied.
+// Like ContextAuthMetadata, it is available to handlers and AuthHandlers.
 func ContextAuthClaims(ctx context.Context) Claims {
 	claims := ctx.Value(ctxKeyAuthClaims)
 	if claims == nil {
@@ -88,6 +118,42 @@ func ContextAuthClaims(ctx context.Context) Claims {
 	return claims.(Claims)
 }
 
+// contextWithAuthTokenHeader attaches the header of the authentication jwt to the given context.
+func contextWithAuthTokenHeader(ctx context.Context, header TokenHeader) context.Context {
+	return context.WithValue(ctx, ctxKeyAuthTokenHeader, header)
+}
+
+// ContextAuthTokenHeader returns the header of the authentication jwt, if any.
+func ContextAuthTokenHeader(ctx context.Context) (TokenHeader, bool) {
+	header, ok := ctx.Value(ctxKeyAuthTokenHeader).(TokenHeader)
+	return header, ok
+}
+
+// contextWithCredentialsType attaches the credential type of the authentication jwt to the given context.
+func contextWithCredentialsType(ctx context.Context, credType CredentialsType) context.Context {
+	return context.WithValue(ctx, ctxKeyAuthCredentialsType, credType)
+}
+
+// ContextCredentialsType returns the credential type of the token that authenticated the request,
+// such as CredentialsTypeAPIKey, which is useful for policies based on how the caller authenticated.
+// It is unaffected by WithAuthClaimsRedactor. It is not set for requests authenticated via TLS (see
+// WithTLSAuthHandler) since they have no token.
+func ContextCredentialsType(ctx context.Context) (CredentialsType, bool) {
+	credType, ok := ctx.Value(ctxKeyAuthCredentialsType).(CredentialsType)
+	return credType, ok
+}
+
+// contextWithAuthTokenIssuedAt attaches when the authentication jwt was issued to the given context.
+func contextWithAuthTokenIssuedAt(ctx context.Context, issuedAt time.Time) context.Context {
+	return context.WithValue(ctx, ctxKeyAuthTokenIssuedAt, issuedAt)
+}
+
+// contextAuthTokenIssuedAt returns when the authentication jwt was issued, if it says.
+func contextAuthTokenIssuedAt(ctx context.Context) (time.Time, bool) {
+	issuedAt, ok := ctx.Value(ctxKeyAuthTokenIssuedAt).(time.Time)
+	return issuedAt, ok
+}
+
 // ContextWithAuthEntity attaches authentication metadata to the given context.
 func ContextWithAuthEntity(ctx context.Context, authEntity interface{}) context.Context {
 	return context.WithValue(ctx, ctxKeyAuthEntity, authEntity)
@@ -111,3 +177,15 @@ func MustContextAuthEntity(ctx context.Context) interface{} {
 	}
 	return authEntity
 }
+
+// MustAuthEntity returns the authentication entity associated with this context for handlers
+// that require an authenticated caller. If there is none, which means the method was not
+// authenticated by the server (e.g. it is exempt from authentication), an Unauthenticated
+// error is returned that handlers should return as is.
+func MustAuthEntity(ctx context.Context) (interface{}, error) {
+	authEntity, err := contextAuthEntity(ctx)
+	if err != nil {
+		return nil, status.Error(codes.Unauthenticated, "authentication required")
+	}
+	return authEntity, nil
+}
@@ -6,6 +6,8 @@ import (
 
 	"github.com/pion/webrtc/v3"
 	"go.viam.com/test"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
 )
 
 func TestContextHost(t *testing.T) {
@@ -38,3 +40,32 @@ func TestContextPeerConnection(t *testing.T) {
 	test.That(t, ok, test.ShouldBeTrue)
 	test.That(t, pc2, test.ShouldEqual, &pc)
 }
+
+func TestContextAuthMetadata(t *testing.T) {
+	authMD, ok := ContextAuthMetadataOK(context.Background())
+	test.That(t, ok, test.ShouldBeFalse)
+	test.That(t, authMD, test.ShouldBeNil)
+	test.That(t, ContextAuthMetadata(context.Background()), test.ShouldBeNil)
+
+	ctx := contextWithAuthMetadata(context.Background(), nil)
+	authMD, ok = ContextAuthMetadataOK(ctx)
+	test.That(t, ok, test.ShouldBeTrue)
+	test.That(t, authMD, test.ShouldBeNil)
+
+	ctx = contextWithAuthMetadata(context.Background(), map[string]string{"tenant": "a"})
+	authMD, ok = ContextAuthMetadataOK(ctx)
+	test.That(t, ok, test.ShouldBeTrue)
+	test.That(t, authMD, test.ShouldResemble, map[string]string{"tenant": "a"})
+	test.That(t, ContextAuthMetadata(ctx), test.ShouldResemble, map[string]string{"tenant": "a"})
+}
+
+func TestMustAuthEntity(t *testing.T) {
+	_, err := MustAuthEntity(context.Background())
+	test.That(t, err, test.ShouldNotBeNil)
+	test.That(t, status.Code(err), test.ShouldEqual, codes.Unauthenticated)
+
+	ctx := ContextWithAuthEntity(context.Background(), "foo")
+	authEntity, err := MustAuthEntity(ctx)
+	test.That(t, err, test.ShouldBeNil)
+	test.That(t, authEntity, test.ShouldEqual, "foo")
+}
@@ -76,7 +76,15 @@ connected to. This is an extension that can be used via the WithAuthenticateToHa
 When a client is connecting, it will first connect to the external auth server with credentials intended
 for that server, and then it will call AuthenticateTo to get a JWT destined for the actual target being
 connected to. AuthenticateTo requires an entity to authenticate as. You can think of this feature as
-the ability to assume the role of another entity.
+the ability to assume the role of another entity. Handlers that call another service on behalf of
+their caller can use OutgoingContextAuthenticatedTo to exchange the caller's JWT for one destined for
+that service.
+
+Servers configured with WithProofOfPossession let clients bind the JWT returned by Authenticate to an
+ed25519 key they hold by sending the public key in the MetadataFieldProofOfPossessionKey metadata field.
+Every request made with a bound JWT must then carry a fresh signature over it in the
+MetadataFieldProofOfPossession metadata field (see SignProofOfPossession), so that a leaked JWT is not
+usable on its own. JWTs issued without a key are unaffected.
 
 Expiration of JWTs is not yet handled/support; see:
 - https://github.com/viamrobotics/goutils/issues/10
@@ -0,0 +1,64 @@
+package rpc
+
+import (
+	"context"
+	"fmt"
+	"sync"
+
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// entityConcurrencyLimiter bounds the number of in-flight requests of each authenticated entity.
+type entityConcurrencyLimiter struct {
+	limit int
+
+	mu       sync.Mutex
+	inFlight map[string]int
+}
+
+func newEntityConcurrencyLimiter(limit int) *entityConcurrencyLimiter {
+	return &entityConcurrencyLimiter{
+		limit:    limit,
+		inFlight: map[string]int{},
+	}
+}
+
+// acquire takes a slot for the entity of the request in ctx and returns the function that gives
+// it back. Requests without an auth entity, such as to exempt methods, are not limited.
+func (l *entityConcurrencyLimiter) acquire(ctx context.Context) (func(), error) {
+	authEntity, err := contextAuthEntity(ctx)
+	if err != nil {
+		return func() {}, nil
+	}
+	key := entityConcurrencyKey(authEntity)
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if l.inFlight[key] >= l.limit {
+		return nil, status.Errorf(codes.ResourceExhausted, "too many concurrent requests; at most %d are allowed per entity", l.limit)
+	}
+	l.inFlight[key]++
+	return func() {
+		l.mu.Lock()
+		defer l.mu.Unlock()
+		l.inFlight[key]--
+		if l.inFlight[key] == 0 {
+			delete(l.inFlight, key)
+		}
+	}, nil
+}
+
+// entityConcurrencyKey returns the key to count an auth entity under. Entities are opaque and
+// may not be comparable (e.g. the entities of TLS authentication), so they are keyed by their
+// string form.
+func entityConcurrencyKey(authEntity interface{}) string {
+	switch e := authEntity.(type) {
+	case string:
+		return e
+	case fmt.Stringer:
+		return e.String()
+	default:
+		return fmt.Sprintf("%v", e)
+	}
+}
@@ -0,0 +1,81 @@
+package rpc
+
+import (
+	"context"
+	"testing"
+
+	"github.com/edaniels/golog"
+	"go.viam.com/test"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+
+	rpcpb "go.viam.com/utils/proto/rpc/v1"
+)
+
+func TestPerEntityConcurrencyLimit(t *testing.T) {
+	logger := golog.NewTestLogger(t)
+	ss := newAuthTestServer(t,
+		WithAuthHandler("fake", MakeSimpleAuthHandler([]string{"foo", "bar"}, "something")),
+		WithPerEntityConcurrencyLimit(1),
+	)
+
+	entityCtx := func(entity string) context.Context {
+		authResp, err := ss.Authenticate(metadata.NewIncomingContext(context.Background(), metadata.MD{}), &rpcpb.AuthenticateRequest{
+			Entity:      entity,
+			Credentials: &rpcpb.Credentials{Type: "fake", Payload: "something"},
+		})
+		test.That(t, err, test.ShouldBeNil)
+		return tokenContext(authResp.GetAccessToken())
+	}
+	fooCtx := entityCtx("foo")
+	barCtx := entityCtx("bar")
+	info := &grpc.UnaryServerInfo{FullMethod: "/some.Service/Method"}
+	okHandler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return nil, nil
+	}
+
+	inHandler := make(chan struct{})
+	unblock := make(chan struct{})
+	errCh := make(chan error, 1)
+	go func() {
+		_, err := ss.authUnaryInterceptor(fooCtx, nil, info, func(ctx context.Context, req interface{}) (interface{}, error) {
+			close(inHandler)
+			<-unblock
+			return nil, nil
+		})
+		errCh <- err
+	}()
+	<-inHandler
+
+	// foo is at its limit for both unary calls and streams while bar is unaffected.
+	_, err := ss.authUnaryInterceptor(fooCtx, nil, info, okHandler)
+	test.That(t, status.Code(err), test.ShouldEqual, codes.ResourceExhausted)
+	err = ss.authStreamInterceptor(nil, &fakeServerStream{ctx: fooCtx}, &grpc.StreamServerInfo{FullMethod: info.FullMethod},
+		func(srv interface{}, stream grpc.ServerStream) error {
+			return nil
+		})
+	test.That(t, status.Code(err), test.ShouldEqual, codes.ResourceExhausted)
+	_, err = ss.authUnaryInterceptor(barCtx, nil, info, okHandler)
+	test.That(t, err, test.ShouldBeNil)
+
+	close(unblock)
+	test.That(t, <-errCh, test.ShouldBeNil)
+	_, err = ss.authUnaryInterceptor(fooCtx, nil, info, okHandler)
+	test.That(t, err, test.ShouldBeNil)
+
+	// a panicking handler gives its slot back.
+	test.That(t, func() {
+		//nolint:errcheck
+		ss.authUnaryInterceptor(fooCtx, nil, info, func(ctx context.Context, req interface{}) (interface{}, error) {
+			panic("whoops")
+		})
+	}, test.ShouldPanic)
+	_, err = ss.authUnaryInterceptor(fooCtx, nil, info, okHandler)
+	test.That(t, err, test.ShouldBeNil)
+	test.That(t, ss.entityConcurrency.inFlight, test.ShouldBeEmpty)
+
+	_, err = NewServer(logger, WithPerEntityConcurrencyLimit(0))
+	test.That(t, err, test.ShouldNotBeNil)
+}
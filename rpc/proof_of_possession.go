@@ -0,0 +1,127 @@
+package rpc
+
+import (
+	"context"
+	"crypto/ed25519"
+	"encoding/base64"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/golang-jwt/jwt/v4"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+)
+
+const (
+	// MetadataFieldProofOfPossessionKey is the request metadata field a client sets on
+	// Authenticate to bind the issued token to its ed25519 public key. The value is made
+	// with EncodeProofOfPossessionKey.
+	MetadataFieldProofOfPossessionKey = "rpc-pop-key"
+
+	// MetadataFieldProofOfPossession is the request metadata field carrying the proof that the
+	// caller holds the key a token is bound to. The value is made with SignProofOfPossession.
+	MetadataFieldProofOfPossession = "rpc-pop-proof"
+
+	// claimProofOfPossessionKey is the claim holding the key a token is bound to.
+	claimProofOfPossessionKey = "rpc_pop_key"
+
+	// defaultProofOfPossessionMaxSkew is how old or far in the future a proof may be when the
+	// server was not configured with WithProofOfPossession.
+	defaultProofOfPossessionMaxSkew = time.Minute
+)
+
+// EncodeProofOfPossessionKey encodes pub as the value of MetadataFieldProofOfPossessionKey.
+func EncodeProofOfPossessionKey(pub ed25519.PublicKey) string {
+	return base64.RawURLEncoding.EncodeToString(pub)
+}
+
+// SignProofOfPossession returns the value of MetadataFieldProofOfPossession proving that the
+// caller holds priv at the given time for a request made with accessToken.
+func SignProofOfPossession(priv ed25519.PrivateKey, accessToken string, at time.Time) string {
+	timestamp := strconv.FormatInt(at.Unix(), 10)
+	sig := ed25519.Sign(priv, proofOfPossessionMessage(timestamp, accessToken))
+	return timestamp + "." + base64.RawURLEncoding.EncodeToString(sig)
+}
+
+// ContextWithProofOfPossession returns a context whose outgoing metadata carries a proof of
+// possession of priv for a request made with accessToken.
+func ContextWithProofOfPossession(ctx context.Context, priv ed25519.PrivateKey, accessToken string) context.Context {
+	return metadata.AppendToOutgoingContext(ctx, MetadataFieldProofOfPossession, SignProofOfPossession(priv, accessToken, time.Now()))
+}
+
+func proofOfPossessionMessage(timestamp, accessToken string) []byte {
+	return []byte(timestamp + "." + accessToken)
+}
+
+func decodeProofOfPossessionKey(encoded string) (ed25519.PublicKey, error) {
+	key, err := base64.RawURLEncoding.DecodeString(encoded)
+	if err != nil {
+		return nil, fmt.Errorf("invalid proof of possession key: %w", err)
+	}
+	if len(key) != ed25519.PublicKeySize {
+		return nil, fmt.Errorf("invalid proof of possession key: expected %d bytes", ed25519.PublicKeySize)
+	}
+	return key, nil
+}
+
+// proofOfPossessionKeyFromMetadata returns the key the client asked to bind its token to, if any.
+func proofOfPossessionKeyFromMetadata(md metadata.MD) (string, error) {
+	values := md.Get(MetadataFieldProofOfPossessionKey)
+	switch len(values) {
+	case 0:
+		return "", nil
+	case 1:
+		if _, err := decodeProofOfPossessionKey(values[0]); err != nil {
+			return "", status.Error(codes.InvalidArgument, err.Error())
+		}
+		return values[0], nil
+	default:
+		return "", status.Errorf(codes.InvalidArgument, "expected one %s", MetadataFieldProofOfPossessionKey)
+	}
+}
+
+// checkProofOfPossession verifies the proof of possession in ctx for tokens bound to a key.
+// Tokens that are not bound to a key are not checked.
+func (ss *simpleServer) checkProofOfPossession(ctx context.Context, token *jwt.Token) error {
+	mapClaims, ok := token.Claims.(jwt.MapClaims)
+	if !ok {
+		return status.Error(codes.Internal, "invalid type for claims, check library implementation")
+	}
+	encodedKey, ok := mapClaims[claimProofOfPossessionKey].(string)
+	if !ok {
+		return nil
+	}
+	key, err := decodeProofOfPossessionKey(encodedKey)
+	if err != nil {
+		return status.Errorf(codes.Unauthenticated, "unauthenticated: %s", err)
+	}
+
+	md, _ := metadata.FromIncomingContext(ctx)
+	proofs := md.Get(MetadataFieldProofOfPossession)
+	if len(proofs) != 1 {
+		return status.Error(codes.Unauthenticated, "unauthenticated: proof of possession required")
+	}
+	timestamp, encodedSig, ok := strings.Cut(proofs[0], ".")
+	if !ok {
+		return status.Error(codes.Unauthenticated, "unauthenticated: malformed proof of possession")
+	}
+	unixSecs, err := strconv.ParseInt(timestamp, 10, 64)
+	if err != nil {
+		return status.Error(codes.Unauthenticated, "unauthenticated: malformed proof of possession")
+	}
+	maxSkew := ss.proofOfPossessionSkew
+	if maxSkew == 0 {
+		maxSkew = defaultProofOfPossessionMaxSkew
+	}
+	if skew := time.Since(time.Unix(unixSecs, 0)); skew > maxSkew || skew < -maxSkew {
+		return status.Error(codes.Unauthenticated, "unauthenticated: proof of possession expired")
+	}
+	sig, err := base64.RawURLEncoding.DecodeString(encodedSig)
+	if err != nil || !ed25519.Verify(key, proofOfPossessionMessage(timestamp, token.Raw), sig) {
+		return status.Error(codes.Unauthenticated, "unauthenticated: invalid proof of possession")
+	}
+	return nil
+}
@@ -0,0 +1,93 @@
+package rpc
+
+import (
+	"context"
+	"crypto/ed25519"
+	"crypto/rand"
+	"testing"
+	"time"
+
+	"github.com/edaniels/golog"
+	"go.viam.com/test"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+
+	rpcpb "go.viam.com/utils/proto/rpc/v1"
+)
+
+func TestServerAuthProofOfPossession(t *testing.T) {
+	logger := golog.NewTestLogger(t)
+	ss := newAuthTestServer(t,
+		WithAuthHandler("fake", MakeSimpleAuthHandler([]string{"foo"}, "bar")),
+		WithProofOfPossession(time.Minute),
+	)
+
+	pub, priv, err := ed25519.GenerateKey(rand.Reader)
+	test.That(t, err, test.ShouldBeNil)
+	_, otherPriv, err := ed25519.GenerateKey(rand.Reader)
+	test.That(t, err, test.ShouldBeNil)
+
+	authenticate := func(md metadata.MD) (string, error) {
+		resp, err := ss.Authenticate(metadata.NewIncomingContext(context.Background(), md), &rpcpb.AuthenticateRequest{
+			Entity:      "foo",
+			Credentials: &rpcpb.Credentials{Type: "fake", Payload: "bar"},
+		})
+		if err != nil {
+			return "", err
+		}
+		return resp.AccessToken, nil
+	}
+	ctxWithProof := func(token, proof string) context.Context {
+		md := metadata.Pairs("authorization", "Bearer "+token)
+		if proof != "" {
+			md.Set(MetadataFieldProofOfPossession, proof)
+		}
+		return metadata.NewIncomingContext(context.Background(), md)
+	}
+
+	boundToken, err := authenticate(metadata.Pairs(MetadataFieldProofOfPossessionKey, EncodeProofOfPossessionKey(pub)))
+	test.That(t, err, test.ShouldBeNil)
+
+	entity, err := ss.ensureAuthed(ctxWithProof(boundToken, SignProofOfPossession(priv, boundToken, time.Now())))
+	test.That(t, err, test.ShouldBeNil)
+	test.That(t, entity, test.ShouldEqual, "foo")
+
+	_, reason, err := ss.ensureAuthedWithReason(ctxWithProof(boundToken, ""))
+	test.That(t, status.Code(err), test.ShouldEqual, codes.Unauthenticated)
+	test.That(t, err.Error(), test.ShouldContainSubstring, "proof of possession required")
+	test.That(t, reason, test.ShouldEqual, AuthFailureInvalidProof)
+
+	_, err = ss.ensureAuthed(ctxWithProof(boundToken, SignProofOfPossession(otherPriv, boundToken, time.Now())))
+	test.That(t, status.Code(err), test.ShouldEqual, codes.Unauthenticated)
+	test.That(t, err.Error(), test.ShouldContainSubstring, "invalid proof of possession")
+
+	_, err = ss.ensureAuthed(ctxWithProof(boundToken, SignProofOfPossession(priv, boundToken, time.Now().Add(-2*time.Minute))))
+	test.That(t, status.Code(err), test.ShouldEqual, codes.Unauthenticated)
+	test.That(t, err.Error(), test.ShouldContainSubstring, "proof of possession expired")
+
+	_, err = ss.ensureAuthed(ctxWithProof(boundToken, "not a proof"))
+	test.That(t, status.Code(err), test.ShouldEqual, codes.Unauthenticated)
+	test.That(t, err.Error(), test.ShouldContainSubstring, "malformed proof of possession")
+
+	// a proof made for another token does not verify.
+	unboundToken, err := authenticate(metadata.MD{})
+	test.That(t, err, test.ShouldBeNil)
+	_, err = ss.ensureAuthed(ctxWithProof(boundToken, SignProofOfPossession(priv, unboundToken, time.Now())))
+	test.That(t, status.Code(err), test.ShouldEqual, codes.Unauthenticated)
+
+	// tokens issued without a key do not need a proof.
+	entity, err = ss.ensureAuthed(ctxWithProof(unboundToken, ""))
+	test.That(t, err, test.ShouldBeNil)
+	test.That(t, entity, test.ShouldEqual, "foo")
+
+	_, err = authenticate(metadata.Pairs(MetadataFieldProofOfPossessionKey, "not a key"))
+	test.That(t, status.Code(err), test.ShouldEqual, codes.InvalidArgument)
+
+	outMD, ok := metadata.FromOutgoingContext(ContextWithProofOfPossession(context.Background(), priv, boundToken))
+	test.That(t, ok, test.ShouldBeTrue)
+	test.That(t, outMD.Get(MetadataFieldProofOfPossession), test.ShouldHaveLength, 1)
+
+	_, err = NewServer(logger, WithProofOfPossession(0))
+	test.That(t, err, test.ShouldNotBeNil)
+}
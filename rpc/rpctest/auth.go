@@ -0,0 +1,89 @@
+// Package rpctest provides helpers for testing code that authenticates with the rpc package.
+package rpctest
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/rsa"
+	"testing"
+
+	"github.com/edaniels/golog"
+	"go.viam.com/test"
+	"google.golang.org/grpc/metadata"
+
+	rpcpb "go.viam.com/utils/proto/rpc/v1"
+	"go.viam.com/utils/rpc"
+)
+
+// StaticCredentialsType is the credentials type of the StaticAuthHandler of NewAuthServer.
+const StaticCredentialsType = rpc.CredentialsType("static")
+
+// testKeyBits is the size of the keys generated by GenerateTestKey. Smaller keys are faster to
+// generate but are rejected by the RSA signing methods of jwt.
+const testKeyBits = 2048
+
+// GenerateTestKey generates an RSA key for signing and verifying tokens in tests.
+func GenerateTestKey(t *testing.T) *rsa.PrivateKey {
+	t.Helper()
+	key, err := rsa.GenerateKey(rand.Reader, testKeyBits)
+	test.That(t, err, test.ShouldBeNil)
+	return key
+}
+
+// StaticAuthHandler returns an AuthHandler that authenticates entity with any payload and
+// returns md as its auth metadata. Every other entity is rejected.
+func StaticAuthHandler(entity string, md map[string]string) rpc.AuthHandler {
+	entityChecker := rpc.MakeEntitiesChecker([]string{entity})
+	return rpc.MakeFuncAuthHandler(
+		func(ctx context.Context, recvEntity, payload string) (map[string]string, error) {
+			if err := entityChecker(ctx, recvEntity); err != nil {
+				return nil, err
+			}
+			return md, nil
+		},
+		func(ctx context.Context, recvEntity string) (interface{}, error) {
+			return recvEntity, entityChecker(ctx, recvEntity)
+		},
+	)
+}
+
+// NewAuthServer returns a server that signs tokens with key and authenticates entity via a
+// StaticAuthHandler for StaticCredentialsType. Additional options are applied after these. The
+// server is stopped when the test finishes.
+func NewAuthServer(
+	t *testing.T,
+	key *rsa.PrivateKey,
+	entity string,
+	md map[string]string,
+	opts ...rpc.ServerOption,
+) rpc.Server {
+	t.Helper()
+	serverOpts := append([]rpc.ServerOption{
+		rpc.WithAuthRSAPrivateKey(key),
+		rpc.WithAuthHandler(StaticCredentialsType, StaticAuthHandler(entity, md)),
+		rpc.WithDisableMulticastDNS(),
+	}, opts...)
+	server, err := rpc.NewServer(golog.NewTestLogger(t), serverOpts...)
+	test.That(t, err, test.ShouldBeNil)
+	t.Cleanup(func() {
+		test.That(t, server.Stop(), test.ShouldBeNil)
+	})
+	return server
+}
+
+// AccessToken authenticates entity with server, which must serve the AuthService like those of
+// NewAuthServer do, and returns the signed access token.
+func AccessToken(t *testing.T, server rpc.Server, entity string) string {
+	t.Helper()
+	authServer, ok := server.(rpcpb.AuthServiceServer)
+	test.That(t, ok, test.ShouldBeTrue)
+	resp, err := authServer.Authenticate(
+		metadata.NewIncomingContext(context.Background(), metadata.MD{}),
+		&rpcpb.AuthenticateRequest{
+			Entity:      entity,
+			Credentials: &rpcpb.Credentials{Type: string(StaticCredentialsType)},
+		},
+	)
+	test.That(t, err, test.ShouldBeNil)
+	return resp.GetAccessToken()
+}
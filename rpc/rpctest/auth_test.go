@@ -0,0 +1,42 @@
+package rpctest
+
+import (
+	"context"
+	"testing"
+
+	"github.com/golang-jwt/jwt/v4"
+	"go.viam.com/test"
+
+	"go.viam.com/utils/rpc"
+)
+
+func TestNewAuthServer(t *testing.T) {
+	key := GenerateTestKey(t)
+	server := NewAuthServer(t, key, "foo", map[string]string{"tenant": "a"})
+
+	var claims rpc.JWTClaims
+	_, err := jwt.ParseWithClaims(AccessToken(t, server, "foo"), &claims, func(token *jwt.Token) (interface{}, error) {
+		return &key.PublicKey, nil
+	})
+	test.That(t, err, test.ShouldBeNil)
+	entity, err := claims.Entity()
+	test.That(t, err, test.ShouldBeNil)
+	test.That(t, entity, test.ShouldEqual, "foo")
+	test.That(t, claims.GetAuthMetadata(), test.ShouldResemble, map[string]string{"tenant": "a"})
+}
+
+func TestStaticAuthHandler(t *testing.T) {
+	handler := StaticAuthHandler("foo", map[string]string{"tenant": "a"})
+
+	md, err := handler.Authenticate(context.Background(), "foo", "anything")
+	test.That(t, err, test.ShouldBeNil)
+	test.That(t, md, test.ShouldResemble, map[string]string{"tenant": "a"})
+	_, err = handler.Authenticate(context.Background(), "bar", "anything")
+	test.That(t, err, test.ShouldNotBeNil)
+
+	entity, err := handler.VerifyEntity(context.Background(), "foo")
+	test.That(t, err, test.ShouldBeNil)
+	test.That(t, entity, test.ShouldEqual, "foo")
+	_, err = handler.VerifyEntity(context.Background(), "bar")
+	test.That(t, err, test.ShouldNotBeNil)
+}
@@ -41,7 +41,13 @@ import (
 	webrtcpb "go.viam.com/utils/proto/rpc/webrtc/v1"
 )
 
-const generatedRSAKeyBits = 4096
+const (
+	generatedRSAKeyBits      = 4096
+	defaultAuthRSAMinKeyBits = 2048
+	defaultAuthMDMaxEntries  = 64
+	defaultAuthMDMaxBytes    = 4096
+	defaultIssuedAtTolerance = 30 * time.Second
+)
 
 // A Server provides a convenient way to get a gRPC server up and running
 // with HTTP facilities.
@@ -96,6 +102,26 @@ type Server interface {
 	http.Handler
 }
 
+// An ExemptMethodsServer can change which methods are exempt from authentication at runtime.
+// The Server returned by NewServer implements it.
+type ExemptMethodsServer interface {
+	// AddExemptMethod exempts the given full method name (e.g. /proto.rpc.v1.AuthService/Authenticate)
+	// from authentication. It is safe to call while the server is running and takes effect on
+	// subsequent requests; requests already past authentication are unaffected.
+	AddExemptMethod(fullMethod string)
+
+	// RemoveExemptMethod requires authentication for the given full method name again. Like
+	// AddExemptMethod, it is safe to call while the server is running and takes effect on
+	// subsequent requests.
+	RemoveExemptMethod(fullMethod string)
+
+	// ExemptMethods returns the sorted full method names currently exempt from authentication.
+	ExemptMethods() []string
+}
+
+// ensure simpleServer implements ExemptMethodsServer.
+var _ ExemptMethodsServer = (*simpleServer)(nil)
+
 type simpleServer struct {
 	rpcpb.UnimplementedAuthServiceServer
 	rpcpb.UnimplementedExternalAuthServiceServer
@@ -113,14 +139,49 @@ type simpleServer struct {
 	serviceServers          []interface{}
 	signalingCallQueue      WebRTCCallQueue
 	authRSAPrivKey          *rsa.PrivateKey
+	authRSAVerificationKeys []*rsa.PublicKey
 	internalUUID            string
 	internalCreds           Credentials
-	tlsAuthHandler          func(ctx context.Context, entities ...string) (interface{}, error)
+	tlsAuthHandler          func(ctx context.Context, entities ...string) (interface{}, map[string]string, error)
+	unixSocketAuthEntity    interface{}
+	tlsInfoExtractor        func(authInfo credentials.AuthInfo) (*x509.Certificate, bool)
+	tokenExtractor          TokenExtractor
 	authHandlers            map[CredentialsType]AuthHandler
+	fallbackAuthHandler     func(forType CredentialsType) (AuthHandler, error)
 	authToType              CredentialsType
 	authToHandler           AuthenticateToHandler
+	authClaimsRedactor      func(claims Claims) Claims
+	authErrorMapper         func(err error) error
+	authResponseHook        func(ctx context.Context, entity, accessToken string) (string, error)
+	authMetrics             bool
+	methodLatencyMetrics    bool
+	messageSizeMetrics      bool
+	authFailureTrailers     bool
+	authFailureDetails      bool
+	debugClaimsLogging      bool
+	tokenStore              TokenStore
+	requiredClaims          []string
+	entityFallbackClaim     string
+	tokenAudiences          []string
+	acceptedAudiences       []string
+	authMDMaxEntries        int
+	authMDMaxBytes          int
+	reservedAuthMDPolicy    ReservedAuthMetadataPolicy
+	authMDCodec             AuthMetadataCodec
+	proofOfPossessionSkew   time.Duration
+	tlsTokenBinding         bool
+	peerTokenBinding        bool
+	expiryGracePeriod       time.Duration
+	recentAuthMethods       map[string]time.Duration
+	maxTokenAge             time.Duration
+	issuedAtTolerance       time.Duration
+	authPolicy              *AuthPolicy
+	entityConcurrency       *entityConcurrencyLimiter
 	mdnsServers             []*zeroconf.Server
+	exemptMethodsMu         sync.RWMutex
 	exemptMethods           map[string]bool
+	registeredMethodsMu     sync.RWMutex
+	registeredMethods       map[string]bool
 	tlsConfig               *tls.Config
 	firstSeenTLSCertLeaf    *x509.Certificate
 	stopped                 bool
@@ -140,8 +201,11 @@ func NewServer(logger golog.Logger, opts ...ServerOption) (Server, error) {
 			return nil, err
 		}
 	}
-	if sOpts.unauthenticated && (len(sOpts.authHandlers) != 0 || sOpts.tlsAuthHandler != nil) {
-		return nil, errMixedUnauthAndAuth
+	if err := sOpts.validateAuth(); err != nil {
+		return nil, err
+	}
+	if sOpts.unixSocketAuthEntity != nil {
+		return nil, errors.New("unix socket auth entity requires a unix socket listener; use NewAuthInterceptors or NewAuthenticatedServer")
 	}
 
 	grpcBindAddr := sOpts.bindAddress
@@ -192,13 +256,9 @@ func NewServer(logger golog.Logger, opts ...ServerOption) (Server, error) {
 		MaxHeaderBytes: MaxMessageSize,
 	}
 
-	authRSAPrivKey := sOpts.authRSAPrivateKey
-	if !sOpts.unauthenticated && authRSAPrivKey == nil {
-		privKey, err := rsa.GenerateKey(rand.Reader, generatedRSAKeyBits)
-		if err != nil {
-			return nil, err
-		}
-		authRSAPrivKey = privKey
+	authRSAPrivKey, err := sOpts.authRSAPrivateKeyOrGenerate()
+	if err != nil {
+		return nil, err
 	}
 
 	internalCredsKey := make([]byte, 64)
@@ -207,10 +267,6 @@ func NewServer(logger golog.Logger, opts ...ServerOption) (Server, error) {
 		return nil, err
 	}
 
-	if sOpts.authHandlers == nil {
-		sOpts.authHandlers = make(map[CredentialsType]AuthHandler)
-	}
-
 	grpcGatewayHandler := runtime.NewServeMux(
 		runtime.WithMarshalerOption(runtime.MIMEWildcard, &runtime.JSONPb{
 			MarshalOptions: protojson.MarshalOptions{
@@ -226,22 +282,21 @@ func NewServer(logger golog.Logger, opts ...ServerOption) (Server, error) {
 		grpcListener:       grpcListener,
 		httpServer:         httpServer,
 		grpcGatewayHandler: grpcGatewayHandler,
-		authRSAPrivKey:     authRSAPrivKey,
 		internalUUID:       uuid.NewString(),
 		internalCreds: Credentials{
 			Type:    credentialsTypeInternal,
 			Payload: base64.StdEncoding.EncodeToString(internalCredsKey),
 		},
-		tlsAuthHandler:       sOpts.tlsAuthHandler,
-		authHandlers:         sOpts.authHandlers,
 		authToType:           sOpts.authToType,
 		authToHandler:        sOpts.authToHandler,
-		exemptMethods:        make(map[string]bool),
+		tokenStore:           sOpts.tokenStore,
 		tlsConfig:            sOpts.tlsConfig,
 		firstSeenTLSCertLeaf: firstSeenTLSCertLeaf,
 		logger:               logger,
 	}
 
+	server.setAuth(&sOpts, authRSAPrivKey)
+
 	grpcLogger := logger.Desugar()
 	if !(sOpts.debug || utils.Debug) {
 		grpcLogger = grpcLogger.WithOptions(zap.IncreaseLevel(zap.LevelEnablerFunc(zapcore.ErrorLevel.Enabled)))
@@ -266,18 +321,13 @@ func NewServer(logger golog.Logger, opts ...ServerOption) (Server, error) {
 		unaryInterceptors = append(unaryInterceptors, server.authUnaryInterceptor)
 		unaryAuthIntPos = len(unaryInterceptors) - 1
 	}
+	var userUnaryInterceptors []grpc.UnaryServerInterceptor
 	if sOpts.unaryInterceptor != nil {
-		unaryInterceptors = append(unaryInterceptors, func(
-			ctx context.Context,
-			req interface{},
-			info *grpc.UnaryServerInfo,
-			handler grpc.UnaryHandler,
-		) (interface{}, error) {
-			if server.exemptMethods[info.FullMethod] {
-				return handler(ctx, req)
-			}
-			return sOpts.unaryInterceptor(ctx, req, info, handler)
-		})
+		userUnaryInterceptors = append(userUnaryInterceptors, sOpts.unaryInterceptor)
+	}
+	userUnaryInterceptors = append(userUnaryInterceptors, sOpts.unaryInterceptors...)
+	if len(userUnaryInterceptors) != 0 {
+		unaryInterceptors = append(unaryInterceptors, server.unaryInterceptorsAfterAuth(userUnaryInterceptors...))
 	}
 	unaryInterceptor := grpc_middleware.ChainUnaryServer(unaryInterceptors...)
 	serverOpts = append(serverOpts, grpc.UnaryInterceptor(unaryInterceptor))
@@ -298,18 +348,13 @@ func NewServer(logger golog.Logger, opts ...ServerOption) (Server, error) {
 		streamInterceptors = append(streamInterceptors, server.authStreamInterceptor)
 		streamAuthIntPos = len(streamInterceptors) - 1
 	}
+	var userStreamInterceptors []grpc.StreamServerInterceptor
 	if sOpts.streamInterceptor != nil {
-		streamInterceptors = append(streamInterceptors, func(
-			srv interface{},
-			serverStream grpc.ServerStream,
-			info *grpc.StreamServerInfo,
-			handler grpc.StreamHandler,
-		) error {
-			if server.exemptMethods[info.FullMethod] {
-				return handler(srv, serverStream)
-			}
-			return sOpts.streamInterceptor(srv, serverStream, info, handler)
-		})
+		userStreamInterceptors = append(userStreamInterceptors, sOpts.streamInterceptor)
+	}
+	userStreamInterceptors = append(userStreamInterceptors, sOpts.streamInterceptors...)
+	if len(userStreamInterceptors) != 0 {
+		streamInterceptors = append(streamInterceptors, server.streamInterceptorsAfterAuth(userStreamInterceptors...))
 	}
 	streamInterceptor := grpc_middleware.ChainStreamServer(streamInterceptors...)
 	serverOpts = append(serverOpts, grpc.StreamInterceptor(streamInterceptor))
@@ -340,8 +385,7 @@ func NewServer(logger golog.Logger, opts ...ServerOption) (Server, error) {
 		}
 		server.authHandlers[credentialsTypeInternal] = MakeSimpleAuthHandler(
 			[]string{server.internalUUID}, server.internalCreds.Payload)
-		// Update this if the proto method or path changes
-		server.exemptMethods["/proto.rpc.v1.AuthService/Authenticate"] = true
+		server.exemptAuthServiceMethods(&sOpts)
 	}
 
 	if sOpts.authToHandler != nil {
@@ -617,6 +661,10 @@ func (ss *simpleServer) InternalAddr() net.Addr {
 }
 
 func (ss *simpleServer) Start() error {
+	for _, method := range unknownExemptMethods(ss.exemptMethodSet(), ss.grpcServer.GetServiceInfo()) {
+		ss.logger.Warnw("method exempt from authentication is not registered", "method", method)
+	}
+
 	var err error
 	var errMu sync.Mutex
 	utils.PanicCapturingGo(func() {
@@ -753,6 +801,9 @@ func (ss *simpleServer) RegisterServiceServer(
 	ss.serviceServerCancels = append(ss.serviceServerCancels, stopCancel)
 	ss.serviceServers = append(ss.serviceServers, svcServer)
 	ss.grpcServer.RegisterService(svcDesc, svcServer)
+	ss.registeredMethodsMu.Lock()
+	ss.registeredMethods = nil
+	ss.registeredMethodsMu.Unlock()
 	if ss.webrtcServer != nil {
 		ss.webrtcServer.RegisterService(svcDesc, svcServer)
 	}
@@ -2,11 +2,22 @@ package rpc
 
 import (
 	"context"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
 	"crypto/x509"
+	"encoding/hex"
+	"encoding/json"
 	"fmt"
+	"math"
+	"net"
+	"sort"
 	"strings"
+	"time"
 
+	"github.com/edaniels/golog"
 	"github.com/golang-jwt/jwt/v4"
+	grpc_middleware "github.com/grpc-ecosystem/go-grpc-middleware"
 	"github.com/pkg/errors"
 	"go.uber.org/multierr"
 	"google.golang.org/grpc"
@@ -16,31 +27,232 @@ import (
 	"google.golang.org/grpc/peer"
 	"google.golang.org/grpc/status"
 
+	"go.viam.com/utils/perf/statz"
 	rpcpb "go.viam.com/utils/proto/rpc/v1"
 )
 
+// authHandler returns the AuthHandler registered for forType or, if there is none, the one the
+// fallback handler (see WithFallbackAuthHandler) provides for it.
 func (ss *simpleServer) authHandler(forType CredentialsType) (AuthHandler, error) {
-	handler, ok := ss.authHandlers[forType]
-	if !ok {
+	if handler, ok := ss.authHandlers[forType]; ok {
+		return handler, nil
+	}
+	if ss.fallbackAuthHandler == nil || forType == credentialsTypeInternal {
+		return nil, status.Errorf(codes.InvalidArgument, "no auth handler for %q", forType)
+	}
+	handler, err := ss.fallbackAuthHandler(forType)
+	if err != nil {
+		if _, ok := status.FromError(err); ok {
+			return nil, err
+		}
+		return nil, status.Errorf(codes.InvalidArgument, "no auth handler for %q: %s", forType, err)
+	}
+	if handler == nil {
 		return nil, status.Errorf(codes.InvalidArgument, "no auth handler for %q", forType)
 	}
 	return handler, nil
 }
 
+// normalizeCredentialsType returns the registered credential type that credType refers to. It
+// is trimmed and, if there is no handler for it as is, matched case insensitively against the
+// registered types. It is returned trimmed as is if it matches none or more than one of them.
+func (ss *simpleServer) normalizeCredentialsType(credType string) CredentialsType {
+	forType := CredentialsType(strings.TrimSpace(credType))
+	if _, ok := ss.authHandlers[forType]; ok {
+		return forType
+	}
+	var match CredentialsType
+	for registered := range ss.authHandlers {
+		if !strings.EqualFold(string(registered), string(forType)) {
+			continue
+		}
+		if match != "" {
+			return forType
+		}
+		match = registered
+	}
+	if match == "" {
+		return forType
+	}
+	return match
+}
+
+// credentialsTypes returns the sorted credential types clients can authenticate with.
+func (ss *simpleServer) credentialsTypes() []string {
+	types := make([]string, 0, len(ss.authHandlers))
+	for credType := range ss.authHandlers {
+		if credType == credentialsTypeInternal {
+			continue
+		}
+		types = append(types, string(credType))
+	}
+	sort.Strings(types)
+	return types
+}
+
+// ensure simpleServer implements AuthHandlerChecker.
+var _ AuthHandlerChecker = (*simpleServer)(nil)
+
+// HasAuthHandler returns whether the server has an auth handler for the credential type.
+func (ss *simpleServer) HasAuthHandler(credType CredentialsType) bool {
+	_, ok := ss.authHandlers[credType]
+	return ok
+}
+
+func (ss *simpleServer) AddExemptMethod(fullMethod string) {
+	ss.exemptMethodsMu.Lock()
+	defer ss.exemptMethodsMu.Unlock()
+	ss.exemptMethods[fullMethod] = true
+}
+
+func (ss *simpleServer) RemoveExemptMethod(fullMethod string) {
+	ss.exemptMethodsMu.Lock()
+	defer ss.exemptMethodsMu.Unlock()
+	delete(ss.exemptMethods, fullMethod)
+}
+
+func (ss *simpleServer) ExemptMethods() []string {
+	ss.exemptMethodsMu.RLock()
+	defer ss.exemptMethodsMu.RUnlock()
+	methods := make([]string, 0, len(ss.exemptMethods))
+	for method := range ss.exemptMethods {
+		methods = append(methods, method)
+	}
+	sort.Strings(methods)
+	return methods
+}
+
+// isExemptMethod returns whether fullMethod is currently exempt from authentication.
+func (ss *simpleServer) isExemptMethod(fullMethod string) bool {
+	ss.exemptMethodsMu.RLock()
+	defer ss.exemptMethodsMu.RUnlock()
+	return ss.exemptMethods[fullMethod]
+}
+
+// exemptMethodSet returns a copy of the methods currently exempt from authentication.
+func (ss *simpleServer) exemptMethodSet() map[string]bool {
+	ss.exemptMethodsMu.RLock()
+	defer ss.exemptMethodsMu.RUnlock()
+	methods := make(map[string]bool, len(ss.exemptMethods))
+	for method := range ss.exemptMethods {
+		methods[method] = true
+	}
+	return methods
+}
+
 const (
 	metadataFieldAuthorization     = "authorization"
 	authorizationValuePrefixBearer = "Bearer "
+
+	// tokenKindHeader is the JWT header marking the kind of tokens the server issues so that
+	// tokens of other kinds signed with the same key, such as refresh tokens, are not accepted
+	// as bearer tokens.
+	tokenKindHeader = "rpc_token_kind"
+	tokenKindAccess = "access"
+
+	// MetadataFieldAuthFailureReason is the trailer set with the AuthFailureReason of a
+	// request rejected by authentication (see WithAuthFailureTrailers).
+	MetadataFieldAuthFailureReason = "auth-failure-reason"
+
+	// MetadataFieldTokenExpiredGrace is the trailer set on requests whose token had expired but
+	// was accepted within the expiry grace period (see WithExpiryGracePeriod). Its value is how
+	// long ago the token expired.
+	MetadataFieldTokenExpiredGrace = "auth-token-expired-grace"
+)
+
+// AuthFailureReason is the class of failure of a rejected authentication. Servers convey it
+// to clients with WithAuthFailureTrailers and WithAuthFailureDetails.
+type AuthFailureReason string
+
+// The classes of authentication failures.
+const (
+	AuthFailureMissingCredentials AuthFailureReason = "missing_credentials"
+	AuthFailureInvalidToken       AuthFailureReason = "invalid_token"
+	AuthFailureInvalidClaims      AuthFailureReason = "invalid_claims"
+	AuthFailureTokenExpired       AuthFailureReason = "token_expired"
+	AuthFailureEntityVerification AuthFailureReason = "entity_verification_failed"
+	AuthFailureInternal           AuthFailureReason = "internal"
+	AuthFailureInvalidProof       AuthFailureReason = "invalid_proof"
+	AuthFailureRequestRejected    AuthFailureReason = "request_rejected"
+	AuthFailureTokenBinding       AuthFailureReason = "token_binding_mismatch"
+
+	// AuthFailureVerificationUnavailable is for tokens that could not be verified because an
+	// external verification backend, such as an OIDC provider, is unavailable.
+	AuthFailureVerificationUnavailable AuthFailureReason = "verification_unavailable"
+
+	// AuthFailureReauthenticationRequired is for requests to methods that require a more recently
+	// issued token than the one presented (see WithRecentAuthRequired).
+	AuthFailureReauthenticationRequired AuthFailureReason = "reauthentication_required"
 )
 
 // JWTClaims extends jwt.RegisteredClaims with information about the credentials as well
 // as authentication metadata.
 type JWTClaims struct {
 	jwt.RegisteredClaims
-	CredentialsType CredentialsType   `json:"rpc_creds_type,omitempty"`
-	AuthMetadata    map[string]string `json:"rpc_auth_md,omitempty"`
+	CredentialsType   CredentialsType     `json:"rpc_creds_type,omitempty"`
+	AuthMetadata      map[string]string   `json:"rpc_auth_md,omitempty"`
+	AuthMetadataMulti map[string][]string `json:"rpc_auth_md_multi,omitempty"`
+	ProofKey          string              `json:"rpc_pop_key,omitempty"`
+	PeerIdentity      string              `json:"rpc_peer,omitempty"`
+	// EncodedAuthMetadata is AuthMetadata encoded by an AuthMetadataCodec (see
+	// WithAuthMetadataCodec). Servers decode it into AuthMetadata when verifying a token.
+	EncodedAuthMetadata string `json:"rpc_auth_md_enc,omitempty"`
+}
+
+// reservedClaimNames are the names of the claims of tokens the server issues.
+var reservedClaimNames = map[string]bool{
+	"iss":               true,
+	"sub":               true,
+	"aud":               true,
+	"exp":               true,
+	"nbf":               true,
+	"iat":               true,
+	"jti":               true,
+	"rpc_creds_type":    true,
+	"rpc_auth_md":       true,
+	"rpc_auth_md_multi": true,
+	"rpc_auth_md_enc":   true,
+	"rpc_pop_key":       true,
+	"rpc_peer":          true,
+}
+
+// ReservedAuthMetadataPolicy is what the server does when an AuthHandler returns auth metadata
+// keys named like a claim of the tokens it issues (see WithReservedAuthMetadataPolicy).
+type ReservedAuthMetadataPolicy string
+
+// The policies for auth metadata keys named like claims.
+const (
+	// ReservedAuthMetadataAllow issues tokens with such keys. This is safe since auth metadata
+	// is nested under its own claim and can never override a claim of the token.
+	ReservedAuthMetadataAllow ReservedAuthMetadataPolicy = "allow"
+	// ReservedAuthMetadataWarn issues tokens with such keys but logs a warning.
+	ReservedAuthMetadataWarn ReservedAuthMetadataPolicy = "warn"
+	// ReservedAuthMetadataReject fails Authenticate instead of issuing a token.
+	ReservedAuthMetadataReject ReservedAuthMetadataPolicy = "reject"
+)
+
+// TokenHeader holds the JWT header fields useful for debugging which key a token was
+// signed with, such as during key rotation.
+type TokenHeader struct {
+	// KeyID is the "kid" header; it is empty if the token does not name its key.
+	KeyID string
+	// Algorithm is the "alg" header.
+	Algorithm string
+	// Kind is the kind of token the server issued, such as "access"; it is empty for tokens
+	// issued elsewhere.
+	Kind string
 }
 
-// Entity entity from the claims Audience.
+func tokenHeaderFromJWT(token *jwt.Token) TokenHeader {
+	keyID, _ := token.Header["kid"].(string)
+	algorithm, _ := token.Header["alg"].(string)
+	kind, _ := token.Header[tokenKindHeader].(string)
+	return TokenHeader{KeyID: keyID, Algorithm: algorithm, Kind: kind}
+}
+
+// Entity entity from the claims Audience. The audience may have been issued either as
+// a single string or as an array of strings; in both cases the first entry is the entity.
+// Any further entries are other audiences the token is valid for (see WithTokenAudiences).
 func (c JWTClaims) Entity() (string, error) {
 	if len(c.Audience) == 0 {
 		return "", status.Error(codes.Unauthenticated, "invalid claims: no audience")
@@ -54,36 +266,160 @@ func (c JWTClaims) GetCredentialsType() CredentialsType {
 	return c.CredentialsType
 }
 
-// GetAuthMetadata returns the metadata from `rpc_auth_md` claim.
+// GetAuthMetadata returns the metadata from `rpc_auth_md` claim. Multi-valued metadata from
+// the `rpc_auth_md_multi` claim is included with its values joined by commas.
 func (c JWTClaims) GetAuthMetadata() map[string]string {
-	return c.AuthMetadata
+	if len(c.AuthMetadataMulti) == 0 {
+		return c.AuthMetadata
+	}
+	authMD := make(map[string]string, len(c.AuthMetadata)+len(c.AuthMetadataMulti))
+	for k, vs := range c.AuthMetadataMulti {
+		authMD[k] = strings.Join(vs, ",")
+	}
+	for k, v := range c.AuthMetadata {
+		authMD[k] = v
+	}
+	return authMD
 }
 
-// ensure JWTClaims implements Claims.
-var _ Claims = JWTClaims{}
+// GetAuthMetadataMulti returns the metadata from the `rpc_auth_md_multi` claim. Metadata from
+// the `rpc_auth_md` claim is included as single values.
+func (c JWTClaims) GetAuthMetadataMulti() map[string][]string {
+	if len(c.AuthMetadata) == 0 {
+		return c.AuthMetadataMulti
+	}
+	authMD := make(map[string][]string, len(c.AuthMetadata)+len(c.AuthMetadataMulti))
+	for k, vs := range c.AuthMetadataMulti {
+		authMD[k] = vs
+	}
+	for k, v := range c.AuthMetadata {
+		authMD[k] = []string{v}
+	}
+	return authMD
+}
+
+// ensure JWTClaims implements Claims and MultiValueClaims.
+var (
+	_ Claims           = JWTClaims{}
+	_ MultiValueClaims = JWTClaims{}
+)
+
+// AllowlistAuthMetadataRedactor returns a claims redactor (see WithAuthClaimsRedactor) that only
+// keeps the given auth metadata keys of JWTClaims. Registered claims are kept as is. Custom claims
+// (see TokenCustomClaimProvider) are returned unchanged and need their own redactor.
+func AllowlistAuthMetadataRedactor(keys ...string) func(claims Claims) Claims {
+	allowed := make(map[string]bool, len(keys))
+	for _, key := range keys {
+		allowed[key] = true
+	}
+	return func(claims Claims) Claims {
+		jwtClaims, ok := claims.(*JWTClaims)
+		if !ok {
+			return claims
+		}
+		redacted := *jwtClaims
+		if jwtClaims.AuthMetadata != nil {
+			redacted.AuthMetadata = make(map[string]string, len(allowed))
+			for k, v := range jwtClaims.AuthMetadata {
+				if allowed[k] {
+					redacted.AuthMetadata[k] = v
+				}
+			}
+		}
+		if jwtClaims.AuthMetadataMulti != nil {
+			redacted.AuthMetadataMulti = make(map[string][]string, len(allowed))
+			for k, vs := range jwtClaims.AuthMetadataMulti {
+				if allowed[k] {
+					redacted.AuthMetadataMulti[k] = vs
+				}
+			}
+		}
+		return &redacted
+	}
+}
 
 func (ss *simpleServer) Authenticate(ctx context.Context, req *rpcpb.AuthenticateRequest) (*rpcpb.AuthenticateResponse, error) {
 	md, ok := metadata.FromIncomingContext(ctx)
 	if !ok {
 		return nil, errors.New("expected metadata")
 	}
+	forType := ss.normalizeCredentialsType(req.Credentials.Type)
 	if len(md[metadataFieldAuthorization]) != 0 {
+		ss.recordAlreadyAuthenticated(forType)
 		return nil, status.Error(codes.InvalidArgument, "already authenticated; cannot re-authenticate")
 	}
-	forType := CredentialsType(req.Credentials.Type)
+	if ss.authMetrics {
+		authenticateCredentialsTypes.Inc(ss.credentialsTypeLabel(forType))
+	}
 	handler, err := ss.authHandler(forType)
 	if err != nil {
-		return nil, err
+		if ss.fallbackAuthHandler != nil {
+			return nil, err
+		}
+		return nil, status.Errorf(codes.InvalidArgument, "no auth handler for %q; valid credential types are: %s",
+			forType, strings.Join(ss.credentialsTypes(), ", "))
+	}
+	var authMD map[string]string
+	var authMDMulti map[string][]string
+	if multiHandler, ok := handler.(MultiValueAuthHandler); ok {
+		authMDMulti, err = multiHandler.AuthenticateMultiValue(ctx, req.Entity, req.Credentials.Payload)
+	} else {
+		authMD, err = handler.Authenticate(ctx, req.Entity, req.Credentials.Payload)
 	}
-	authMD, err := handler.Authenticate(ctx, req.Entity, req.Credentials.Payload)
 	if err != nil {
+		if ss.authErrorMapper != nil {
+			if mapped := ss.authErrorMapper(err); mapped != nil {
+				err = mapped
+			}
+		}
 		if _, ok := status.FromError(err); ok {
 			return nil, err
 		}
 		return nil, status.Errorf(codes.PermissionDenied, "failed to authenticate: %s", err.Error())
 	}
 
-	token, err := ss.signAccessTokenForEntity(forType, req.Entity, authMD)
+	var proofKey string
+	if ss.proofOfPossessionSkew != 0 {
+		proofKey, err = proofOfPossessionKeyFromMetadata(md)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	var peerIdentity string
+	if ss.peerTokenBinding {
+		peerIdentity = ss.peerIdentity(ctx)
+	}
+
+	var storeKey TokenStoreKey
+	if ss.tokenStore != nil {
+		storeKey = newTokenStoreKey(forType, req.Entity, authMD, authMDMulti)
+		storeKey.proofKey = proofKey
+		storeKey.peerIdentity = peerIdentity
+		token, ok := ss.tokenStore.Get(storeKey)
+		ok = ok && ss.isStoredTokenFresh(token)
+		ss.recordTokenStoreLookup(forType, ok)
+		if ok {
+			token, err = ss.applyAuthResponseHook(ctx, req.Entity, token)
+			if err != nil {
+				return nil, err
+			}
+			return &rpcpb.AuthenticateResponse{
+				AccessToken: token,
+			}, nil
+		}
+	}
+
+	token, err := ss.signAccessTokenForEntity(forType, req.Entity, authMD, authMDMulti, proofKey, peerIdentity)
+	if err != nil {
+		return nil, err
+	}
+
+	if ss.tokenStore != nil {
+		ss.tokenStore.Put(storeKey, token)
+	}
+
+	token, err = ss.applyAuthResponseHook(ctx, req.Entity, token)
 	if err != nil {
 		return nil, err
 	}
@@ -98,8 +434,28 @@ func (ss *simpleServer) AuthenticateTo(ctx context.Context, req *rpcpb.Authentic
 	if err != nil {
 		return nil, err
 	}
+	if tlsAuthMD := contextTLSAuthMetadata(ctx); len(tlsAuthMD) != 0 {
+		mergedMD := make(map[string]string, len(tlsAuthMD)+len(authMD))
+		for k, v := range tlsAuthMD {
+			mergedMD[k] = v
+		}
+		for k, v := range authMD {
+			mergedMD[k] = v
+		}
+		authMD = mergedMD
+	}
 
-	token, err := ss.signAccessTokenForEntity(ss.authToType, req.Entity, authMD)
+	var peerIdentity string
+	if ss.peerTokenBinding {
+		peerIdentity = ss.peerIdentity(ctx)
+	}
+
+	token, err := ss.signAccessTokenForEntity(ss.authToType, req.Entity, authMD, nil, "", peerIdentity)
+	if err != nil {
+		return nil, err
+	}
+
+	token, err = ss.applyAuthResponseHook(ctx, req.Entity, token)
 	if err != nil {
 		return nil, err
 	}
@@ -109,23 +465,88 @@ func (ss *simpleServer) AuthenticateTo(ctx context.Context, req *rpcpb.Authentic
 	}, nil
 }
 
+// applyAuthResponseHook passes the access token that Authenticate or AuthenticateTo is about to
+// respond with for entity through the hook set by WithAuthenticateResponseHook, if any.
+func (ss *simpleServer) applyAuthResponseHook(ctx context.Context, entity, accessToken string) (string, error) {
+	if ss.authResponseHook == nil {
+		return accessToken, nil
+	}
+	return ss.authResponseHook(ctx, entity, accessToken)
+}
+
 func (ss *simpleServer) signAccessTokenForEntity(
 	forType CredentialsType,
 	entity string,
 	authMD map[string]string,
+	authMDMulti map[string][]string,
+	proofKey string,
+	peerIdentity string,
 ) (string, error) {
-	token := jwt.NewWithClaims(jwt.SigningMethodRS256, JWTClaims{
+	audiences := make([]string, 0, 1+len(ss.tokenAudiences))
+	audiences = append(audiences, entity)
+	audiences = append(audiences, ss.tokenAudiences...)
+	return ss.signAccessTokenForAudiences(forType, audiences, authMD, authMDMulti, proofKey, peerIdentity)
+}
+
+// signAccessTokenForAudiences signs an access token valid for each of audiences, the first of
+// which is the entity the token is for. A non-empty peerIdentity binds the token to that peer.
+func (ss *simpleServer) signAccessTokenForAudiences(
+	forType CredentialsType,
+	audiences []string,
+	authMD map[string]string,
+	authMDMulti map[string][]string,
+	proofKey string,
+	peerIdentity string,
+) (string, error) {
+	if len(audiences) == 0 {
+		return "", status.Error(codes.Internal, "cannot sign a token without an audience")
+	}
+	if err := ss.checkAuthMetadataLimits(authMD, authMDMulti); err != nil {
+		ss.logger.Errorw("auth metadata exceeds limits", "entity", audiences[0], "credentials_type", forType, "error", err)
+		return "", status.Error(codes.Internal, "failed to authenticate: auth metadata too large")
+	}
+	if reserved := reservedAuthMetadataKeys(authMD, authMDMulti); len(reserved) != 0 {
+		switch ss.reservedAuthMDPolicy {
+		case ReservedAuthMetadataReject:
+			ss.logger.Errorw("auth metadata uses reserved claim names", "entity", audiences[0], "credentials_type", forType, "keys", reserved)
+			return "", status.Error(codes.Internal, "failed to authenticate: auth metadata uses reserved claim names")
+		case ReservedAuthMetadataWarn:
+			ss.logger.Warnw("auth metadata uses reserved claim names", "entity", audiences[0], "credentials_type", forType, "keys", reserved)
+		}
+	}
+	claims := JWTClaims{
 		RegisteredClaims: jwt.RegisteredClaims{
-			Audience: jwt.ClaimStrings{entity},
+			Audience: jwt.ClaimStrings(audiences),
+			IssuedAt: jwt.NewNumericDate(time.Now()),
 		},
-		CredentialsType: forType,
-		AuthMetadata:    authMD,
+		CredentialsType:   forType,
+		AuthMetadata:      authMD,
+		AuthMetadataMulti: authMDMulti,
+		ProofKey:          proofKey,
+		PeerIdentity:      peerIdentity,
 		// TODO(GOUT-13): expiration
 		// TODO(GOUT-12): refresh token
 		// TODO(GOUT-9): more complete info
-	})
+	}
+	if ss.authMDCodec != nil && len(authMD) != 0 {
+		encoded, err := ss.authMDCodec.EncodeAuthMetadata(authMD)
+		if err != nil {
+			ss.logger.Errorw("failed to encode auth metadata", "entity", audiences[0], "credentials_type", forType, "error", err)
+			return "", status.Error(codes.Internal, "failed to authenticate: cannot encode auth metadata")
+		}
+		claims.AuthMetadata = nil
+		claims.EncodedAuthMetadata = encoded
+	}
+	token := jwt.NewWithClaims(jwt.SigningMethodRS256, claims)
+	token.Header[tokenKindHeader] = tokenKindAccess
+	// With rotation, name the signing key so verifiers using the JWKS can pick it.
+	if len(ss.authRSAVerificationKeys) != 0 {
+		token.Header["kid"] = rsaKeyID(&ss.authRSAPrivKey.PublicKey)
+	}
 
+	signStart := time.Now()
 	tokenString, err := token.SignedString(ss.authRSAPrivKey)
+	ss.recordTokenSigning(forType, err, time.Since(signStart))
 	if err != nil {
 		ss.logger.Errorw("failed to sign JWT", "error", err)
 		return "", status.Error(codes.PermissionDenied, "failed to authenticate")
@@ -134,20 +555,321 @@ func (ss *simpleServer) signAccessTokenForEntity(
 	return tokenString, nil
 }
 
+// checkAuthMetadataLimits returns an error if the auth metadata exceeds the configured limits.
+func (ss *simpleServer) checkAuthMetadataLimits(authMD map[string]string, authMDMulti map[string][]string) error {
+	entries := len(authMD) + len(authMDMulti)
+	if entries > ss.authMDMaxEntries {
+		return errors.Errorf("auth metadata has %d entries; must be at most %d", entries, ss.authMDMaxEntries)
+	}
+	var size int
+	for k, v := range authMD {
+		size += len(k) + len(v)
+	}
+	for k, vs := range authMDMulti {
+		size += len(k)
+		for _, v := range vs {
+			size += len(v)
+		}
+	}
+	if size > ss.authMDMaxBytes {
+		return errors.Errorf("auth metadata is %d bytes; must be at most %d bytes", size, ss.authMDMaxBytes)
+	}
+	return nil
+}
+
+// reservedAuthMetadataKeys returns the sorted auth metadata keys that are named like a claim of
+// the tokens the server issues.
+func reservedAuthMetadataKeys(authMD map[string]string, authMDMulti map[string][]string) []string {
+	var reserved []string
+	for k := range authMD {
+		if reservedClaimNames[k] {
+			reserved = append(reserved, k)
+		}
+	}
+	for k := range authMDMulti {
+		if _, ok := authMD[k]; !ok && reservedClaimNames[k] {
+			reserved = append(reserved, k)
+		}
+	}
+	sort.Strings(reserved)
+	return reserved
+}
+
+// validateAuth checks that the authentication related options are consistent.
+func (sOpts *serverOptions) validateAuth() error {
+	if sOpts.unauthenticated && (len(sOpts.authHandlers) != 0 || sOpts.tlsAuthHandler != nil || sOpts.unixSocketAuthEntity != nil) {
+		return errMixedUnauthAndAuth
+	}
+	if sOpts.authenticateToExempt && sOpts.authToHandler == nil {
+		return errors.New("cannot exempt AuthenticateTo from authentication without an AuthenticateToHandler")
+	}
+
+	if sOpts.authRSAPrivateKey != nil {
+		minKeyBits := sOpts.authRSAMinKeyBits
+		if minKeyBits == 0 {
+			minKeyBits = defaultAuthRSAMinKeyBits
+		}
+		if keyBits := sOpts.authRSAPrivateKey.N.BitLen(); keyBits < minKeyBits {
+			return errors.Errorf("auth RSA private key is %d bits; must be at least %d bits", keyBits, minKeyBits)
+		}
+	}
+	return nil
+}
+
+// authRSAPrivateKeyOrGenerate returns the configured auth RSA private key or generates one
+// if authentication is enabled.
+func (sOpts *serverOptions) authRSAPrivateKeyOrGenerate() (*rsa.PrivateKey, error) {
+	if sOpts.unauthenticated || sOpts.authRSAPrivateKey != nil {
+		return sOpts.authRSAPrivateKey, nil
+	}
+	return rsa.GenerateKey(rand.Reader, generatedRSAKeyBits)
+}
+
+// setAuth sets everything the server needs to authenticate requests.
+func (ss *simpleServer) setAuth(sOpts *serverOptions, authRSAPrivKey *rsa.PrivateKey) {
+	ss.authRSAPrivKey = authRSAPrivKey
+	ss.authRSAVerificationKeys = sOpts.authRSAVerificationKeys
+	ss.tlsAuthHandler = sOpts.tlsAuthHandler
+	ss.tlsInfoExtractor = sOpts.tlsInfoExtractor
+	ss.tokenExtractor = sOpts.tokenExtractor
+	ss.authHandlers = sOpts.authHandlers
+	if ss.authHandlers == nil {
+		ss.authHandlers = make(map[CredentialsType]AuthHandler)
+	}
+	ss.fallbackAuthHandler = sOpts.fallbackAuthHandler
+	ss.authClaimsRedactor = sOpts.authClaimsRedactor
+	ss.authErrorMapper = sOpts.authErrorMapper
+	ss.authResponseHook = sOpts.authResponseHook
+	ss.authMetrics = sOpts.authMetrics
+	ss.methodLatencyMetrics = sOpts.methodLatencyMetrics
+	ss.messageSizeMetrics = sOpts.messageSizeMetrics
+	ss.authFailureTrailers = sOpts.authFailureTrailers
+	ss.authFailureDetails = sOpts.authFailureDetails
+	ss.debugClaimsLogging = sOpts.debugClaimsLogging
+	if ss.debugClaimsLogging {
+		ss.logger.Warn("DEBUG CLAIMS LOGGING IS ENABLED; the claims of every authenticated request will be logged. " +
+			"Do not use this in production.")
+	}
+	ss.requiredClaims = sOpts.requiredClaims
+	ss.entityFallbackClaim = sOpts.entityFallbackClaim
+	ss.tokenAudiences = sOpts.tokenAudiences
+	ss.acceptedAudiences = sOpts.acceptedAudiences
+	ss.authMDMaxEntries = sOpts.authMDMaxEntries
+	if ss.authMDMaxEntries == 0 {
+		ss.authMDMaxEntries = defaultAuthMDMaxEntries
+	}
+	ss.authMDMaxBytes = sOpts.authMDMaxBytes
+	if ss.authMDMaxBytes == 0 {
+		ss.authMDMaxBytes = defaultAuthMDMaxBytes
+	}
+	ss.reservedAuthMDPolicy = sOpts.reservedAuthMDPolicy
+	ss.authMDCodec = sOpts.authMDCodec
+	ss.proofOfPossessionSkew = sOpts.proofOfPossessionSkew
+	ss.tlsTokenBinding = sOpts.tlsTokenBinding
+	ss.peerTokenBinding = sOpts.peerTokenBinding
+	ss.unixSocketAuthEntity = sOpts.unixSocketAuthEntity
+	ss.expiryGracePeriod = sOpts.expiryGracePeriod
+	ss.recentAuthMethods = sOpts.recentAuthMethods
+	ss.maxTokenAge = sOpts.maxTokenAge
+	ss.issuedAtTolerance = sOpts.issuedAtTolerance
+	ss.authPolicy = sOpts.authPolicy
+	if ss.issuedAtTolerance == 0 {
+		ss.issuedAtTolerance = defaultIssuedAtTolerance
+	}
+	if sOpts.perEntityConcurrency != 0 {
+		ss.entityConcurrency = newEntityConcurrencyLimiter(sOpts.perEntityConcurrency)
+	}
+	ss.exemptMethods = make(map[string]bool)
+}
+
+// NewAuthInterceptors returns the interceptors a Server uses to authenticate requests for use
+// with a grpc.Server not created by NewServer. Requests are authenticated exactly as a Server
+// would, using only the authentication related ServerOptions (e.g. WithAuthHandler,
+// WithTLSAuthHandler, WithAuthRSAPrivateKey); the rest are ignored. These interceptors do not
+// issue tokens, so internally signed tokens are only accepted if WithAuthRSAPrivateKey or
+// WithAuthRSAVerificationKeys is given the key of the server that issued them.
+func NewAuthInterceptors(
+	logger golog.Logger,
+	opts ...ServerOption,
+) (grpc.UnaryServerInterceptor, grpc.StreamServerInterceptor, error) {
+	var sOpts serverOptions
+	for _, opt := range opts {
+		if err := opt.apply(&sOpts); err != nil {
+			return nil, nil, err
+		}
+	}
+	if sOpts.unauthenticated {
+		return nil, nil, errors.New("cannot create auth interceptors that are unauthenticated")
+	}
+	if err := sOpts.validateAuth(); err != nil {
+		return nil, nil, err
+	}
+	authRSAPrivKey, err := sOpts.authRSAPrivateKeyOrGenerate()
+	if err != nil {
+		return nil, nil, err
+	}
+
+	ss := &simpleServer{logger: logger}
+	ss.setAuth(&sOpts, authRSAPrivKey)
+	return ss.authUnaryInterceptor, ss.authStreamInterceptor, nil
+}
+
+// NewAuthenticatedServer returns a grpc.Server whose requests are authenticated exactly as a
+// Server would and that serves the AuthService (and the ExternalAuthService if WithAuthenticateToHandler
+// is given) so that clients can obtain tokens from it. Of the remaining ServerOptions, only the
+// TLS config, interceptors, stats handler, and unknown service handler are applied; the caller
+// is responsible for registering its services and serving the returned server.
+func NewAuthenticatedServer(logger golog.Logger, opts ...ServerOption) (*grpc.Server, error) {
+	var sOpts serverOptions
+	for _, opt := range opts {
+		if err := opt.apply(&sOpts); err != nil {
+			return nil, err
+		}
+	}
+	if sOpts.unauthenticated {
+		return nil, errors.New("cannot create an authenticated server that is unauthenticated")
+	}
+	if err := sOpts.validateAuth(); err != nil {
+		return nil, err
+	}
+	authRSAPrivKey, err := sOpts.authRSAPrivateKeyOrGenerate()
+	if err != nil {
+		return nil, err
+	}
+
+	ss := &simpleServer{
+		authToType:    sOpts.authToType,
+		authToHandler: sOpts.authToHandler,
+		tokenStore:    sOpts.tokenStore,
+		tlsConfig:     sOpts.tlsConfig,
+		logger:        logger,
+	}
+	ss.setAuth(&sOpts, authRSAPrivKey)
+	ss.exemptAuthServiceMethods(&sOpts)
+
+	unaryInterceptors := []grpc.UnaryServerInterceptor{ss.authUnaryInterceptor}
+	var userUnaryInterceptors []grpc.UnaryServerInterceptor
+	if sOpts.unaryInterceptor != nil {
+		userUnaryInterceptors = append(userUnaryInterceptors, sOpts.unaryInterceptor)
+	}
+	userUnaryInterceptors = append(userUnaryInterceptors, sOpts.unaryInterceptors...)
+	if len(userUnaryInterceptors) != 0 {
+		unaryInterceptors = append(unaryInterceptors, ss.unaryInterceptorsAfterAuth(userUnaryInterceptors...))
+	}
+	streamInterceptors := []grpc.StreamServerInterceptor{ss.authStreamInterceptor}
+	var userStreamInterceptors []grpc.StreamServerInterceptor
+	if sOpts.streamInterceptor != nil {
+		userStreamInterceptors = append(userStreamInterceptors, sOpts.streamInterceptor)
+	}
+	userStreamInterceptors = append(userStreamInterceptors, sOpts.streamInterceptors...)
+	if len(userStreamInterceptors) != 0 {
+		streamInterceptors = append(streamInterceptors, ss.streamInterceptorsAfterAuth(userStreamInterceptors...))
+	}
+
+	serverOpts := []grpc.ServerOption{
+		grpc.ChainUnaryInterceptor(unaryInterceptors...),
+		grpc.ChainStreamInterceptor(streamInterceptors...),
+	}
+	if sOpts.tlsConfig != nil {
+		serverOpts = append(serverOpts, grpc.Creds(credentials.NewTLS(sOpts.tlsConfig)))
+	}
+	if sOpts.statsHandler != nil {
+		serverOpts = append(serverOpts, grpc.StatsHandler(sOpts.statsHandler))
+	}
+	if sOpts.unknownStreamDesc != nil {
+		serverOpts = append(serverOpts, grpc.UnknownServiceHandler(sOpts.unknownStreamDesc.Handler))
+	}
+
+	grpcServer := grpc.NewServer(serverOpts...)
+	ss.grpcServer = grpcServer
+	rpcpb.RegisterAuthServiceServer(grpcServer, ss)
+	if sOpts.authToHandler != nil {
+		rpcpb.RegisterExternalAuthServiceServer(grpcServer, ss)
+	}
+	return grpcServer, nil
+}
+
+// exemptAuthServiceMethods exempts the methods of the auth services that must be callable
+// without a token from authentication: Authenticate and, if WithAuthenticateToExempt is set,
+// AuthenticateTo.
+func (ss *simpleServer) exemptAuthServiceMethods(sOpts *serverOptions) {
+	ss.AddExemptMethod(fullMethodName(&rpcpb.AuthService_ServiceDesc, "Authenticate"))
+	if sOpts.authToHandler != nil && sOpts.authenticateToExempt {
+		ss.AddExemptMethod(fullMethodName(&rpcpb.ExternalAuthService_ServiceDesc, "AuthenticateTo"))
+	}
+}
+
+// fullMethodName returns the full name of the method of the service described by desc.
+func fullMethodName(desc *grpc.ServiceDesc, method string) string {
+	return "/" + desc.ServiceName + "/" + method
+}
+
+// unknownExemptMethods returns the methods exempt from authentication that are not a method of
+// any of services. Such an exemption is most likely a typo or was not updated after a rename.
+func unknownExemptMethods(exemptMethods map[string]bool, services map[string]grpc.ServiceInfo) []string {
+	registered := registeredMethodSet(services)
+	var unknown []string
+	for method := range exemptMethods {
+		if !registered[method] {
+			unknown = append(unknown, method)
+		}
+	}
+	sort.Strings(unknown)
+	return unknown
+}
+
+// registeredMethodSet returns the full names of the methods of services.
+func registeredMethodSet(services map[string]grpc.ServiceInfo) map[string]bool {
+	registered := make(map[string]bool)
+	for svcName, info := range services {
+		for _, method := range info.Methods {
+			registered["/"+svcName+"/"+method.Name] = true
+		}
+	}
+	return registered
+}
+
 func (ss *simpleServer) authUnaryInterceptor(
 	ctx context.Context,
 	req interface{},
 	info *grpc.UnaryServerInfo,
 	handler grpc.UnaryHandler,
-) (interface{}, error) {
-	if !ss.exemptMethods[info.FullMethod] {
-		authEntity, err := ss.ensureAuthed(ctx)
-		if err != nil {
-			return nil, err
+) (resp interface{}, err error) {
+	defer func() {
+		ss.recordAuthRequest(info.FullMethod, err)
+	}()
+	ctx = statz.ContextWithMethod(ctx, info.FullMethod)
+	if !ss.isExemptMethod(info.FullMethod) {
+		authCtx, reason, authErr := ss.ensureAuthedForMethod(ctx, info.FullMethod)
+		if authErr != nil {
+			ss.recordAuthRejection(info.FullMethod, authErr)
+			if ss.authFailureTrailers {
+				//nolint:errcheck
+				grpc.SetTrailer(ctx, metadata.Pairs(MetadataFieldAuthFailureReason, string(reason)))
+			}
+			return nil, ss.withAuthFailureDetails(authErr, reason)
 		}
-		ctx = ContextWithAuthEntity(ctx, authEntity)
+		ctx = authCtx
 	}
-	return handler(ctx, req)
+	if ss.entityConcurrency != nil {
+		release, limitErr := ss.entityConcurrency.acquire(ctx)
+		if limitErr != nil {
+			return nil, limitErr
+		}
+		defer release()
+	}
+	ss.recordRequestSize(info.FullMethod, req)
+	if !ss.methodLatencyMetrics {
+		resp, err = handler(ctx, req)
+	} else {
+		start := time.Now()
+		resp, err = handler(ctx, req)
+		ss.recordMethodLatency(info.FullMethod, err, time.Since(start))
+	}
+	if err == nil {
+		ss.recordResponseSize(info.FullMethod, resp)
+	}
+	return resp, err
 }
 
 func (ss *simpleServer) authStreamInterceptor(
@@ -155,16 +877,224 @@ func (ss *simpleServer) authStreamInterceptor(
 	serverStream grpc.ServerStream,
 	info *grpc.StreamServerInfo,
 	handler grpc.StreamHandler,
-) error {
-	if !ss.exemptMethods[info.FullMethod] {
-		authEntity, err := ss.ensureAuthed(serverStream.Context())
+) (err error) {
+	defer func() {
+		ss.recordAuthRequest(info.FullMethod, err)
+	}()
+	ctx := statz.ContextWithMethod(serverStream.Context(), info.FullMethod)
+	if !ss.isExemptMethod(info.FullMethod) {
+		authCtx, reason, authErr := ss.ensureAuthedForMethod(ctx, info.FullMethod)
+		if authErr != nil {
+			ss.recordAuthRejection(info.FullMethod, authErr)
+			if ss.authFailureTrailers {
+				serverStream.SetTrailer(metadata.Pairs(MetadataFieldAuthFailureReason, string(reason)))
+			}
+			return ss.withAuthFailureDetails(authErr, reason)
+		}
+		ctx = authCtx
+	}
+	if ss.entityConcurrency != nil {
+		release, limitErr := ss.entityConcurrency.acquire(ctx)
+		if limitErr != nil {
+			return limitErr
+		}
+		defer release()
+	}
+	if ss.messageSizeMetrics {
+		serverStream = sizeRecordingServerStream{serverStream, ss, info.FullMethod}
+	}
+	return handler(srv, ctxWrappedServerStream{serverStream, ctx})
+}
+
+// checkTokenKind ensures the token is not of a kind other than an access token. Tokens without
+// a kind, such as ones issued by other services or before kinds were marked, are accepted.
+func checkTokenKind(token *jwt.Token) error {
+	kind, ok := token.Header[tokenKindHeader]
+	if !ok || kind == tokenKindAccess {
+		return nil
+	}
+	return status.Errorf(codes.Unauthenticated, "unauthenticated: token of kind %v cannot be used for access", kind)
+}
+
+// checkRequiredClaims ensures the token has every claim configured with WithRequiredClaims.
+func (ss *simpleServer) checkRequiredClaims(token *jwt.Token) error {
+	if len(ss.requiredClaims) == 0 {
+		return nil
+	}
+	mapClaims, ok := token.Claims.(jwt.MapClaims)
+	if !ok {
+		return status.Error(codes.Internal, "invalid type for claims, check library implementation")
+	}
+	var missing []string
+	for _, key := range ss.requiredClaims {
+		if _, ok := mapClaims[key]; !ok {
+			missing = append(missing, key)
+		}
+	}
+	if len(missing) != 0 {
+		return status.Errorf(codes.Unauthenticated, "unauthenticated: token is missing required claims: %s", strings.Join(missing, ", "))
+	}
+	return nil
+}
+
+// checkAcceptedAudiences ensures the token has one of the audiences configured with
+// WithAcceptedAudiences. The audience may be a single string or an array of strings.
+func (ss *simpleServer) checkAcceptedAudiences(token *jwt.Token) error {
+	if len(ss.acceptedAudiences) == 0 {
+		return nil
+	}
+	mapClaims, ok := token.Claims.(jwt.MapClaims)
+	if !ok {
+		return status.Error(codes.Internal, "invalid type for claims, check library implementation")
+	}
+	for _, aud := range ss.acceptedAudiences {
+		if mapClaims.VerifyAudience(aud, true) {
+			return nil
+		}
+	}
+	return status.Error(codes.Unauthenticated, "unauthenticated: token is not valid for any accepted audience")
+}
+
+// entityFromFallbackClaim returns the entity from the configured fallback claim of the token, if any.
+func (ss *simpleServer) entityFromFallbackClaim(token *jwt.Token) (string, bool) {
+	if ss.entityFallbackClaim == "" {
+		return "", false
+	}
+	mapClaims, ok := token.Claims.(jwt.MapClaims)
+	if !ok {
+		return "", false
+	}
+	entity, ok := mapClaims[ss.entityFallbackClaim].(string)
+	if !ok || entity == "" {
+		return "", false
+	}
+	return entity, true
+}
+
+// acceptExpiredInGrace returns if a token whose claims failed validation with err should be
+// accepted anyway because the only failure is that it expired less than the expiry grace period
+// ago. Accepted tokens are logged, counted, and flagged to the client in a trailer.
+func (ss *simpleServer) acceptExpiredInGrace(ctx context.Context, token *jwt.Token, claims Claims, err error) bool {
+	if ss.expiryGracePeriod == 0 {
+		return false
+	}
+	var vErr *jwt.ValidationError
+	if !errors.As(err, &vErr) || vErr.Errors != jwt.ValidationErrorExpired {
+		return false
+	}
+	expiredBy, ok := tokenExpiredBy(token, claims)
+	if !ok || expiredBy >= ss.expiryGracePeriod {
+		return false
+	}
+
+	ss.logger.Warnw("accepting expired token within grace period", "expired_by", expiredBy)
+	ss.recordExpiredTokenInGrace(claims.GetCredentialsType())
+	//nolint:errcheck
+	grpc.SetTrailer(ctx, metadata.Pairs(MetadataFieldTokenExpiredGrace, expiredBy.String()))
+	return true
+}
+
+// tokenExpiredBy returns how long ago the token with the given claims expired, if it has an expiry.
+func tokenExpiredBy(token *jwt.Token, claims Claims) (time.Duration, bool) {
+	exp, ok := claimsExpiresAt(token, claims)
+	if !ok {
+		return 0, false
+	}
+	return time.Since(exp), true
+}
+
+// claimsExpiresAt returns when the claims of token expire. Claims implementing ExpiringClaims
+// say so themselves while the "exp" claim is used for the rest.
+func claimsExpiresAt(token *jwt.Token, claims Claims) (time.Time, bool) {
+	if expiring, ok := claims.(ExpiringClaims); ok {
+		return expiring.ExpiresAt()
+	}
+	if jwtClaims, ok := claims.(*JWTClaims); ok {
+		if jwtClaims.RegisteredClaims.ExpiresAt == nil {
+			return time.Time{}, false
+		}
+		return jwtClaims.RegisteredClaims.ExpiresAt.Time, true
+	}
+	return numericDateClaim(token, "exp")
+}
+
+// numericDateClaim returns the time of a NumericDate claim of token such as "exp" or "iat".
+func numericDateClaim(token *jwt.Token, name string) (time.Time, bool) {
+	mapClaims, ok := token.Claims.(jwt.MapClaims)
+	if !ok {
+		return time.Time{}, false
+	}
+	var date float64
+	switch v := mapClaims[name].(type) {
+	case float64:
+		date = v
+	case json.Number:
+		f, err := v.Float64()
 		if err != nil {
-			return err
+			return time.Time{}, false
 		}
-		ctx := ContextWithAuthEntity(serverStream.Context(), authEntity)
-		serverStream = ctxWrappedServerStream{serverStream, ctx}
+		date = f
+	default:
+		return time.Time{}, false
+	}
+	sec, frac := math.Modf(date)
+	return time.Unix(int64(sec), int64(frac*1e9)), true
+}
+
+// isSignatureInvalid returns if err is from a token whose signature did not verify.
+func isSignatureInvalid(err error) bool {
+	var vErr *jwt.ValidationError
+	return errors.As(err, &vErr) && vErr.Errors&jwt.ValidationErrorSignatureInvalid != 0
+}
+
+// verifiedCertFromTLSInfo is the default TLS info extractor which returns the leaf of the
+// first verified chain of credentials.TLSInfo.
+func verifiedCertFromTLSInfo(authInfo credentials.AuthInfo) (*x509.Certificate, bool) {
+	tlsInfo, ok := authInfo.(credentials.TLSInfo)
+	if !ok {
+		return nil, false
+	}
+	verifiedChains := tlsInfo.State.VerifiedChains
+	if len(verifiedChains) == 0 || len(verifiedChains[0]) == 0 {
+		return nil, false
+	}
+	return verifiedChains[0][0], true
+}
+
+// unaryInterceptorsAfterAuth chains the given interceptors so that they run, in order,
+// after the auth interceptor has injected the authenticated context. Methods exempt
+// from authentication skip the chain entirely.
+func (ss *simpleServer) unaryInterceptorsAfterAuth(interceptors ...grpc.UnaryServerInterceptor) grpc.UnaryServerInterceptor {
+	chain := grpc_middleware.ChainUnaryServer(interceptors...)
+	return func(
+		ctx context.Context,
+		req interface{},
+		info *grpc.UnaryServerInfo,
+		handler grpc.UnaryHandler,
+	) (interface{}, error) {
+		if ss.isExemptMethod(info.FullMethod) {
+			return handler(ctx, req)
+		}
+		return chain(ctx, req, info, handler)
+	}
+}
+
+// streamInterceptorsAfterAuth chains the given interceptors so that they run, in order,
+// after the auth interceptor has injected the authenticated context. Methods exempt
+// from authentication skip the chain entirely.
+func (ss *simpleServer) streamInterceptorsAfterAuth(interceptors ...grpc.StreamServerInterceptor) grpc.StreamServerInterceptor {
+	chain := grpc_middleware.ChainStreamServer(interceptors...)
+	return func(
+		srv interface{},
+		serverStream grpc.ServerStream,
+		info *grpc.StreamServerInfo,
+		handler grpc.StreamHandler,
+	) error {
+		if ss.isExemptMethod(info.FullMethod) {
+			return handler(srv, serverStream)
+		}
+		return chain(srv, serverStream, info, handler)
 	}
-	return handler(srv, serverStream)
 }
 
 type ctxWrappedServerStream struct {
@@ -191,36 +1121,280 @@ func tokenFromContext(ctx context.Context) (string, error) {
 	return strings.TrimPrefix(authHeader[0], authorizationValuePrefixBearer), nil
 }
 
+// tokenFromRequest returns the access token of the request in ctx, preferring the one found by
+// the configured TokenExtractor, if any.
+func (ss *simpleServer) tokenFromRequest(ctx context.Context) (string, error) {
+	if ss.tokenExtractor != nil {
+		if token, ok := ss.tokenExtractor(ctx); ok {
+			return token, nil
+		}
+	}
+	return tokenFromContext(ctx)
+}
+
 var errNotTLSAuthed = errors.New("not authenticated via TLS")
 
 func (ss *simpleServer) ensureAuthed(ctx context.Context) (interface{}, error) {
-	tokenString, err := tokenFromContext(ctx)
+	entity, _, err := ss.ensureAuthedWithReason(ctx)
+	return entity, err
+}
+
+// ensureAuthedWithReason is ensureAuthed but also returns the class of failure when
+// authentication fails.
+func (ss *simpleServer) ensureAuthedWithReason(ctx context.Context) (interface{}, AuthFailureReason, error) {
+	_, authEntity, _, reason, err := ss.authenticateRequest(ctx)
+	return authEntity, reason, err
+}
+
+// ensureAuthedForMethod is ensureAuthedWithReason for a request to fullMethod. It additionally
+// lets the AuthHandler that verified the request veto it if it is a RequestAwareVerifier. On
+// success it returns the context handlers are called with, which carries the auth entity along
+// with the claims, token header, credential type, and auth metadata of the request.
+func (ss *simpleServer) ensureAuthedForMethod(ctx context.Context, fullMethod string) (context.Context, AuthFailureReason, error) {
+	start := time.Now()
+	authCtx, reason, err := ss.authenticateMethodRequest(ctx, fullMethod)
+	ss.recordAuthLatency(authCtx, time.Since(start))
+	return authCtx, reason, err
+}
+
+// authenticateMethodRequest is ensureAuthedForMethod without recording how long it took.
+func (ss *simpleServer) authenticateMethodRequest(ctx context.Context, fullMethod string) (context.Context, AuthFailureReason, error) {
+	authCtx, authEntity, handler, reason, err := ss.authenticateRequest(ctx)
+	if err != nil {
+		return nil, reason, err
+	}
+	if err := ss.checkRecentAuth(authCtx, fullMethod); err != nil {
+		return nil, AuthFailureReauthenticationRequired, err
+	}
+	authCtx = ContextWithAuthEntity(authCtx, authEntity)
+	if verifier, ok := handler.(RequestAwareVerifier); ok {
+		if err := verifier.VerifyRequest(authCtx, authEntity, fullMethod); err != nil {
+			if _, ok := status.FromError(err); ok {
+				return nil, AuthFailureRequestRejected, err
+			}
+			return nil, AuthFailureRequestRejected, status.Errorf(codes.PermissionDenied, "request rejected: %s", err)
+		}
+	}
+	if ss.authPolicy != nil {
+		if err := ss.authPolicy.authorize(authCtx, fullMethod); err != nil {
+			return nil, AuthFailureRequestRejected, err
+		}
+	}
+	return authCtx, "", nil
+}
+
+// checkMaxTokenAge ensures the token was issued less than the max token age ago, if one is set.
+func (ss *simpleServer) checkMaxTokenAge(token *jwt.Token) error {
+	if ss.maxTokenAge == 0 {
+		return nil
+	}
+	issuedAt, ok := numericDateClaim(token, "iat")
+	if !ok {
+		return status.Error(codes.Unauthenticated, "unauthenticated: token has no issued at (iat) claim")
+	}
+	if time.Since(issuedAt) >= ss.maxTokenAge {
+		return status.Errorf(codes.Unauthenticated, "unauthenticated: token was issued more than %s ago", ss.maxTokenAge)
+	}
+	return nil
+}
+
+// storedTokenMaxAge returns the age at which a token kept by the token store is no longer
+// returned by Authenticate: the shortest of the max token age and the max ages of methods that
+// require recent authentication. Otherwise a client stepping up for a recently authenticated
+// method would be handed back the stale token it already has. It is zero if there is no limit.
+func (ss *simpleServer) storedTokenMaxAge() time.Duration {
+	maxAge := ss.maxTokenAge
+	for _, methodMaxAge := range ss.recentAuthMethods {
+		if maxAge == 0 || methodMaxAge < maxAge {
+			maxAge = methodMaxAge
+		}
+	}
+	return maxAge
+}
+
+// isStoredTokenFresh returns whether a token from the token store was issued recently enough to
+// be returned by Authenticate (see storedTokenMaxAge).
+func (ss *simpleServer) isStoredTokenFresh(token string) bool {
+	maxAge := ss.storedTokenMaxAge()
+	if maxAge == 0 {
+		return true
+	}
+	parsed, _, err := jwt.NewParser().ParseUnverified(token, jwt.MapClaims{})
+	if err != nil {
+		return false
+	}
+	issuedAt, ok := numericDateClaim(parsed, "iat")
+	return ok && time.Since(issuedAt) < maxAge
+}
+
+// checkIssuedAt ensures the token was not issued further in the future than the issued at
+// tolerance. Tokens without an "iat" claim are accepted.
+func (ss *simpleServer) checkIssuedAt(token *jwt.Token) error {
+	issuedAt, ok := numericDateClaim(token, "iat")
+	if !ok {
+		return nil
+	}
+	if time.Until(issuedAt) > ss.issuedAtTolerance {
+		return status.Errorf(codes.Unauthenticated,
+			"unauthenticated: token was issued more than %s in the future", ss.issuedAtTolerance)
+	}
+	return nil
+}
+
+// isIssuedAtOnlyError returns whether err is a claims validation error solely because the token
+// was issued in the future.
+func isIssuedAtOnlyError(err error) bool {
+	var vErr *jwt.ValidationError
+	return errors.As(err, &vErr) && vErr.Errors == jwt.ValidationErrorIssuedAt
+}
+
+// checkRecentAuth ensures that a request to fullMethod, if it requires recent authentication, was
+// authenticated with a token issued recently enough.
+func (ss *simpleServer) checkRecentAuth(authCtx context.Context, fullMethod string) error {
+	maxAge, ok := ss.recentAuthMethods[fullMethod]
+	if !ok {
+		return nil
+	}
+	issuedAt, ok := contextAuthTokenIssuedAt(authCtx)
+	if !ok || time.Since(issuedAt) >= maxAge {
+		return status.Errorf(codes.Unauthenticated, "unauthenticated: %s requires a token issued within the last %s", fullMethod, maxAge)
+	}
+	return nil
+}
+
+// verifiedPeerCert returns the verified client certificate of the request in ctx, if any.
+func (ss *simpleServer) verifiedPeerCert(ctx context.Context) *x509.Certificate {
+	p, ok := peer.FromContext(ctx)
+	if !ok || p.AuthInfo == nil {
+		return nil
+	}
+	extractor := ss.tlsInfoExtractor
+	if extractor == nil {
+		extractor = verifiedCertFromTLSInfo
+	}
+	cert, ok := extractor(p.AuthInfo)
+	if !ok {
+		return nil
+	}
+	return cert
+}
+
+// checkTLSTokenBinding ensures that a token for entity is presented over a connection whose
+// verified client certificate, if there is one, names entity as one of its DNS SANs.
+func (ss *simpleServer) checkTLSTokenBinding(ctx context.Context, entity string) error {
+	if !ss.tlsTokenBinding {
+		return nil
+	}
+	cert := ss.verifiedPeerCert(ctx)
+	if cert == nil {
+		return nil
+	}
+	for _, name := range cert.DNSNames {
+		if name == entity {
+			return nil
+		}
+	}
+	return status.Error(codes.Unauthenticated, "unauthenticated: token entity does not match client certificate")
+}
+
+// peerIdentity returns the identity of the peer of the request in ctx that tokens are bound to
+// with WithPeerTokenBinding: the SHA-256 fingerprint of its verified client certificate if it
+// has one or else its IP address. It is empty if the peer is unknown.
+func (ss *simpleServer) peerIdentity(ctx context.Context) string {
+	if cert := ss.verifiedPeerCert(ctx); cert != nil {
+		sum := sha256.Sum256(cert.Raw)
+		return "cert:" + hex.EncodeToString(sum[:])
+	}
+	p, ok := peer.FromContext(ctx)
+	if !ok || p.Addr == nil {
+		return ""
+	}
+	host, _, err := net.SplitHostPort(p.Addr.String())
+	if err != nil {
+		host = p.Addr.String()
+	}
+	return "ip:" + host
+}
+
+// checkPeerTokenBinding ensures that a token bound to a peer with WithPeerTokenBinding is
+// presented by that same peer. Tokens that are not bound to a peer are accepted.
+func (ss *simpleServer) checkPeerTokenBinding(ctx context.Context, token *jwt.Token) error {
+	if !ss.peerTokenBinding {
+		return nil
+	}
+	mapClaims, ok := token.Claims.(jwt.MapClaims)
+	if !ok {
+		return status.Error(codes.Internal, "invalid type for claims, check library implementation")
+	}
+	boundTo, _ := mapClaims["rpc_peer"].(string)
+	if boundTo == "" || boundTo == ss.peerIdentity(ctx) {
+		return nil
+	}
+	return status.Error(codes.Unauthenticated, "unauthenticated: token is bound to a different peer")
+}
+
+// authenticateRequest authenticates the request in ctx and returns the authenticated entity along
+// with the AuthHandler that verified it, which is nil for requests authenticated via TLS. The
+// returned context carries what was learned from the token (claims, token header, credential
+// type, and auth metadata) but not the entity itself.
+func (ss *simpleServer) authenticateRequest(ctx context.Context) (context.Context, interface{}, AuthHandler, AuthFailureReason, error) {
+	if ss.unixSocketAuthEntity != nil && isUnixSocketPeer(ctx) {
+		return ctx, ss.unixSocketAuthEntity, nil, "", nil
+	}
+	tokenString, err := ss.tokenFromRequest(ctx)
 	if err != nil {
 		// check TLS state
 		if ss.tlsAuthHandler == nil {
-			return nil, err
-		}
-		var verifiedCert *x509.Certificate
-		if p, ok := peer.FromContext(ctx); ok && p.AuthInfo != nil {
-			if authInfo, ok := p.AuthInfo.(credentials.TLSInfo); ok {
-				verifiedChains := authInfo.State.VerifiedChains
-				if len(verifiedChains) != 0 && len(verifiedChains[0]) != 0 {
-					verifiedCert = verifiedChains[0][0]
-				}
-			}
+			return nil, nil, nil, AuthFailureMissingCredentials, err
 		}
+		verifiedCert := ss.verifiedPeerCert(ctx)
 		if verifiedCert == nil {
-			return nil, err
+			return nil, nil, nil, AuthFailureMissingCredentials, err
 		}
-		if tlsAuthEntity, tlsErr := ss.tlsAuthHandler(ctx, verifiedCert.DNSNames...); tlsErr == nil {
-			return tlsAuthEntity, nil
-		} else if !errors.Is(tlsErr, errNotTLSAuthed) {
-			return nil, multierr.Combine(err, tlsErr)
+		return ss.authenticateCert(ctx, verifiedCert, err)
+	}
+	return ss.authenticateToken(ctx, tokenString)
+}
+
+// isUnixSocketPeer returns whether the request was made over a unix socket connection.
+func isUnixSocketPeer(ctx context.Context) bool {
+	p, ok := peer.FromContext(ctx)
+	if !ok {
+		return false
+	}
+	_, ok = p.Addr.(*net.UnixAddr)
+	return ok
+}
+
+// authenticateCert is authenticateRequest for a request made with the verified client certificate
+// cert and no token. noTokenErr is why there is no token and is returned if the certificate
+// does not authenticate the request either.
+func (ss *simpleServer) authenticateCert(
+	ctx context.Context,
+	cert *x509.Certificate,
+	noTokenErr error,
+) (context.Context, interface{}, AuthHandler, AuthFailureReason, error) {
+	if ss.tlsAuthHandler == nil {
+		return nil, nil, nil, AuthFailureMissingCredentials, noTokenErr
+	}
+	if tlsAuthEntity, tlsAuthMD, tlsErr := ss.tlsAuthHandler(ctx, cert.DNSNames...); tlsErr == nil {
+		if tlsAuthMD != nil {
+			ctx = contextWithTLSAuthMetadata(contextWithAuthMetadata(ctx, tlsAuthMD), tlsAuthMD)
 		}
-		return nil, err
+		return ctx, tlsAuthEntity, nil, "", nil
+	} else if !errors.Is(tlsErr, errNotTLSAuthed) {
+		return nil, nil, nil, AuthFailureEntityVerification, multierr.Combine(noTokenErr, tlsErr)
 	}
+	return nil, nil, nil, AuthFailureMissingCredentials, noTokenErr
+}
 
+// authenticateToken is authenticateRequest for a request made with tokenString.
+func (ss *simpleServer) authenticateToken(
+	ctx context.Context,
+	tokenString string,
+) (context.Context, interface{}, AuthHandler, AuthFailureReason, error) {
 	var handler AuthHandler
+	var err error
 
 	// Skip validating cliams until rpc_creds_type can determine if custom claim is used. Claims must be validated
 	// after decoding the jwt.
@@ -228,31 +1402,73 @@ func (ss *simpleServer) ensureAuthed(ctx context.Context) (interface{}, error) {
 	jwtParser := jwt.NewParser(jwt.WithoutClaimsValidation())
 
 	// Parse without claims and use the default provided by jwt library. This allows us to get all unknown claims.
-	outToken, err := jwtParser.Parse(tokenString, func(token *jwt.Token) (interface{}, error) {
-		// Get the credential type from the claims
-		credType, err := getCredentialsTypeFromMapClaims(token.Claims)
-		if err != nil {
-			return nil, err
-		}
+	// Internally signed tokens are tried against each internal verification key in order until one verifies.
+	var outToken *jwt.Token
+	// noHandler is whether the last parse failed to find the handler of the token.
+	var noHandler bool
+	for keyIdx := 0; ; keyIdx++ {
+		var usedInternalKey bool
+		outToken, err = jwtParser.Parse(tokenString, func(token *jwt.Token) (interface{}, error) {
+			// Get the credential type from the claims
+			credType, err := getCredentialsTypeFromMapClaims(token.Claims)
+			if err != nil {
+				noHandler = true
+				return nil, err
+			}
 
-		handler, err = ss.authHandler(credType)
-		if err != nil {
-			return nil, err
-		}
+			handler, err = ss.authHandler(credType)
+			if err != nil {
+				noHandler = true
+				return nil, err
+			}
 
-		if provider, ok := handler.(TokenVerificationKeyProvider); ok {
-			return provider.TokenVerificationKey(token)
-		}
+			if provider, ok := handler.(TokenVerificationKeyProvider); ok {
+				return provider.TokenVerificationKey(token)
+			}
+
+			// signed internally
+			if _, ok := token.Method.(*jwt.SigningMethodRSA); !ok {
+				return nil, fmt.Errorf("unexpected signing method %q", token.Method.Alg())
+			}
 
-		// signed internally
-		if _, ok := token.Method.(*jwt.SigningMethodRSA); !ok {
-			return nil, fmt.Errorf("unexpected signing method %q", token.Method.Alg())
+			usedInternalKey = true
+			if keyIdx == 0 {
+				return &ss.authRSAPrivKey.PublicKey, nil
+			}
+			return ss.authRSAVerificationKeys[keyIdx-1], nil
+		})
+		if err == nil || !usedInternalKey || keyIdx >= len(ss.authRSAVerificationKeys) || !isSignatureInvalid(err) {
+			break
 		}
+	}
+	if err != nil {
+		ss.recordTokenError(tokenErrorCategory(err, noHandler))
+	}
+	if errors.Is(err, errVerificationBackendUnavailable) {
+		return nil, nil, nil, AuthFailureVerificationUnavailable, status.Errorf(codes.Unavailable, "cannot verify token: %s", err)
+	}
+	if err != nil {
+		return nil, nil, nil, AuthFailureInvalidToken, status.Errorf(codes.Unauthenticated, "unauthenticated: %s", err)
+	}
+
+	err = checkTokenKind(outToken)
+	if err != nil {
+		return nil, nil, nil, AuthFailureInvalidToken, err
+	}
+
+	err = ss.checkRequiredClaims(outToken)
+	if err != nil {
+		return nil, nil, nil, AuthFailureInvalidClaims, err
+	}
 
-		return &ss.authRSAPrivKey.PublicKey, nil
-	})
+	err = ss.checkAcceptedAudiences(outToken)
 	if err != nil {
-		return nil, status.Errorf(codes.Unauthenticated, "unauthenticated: %s", err)
+		return nil, nil, nil, AuthFailureInvalidClaims, err
+	}
+
+	err = ss.checkProofOfPossession(ctx, outToken)
+	if err != nil {
+		return nil, nil, nil, AuthFailureInvalidProof, err
 	}
 
 	// By default use the standard rpc.JWTClaims
@@ -263,7 +1479,8 @@ func (ss *simpleServer) ensureAuthed(ctx context.Context) (interface{}, error) {
 		// reset the claims to the handlers version
 		claims = provider.CreateClaims()
 		if claims == nil {
-			return nil, status.Error(codes.Internal, "invalid implementation of TokenCustomClaimProvider, cannot return nil")
+			return nil, nil, nil, AuthFailureInternal, status.Error(
+				codes.Internal, "invalid implementation of TokenCustomClaimProvider, cannot return nil")
 		}
 	}
 
@@ -272,29 +1489,86 @@ func (ss *simpleServer) ensureAuthed(ctx context.Context) (interface{}, error) {
 	// usess pointers to time.Time causing parsing issues. For now we can just reparse the json jwt token into the claim.
 	_, _, err = jwtParser.ParseUnverified(outToken.Raw, claims)
 	if err != nil {
-		return nil, status.Errorf(codes.InvalidArgument, "error decoding claims: %s", err)
+		return nil, nil, nil, AuthFailureInvalidClaims, status.Errorf(codes.InvalidArgument, "error decoding claims: %s", err)
+	}
+	if err := ss.decodeAuthMetadata(claims); err != nil {
+		return nil, nil, nil, AuthFailureInvalidClaims, status.Errorf(codes.Unauthenticated, "unauthenticated: %s", err)
+	}
+
+	if err := ss.checkIssuedAt(outToken); err != nil {
+		ss.recordTokenError(tokenErrorInvalidClaims)
+		return nil, nil, nil, AuthFailureInvalidClaims, err
 	}
 
 	// We MUST validate claims here. We disabled claims validation in the parser above.
 	err = claims.Valid()
+	// Claims such as JWTClaims reject any "iat" in the future but it was checked with tolerance above.
+	if isIssuedAtOnlyError(err) {
+		err = nil
+	}
 	if err != nil {
-		return nil, status.Errorf(codes.Unauthenticated, "unauthenticated: %s", err)
+		reason := AuthFailureInvalidClaims
+		var vErr *jwt.ValidationError
+		if errors.As(err, &vErr) && vErr.Errors&jwt.ValidationErrorExpired != 0 {
+			reason = AuthFailureTokenExpired
+		}
+		if !ss.acceptExpiredInGrace(ctx, outToken, claims, err) {
+			ss.recordTokenError(tokenErrorCategory(err, false))
+			return nil, nil, nil, reason, status.Errorf(codes.Unauthenticated, "unauthenticated: %s", err)
+		}
+	}
+
+	if err := ss.checkMaxTokenAge(outToken); err != nil {
+		return nil, nil, nil, AuthFailureTokenExpired, err
 	}
 
 	entity, err := claims.Entity()
 	if err != nil {
-		return nil, err
+		fallbackEntity, ok := ss.entityFromFallbackClaim(outToken)
+		if !ok {
+			return nil, nil, nil, AuthFailureInvalidClaims, err
+		}
+		entity = fallbackEntity
+	}
+
+	if err := ss.checkTLSTokenBinding(ctx, entity); err != nil {
+		return nil, nil, nil, AuthFailureTokenBinding, err
+	}
+	if err := ss.checkPeerTokenBinding(ctx, outToken); err != nil {
+		return nil, nil, nil, AuthFailureTokenBinding, err
+	}
+
+	ctx = contextWithCredentialsType(ctx, claims.GetCredentialsType())
+
+	// Only keep what is allowed of the claims in the context.
+	if ss.authClaimsRedactor != nil {
+		claims = ss.authClaimsRedactor(claims)
+		if claims == nil {
+			return nil, nil, nil, AuthFailureInternal, status.Error(codes.Internal, "invalid auth claims redactor, cannot return nil")
+		}
 	}
 
 	// Pass the raw claims to the Context.
 	ctx = contextWithAuthClaims(ctx, claims)
+	ctx = contextWithAuthTokenHeader(ctx, tokenHeaderFromJWT(outToken))
+	if issuedAt, ok := numericDateClaim(outToken, "iat"); ok {
+		ctx = contextWithAuthTokenIssuedAt(ctx, issuedAt)
+	}
 
 	// Pass the auth metadata to the context.
 	if claims.GetAuthMetadata() != nil {
 		ctx = contextWithAuthMetadata(ctx, claims.GetAuthMetadata())
 	}
 
-	return handler.VerifyEntity(ctx, entity)
+	authEntity, err := handler.VerifyEntity(ctx, entity)
+	if err != nil {
+		return nil, nil, nil, AuthFailureEntityVerification, err
+	}
+	ss.recordAuthenticatedEntity(entity)
+	if ss.debugClaimsLogging {
+		ss.logger.Debugw("authenticated request", "entity", entity, "claims", claims)
+	}
+	return ctx, authEntity, handler, "", nil
 }
 
 func getCredentialsTypeFromMapClaims(in jwt.Claims) (CredentialsType, error) {
@@ -0,0 +1,358 @@
+package rpc
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"github.com/golang-jwt/jwt/v4"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/proto"
+
+	"go.viam.com/utils/perf/statz"
+	"go.viam.com/utils/perf/statz/units"
+)
+
+// credentialsTypeUnknownLabel is used in place of credential types that have no registered
+// handler in order to bound the cardinality of auth metrics.
+const credentialsTypeUnknownLabel = "unknown"
+
+// methodUnknownLabel is used in place of methods that are not registered with the server, such
+// as those served by an unknown service handler, in order to bound the cardinality of auth metrics.
+const methodUnknownLabel = "unknown"
+
+var authenticateCredentialsTypes = statz.NewCounter1[string]("rpc/auth/authenticate_credentials_types", statz.MetricConfig{
+	Description: "The number of Authenticate calls by credential type, regardless of success.",
+	Unit:        units.Dimensionless,
+	Labels: []statz.Label{
+		{Name: "credentials_type", Description: "The credential type requested or unknown if there is no handler for it."},
+	},
+})
+
+var authenticateAlreadyAuthenticated = statz.NewCounter1[string]("rpc/auth/authenticate_already_authenticated", statz.MetricConfig{
+	Description: "The number of Authenticate calls rejected because the request already had an authorization header.",
+	Unit:        units.Dimensionless,
+	Labels: []statz.Label{
+		{Name: "credentials_type", Description: "The credential type requested or unknown if there is no handler for it."},
+	},
+})
+
+// recordAlreadyAuthenticated counts an Authenticate call for forType made with an authorization header.
+func (ss *simpleServer) recordAlreadyAuthenticated(forType CredentialsType) {
+	if !ss.authMetrics {
+		return
+	}
+	authenticateAlreadyAuthenticated.Inc(ss.credentialsTypeLabel(forType))
+}
+
+var authenticatedEntities = statz.NewCardinalityGauge("rpc/auth/authenticated_entities", statz.MetricConfig{
+	Description: "The estimated number of distinct entities that authenticated requests with a token.",
+	Unit:        units.Dimensionless,
+})
+
+// recordAuthenticatedEntity feeds the entity of a token authenticated request into the
+// distinct entity estimate.
+func (ss *simpleServer) recordAuthenticatedEntity(entity string) {
+	if !ss.authMetrics {
+		return
+	}
+	authenticatedEntities.Observe(entity)
+}
+
+var expiredTokensInGrace = statz.NewCounter1[string]("rpc/auth/expired_tokens_in_grace", statz.MetricConfig{
+	Description: "The number of requests whose expired token was accepted within the expiry grace period.",
+	Unit:        units.Dimensionless,
+	Labels: []statz.Label{
+		{Name: "credentials_type", Description: "The credential type of the token or unknown if there is no handler for it."},
+	},
+})
+
+// recordExpiredTokenInGrace counts a request accepted with an expired token of forType.
+func (ss *simpleServer) recordExpiredTokenInGrace(forType CredentialsType) {
+	if !ss.authMetrics {
+		return
+	}
+	expiredTokensInGrace.Inc(ss.credentialsTypeLabel(forType))
+}
+
+// The categories of tokens that failed to parse or validate.
+const (
+	tokenErrorExpired                 = "expired"
+	tokenErrorBadSignature            = "bad_signature"
+	tokenErrorMalformed               = "malformed"
+	tokenErrorUnknownCredentialsType  = "unknown_credentials_type"
+	tokenErrorUnknownKey              = "unknown_key"
+	tokenErrorInvalidClaims           = "invalid_claims"
+	tokenErrorVerificationUnavailable = "verification_unavailable"
+	tokenErrorOther                   = "other"
+)
+
+var tokenErrors = statz.NewCounter1[string]("rpc/auth/token_errors", statz.MetricConfig{
+	Description: "The number of tokens that failed to parse or validate by the category of failure.",
+	Unit:        units.Dimensionless,
+	Labels: []statz.Label{
+		{Name: "category", Description: "The category of the failure.", Values: []string{
+			tokenErrorExpired,
+			tokenErrorBadSignature,
+			tokenErrorMalformed,
+			tokenErrorUnknownCredentialsType,
+			tokenErrorUnknownKey,
+			tokenErrorInvalidClaims,
+			tokenErrorVerificationUnavailable,
+			tokenErrorOther,
+		}},
+	},
+})
+
+// tokenErrorCategory classifies err, returned from parsing or validating the claims of a token,
+// for the rpc/auth/token_errors metric. noHandler is whether the token's handler was not found.
+func tokenErrorCategory(err error, noHandler bool) string {
+	if errors.Is(err, errVerificationBackendUnavailable) {
+		return tokenErrorVerificationUnavailable
+	}
+	var vErr *jwt.ValidationError
+	if !errors.As(err, &vErr) {
+		return tokenErrorOther
+	}
+	switch {
+	case vErr.Errors&jwt.ValidationErrorMalformed != 0:
+		return tokenErrorMalformed
+	case vErr.Errors&jwt.ValidationErrorUnverifiable != 0:
+		// the key function failed, either finding the handler or the key of the token.
+		if noHandler {
+			return tokenErrorUnknownCredentialsType
+		}
+		return tokenErrorUnknownKey
+	case vErr.Errors&jwt.ValidationErrorSignatureInvalid != 0:
+		return tokenErrorBadSignature
+	case vErr.Errors&jwt.ValidationErrorExpired != 0:
+		return tokenErrorExpired
+	default:
+		return tokenErrorInvalidClaims
+	}
+}
+
+// recordTokenError counts a token that failed to parse or validate with the given category.
+func (ss *simpleServer) recordTokenError(category string) {
+	if !ss.authMetrics {
+		return
+	}
+	tokenErrors.Inc(category)
+}
+
+var authInterceptorRejections = statz.NewCounter2[string, string]("rpc/auth/interceptor_rejections", statz.MetricConfig{
+	Description: "The number of requests rejected by the auth interceptors before reaching a handler.",
+	Unit:        units.Dimensionless,
+	Labels: []statz.Label{
+		{Name: "method", Description: "The full gRPC method name or unknown if it is not registered with the server."},
+		{Name: "reason", Description: "The gRPC status code the request was rejected with."},
+	},
+})
+
+var authInterceptorRequests = statz.NewCounter2[string, string]("rpc/auth/interceptor_requests", statz.MetricConfig{
+	Description: "The number of requests through the auth interceptors by their final status.",
+	Unit:        units.Dimensionless,
+	Labels: []statz.Label{
+		{Name: "method", Description: "The full gRPC method name or unknown if it is not registered with the server."},
+		{Name: "code", Description: "The canonical gRPC status code the request finished with."},
+	},
+})
+
+// statusCodeLabel returns the gRPC status code of err as a label value. Non canonical codes
+// are reported as Unknown in order to bound the cardinality of the label.
+func statusCodeLabel(err error) string {
+	code := status.Code(err)
+	if code > codes.Unauthenticated {
+		code = codes.Unknown
+	}
+	return code.String()
+}
+
+// recordAuthRequest counts a request to method that finished with err.
+func (ss *simpleServer) recordAuthRequest(method string, err error) {
+	if !ss.authMetrics {
+		return
+	}
+	authInterceptorRequests.Inc(ss.methodLabel(method), statusCodeLabel(err))
+}
+
+// recordAuthRejection counts a request to method rejected by the auth interceptors with err.
+func (ss *simpleServer) recordAuthRejection(method string, err error) {
+	if !ss.authMetrics {
+		return
+	}
+	authInterceptorRejections.Inc(ss.methodLabel(method), statusCodeLabel(err))
+}
+
+var tokenStoreLookups = statz.NewCounter2[string, bool]("rpc/auth/token_store_lookups", statz.MetricConfig{
+	Description: "The number of times Authenticate consulted the token store and whether it had a token.",
+	Unit:        units.Dimensionless,
+	Labels: []statz.Label{
+		{Name: "credentials_type", Description: "The credential type authenticated with."},
+		{Name: "hit", Description: "If a previously issued token was returned."},
+	},
+})
+
+// recordTokenStoreLookup counts a token store lookup for the credential type.
+func (ss *simpleServer) recordTokenStoreLookup(forType CredentialsType, hit bool) {
+	if !ss.authMetrics {
+		return
+	}
+	tokenStoreLookups.Inc(ss.credentialsTypeLabel(forType), hit)
+}
+
+var tokenSigningLatency = statz.NewMillisecondsDistribution1[string]("rpc/auth/token_signing_latency", statz.MetricConfig{
+	Description: "The duration of signing access tokens, regardless of success.",
+	Unit:        units.Milliseconds,
+	Labels: []statz.Label{
+		{Name: "credentials_type", Description: "The credential type of the token or unknown if there is no handler for it."},
+	},
+}, statz.LatencyDistribution)
+
+var tokenSigningFailures = statz.NewCounter1[string]("rpc/auth/token_signing_failures", statz.MetricConfig{
+	Description: "The number of access tokens that failed to be signed.",
+	Unit:        units.Dimensionless,
+	Labels: []statz.Label{
+		{Name: "credentials_type", Description: "The credential type of the token or unknown if there is no handler for it."},
+	},
+})
+
+// recordTokenSigning records the duration of signing an access token of forType that failed with err.
+func (ss *simpleServer) recordTokenSigning(forType CredentialsType, err error, d time.Duration) {
+	if !ss.authMetrics {
+		return
+	}
+	label := ss.credentialsTypeLabel(forType)
+	tokenSigningLatency.ObserveMilliseconds(d, label)
+	if err != nil {
+		tokenSigningFailures.Inc(label)
+	}
+}
+
+var authLatency = statz.NewNanosecondsDistribution1[string]("rpc/auth/authentication_latency", statz.MetricConfig{
+	Description: "The total time spent authenticating requests in the auth interceptors, including verifying the entity.",
+	Unit:        units.Nanoseconds,
+	Labels: []statz.Label{
+		{Name: "credentials_type", Description: "The credential type of the token or unknown if there is none or no handler for it."},
+	},
+}, statz.Distribution{})
+
+// recordAuthLatency records the duration of authenticating a request. authCtx is the context of
+// the authenticated request, if it was authenticated.
+func (ss *simpleServer) recordAuthLatency(authCtx context.Context, d time.Duration) {
+	if !ss.authMetrics {
+		return
+	}
+	var credType CredentialsType
+	if authCtx != nil {
+		credType, _ = ContextCredentialsType(authCtx)
+	}
+	authLatency.ObserveDuration(d, ss.credentialsTypeLabel(credType))
+}
+
+// credentialsTypeLabel returns the credential type as a label value bounded to the registered handlers.
+func (ss *simpleServer) credentialsTypeLabel(forType CredentialsType) string {
+	if _, ok := ss.authHandlers[forType]; !ok {
+		return credentialsTypeUnknownLabel
+	}
+	return string(forType)
+}
+
+// methodLabel returns the method as a label value bounded to the methods registered with the
+// server. The interceptors from NewAuthInterceptors have no server to consult and so report
+// every method as unknown.
+func (ss *simpleServer) methodLabel(method string) string {
+	ss.registeredMethodsMu.RLock()
+	registered := ss.registeredMethods
+	ss.registeredMethodsMu.RUnlock()
+	if registered == nil {
+		ss.registeredMethodsMu.Lock()
+		if ss.registeredMethods == nil {
+			ss.registeredMethods = map[string]bool{}
+			if ss.grpcServer != nil {
+				ss.registeredMethods = registeredMethodSet(ss.grpcServer.GetServiceInfo())
+			}
+		}
+		registered = ss.registeredMethods
+		ss.registeredMethodsMu.Unlock()
+	}
+	if !registered[method] {
+		return methodUnknownLabel
+	}
+	return method
+}
+
+var methodHandlerLatency = statz.NewMillisecondsDistribution2[string, string]("rpc/auth/handler_latency", statz.MetricConfig{
+	Description: "The duration of unary handler calls behind the auth interceptor.",
+	Unit:        units.Milliseconds,
+	Labels: []statz.Label{
+		{Name: "method", Description: "The full gRPC method name or unknown if it is not registered with the server."},
+		{Name: "code", Description: "The canonical gRPC status code the handler returned."},
+	},
+}, statz.LatencyDistribution)
+
+// recordMethodLatency records the duration of a handler call to method that returned err.
+func (ss *simpleServer) recordMethodLatency(method string, err error, d time.Duration) {
+	methodHandlerLatency.ObserveMilliseconds(d, ss.methodLabel(method), statusCodeLabel(err))
+}
+
+var requestMessageSizes = statz.NewDistribution1[string]("rpc/auth/request_size", statz.MetricConfig{
+	Description: "The serialized size of request messages behind the auth interceptor.",
+	Unit:        units.Bytes,
+	Labels: []statz.Label{
+		{Name: "method", Description: "The full gRPC method name or unknown if it is not registered with the server."},
+	},
+}, statz.Distribution{})
+
+var responseMessageSizes = statz.NewDistribution1[string]("rpc/auth/response_size", statz.MetricConfig{
+	Description: "The serialized size of response messages behind the auth interceptor.",
+	Unit:        units.Bytes,
+	Labels: []statz.Label{
+		{Name: "method", Description: "The full gRPC method name or unknown if it is not registered with the server."},
+	},
+}, statz.Distribution{})
+
+// recordRequestSize records the size of a request message to method if it is a protobuf.
+func (ss *simpleServer) recordRequestSize(method string, msg interface{}) {
+	if !ss.messageSizeMetrics {
+		return
+	}
+	if m, ok := msg.(proto.Message); ok {
+		requestMessageSizes.Observe(float64(proto.Size(m)), ss.methodLabel(method))
+	}
+}
+
+// recordResponseSize records the size of a response message from method if it is a protobuf.
+func (ss *simpleServer) recordResponseSize(method string, msg interface{}) {
+	if !ss.messageSizeMetrics {
+		return
+	}
+	if m, ok := msg.(proto.Message); ok {
+		responseMessageSizes.Observe(float64(proto.Size(m)), ss.methodLabel(method))
+	}
+}
+
+// sizeRecordingServerStream records the size of every message received and sent on a stream.
+type sizeRecordingServerStream struct {
+	grpc.ServerStream
+	ss     *simpleServer
+	method string
+}
+
+func (s sizeRecordingServerStream) RecvMsg(m interface{}) error {
+	if err := s.ServerStream.RecvMsg(m); err != nil {
+		return err
+	}
+	s.ss.recordRequestSize(s.method, m)
+	return nil
+}
+
+func (s sizeRecordingServerStream) SendMsg(m interface{}) error {
+	if err := s.ServerStream.SendMsg(m); err != nil {
+		return err
+	}
+	s.ss.recordResponseSize(s.method, m)
+	return nil
+}
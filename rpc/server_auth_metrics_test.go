@@ -0,0 +1,482 @@
+package rpc
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/rsa"
+	"errors"
+	"math/big"
+	"testing"
+	"time"
+
+	"github.com/edaniels/golog"
+	"github.com/golang-jwt/jwt/v4"
+	"go.viam.com/test"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/proto"
+
+	"go.viam.com/utils/perf/statz"
+	"go.viam.com/utils/perf/statz/statztest"
+	rpcpb "go.viam.com/utils/proto/rpc/v1"
+)
+
+// newAuthTestServer returns a server with the given options that is stopped when the test ends.
+func newAuthTestServer(t *testing.T, opts ...ServerOption) *simpleServer {
+	t.Helper()
+	return newAuthTestServerWithLogger(t, golog.NewTestLogger(t), opts...)
+}
+
+// newAuthTestServerWithLogger is newAuthTestServer logging to the given logger.
+func newAuthTestServerWithLogger(t *testing.T, logger golog.Logger, opts ...ServerOption) *simpleServer {
+	t.Helper()
+	rpcServer, err := NewServer(logger, append([]ServerOption{WithDisableMulticastDNS()}, opts...)...)
+	test.That(t, err, test.ShouldBeNil)
+	t.Cleanup(func() {
+		test.That(t, rpcServer.Stop(), test.ShouldBeNil)
+	})
+	return rpcServer.(*simpleServer)
+}
+
+// tokenContext returns an incoming request context authorized with the access token.
+func tokenContext(token string) context.Context {
+	return metadata.NewIncomingContext(context.Background(), metadata.Pairs("authorization", "Bearer "+token))
+}
+
+func newAuthMetricsTestServer(t *testing.T, opts ...ServerOption) *simpleServer {
+	t.Helper()
+	return newAuthTestServer(t, append([]ServerOption{
+		WithAuthHandler("fake", MakeSimpleAuthHandler([]string{"foo"}, "something")),
+		WithAuthMetrics(),
+	}, opts...)...)
+}
+
+// registerTestMethods registers the given methods of some.Service with ss so that they are
+// labeled by name in auth metrics.
+func registerTestMethods(t *testing.T, ss *simpleServer, methods ...string) {
+	t.Helper()
+	desc := &grpc.ServiceDesc{ServiceName: "some.Service", HandlerType: (*interface{})(nil)}
+	for _, method := range methods {
+		desc.Methods = append(desc.Methods, grpc.MethodDesc{MethodName: method})
+	}
+	test.That(t, ss.RegisterServiceServer(context.Background(), desc, struct{}{}), test.ShouldBeNil)
+}
+
+func TestAuthMetricsCredentialsTypes(t *testing.T) {
+	ss := newAuthMetricsTestServer(t)
+	recorder := statztest.NewCounterRecorder("rpc/auth/authenticate_credentials_types")
+	ctx := metadata.NewIncomingContext(context.Background(), metadata.MD{})
+
+	before := recorder.Value("credentials_type", "fake")
+	beforeUnknown := recorder.Value("credentials_type", credentialsTypeUnknownLabel)
+
+	_, err := ss.Authenticate(ctx, &rpcpb.AuthenticateRequest{Entity: "foo", Credentials: &rpcpb.Credentials{
+		Type:    "fake",
+		Payload: "something",
+	}})
+	test.That(t, err, test.ShouldBeNil)
+	_, err = ss.Authenticate(ctx, &rpcpb.AuthenticateRequest{Entity: "foo", Credentials: &rpcpb.Credentials{
+		Type:    "fake",
+		Payload: "wrong",
+	}})
+	test.That(t, err, test.ShouldNotBeNil)
+	_, err = ss.Authenticate(ctx, &rpcpb.AuthenticateRequest{Entity: "foo", Credentials: &rpcpb.Credentials{
+		Type:    "notfake",
+		Payload: "something",
+	}})
+	test.That(t, err, test.ShouldNotBeNil)
+
+	test.That(t, recorder.Value("credentials_type", "fake"), test.ShouldEqual, before+2)
+	test.That(t, recorder.Value("credentials_type", credentialsTypeUnknownLabel), test.ShouldEqual, beforeUnknown+1)
+	test.That(t, recorder.Value("credentials_type", "notfake"), test.ShouldEqual, 0)
+}
+
+func TestAuthMetricsAlreadyAuthenticated(t *testing.T) {
+	ss := newAuthMetricsTestServer(t)
+	recorder := statztest.NewCounterRecorder("rpc/auth/authenticate_already_authenticated")
+	authedCtx := metadata.NewIncomingContext(context.Background(), metadata.Pairs("authorization", "Bearer token"))
+
+	before := recorder.Value("credentials_type", "fake")
+	beforeUnknown := recorder.Value("credentials_type", credentialsTypeUnknownLabel)
+
+	for _, forType := range []string{"fake", "fake", "notfake"} {
+		_, err := ss.Authenticate(authedCtx, &rpcpb.AuthenticateRequest{Entity: "foo", Credentials: &rpcpb.Credentials{
+			Type:    forType,
+			Payload: "something",
+		}})
+		test.That(t, status.Code(err), test.ShouldEqual, codes.InvalidArgument)
+	}
+	_, err := ss.Authenticate(metadata.NewIncomingContext(context.Background(), metadata.MD{}), &rpcpb.AuthenticateRequest{
+		Entity:      "foo",
+		Credentials: &rpcpb.Credentials{Type: "fake", Payload: "something"},
+	})
+	test.That(t, err, test.ShouldBeNil)
+
+	test.That(t, recorder.Value("credentials_type", "fake"), test.ShouldEqual, before+2)
+	test.That(t, recorder.Value("credentials_type", credentialsTypeUnknownLabel), test.ShouldEqual, beforeUnknown+1)
+}
+
+func TestAuthMetricsAuthenticatedEntities(t *testing.T) {
+	entities := []string{"authenticated_entities_a", "authenticated_entities_b"}
+	ss := newAuthMetricsTestServer(t, WithAuthHandler("entities", MakeSimpleAuthHandler(entities, "something")))
+	const method = "/some.Service/Method"
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return nil, nil
+	}
+
+	before := authenticatedEntities.Estimate()
+	for i := 0; i < 3; i++ {
+		for _, entity := range entities {
+			resp, err := ss.Authenticate(
+				metadata.NewIncomingContext(context.Background(), metadata.MD{}),
+				&rpcpb.AuthenticateRequest{Entity: entity, Credentials: &rpcpb.Credentials{Type: "entities", Payload: "something"}},
+			)
+			test.That(t, err, test.ShouldBeNil)
+			ctx := metadata.NewIncomingContext(context.Background(), metadata.Pairs("authorization", "Bearer "+resp.GetAccessToken()))
+			_, err = ss.authUnaryInterceptor(ctx, nil, &grpc.UnaryServerInfo{FullMethod: method}, handler)
+			test.That(t, err, test.ShouldBeNil)
+		}
+	}
+	test.That(t, authenticatedEntities.Estimate(), test.ShouldEqual, before+2)
+}
+
+func TestAuthMetricsTokenErrors(t *testing.T) {
+	ss := newAuthMetricsTestServer(t, WithAuthHandler("keyed", WithTokenVerificationKeyProvider(
+		MakeSimpleAuthHandler([]string{"foo"}, "something"),
+		func(token *jwt.Token) (interface{}, error) {
+			return nil, errors.New("unknown key id")
+		},
+	)))
+	recorder := statztest.NewCounterRecorder("rpc/auth/token_errors")
+	otherKey, err := rsa.GenerateKey(rand.Reader, 2048)
+	test.That(t, err, test.ShouldBeNil)
+
+	signToken := func(key *rsa.PrivateKey, credType CredentialsType, expiresAt time.Time) string {
+		token, err := jwt.NewWithClaims(jwt.SigningMethodRS256, JWTClaims{
+			RegisteredClaims: jwt.RegisteredClaims{
+				Audience:  jwt.ClaimStrings{"foo"},
+				ExpiresAt: jwt.NewNumericDate(expiresAt),
+			},
+			CredentialsType: credType,
+		}).SignedString(key)
+		test.That(t, err, test.ShouldBeNil)
+		return token
+	}
+	valid := time.Now().Add(time.Hour)
+
+	for _, tc := range []struct {
+		token    string
+		category string
+	}{
+		{"notatoken", tokenErrorMalformed},
+		{signToken(ss.authRSAPrivKey, "nope", valid), tokenErrorUnknownCredentialsType},
+		{signToken(ss.authRSAPrivKey, "keyed", valid), tokenErrorUnknownKey},
+		{signToken(otherKey, "fake", valid), tokenErrorBadSignature},
+		{signToken(ss.authRSAPrivKey, "fake", time.Now().Add(-time.Hour)), tokenErrorExpired},
+	} {
+		t.Run(tc.category, func(t *testing.T) {
+			before := recorder.Value("category", tc.category)
+			ctx := metadata.NewIncomingContext(context.Background(), metadata.Pairs("authorization", "Bearer "+tc.token))
+			_, err := ss.ensureAuthed(ctx)
+			test.That(t, status.Code(err), test.ShouldEqual, codes.Unauthenticated)
+			test.That(t, recorder.Value("category", tc.category), test.ShouldEqual, before+1)
+		})
+	}
+}
+
+func TestAuthMetricsAuthenticationLatency(t *testing.T) {
+	ss := newAuthMetricsTestServer(t)
+	recorder := statztest.NewDistributionRecorder("rpc/auth/authentication_latency")
+	info := &grpc.UnaryServerInfo{FullMethod: "/some.Service/Method"}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return nil, nil
+	}
+
+	before := recorder.Value("credentials_type", "fake").Count
+	beforeUnknown := recorder.Value("credentials_type", credentialsTypeUnknownLabel).Count
+
+	resp, err := ss.Authenticate(metadata.NewIncomingContext(context.Background(), metadata.MD{}), &rpcpb.AuthenticateRequest{
+		Entity:      "foo",
+		Credentials: &rpcpb.Credentials{Type: "fake", Payload: "something"},
+	})
+	test.That(t, err, test.ShouldBeNil)
+	ctx := metadata.NewIncomingContext(context.Background(), metadata.Pairs("authorization", "Bearer "+resp.GetAccessToken()))
+	_, err = ss.authUnaryInterceptor(ctx, nil, info, handler)
+	test.That(t, err, test.ShouldBeNil)
+	_, err = ss.authUnaryInterceptor(context.Background(), nil, info, handler)
+	test.That(t, status.Code(err), test.ShouldEqual, codes.Unauthenticated)
+
+	value := recorder.Value("credentials_type", "fake")
+	test.That(t, value.Count, test.ShouldEqual, before+1)
+	test.That(t, value.Sum, test.ShouldBeGreaterThan, 0)
+	test.That(t, recorder.Value("credentials_type", credentialsTypeUnknownLabel).Count, test.ShouldEqual, beforeUnknown+1)
+}
+
+func TestAuthMetricsInterceptorRejections(t *testing.T) {
+	ss := newAuthMetricsTestServer(t)
+	recorder := statztest.NewCounterRecorder("rpc/auth/interceptor_rejections")
+	const method = "/some.Service/Method"
+	registerTestMethods(t, ss, "Method")
+
+	before := recorder.Value("method", method, "reason", codes.Unauthenticated.String())
+
+	handlerCalled := false
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		handlerCalled = true
+		return nil, nil
+	}
+	_, err := ss.authUnaryInterceptor(context.Background(), nil, &grpc.UnaryServerInfo{FullMethod: method}, handler)
+	test.That(t, err, test.ShouldNotBeNil)
+	test.That(t, status.Code(err), test.ShouldEqual, codes.Unauthenticated)
+	test.That(t, handlerCalled, test.ShouldBeFalse)
+
+	err = ss.authStreamInterceptor(nil, &fakeServerStream{ctx: context.Background()}, &grpc.StreamServerInfo{FullMethod: method},
+		func(srv interface{}, stream grpc.ServerStream) error {
+			handlerCalled = true
+			return nil
+		})
+	test.That(t, err, test.ShouldNotBeNil)
+	test.That(t, handlerCalled, test.ShouldBeFalse)
+
+	test.That(t, recorder.Value("method", method, "reason", codes.Unauthenticated.String()), test.ShouldEqual, before+2)
+
+	// exempt methods are not rejected.
+	ss.AddExemptMethod(method)
+	_, err = ss.authUnaryInterceptor(context.Background(), nil, &grpc.UnaryServerInfo{FullMethod: method}, handler)
+	test.That(t, err, test.ShouldBeNil)
+	test.That(t, handlerCalled, test.ShouldBeTrue)
+	test.That(t, recorder.Value("method", method, "reason", codes.Unauthenticated.String()), test.ShouldEqual, before+2)
+}
+
+type fakeServerStream struct {
+	grpc.ServerStream
+	ctx context.Context
+}
+
+func (s *fakeServerStream) Context() context.Context {
+	return s.ctx
+}
+
+func TestAuthMetricsInterceptorRequests(t *testing.T) {
+	ss := newAuthMetricsTestServer(t)
+	recorder := statztest.NewCounterRecorder("rpc/auth/interceptor_requests")
+	const method = "/some.Service/Requests"
+	registerTestMethods(t, ss, "Requests", "AuthedRequests")
+	ss.AddExemptMethod(method)
+
+	beforeOK := recorder.Value("method", method, "code", codes.OK.String())
+	beforeNotFound := recorder.Value("method", method, "code", codes.NotFound.String())
+	beforeUnknown := recorder.Value("method", method, "code", codes.Unknown.String())
+
+	for _, handlerErr := range []error{
+		nil,
+		status.Error(codes.NotFound, "not found"),
+		errors.New("not a status"),
+		status.Error(codes.Code(100), "not canonical"),
+	} {
+		_, err := ss.authUnaryInterceptor(context.Background(), nil, &grpc.UnaryServerInfo{FullMethod: method},
+			func(ctx context.Context, req interface{}) (interface{}, error) {
+				return nil, handlerErr
+			})
+		test.That(t, err, test.ShouldEqual, handlerErr)
+	}
+
+	err := ss.authStreamInterceptor(nil, &fakeServerStream{ctx: context.Background()}, &grpc.StreamServerInfo{FullMethod: method},
+		func(srv interface{}, stream grpc.ServerStream) error {
+			return nil
+		})
+	test.That(t, err, test.ShouldBeNil)
+
+	test.That(t, recorder.Value("method", method, "code", codes.OK.String()), test.ShouldEqual, beforeOK+2)
+	test.That(t, recorder.Value("method", method, "code", codes.NotFound.String()), test.ShouldEqual, beforeNotFound+1)
+	test.That(t, recorder.Value("method", method, "code", codes.Unknown.String()), test.ShouldEqual, beforeUnknown+2)
+
+	// rejected requests are counted with the code they were rejected with.
+	const authedMethod = "/some.Service/AuthedRequests"
+	_, err = ss.authUnaryInterceptor(context.Background(), nil, &grpc.UnaryServerInfo{FullMethod: authedMethod},
+		func(ctx context.Context, req interface{}) (interface{}, error) {
+			return nil, nil
+		})
+	test.That(t, err, test.ShouldNotBeNil)
+	test.That(t, recorder.Value("method", authedMethod, "code", codes.Unauthenticated.String()), test.ShouldEqual, 1)
+
+	// methods that are not registered, such as those of an unknown service handler, are not labeled by name.
+	const unregisteredMethod = "/some.Service/Unregistered"
+	beforeUnregistered := recorder.Value("method", methodUnknownLabel, "code", codes.Unauthenticated.String())
+	_, err = ss.authUnaryInterceptor(context.Background(), nil, &grpc.UnaryServerInfo{FullMethod: unregisteredMethod},
+		func(ctx context.Context, req interface{}) (interface{}, error) {
+			return nil, nil
+		})
+	test.That(t, err, test.ShouldNotBeNil)
+	test.That(t, recorder.Value("method", methodUnknownLabel, "code", codes.Unauthenticated.String()), test.ShouldEqual, beforeUnregistered+1)
+	test.That(t, recorder.Value("method", unregisteredMethod, "code", codes.Unauthenticated.String()), test.ShouldEqual, 0)
+}
+
+func TestAuthMetricsTokenStoreLookups(t *testing.T) {
+	ss := newAuthMetricsTestServer(t, WithTokenStore(NewMemoryTokenStore(time.Hour)))
+	recorder := statztest.NewCounterRecorder("rpc/auth/token_store_lookups")
+	ctx := metadata.NewIncomingContext(context.Background(), metadata.MD{})
+
+	beforeHits := recorder.Value("credentials_type", "fake", "hit", "true")
+	beforeMisses := recorder.Value("credentials_type", "fake", "hit", "false")
+
+	for i := 0; i < 3; i++ {
+		_, err := ss.Authenticate(ctx, &rpcpb.AuthenticateRequest{Entity: "foo", Credentials: &rpcpb.Credentials{
+			Type:    "fake",
+			Payload: "something",
+		}})
+		test.That(t, err, test.ShouldBeNil)
+	}
+
+	test.That(t, recorder.Value("credentials_type", "fake", "hit", "false"), test.ShouldEqual, beforeMisses+1)
+	test.That(t, recorder.Value("credentials_type", "fake", "hit", "true"), test.ShouldEqual, beforeHits+2)
+}
+
+func TestAuthMetricsTokenSigning(t *testing.T) {
+	ss := newAuthMetricsTestServer(t)
+	latencyRecorder := statztest.NewDistributionRecorder("rpc/auth/token_signing_latency")
+	failureRecorder := statztest.NewCounterRecorder("rpc/auth/token_signing_failures")
+	ctx := metadata.NewIncomingContext(context.Background(), metadata.MD{})
+
+	before := latencyRecorder.Value("credentials_type", "fake").Count
+	beforeFailures := failureRecorder.Value("credentials_type", "fake")
+
+	_, err := ss.Authenticate(ctx, &rpcpb.AuthenticateRequest{Entity: "foo", Credentials: &rpcpb.Credentials{
+		Type:    "fake",
+		Payload: "something",
+	}})
+	test.That(t, err, test.ShouldBeNil)
+	test.That(t, latencyRecorder.Value("credentials_type", "fake").Count, test.ShouldEqual, before+1)
+	test.That(t, failureRecorder.Value("credentials_type", "fake"), test.ShouldEqual, beforeFailures)
+
+	// a modulus too small to hold a signature fails signing.
+	ss.authRSAPrivKey = &rsa.PrivateKey{PublicKey: rsa.PublicKey{N: big.NewInt(1 << 62), E: 65537}, D: big.NewInt(3)}
+	_, err = ss.Authenticate(ctx, &rpcpb.AuthenticateRequest{Entity: "foo", Credentials: &rpcpb.Credentials{
+		Type:    "fake",
+		Payload: "something",
+	}})
+	test.That(t, status.Code(err), test.ShouldEqual, codes.PermissionDenied)
+	test.That(t, latencyRecorder.Value("credentials_type", "fake").Count, test.ShouldEqual, before+2)
+	test.That(t, failureRecorder.Value("credentials_type", "fake"), test.ShouldEqual, beforeFailures+1)
+}
+
+func TestMethodLatencyMetrics(t *testing.T) {
+	ss := newAuthMetricsTestServer(t, WithMethodLatencyMetrics())
+	recorder := statztest.NewDistributionRecorder("rpc/auth/handler_latency")
+	const method = "/some.Service/Latency"
+	registerTestMethods(t, ss, "Latency", "AuthedLatency")
+	ss.AddExemptMethod(method)
+
+	for _, handlerErr := range []error{nil, status.Error(codes.NotFound, "not found")} {
+		_, err := ss.authUnaryInterceptor(context.Background(), nil, &grpc.UnaryServerInfo{FullMethod: method},
+			func(ctx context.Context, req interface{}) (interface{}, error) {
+				time.Sleep(2 * time.Millisecond)
+				return nil, handlerErr
+			})
+		test.That(t, err, test.ShouldEqual, handlerErr)
+	}
+
+	okValue := recorder.Value("method", method, "code", codes.OK.String())
+	test.That(t, okValue.Count, test.ShouldEqual, 1)
+	test.That(t, okValue.Sum, test.ShouldBeGreaterThanOrEqualTo, 2)
+	test.That(t, recorder.Value("method", method, "code", codes.NotFound.String()).Count, test.ShouldEqual, 1)
+
+	// rejected requests never reach the handler.
+	const authedMethod = "/some.Service/AuthedLatency"
+	_, err := ss.authUnaryInterceptor(context.Background(), nil, &grpc.UnaryServerInfo{FullMethod: authedMethod},
+		func(ctx context.Context, req interface{}) (interface{}, error) {
+			return nil, nil
+		})
+	test.That(t, err, test.ShouldNotBeNil)
+	test.That(t, recorder.Value("method", authedMethod, "code", codes.Unauthenticated.String()).Count, test.ShouldEqual, 0)
+}
+
+// fakeMsgServerStream is a fakeServerStream whose messages go nowhere.
+type fakeMsgServerStream struct {
+	fakeServerStream
+}
+
+func (s *fakeMsgServerStream) RecvMsg(m interface{}) error {
+	return nil
+}
+
+func (s *fakeMsgServerStream) SendMsg(m interface{}) error {
+	return nil
+}
+
+func TestMessageSizeMetrics(t *testing.T) {
+	ss := newAuthMetricsTestServer(t, WithMessageSizeMetrics())
+	requestRecorder := statztest.NewDistributionRecorder("rpc/auth/request_size")
+	responseRecorder := statztest.NewDistributionRecorder("rpc/auth/response_size")
+	const method = "/some.Service/Sizes"
+	registerTestMethods(t, ss, "Sizes", "AuthedSizes")
+	ss.AddExemptMethod(method)
+
+	req := &rpcpb.AuthenticateRequest{Entity: "foo"}
+	resp := &rpcpb.AuthenticateResponse{AccessToken: "some.access.token"}
+	_, err := ss.authUnaryInterceptor(context.Background(), req, &grpc.UnaryServerInfo{FullMethod: method},
+		func(ctx context.Context, req interface{}) (interface{}, error) {
+			return resp, nil
+		})
+	test.That(t, err, test.ShouldBeNil)
+
+	err = ss.authStreamInterceptor(nil, &fakeMsgServerStream{fakeServerStream{ctx: context.Background()}},
+		&grpc.StreamServerInfo{FullMethod: method},
+		func(srv interface{}, stream grpc.ServerStream) error {
+			if err := stream.RecvMsg(req); err != nil {
+				return err
+			}
+			return stream.SendMsg(resp)
+		})
+	test.That(t, err, test.ShouldBeNil)
+
+	requestValue := requestRecorder.Value("method", method)
+	test.That(t, requestValue.Count, test.ShouldEqual, 2)
+	test.That(t, requestValue.Sum, test.ShouldEqual, float64(2*proto.Size(req)))
+	responseValue := responseRecorder.Value("method", method)
+	test.That(t, responseValue.Count, test.ShouldEqual, 2)
+	test.That(t, responseValue.Sum, test.ShouldEqual, float64(2*proto.Size(resp)))
+
+	// responses of failed calls and rejected requests are not recorded.
+	_, err = ss.authUnaryInterceptor(context.Background(), req, &grpc.UnaryServerInfo{FullMethod: method},
+		func(ctx context.Context, req interface{}) (interface{}, error) {
+			return resp, status.Error(codes.NotFound, "not found")
+		})
+	test.That(t, err, test.ShouldNotBeNil)
+	test.That(t, requestRecorder.Value("method", method).Count, test.ShouldEqual, 3)
+	test.That(t, responseRecorder.Value("method", method).Count, test.ShouldEqual, 2)
+
+	const authedMethod = "/some.Service/AuthedSizes"
+	_, err = ss.authUnaryInterceptor(context.Background(), req, &grpc.UnaryServerInfo{FullMethod: authedMethod},
+		func(ctx context.Context, req interface{}) (interface{}, error) {
+			return resp, nil
+		})
+	test.That(t, err, test.ShouldNotBeNil)
+	test.That(t, requestRecorder.Value("method", authedMethod).Count, test.ShouldEqual, 0)
+}
+
+func TestAuthInterceptorsMethodContext(t *testing.T) {
+	ss := newAuthMetricsTestServer(t)
+	const method = "/some.Service/Tagged"
+	ss.AddExemptMethod(method)
+
+	var unaryMethod string
+	_, err := ss.authUnaryInterceptor(context.Background(), nil, &grpc.UnaryServerInfo{FullMethod: method},
+		func(ctx context.Context, req interface{}) (interface{}, error) {
+			unaryMethod = statz.MethodLabel(ctx)
+			return nil, nil
+		})
+	test.That(t, err, test.ShouldBeNil)
+	test.That(t, unaryMethod, test.ShouldEqual, method)
+
+	var streamMethod string
+	err = ss.authStreamInterceptor(nil, &fakeServerStream{ctx: context.Background()}, &grpc.StreamServerInfo{FullMethod: method},
+		func(srv interface{}, stream grpc.ServerStream) error {
+			streamMethod = statz.MethodLabel(stream.Context())
+			return nil
+		})
+	test.That(t, err, test.ShouldBeNil)
+	test.That(t, streamMethod, test.ShouldEqual, method)
+}
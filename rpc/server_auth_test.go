@@ -4,6 +4,8 @@ import (
 	"context"
 	"crypto/rand"
 	"crypto/rsa"
+	"crypto/tls"
+	"crypto/x509"
 	"encoding/json"
 	"fmt"
 	"io"
@@ -20,10 +22,13 @@ import (
 	"go.viam.com/test"
 	"google.golang.org/grpc"
 	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials"
 	"google.golang.org/grpc/credentials/insecure"
 	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/peer"
 	"google.golang.org/grpc/status"
 
+	"go.viam.com/utils/perf/statz/statztest"
 	pb "go.viam.com/utils/proto/rpc/examples/echo/v1"
 	rpcpb "go.viam.com/utils/proto/rpc/v1"
 	echoserver "go.viam.com/utils/rpc/examples/echo/server"
@@ -301,6 +306,367 @@ func TestServerAuth(t *testing.T) {
 	test.That(t, err, test.ShouldBeNil)
 }
 
+func TestServerHasAuthHandler(t *testing.T) {
+	logger := golog.NewTestLogger(t)
+	rpcServer, err := NewServer(
+		logger,
+		WithAuthHandler("fake", MakeSimpleAuthHandler([]string{"foo"}, "bar")),
+		WithDisableMulticastDNS(),
+	)
+	test.That(t, err, test.ShouldBeNil)
+	defer func() {
+		test.That(t, rpcServer.Stop(), test.ShouldBeNil)
+	}()
+
+	checker, ok := rpcServer.(AuthHandlerChecker)
+	test.That(t, ok, test.ShouldBeTrue)
+	test.That(t, checker.HasAuthHandler("fake"), test.ShouldBeTrue)
+	test.That(t, checker.HasAuthHandler("fkae"), test.ShouldBeFalse)
+
+	test.That(t, CredentialsType("fake").ValidateFor(checker), test.ShouldBeNil)
+	err = CredentialsType("fkae").ValidateFor(checker)
+	test.That(t, err, test.ShouldNotBeNil)
+	test.That(t, err.Error(), test.ShouldContainSubstring, `no auth handler for "fkae"`)
+	test.That(t, CredentialsType("").ValidateFor(checker), test.ShouldNotBeNil)
+}
+
+func TestServerAuthenticateNormalizesCredentialsType(t *testing.T) {
+	ss := newAuthTestServer(t,
+		WithAuthHandler("fake", MakeSimpleAuthHandler([]string{"foo"}, "bar")),
+		WithAuthHandler(CredentialsTypeAPIKey, MakeSimpleAuthHandler([]string{"foo"}, "key")),
+	)
+
+	authenticate := func(credType, payload string) error {
+		_, err := ss.Authenticate(metadata.NewIncomingContext(context.Background(), metadata.MD{}), &rpcpb.AuthenticateRequest{
+			Entity:      "foo",
+			Credentials: &rpcpb.Credentials{Type: credType, Payload: payload},
+		})
+		return err
+	}
+
+	test.That(t, authenticate("fake", "bar"), test.ShouldBeNil)
+	test.That(t, authenticate(" FAKE ", "bar"), test.ShouldBeNil)
+	test.That(t, authenticate("API-Key", "key"), test.ShouldBeNil)
+
+	err := authenticate("fkae", "bar")
+	test.That(t, status.Code(err), test.ShouldEqual, codes.InvalidArgument)
+	test.That(t, status.Convert(err).Message(), test.ShouldEqual,
+		`no auth handler for "fkae"; valid credential types are: api-key, fake`)
+}
+
+func TestServerFallbackAuthHandler(t *testing.T) {
+	logger := golog.NewTestLogger(t)
+	var fallbackTypes []CredentialsType
+	ss := newAuthTestServer(t,
+		WithAuthHandler("fake", MakeSimpleAuthHandler([]string{"foo"}, "bar")),
+		WithFallbackAuthHandler(func(forType CredentialsType) (AuthHandler, error) {
+			fallbackTypes = append(fallbackTypes, forType)
+			if !strings.HasPrefix(string(forType), "generic-") {
+				return nil, errors.New("not a generic type")
+			}
+			return MakeSimpleAuthHandler([]string{"foo"}, "generic"), nil
+		}),
+	)
+
+	authenticate := func(credType, payload string) (string, error) {
+		authResp, err := ss.Authenticate(metadata.NewIncomingContext(context.Background(), metadata.MD{}), &rpcpb.AuthenticateRequest{
+			Entity:      "foo",
+			Credentials: &rpcpb.Credentials{Type: credType, Payload: payload},
+		})
+		return authResp.GetAccessToken(), err
+	}
+
+	// registered handlers take precedence.
+	_, err := authenticate("fake", "bar")
+	test.That(t, err, test.ShouldBeNil)
+	test.That(t, fallbackTypes, test.ShouldBeEmpty)
+
+	token, err := authenticate("generic-oauth", "generic")
+	test.That(t, err, test.ShouldBeNil)
+	test.That(t, fallbackTypes, test.ShouldResemble, []CredentialsType{"generic-oauth"})
+
+	// tokens of fallback types are verified by the fallback too.
+	entity, err := ss.ensureAuthed(metadata.NewIncomingContext(context.Background(), metadata.Pairs("authorization", "Bearer "+token)))
+	test.That(t, err, test.ShouldBeNil)
+	test.That(t, entity, test.ShouldEqual, "foo")
+	test.That(t, fallbackTypes, test.ShouldResemble, []CredentialsType{"generic-oauth", "generic-oauth"})
+
+	_, err = authenticate("other", "generic")
+	test.That(t, status.Code(err), test.ShouldEqual, codes.InvalidArgument)
+	test.That(t, err.Error(), test.ShouldContainSubstring, "not a generic type")
+
+	_, err = NewServer(logger, WithFallbackAuthHandler(nil))
+	test.That(t, err, test.ShouldNotBeNil)
+}
+
+func TestUnknownExemptMethods(t *testing.T) {
+	ss := newAuthTestServer(t, WithAuthHandler("fake", MakeSimpleAuthHandler([]string{"foo"}, "bar")))
+
+	test.That(t, unknownExemptMethods(ss.exemptMethods, ss.grpcServer.GetServiceInfo()), test.ShouldBeEmpty)
+
+	ss.AddExemptMethod("/proto.rpc.v1.AuthService/Authenticat")
+	ss.AddExemptMethod("/proto.rpc.examples.echo.v1.EchoService/Echo")
+	test.That(t, unknownExemptMethods(ss.exemptMethods, ss.grpcServer.GetServiceInfo()), test.ShouldResemble, []string{
+		"/proto.rpc.examples.echo.v1.EchoService/Echo",
+		"/proto.rpc.v1.AuthService/Authenticat",
+	})
+
+	test.That(t, ss.RegisterServiceServer(
+		context.Background(),
+		&pb.EchoService_ServiceDesc,
+		&echoserver.Server{},
+	), test.ShouldBeNil)
+	test.That(t, unknownExemptMethods(ss.exemptMethods, ss.grpcServer.GetServiceInfo()), test.ShouldResemble, []string{
+		"/proto.rpc.v1.AuthService/Authenticat",
+	})
+}
+
+func TestServerExemptMethodsAtRuntime(t *testing.T) {
+	ss := newAuthTestServer(t,
+		WithAuthHandler("fake", MakeSimpleAuthHandler([]string{"foo"}, "bar")),
+	)
+	var exemptServer ExemptMethodsServer = ss
+
+	const method = "/proto.rpc.examples.echo.v1.EchoService/Echo"
+	test.That(t, exemptServer.ExemptMethods(), test.ShouldResemble, []string{"/proto.rpc.v1.AuthService/Authenticate"})
+
+	info := &grpc.UnaryServerInfo{FullMethod: method}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return "ok", nil
+	}
+	_, err := ss.authUnaryInterceptor(context.Background(), nil, info, handler)
+	test.That(t, status.Code(err), test.ShouldEqual, codes.Unauthenticated)
+
+	exemptServer.AddExemptMethod(method)
+	test.That(t, exemptServer.ExemptMethods(), test.ShouldResemble, []string{
+		method,
+		"/proto.rpc.v1.AuthService/Authenticate",
+	})
+	resp, err := ss.authUnaryInterceptor(context.Background(), nil, info, handler)
+	test.That(t, err, test.ShouldBeNil)
+	test.That(t, resp, test.ShouldEqual, "ok")
+
+	exemptServer.RemoveExemptMethod(method)
+	test.That(t, exemptServer.ExemptMethods(), test.ShouldResemble, []string{"/proto.rpc.v1.AuthService/Authenticate"})
+	_, err = ss.authUnaryInterceptor(context.Background(), nil, info, handler)
+	test.That(t, status.Code(err), test.ShouldEqual, codes.Unauthenticated)
+}
+
+func TestServerAuthServiceExemptMethods(t *testing.T) {
+	logger := golog.NewTestLogger(t)
+	authToHandler := func(ctx context.Context, entity string) (map[string]string, error) {
+		return map[string]string{}, nil
+	}
+	newServer := func(opts ...ServerOption) *simpleServer {
+		return newAuthTestServer(t, append([]ServerOption{
+			WithAuthHandler("fake", MakeSimpleAuthHandler([]string{"foo"}, "bar")),
+			WithAuthenticateToHandler("fakeTo", authToHandler),
+		}, opts...)...)
+	}
+
+	test.That(t, newServer().ExemptMethods(), test.ShouldResemble, []string{"/proto.rpc.v1.AuthService/Authenticate"})
+	ss := newServer(WithAuthenticateToExempt())
+	test.That(t, ss.ExemptMethods(), test.ShouldResemble, []string{
+		"/proto.rpc.v1.AuthService/Authenticate",
+		"/proto.rpc.v1.ExternalAuthService/AuthenticateTo",
+	})
+	test.That(t, unknownExemptMethods(ss.exemptMethodSet(), ss.grpcServer.GetServiceInfo()), test.ShouldBeEmpty)
+
+	_, err := NewServer(logger, WithAuthenticateToExempt(), WithDisableMulticastDNS())
+	test.That(t, err, test.ShouldNotBeNil)
+	test.That(t, err.Error(), test.ShouldContainSubstring, "without an AuthenticateToHandler")
+}
+
+func TestServerAuthMetadataInHandlers(t *testing.T) {
+	ss := newAuthTestServer(t,
+		WithAuthHandler("fake", MakeFuncAuthHandler(func(ctx context.Context, entity, payload string) (map[string]string, error) {
+			return map[string]string{"tenant": "a"}, nil
+		}, func(ctx context.Context, entity string) (interface{}, error) {
+			return entity, nil
+		})),
+	)
+
+	authResp, err := ss.Authenticate(metadata.NewIncomingContext(context.Background(), metadata.MD{}), &rpcpb.AuthenticateRequest{
+		Entity:      "foo",
+		Credentials: &rpcpb.Credentials{Type: "fake", Payload: "bar"},
+	})
+	test.That(t, err, test.ShouldBeNil)
+	ctx := metadata.NewIncomingContext(context.Background(), metadata.Pairs("authorization", "Bearer "+authResp.GetAccessToken()))
+
+	checkHandlerContext := func(ctx context.Context) {
+		test.That(t, MustContextAuthEntity(ctx), test.ShouldEqual, "foo")
+		test.That(t, ContextAuthMetadata(ctx), test.ShouldResemble, map[string]string{"tenant": "a"})
+		test.That(t, ContextAuthClaims(ctx), test.ShouldNotBeNil)
+		_, ok := ContextAuthTokenHeader(ctx)
+		test.That(t, ok, test.ShouldBeTrue)
+		credType, ok := ContextCredentialsType(ctx)
+		test.That(t, ok, test.ShouldBeTrue)
+		test.That(t, credType, test.ShouldEqual, CredentialsType("fake"))
+	}
+
+	_, ok := ContextCredentialsType(ctx)
+	test.That(t, ok, test.ShouldBeFalse)
+
+	var handlerCalled bool
+	_, err = ss.authUnaryInterceptor(ctx, nil, &grpc.UnaryServerInfo{FullMethod: "/some.Service/Method"},
+		func(ctx context.Context, req interface{}) (interface{}, error) {
+			handlerCalled = true
+			checkHandlerContext(ctx)
+			return nil, nil
+		})
+	test.That(t, err, test.ShouldBeNil)
+	test.That(t, handlerCalled, test.ShouldBeTrue)
+
+	handlerCalled = false
+	err = ss.authStreamInterceptor(nil, &fakeServerStream{ctx: ctx}, &grpc.StreamServerInfo{FullMethod: "/some.Service/Method"},
+		func(srv interface{}, stream grpc.ServerStream) error {
+			handlerCalled = true
+			checkHandlerContext(stream.Context())
+			return nil
+		})
+	test.That(t, err, test.ShouldBeNil)
+	test.That(t, handlerCalled, test.ShouldBeTrue)
+}
+
+func TestServerAuthenticateResponseHook(t *testing.T) {
+	logger := golog.NewTestLogger(t)
+
+	var hookEntities []string
+	ss := newAuthTestServer(t,
+		WithAuthHandler("fake", MakeSimpleAuthHandler([]string{"foo", "fail"}, "bar")),
+		WithAuthenticateToHandler("fake", func(ctx context.Context, entity string) (map[string]string, error) {
+			return nil, nil
+		}),
+		WithAuthenticateResponseHook(func(ctx context.Context, entity, accessToken string) (string, error) {
+			hookEntities = append(hookEntities, entity)
+			if entity == "fail" {
+				return "", status.Error(codes.Unavailable, "try again")
+			}
+			return "wrapped:" + accessToken, nil
+		}),
+	)
+
+	authenticate := func(entity string) (*rpcpb.AuthenticateResponse, error) {
+		return ss.Authenticate(metadata.NewIncomingContext(context.Background(), metadata.MD{}), &rpcpb.AuthenticateRequest{
+			Entity:      entity,
+			Credentials: &rpcpb.Credentials{Type: "fake", Payload: "bar"},
+		})
+	}
+
+	authResp, err := authenticate("foo")
+	test.That(t, err, test.ShouldBeNil)
+	wrappedToken := authResp.GetAccessToken()
+	test.That(t, wrappedToken, test.ShouldStartWith, "wrapped:")
+	ctx := metadata.NewIncomingContext(context.Background(),
+		metadata.Pairs("authorization", "Bearer "+strings.TrimPrefix(wrappedToken, "wrapped:")))
+	entity, err := ss.ensureAuthed(ctx)
+	test.That(t, err, test.ShouldBeNil)
+	test.That(t, entity, test.ShouldEqual, "foo")
+
+	_, err = authenticate("fail")
+	test.That(t, status.Code(err), test.ShouldEqual, codes.Unavailable)
+
+	authToResp, err := ss.AuthenticateTo(context.Background(), &rpcpb.AuthenticateToRequest{Entity: "bar"})
+	test.That(t, err, test.ShouldBeNil)
+	test.That(t, authToResp.GetAccessToken(), test.ShouldStartWith, "wrapped:")
+	test.That(t, hookEntities, test.ShouldResemble, []string{"foo", "fail", "bar"})
+
+	_, err = NewServer(logger, WithAuthenticateResponseHook(nil))
+	test.That(t, err, test.ShouldNotBeNil)
+}
+
+func TestServerAuthMetadataLimits(t *testing.T) {
+	logger := golog.NewTestLogger(t)
+
+	authMDs := map[string]map[string]string{
+		"small":       {"a": "1", "b": "2"},
+		"too_many":    {"a": "1", "b": "2", "c": "3"},
+		"too_large":   {"a": strings.Repeat("x", 20)},
+		"exact_bytes": {"a": strings.Repeat("x", 19)},
+	}
+	ss := newAuthTestServer(t,
+		WithAuthHandler("fake", MakeFuncAuthHandler(func(ctx context.Context, entity, payload string) (map[string]string, error) {
+			return authMDs[payload], nil
+		}, func(ctx context.Context, entity string) (interface{}, error) {
+			return entity, nil
+		})),
+		WithAuthMetadataLimits(2, 20),
+	)
+
+	authenticate := func(payload string) error {
+		_, err := ss.Authenticate(metadata.NewIncomingContext(context.Background(), metadata.MD{}), &rpcpb.AuthenticateRequest{
+			Entity:      "foo",
+			Credentials: &rpcpb.Credentials{Type: "fake", Payload: payload},
+		})
+		return err
+	}
+
+	test.That(t, authenticate("small"), test.ShouldBeNil)
+	test.That(t, authenticate("exact_bytes"), test.ShouldBeNil)
+	for _, payload := range []string{"too_many", "too_large"} {
+		err := authenticate(payload)
+		test.That(t, status.Code(err), test.ShouldEqual, codes.Internal)
+		test.That(t, err.Error(), test.ShouldContainSubstring, "auth metadata too large")
+	}
+
+	defaultServer, err := NewServer(logger, WithAuthHandler("fake", MakeSimpleAuthHandler([]string{"foo"}, "bar")), WithDisableMulticastDNS())
+	test.That(t, err, test.ShouldBeNil)
+	defer func() {
+		test.That(t, defaultServer.Stop(), test.ShouldBeNil)
+	}()
+	test.That(t, defaultServer.(*simpleServer).authMDMaxEntries, test.ShouldEqual, defaultAuthMDMaxEntries)
+	test.That(t, defaultServer.(*simpleServer).authMDMaxBytes, test.ShouldEqual, defaultAuthMDMaxBytes)
+
+	_, err = NewServer(logger, WithAuthMetadataLimits(0, 10))
+	test.That(t, err, test.ShouldNotBeNil)
+}
+
+func TestServerAuthReservedAuthMetadata(t *testing.T) {
+	logger := golog.NewTestLogger(t)
+	authMD := map[string]string{"exp": "1", "aud": "bar", "role": "admin"}
+	newServer := func(opts ...ServerOption) *simpleServer {
+		return newAuthTestServer(t, append([]ServerOption{
+			WithAuthHandler("fake", MakeFuncAuthHandler(func(ctx context.Context, entity, payload string) (map[string]string, error) {
+				return authMD, nil
+			}, func(ctx context.Context, entity string) (interface{}, error) {
+				return entity, nil
+			})),
+		}, opts...)...)
+	}
+	authenticate := func(ss *simpleServer) (*rpcpb.AuthenticateResponse, error) {
+		return ss.Authenticate(metadata.NewIncomingContext(context.Background(), metadata.MD{}), &rpcpb.AuthenticateRequest{
+			Entity:      "foo",
+			Credentials: &rpcpb.Credentials{Type: "fake", Payload: "something"},
+		})
+	}
+
+	test.That(t, reservedAuthMetadataKeys(authMD, map[string][]string{"iss": {"a"}, "exp": {"1"}}), test.ShouldResemble,
+		[]string{"aud", "exp", "iss"})
+
+	// nesting keeps the reserved keys from overriding the claims of the token.
+	for _, policy := range []ReservedAuthMetadataPolicy{"", ReservedAuthMetadataAllow, ReservedAuthMetadataWarn} {
+		var opts []ServerOption
+		if policy != "" {
+			opts = append(opts, WithReservedAuthMetadataPolicy(policy))
+		}
+		ss := newServer(opts...)
+		resp, err := authenticate(ss)
+		test.That(t, err, test.ShouldBeNil)
+		ctx := metadata.NewIncomingContext(context.Background(), metadata.Pairs("authorization", "Bearer "+resp.GetAccessToken()))
+		authCtx, _, err := ss.ensureAuthedForMethod(ctx, "/some.Service/Method")
+		test.That(t, err, test.ShouldBeNil)
+		test.That(t, MustContextAuthEntity(authCtx), test.ShouldEqual, "foo")
+		test.That(t, ContextAuthMetadata(authCtx), test.ShouldResemble, authMD)
+	}
+
+	_, err := authenticate(newServer(WithReservedAuthMetadataPolicy(ReservedAuthMetadataReject)))
+	test.That(t, status.Code(err), test.ShouldEqual, codes.Internal)
+	test.That(t, err.Error(), test.ShouldContainSubstring, "auth metadata uses reserved claim names")
+
+	_, err = NewServer(logger, WithReservedAuthMetadataPolicy("ignore"), WithDisableMulticastDNS())
+	test.That(t, err, test.ShouldNotBeNil)
+}
+
 func TestServerAuthJWTExpiration(t *testing.T) {
 	testutils.SkipUnlessInternet(t)
 	logger := golog.NewTestLogger(t)
@@ -405,68 +771,1232 @@ func TestServerAuthJWTAudience(t *testing.T) {
 	)
 	test.That(t, err, test.ShouldBeNil)
 
-	httpListener, err := net.Listen("tcp", "localhost:0")
+	httpListener, err := net.Listen("tcp", "localhost:0")
+	test.That(t, err, test.ShouldBeNil)
+
+	errChan := make(chan error)
+	go func() {
+		errChan <- rpcServer.Serve(httpListener)
+	}()
+
+	conn, err := grpc.DialContext(
+		context.Background(),
+		httpListener.Addr().String(),
+		grpc.WithTransportCredentials(insecure.NewCredentials()),
+		grpc.WithBlock(),
+	)
+	test.That(t, err, test.ShouldBeNil)
+	defer func() {
+		test.That(t, conn.Close(), test.ShouldBeNil)
+	}()
+	client := pb.NewEchoServiceClient(conn)
+
+	t.Cleanup(func() {
+		test.That(t, rpcServer.Stop(), test.ShouldBeNil)
+		err = <-errChan
+		test.That(t, err, test.ShouldBeNil)
+	})
+
+	for _, tc := range []struct {
+		correctEntity bool
+		singleString  bool
+	}{
+		{false, false},
+		{true, false},
+		{false, true},
+		{true, true},
+	} {
+		correctEntity := tc.correctEntity
+		t.Run(fmt.Sprintf("correctEntity=%t,singleString=%t", correctEntity, tc.singleString), func(t *testing.T) {
+			var aud string
+			if correctEntity {
+				aud = expectedEntity
+			} else {
+				aud = "actually matters"
+			}
+			var token *jwt.Token
+			if tc.singleString {
+				// some external issuers emit aud as a bare string instead of an array.
+				token = jwt.NewWithClaims(jwt.SigningMethodRS256, jwt.MapClaims{
+					"aud":            aud,
+					"rpc_creds_type": "fake",
+				})
+			} else {
+				token = jwt.NewWithClaims(jwt.SigningMethodRS256, JWTClaims{
+					RegisteredClaims: jwt.RegisteredClaims{
+						Audience: jwt.ClaimStrings{aud},
+					},
+					CredentialsType: CredentialsType("fake"),
+				})
+			}
+
+			tokenString, err := token.SignedString(privKey)
+			test.That(t, err, test.ShouldBeNil)
+
+			md := make(metadata.MD)
+			bearer := fmt.Sprintf("Bearer %s", tokenString)
+			md.Set("authorization", bearer)
+			ctx := metadata.NewOutgoingContext(context.Background(), md)
+
+			echoResp, err := client.Echo(ctx, &pb.EchoRequest{Message: "hello"})
+			if correctEntity {
+				test.That(t, err, test.ShouldBeNil)
+				test.That(t, echoResp.GetMessage(), test.ShouldEqual, "hello")
+			} else {
+				test.That(t, err, test.ShouldNotBeNil)
+				gStatus, ok := status.FromError(err)
+				test.That(t, ok, test.ShouldBeTrue)
+				test.That(t, gStatus.Code(), test.ShouldEqual, codes.Unauthenticated)
+				test.That(t, gStatus.Message(), test.ShouldContainSubstring, "cannot authenticate")
+			}
+		})
+	}
+}
+
+func TestJWTClaimsEntityAudienceForms(t *testing.T) {
+	for _, tc := range []struct {
+		name string
+		json string
+	}{
+		{"string", `{"aud":"someent","rpc_creds_type":"fake"}`},
+		{"array", `{"aud":["someent","other"],"rpc_creds_type":"fake"}`},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			var claims JWTClaims
+			test.That(t, json.Unmarshal([]byte(tc.json), &claims), test.ShouldBeNil)
+			entity, err := claims.Entity()
+			test.That(t, err, test.ShouldBeNil)
+			test.That(t, entity, test.ShouldEqual, "someent")
+			test.That(t, claims.CredentialsType, test.ShouldEqual, CredentialsType("fake"))
+		})
+	}
+
+	var claims JWTClaims
+	test.That(t, json.Unmarshal([]byte(`{"rpc_creds_type":"fake"}`), &claims), test.ShouldBeNil)
+	_, err := claims.Entity()
+	test.That(t, err, test.ShouldNotBeNil)
+}
+
+func TestServerAuthFailureTrailers(t *testing.T) {
+	testutils.SkipUnlessInternet(t)
+	logger := golog.NewTestLogger(t)
+
+	for _, enabled := range []bool{false, true} {
+		t.Run(fmt.Sprintf("enabled=%t", enabled), func(t *testing.T) {
+			opts := []ServerOption{WithAuthHandler("fake", MakeSimpleAuthHandler([]string{"foo"}, "bar"))}
+			if enabled {
+				opts = append(opts, WithAuthFailureTrailers())
+			}
+			rpcServer, err := NewServer(logger, opts...)
+			test.That(t, err, test.ShouldBeNil)
+
+			err = rpcServer.RegisterServiceServer(
+				context.Background(),
+				&pb.EchoService_ServiceDesc,
+				&echoserver.Server{},
+				pb.RegisterEchoServiceHandlerFromEndpoint,
+			)
+			test.That(t, err, test.ShouldBeNil)
+
+			httpListener, err := net.Listen("tcp", "localhost:0")
+			test.That(t, err, test.ShouldBeNil)
+
+			errChan := make(chan error)
+			go func() {
+				errChan <- rpcServer.Serve(httpListener)
+			}()
+			defer func() {
+				test.That(t, rpcServer.Stop(), test.ShouldBeNil)
+				test.That(t, <-errChan, test.ShouldBeNil)
+			}()
+
+			conn, err := grpc.DialContext(
+				context.Background(),
+				httpListener.Addr().String(),
+				grpc.WithTransportCredentials(insecure.NewCredentials()),
+				grpc.WithBlock(),
+			)
+			test.That(t, err, test.ShouldBeNil)
+			defer func() {
+				test.That(t, conn.Close(), test.ShouldBeNil)
+			}()
+			client := pb.NewEchoServiceClient(conn)
+
+			wrongEntityToken, err := jwt.NewWithClaims(jwt.SigningMethodRS256, JWTClaims{
+				RegisteredClaims: jwt.RegisteredClaims{
+					Audience: jwt.ClaimStrings{"notfoo"},
+				},
+				CredentialsType: CredentialsType("fake"),
+			}).SignedString(rpcServer.(*simpleServer).authRSAPrivKey)
+			test.That(t, err, test.ShouldBeNil)
+
+			for _, tc := range []struct {
+				authorization string
+				reason        AuthFailureReason
+			}{
+				{"", AuthFailureMissingCredentials},
+				{"Bearer notatoken", AuthFailureInvalidToken},
+				{"Bearer " + wrongEntityToken, AuthFailureEntityVerification},
+			} {
+				ctx := context.Background()
+				if tc.authorization != "" {
+					ctx = metadata.AppendToOutgoingContext(ctx, "authorization", tc.authorization)
+				}
+
+				var trailer metadata.MD
+				_, err = client.Echo(ctx, &pb.EchoRequest{Message: "hello"}, grpc.Trailer(&trailer))
+				test.That(t, err, test.ShouldNotBeNil)
+				test.That(t, status.Code(err), test.ShouldEqual, codes.Unauthenticated)
+
+				stream, err := client.EchoMultiple(ctx, &pb.EchoMultipleRequest{Message: "hello"})
+				test.That(t, err, test.ShouldBeNil)
+				_, err = stream.Recv()
+				test.That(t, err, test.ShouldNotBeNil)
+				test.That(t, status.Code(err), test.ShouldEqual, codes.Unauthenticated)
+
+				if enabled {
+					test.That(t, trailer.Get(MetadataFieldAuthFailureReason), test.ShouldResemble, []string{string(tc.reason)})
+					test.That(t, stream.Trailer().Get(MetadataFieldAuthFailureReason), test.ShouldResemble, []string{string(tc.reason)})
+				} else {
+					test.That(t, trailer.Get(MetadataFieldAuthFailureReason), test.ShouldBeEmpty)
+					test.That(t, stream.Trailer().Get(MetadataFieldAuthFailureReason), test.ShouldBeEmpty)
+				}
+			}
+		})
+	}
+}
+
+func TestServerAuthExpiryGracePeriod(t *testing.T) {
+	testutils.SkipUnlessInternet(t)
+	logger := golog.NewTestLogger(t)
+
+	rpcServer, err := NewServer(
+		logger,
+		WithAuthHandler("fake", MakeSimpleAuthHandler([]string{"foo"}, "bar")),
+		WithExpiryGracePeriod(time.Hour),
+		WithAuthMetrics(),
+		WithDisableMulticastDNS(),
+	)
+	test.That(t, err, test.ShouldBeNil)
+
+	err = rpcServer.RegisterServiceServer(
+		context.Background(),
+		&pb.EchoService_ServiceDesc,
+		&echoserver.Server{},
+		pb.RegisterEchoServiceHandlerFromEndpoint,
+	)
+	test.That(t, err, test.ShouldBeNil)
+
+	httpListener, err := net.Listen("tcp", "localhost:0")
+	test.That(t, err, test.ShouldBeNil)
+
+	errChan := make(chan error)
+	go func() {
+		errChan <- rpcServer.Serve(httpListener)
+	}()
+	defer func() {
+		test.That(t, rpcServer.Stop(), test.ShouldBeNil)
+		test.That(t, <-errChan, test.ShouldBeNil)
+	}()
+
+	conn, err := grpc.DialContext(
+		context.Background(),
+		httpListener.Addr().String(),
+		grpc.WithTransportCredentials(insecure.NewCredentials()),
+		grpc.WithBlock(),
+	)
+	test.That(t, err, test.ShouldBeNil)
+	defer func() {
+		test.That(t, conn.Close(), test.ShouldBeNil)
+	}()
+	client := pb.NewEchoServiceClient(conn)
+	recorder := statztest.NewCounterRecorder("rpc/auth/expired_tokens_in_grace")
+	before := recorder.Value("credentials_type", "fake")
+
+	tokenExpiredAgo := func(ago time.Duration) string {
+		token, err := jwt.NewWithClaims(jwt.SigningMethodRS256, JWTClaims{
+			RegisteredClaims: jwt.RegisteredClaims{
+				Audience:  jwt.ClaimStrings{"foo"},
+				ExpiresAt: jwt.NewNumericDate(time.Now().Add(-ago)),
+			},
+			CredentialsType: CredentialsType("fake"),
+		}).SignedString(rpcServer.(*simpleServer).authRSAPrivKey)
+		test.That(t, err, test.ShouldBeNil)
+		return token
+	}
+
+	ctx := metadata.AppendToOutgoingContext(context.Background(), "authorization", "Bearer "+tokenExpiredAgo(time.Minute))
+	var trailer metadata.MD
+	echoResp, err := client.Echo(ctx, &pb.EchoRequest{Message: "hello"}, grpc.Trailer(&trailer))
+	test.That(t, err, test.ShouldBeNil)
+	test.That(t, echoResp.GetMessage(), test.ShouldEqual, "hello")
+	test.That(t, trailer.Get(MetadataFieldTokenExpiredGrace), test.ShouldHaveLength, 1)
+	test.That(t, recorder.Value("credentials_type", "fake"), test.ShouldEqual, before+1)
+
+	ctx = metadata.AppendToOutgoingContext(context.Background(), "authorization", "Bearer "+tokenExpiredAgo(2*time.Hour))
+	trailer = nil
+	_, err = client.Echo(ctx, &pb.EchoRequest{Message: "hello"}, grpc.Trailer(&trailer))
+	test.That(t, status.Code(err), test.ShouldEqual, codes.Unauthenticated)
+	test.That(t, trailer.Get(MetadataFieldTokenExpiredGrace), test.ShouldBeEmpty)
+	test.That(t, recorder.Value("credentials_type", "fake"), test.ShouldEqual, before+1)
+
+	_, err = NewServer(logger, WithExpiryGracePeriod(0))
+	test.That(t, err, test.ShouldNotBeNil)
+}
+
+// validUntilClaims are custom claims that expire by their own "valid_until" claim.
+type validUntilClaims struct {
+	JWTClaims
+	ValidUntil int64 `json:"valid_until,omitempty"`
+}
+
+func (c *validUntilClaims) ExpiresAt() (time.Time, bool) {
+	if c.ValidUntil == 0 {
+		return time.Time{}, false
+	}
+	return time.Unix(c.ValidUntil, 0), true
+}
+
+func (c *validUntilClaims) Valid() error {
+	if exp, ok := c.ExpiresAt(); ok && !time.Now().Before(exp) {
+		return jwt.NewValidationError("token is expired", jwt.ValidationErrorExpired)
+	}
+	return c.JWTClaims.Valid()
+}
+
+func TestServerAuthExpiryGracePeriodCustomClaims(t *testing.T) {
+	ss := newAuthTestServer(t,
+		WithAuthHandler("fake", WithTokenCustomClaimProvider(
+			MakeSimpleAuthHandler([]string{"foo"}, "bar"),
+			func() Claims { return &validUntilClaims{} },
+		)),
+		WithExpiryGracePeriod(time.Hour),
+	)
+
+	ctxExpiredAgo := func(ago time.Duration) context.Context {
+		tokenString, err := jwt.NewWithClaims(jwt.SigningMethodRS256, &validUntilClaims{
+			JWTClaims: JWTClaims{
+				RegisteredClaims: jwt.RegisteredClaims{
+					Audience: jwt.ClaimStrings{"foo"},
+				},
+				CredentialsType: CredentialsType("fake"),
+			},
+			ValidUntil: time.Now().Add(-ago).Unix(),
+		}).SignedString(ss.authRSAPrivKey)
+		test.That(t, err, test.ShouldBeNil)
+		return metadata.NewIncomingContext(context.Background(), metadata.Pairs("authorization", "Bearer "+tokenString))
+	}
+
+	// the expiry comes from the claims themselves since the token has no "exp" claim.
+	entity, err := ss.ensureAuthed(ctxExpiredAgo(time.Minute))
+	test.That(t, err, test.ShouldBeNil)
+	test.That(t, entity, test.ShouldEqual, "foo")
+
+	_, err = ss.ensureAuthed(ctxExpiredAgo(2 * time.Hour))
+	test.That(t, status.Code(err), test.ShouldEqual, codes.Unauthenticated)
+	test.That(t, err.Error(), test.ShouldContainSubstring, "token is expired")
+}
+
+func TestServerAuthTokenHeader(t *testing.T) {
+	var verifiedHeader TokenHeader
+	var verifiedHeaderOK bool
+	ss := newAuthTestServer(t,
+		WithAuthHandler("fake", MakeFuncAuthHandler(func(ctx context.Context, entity, payload string) (map[string]string, error) {
+			return nil, nil
+		}, func(ctx context.Context, entity string) (interface{}, error) {
+			verifiedHeader, verifiedHeaderOK = ContextAuthTokenHeader(ctx)
+			return entity, nil
+		})),
+	)
+
+	token := jwt.NewWithClaims(jwt.SigningMethodRS256, JWTClaims{
+		RegisteredClaims: jwt.RegisteredClaims{
+			Audience: jwt.ClaimStrings{"foo"},
+		},
+		CredentialsType: CredentialsType("fake"),
+	})
+	token.Header["kid"] = "key-2"
+	tokenString, err := token.SignedString(ss.authRSAPrivKey)
+	test.That(t, err, test.ShouldBeNil)
+
+	_, err = ss.ensureAuthed(metadata.NewIncomingContext(context.Background(), metadata.Pairs("authorization", "Bearer "+tokenString)))
+	test.That(t, err, test.ShouldBeNil)
+	test.That(t, verifiedHeaderOK, test.ShouldBeTrue)
+	test.That(t, verifiedHeader, test.ShouldResemble, TokenHeader{KeyID: "key-2", Algorithm: "RS256"})
+
+	_, ok := ContextAuthTokenHeader(context.Background())
+	test.That(t, ok, test.ShouldBeFalse)
+}
+
+func TestServerAuthRSAVerificationKeys(t *testing.T) {
+	logger := golog.NewTestLogger(t)
+
+	currentKey, err := rsa.GenerateKey(rand.Reader, 2048)
+	test.That(t, err, test.ShouldBeNil)
+	previousKey, err := rsa.GenerateKey(rand.Reader, 2048)
+	test.That(t, err, test.ShouldBeNil)
+	olderKey, err := rsa.GenerateKey(rand.Reader, 2048)
+	test.That(t, err, test.ShouldBeNil)
+	unknownKey, err := rsa.GenerateKey(rand.Reader, 2048)
+	test.That(t, err, test.ShouldBeNil)
+
+	ss := newAuthTestServer(t,
+		WithAuthHandler("fake", MakeSimpleAuthHandler([]string{"foo"}, "bar")),
+		WithAuthRSAPrivateKey(currentKey),
+		WithAuthRSAVerificationKeys(&previousKey.PublicKey, &olderKey.PublicKey),
+	)
+
+	ctxWithTokenSignedBy := func(key *rsa.PrivateKey) context.Context {
+		tokenString, err := jwt.NewWithClaims(jwt.SigningMethodRS256, JWTClaims{
+			RegisteredClaims: jwt.RegisteredClaims{
+				Audience: jwt.ClaimStrings{"foo"},
+			},
+			CredentialsType: CredentialsType("fake"),
+		}).SignedString(key)
+		test.That(t, err, test.ShouldBeNil)
+		return metadata.NewIncomingContext(context.Background(), metadata.Pairs("authorization", "Bearer "+tokenString))
+	}
+
+	for _, key := range []*rsa.PrivateKey{currentKey, previousKey, olderKey} {
+		entity, err := ss.ensureAuthed(ctxWithTokenSignedBy(key))
+		test.That(t, err, test.ShouldBeNil)
+		test.That(t, entity, test.ShouldEqual, "foo")
+	}
+
+	_, err = ss.ensureAuthed(ctxWithTokenSignedBy(unknownKey))
+	test.That(t, err, test.ShouldNotBeNil)
+	test.That(t, status.Code(err), test.ShouldEqual, codes.Unauthenticated)
+	test.That(t, err.Error(), test.ShouldContainSubstring, "verification error")
+
+	_, err = NewServer(logger, WithAuthRSAVerificationKeys(nil))
+	test.That(t, err, test.ShouldNotBeNil)
+}
+
+func TestServerAuthRequiredClaims(t *testing.T) {
+	logger := golog.NewTestLogger(t)
+
+	ss := newAuthTestServer(t,
+		WithAuthHandler("fake", MakeSimpleAuthHandler([]string{"foo"}, "bar")),
+		WithRequiredClaims("tenant", "region"),
+	)
+
+	ctxWithClaims := func(claims jwt.MapClaims) context.Context {
+		claims["aud"] = "foo"
+		claims["rpc_creds_type"] = "fake"
+		tokenString, err := jwt.NewWithClaims(jwt.SigningMethodRS256, claims).SignedString(ss.authRSAPrivKey)
+		test.That(t, err, test.ShouldBeNil)
+		return metadata.NewIncomingContext(context.Background(), metadata.Pairs("authorization", "Bearer "+tokenString))
+	}
+
+	entity, err := ss.ensureAuthed(ctxWithClaims(jwt.MapClaims{"tenant": "a", "region": "b"}))
+	test.That(t, err, test.ShouldBeNil)
+	test.That(t, entity, test.ShouldEqual, "foo")
+
+	_, err = ss.ensureAuthed(ctxWithClaims(jwt.MapClaims{"tenant": "a"}))
+	test.That(t, err, test.ShouldNotBeNil)
+	test.That(t, status.Code(err), test.ShouldEqual, codes.Unauthenticated)
+	test.That(t, err.Error(), test.ShouldContainSubstring, "missing required claims: region")
+
+	_, err = ss.ensureAuthed(ctxWithClaims(jwt.MapClaims{}))
+	test.That(t, err, test.ShouldNotBeNil)
+	test.That(t, err.Error(), test.ShouldContainSubstring, "missing required claims: tenant, region")
+
+	_, err = NewServer(logger, WithRequiredClaims(""))
+	test.That(t, err, test.ShouldNotBeNil)
+}
+
+func TestServerAuthEntityFallbackClaim(t *testing.T) {
+	logger := golog.NewTestLogger(t)
+
+	ctxWithClaims := func(ss *simpleServer, claims jwt.MapClaims) context.Context {
+		claims["rpc_creds_type"] = "fake"
+		tokenString, err := jwt.NewWithClaims(jwt.SigningMethodRS256, claims).SignedString(ss.authRSAPrivKey)
+		test.That(t, err, test.ShouldBeNil)
+		return metadata.NewIncomingContext(context.Background(), metadata.Pairs("authorization", "Bearer "+tokenString))
+	}
+
+	ss := newAuthTestServer(t, WithAuthHandler("fake", MakeSimpleAuthHandler([]string{"foo", "bar"}, "baz")))
+	_, err := ss.ensureAuthed(ctxWithClaims(ss, jwt.MapClaims{"sub": "foo"}))
+	test.That(t, err, test.ShouldNotBeNil)
+	test.That(t, err.Error(), test.ShouldContainSubstring, "no audience")
+
+	ss = newAuthTestServer(t,
+		WithAuthHandler("fake", MakeSimpleAuthHandler([]string{"foo", "bar"}, "baz")),
+		WithEntityFallbackClaim("sub"),
+	)
+
+	entity, err := ss.ensureAuthed(ctxWithClaims(ss, jwt.MapClaims{"sub": "foo"}))
+	test.That(t, err, test.ShouldBeNil)
+	test.That(t, entity, test.ShouldEqual, "foo")
+
+	entity, err = ss.ensureAuthed(ctxWithClaims(ss, jwt.MapClaims{"aud": "bar", "sub": "foo"}))
+	test.That(t, err, test.ShouldBeNil)
+	test.That(t, entity, test.ShouldEqual, "bar")
+
+	_, err = ss.ensureAuthed(ctxWithClaims(ss, jwt.MapClaims{}))
+	test.That(t, err, test.ShouldNotBeNil)
+	test.That(t, err.Error(), test.ShouldContainSubstring, "no audience")
+
+	_, err = ss.ensureAuthed(ctxWithClaims(ss, jwt.MapClaims{"sub": "unknown"}))
+	test.That(t, err, test.ShouldNotBeNil)
+
+	_, err = NewServer(logger, WithEntityFallbackClaim(""))
+	test.That(t, err, test.ShouldNotBeNil)
+}
+
+func TestServerAuthTokenAudiences(t *testing.T) {
+	logger := golog.NewTestLogger(t)
+
+	ss := newAuthTestServer(t,
+		WithAuthHandler("fake", MakeSimpleAuthHandler([]string{"foo"}, "bar")),
+		WithTokenAudiences("svc-a", "svc-b"),
+		WithAcceptedAudiences("svc-a"),
+	)
+
+	authResp, err := ss.Authenticate(metadata.NewIncomingContext(context.Background(), metadata.MD{}), &rpcpb.AuthenticateRequest{
+		Entity:      "foo",
+		Credentials: &rpcpb.Credentials{Type: "fake", Payload: "bar"},
+	})
+	test.That(t, err, test.ShouldBeNil)
+	var claims JWTClaims
+	_, _, err = jwt.NewParser().ParseUnverified(authResp.GetAccessToken(), &claims)
+	test.That(t, err, test.ShouldBeNil)
+	test.That(t, claims.Audience, test.ShouldResemble, jwt.ClaimStrings{"foo", "svc-a", "svc-b"})
+	entity, err := claims.Entity()
+	test.That(t, err, test.ShouldBeNil)
+	test.That(t, entity, test.ShouldEqual, "foo")
+
+	tokenCtx := metadata.NewIncomingContext(context.Background(), metadata.Pairs("authorization", "Bearer "+authResp.GetAccessToken()))
+	authEntity, err := ss.ensureAuthed(tokenCtx)
+	test.That(t, err, test.ShouldBeNil)
+	test.That(t, authEntity, test.ShouldEqual, "foo")
+
+	// another service of the cluster sharing the key accepts the token while one outside of it does not.
+	authenticatorFor := func(audience string) *Authenticator {
+		authenticator, err := NewAuthenticator(
+			logger,
+			WithAuthRSAPrivateKey(ss.authRSAPrivKey),
+			WithAuthHandler("fake", MakeSimpleAuthHandler([]string{"foo"}, "bar")),
+			WithAcceptedAudiences(audience),
+		)
+		test.That(t, err, test.ShouldBeNil)
+		return authenticator
+	}
+	ctx, err := authenticatorFor("svc-b").AuthenticateToken(context.Background(), authResp.GetAccessToken())
+	test.That(t, err, test.ShouldBeNil)
+	test.That(t, MustContextAuthEntity(ctx), test.ShouldEqual, "foo")
+
+	_, err = authenticatorFor("svc-c").AuthenticateToken(context.Background(), authResp.GetAccessToken())
+	test.That(t, status.Code(err), test.ShouldEqual, codes.Unauthenticated)
+	test.That(t, err.Error(), test.ShouldContainSubstring, "not valid for any accepted audience")
+
+	_, err = NewServer(logger, WithTokenAudiences(""))
+	test.That(t, err, test.ShouldNotBeNil)
+	_, err = NewServer(logger, WithAcceptedAudiences(""))
+	test.That(t, err, test.ShouldNotBeNil)
+}
+
+func TestServerAuthTokenKind(t *testing.T) {
+	ss := newAuthTestServer(t,
+		WithAuthHandler("fake", MakeSimpleAuthHandler([]string{"foo"}, "bar")),
+	)
+
+	authResp, err := ss.Authenticate(metadata.NewIncomingContext(context.Background(), metadata.MD{}), &rpcpb.AuthenticateRequest{
+		Entity:      "foo",
+		Credentials: &rpcpb.Credentials{Type: "fake", Payload: "bar"},
+	})
+	test.That(t, err, test.ShouldBeNil)
+	issued, _, err := jwt.NewParser().ParseUnverified(authResp.GetAccessToken(), &JWTClaims{})
+	test.That(t, err, test.ShouldBeNil)
+	test.That(t, tokenHeaderFromJWT(issued).Kind, test.ShouldEqual, "access")
+
+	tokenCtx := func(tokenString string) context.Context {
+		return metadata.NewIncomingContext(context.Background(), metadata.Pairs("authorization", "Bearer "+tokenString))
+	}
+	entity, err := ss.ensureAuthed(tokenCtx(authResp.GetAccessToken()))
+	test.That(t, err, test.ShouldBeNil)
+	test.That(t, entity, test.ShouldEqual, "foo")
+
+	signedWithKind := func(kind string) string {
+		token := jwt.NewWithClaims(jwt.SigningMethodRS256, JWTClaims{
+			RegisteredClaims: jwt.RegisteredClaims{
+				Audience: jwt.ClaimStrings{"foo"},
+			},
+			CredentialsType: CredentialsType("fake"),
+		})
+		if kind != "" {
+			token.Header["rpc_token_kind"] = kind
+		}
+		tokenString, err := token.SignedString(ss.authRSAPrivKey)
+		test.That(t, err, test.ShouldBeNil)
+		return tokenString
+	}
+
+	_, err = ss.ensureAuthed(tokenCtx(signedWithKind("refresh")))
+	test.That(t, status.Code(err), test.ShouldEqual, codes.Unauthenticated)
+	test.That(t, err.Error(), test.ShouldContainSubstring, "token of kind refresh cannot be used for access")
+
+	// tokens that do not say what kind they are are still accepted.
+	entity, err = ss.ensureAuthed(tokenCtx(signedWithKind("")))
+	test.That(t, err, test.ShouldBeNil)
+	test.That(t, entity, test.ShouldEqual, "foo")
+}
+
+func TestNewAuthInterceptors(t *testing.T) {
+	testutils.SkipUnlessInternet(t)
+	logger := golog.NewTestLogger(t)
+
+	privKey, err := rsa.GenerateKey(rand.Reader, 2048)
+	test.That(t, err, test.ShouldBeNil)
+
+	unaryInterceptor, streamInterceptor, err := NewAuthInterceptors(
+		logger,
+		WithAuthHandler("fake", MakeSimpleAuthHandler([]string{"foo"}, "bar")),
+		WithAuthRSAPrivateKey(privKey),
+	)
+	test.That(t, err, test.ShouldBeNil)
+
+	grpcServer := grpc.NewServer(grpc.UnaryInterceptor(unaryInterceptor), grpc.StreamInterceptor(streamInterceptor))
+	pb.RegisterEchoServiceServer(grpcServer, &echoserver.Server{})
+
+	listener, err := net.Listen("tcp", "localhost:0")
+	test.That(t, err, test.ShouldBeNil)
+	errChan := make(chan error)
+	go func() {
+		errChan <- grpcServer.Serve(listener)
+	}()
+	defer func() {
+		grpcServer.Stop()
+		test.That(t, <-errChan, test.ShouldBeNil)
+	}()
+
+	conn, err := grpc.DialContext(
+		context.Background(),
+		listener.Addr().String(),
+		grpc.WithTransportCredentials(insecure.NewCredentials()),
+		grpc.WithBlock(),
+	)
+	test.That(t, err, test.ShouldBeNil)
+	defer func() {
+		test.That(t, conn.Close(), test.ShouldBeNil)
+	}()
+	client := pb.NewEchoServiceClient(conn)
+
+	_, err = client.Echo(context.Background(), &pb.EchoRequest{Message: "hello"})
+	test.That(t, err, test.ShouldNotBeNil)
+	test.That(t, status.Code(err), test.ShouldEqual, codes.Unauthenticated)
+
+	tokenString, err := jwt.NewWithClaims(jwt.SigningMethodRS256, JWTClaims{
+		RegisteredClaims: jwt.RegisteredClaims{
+			Audience: jwt.ClaimStrings{"foo"},
+		},
+		CredentialsType: CredentialsType("fake"),
+	}).SignedString(privKey)
+	test.That(t, err, test.ShouldBeNil)
+	ctx := metadata.AppendToOutgoingContext(context.Background(), "authorization", "Bearer "+tokenString)
+
+	echoResp, err := client.Echo(ctx, &pb.EchoRequest{Message: "hello"})
+	test.That(t, err, test.ShouldBeNil)
+	test.That(t, echoResp.GetMessage(), test.ShouldEqual, "hello")
+
+	stream, err := client.EchoMultiple(ctx, &pb.EchoMultipleRequest{Message: "hello"})
+	test.That(t, err, test.ShouldBeNil)
+	_, err = stream.Recv()
+	test.That(t, err, test.ShouldBeNil)
+
+	_, _, err = NewAuthInterceptors(logger, WithUnauthenticated())
+	test.That(t, err, test.ShouldNotBeNil)
+}
+
+func TestNewAuthenticatedServer(t *testing.T) {
+	testutils.SkipUnlessInternet(t)
+	logger := golog.NewTestLogger(t)
+
+	grpcServer, err := NewAuthenticatedServer(
+		logger,
+		WithAuthHandler("fake", MakeSimpleAuthHandler([]string{"foo"}, "bar")),
+	)
+	test.That(t, err, test.ShouldBeNil)
+	pb.RegisterEchoServiceServer(grpcServer, &echoserver.Server{})
+
+	listener, err := net.Listen("tcp", "localhost:0")
+	test.That(t, err, test.ShouldBeNil)
+	errChan := make(chan error)
+	go func() {
+		errChan <- grpcServer.Serve(listener)
+	}()
+	defer func() {
+		grpcServer.Stop()
+		test.That(t, <-errChan, test.ShouldBeNil)
+	}()
+
+	conn, err := grpc.DialContext(
+		context.Background(),
+		listener.Addr().String(),
+		grpc.WithTransportCredentials(insecure.NewCredentials()),
+		grpc.WithBlock(),
+	)
+	test.That(t, err, test.ShouldBeNil)
+	defer func() {
+		test.That(t, conn.Close(), test.ShouldBeNil)
+	}()
+	client := pb.NewEchoServiceClient(conn)
+
+	_, err = client.Echo(context.Background(), &pb.EchoRequest{Message: "hello"})
+	test.That(t, err, test.ShouldNotBeNil)
+	test.That(t, status.Code(err), test.ShouldEqual, codes.Unauthenticated)
+
+	authClient := rpcpb.NewAuthServiceClient(conn)
+	authResp, err := authClient.Authenticate(context.Background(), &rpcpb.AuthenticateRequest{
+		Entity: "foo",
+		Credentials: &rpcpb.Credentials{
+			Type:    "fake",
+			Payload: "bar",
+		},
+	})
+	test.That(t, err, test.ShouldBeNil)
+	ctx := metadata.AppendToOutgoingContext(context.Background(), "authorization", "Bearer "+authResp.GetAccessToken())
+
+	echoResp, err := client.Echo(ctx, &pb.EchoRequest{Message: "hello"})
+	test.That(t, err, test.ShouldBeNil)
+	test.That(t, echoResp.GetMessage(), test.ShouldEqual, "hello")
+
+	stream, err := client.EchoMultiple(ctx, &pb.EchoMultipleRequest{Message: "hello"})
+	test.That(t, err, test.ShouldBeNil)
+	_, err = stream.Recv()
+	test.That(t, err, test.ShouldBeNil)
+
+	_, err = NewAuthenticatedServer(logger, WithUnauthenticated())
+	test.That(t, err, test.ShouldNotBeNil)
+}
+
+type requestAwareAuthHandler struct {
+	AuthHandler
+	allowed map[string]string
+}
+
+func (h *requestAwareAuthHandler) VerifyRequest(ctx context.Context, entity interface{}, fullMethod string) error {
+	if MustContextAuthEntity(ctx) != entity {
+		return errors.New("expected entity in context")
+	}
+	switch h.allowed[fullMethod] {
+	case entity:
+		return nil
+	case "":
+		return status.Error(codes.Unimplemented, "no policy")
+	default:
+		return errors.New("not allowed")
+	}
+}
+
+func TestServerAuthRequestAwareVerifier(t *testing.T) {
+	ss := newAuthTestServer(t,
+		WithAuthHandler("fake", &requestAwareAuthHandler{
+			AuthHandler: MakeSimpleAuthHandler([]string{"foo", "bar"}, "baz"),
+			allowed: map[string]string{
+				"/some.Service/Foo": "foo",
+				"/some.Service/Bar": "bar",
+			},
+		}),
+	)
+
+	ctxFor := func(entity string) context.Context {
+		tokenString, err := ss.signAccessTokenForEntity("fake", entity, nil, nil, "", "")
+		test.That(t, err, test.ShouldBeNil)
+		return metadata.NewIncomingContext(context.Background(), metadata.Pairs("authorization", "Bearer "+tokenString))
+	}
+	callUnary := func(ctx context.Context, method string) error {
+		_, err := ss.authUnaryInterceptor(ctx, nil, &grpc.UnaryServerInfo{FullMethod: method},
+			func(ctx context.Context, req interface{}) (interface{}, error) {
+				return nil, nil
+			})
+		return err
+	}
+
+	test.That(t, callUnary(ctxFor("foo"), "/some.Service/Foo"), test.ShouldBeNil)
+	test.That(t, callUnary(ctxFor("bar"), "/some.Service/Bar"), test.ShouldBeNil)
+
+	err := callUnary(ctxFor("foo"), "/some.Service/Bar")
+	test.That(t, status.Code(err), test.ShouldEqual, codes.PermissionDenied)
+	test.That(t, err.Error(), test.ShouldContainSubstring, "request rejected: not allowed")
+
+	err = callUnary(ctxFor("foo"), "/some.Service/Other")
+	test.That(t, status.Code(err), test.ShouldEqual, codes.Unimplemented)
+
+	err = ss.authStreamInterceptor(nil, &fakeServerStream{ctx: ctxFor("bar")}, &grpc.StreamServerInfo{FullMethod: "/some.Service/Foo"},
+		func(srv interface{}, stream grpc.ServerStream) error {
+			return nil
+		})
+	test.That(t, status.Code(err), test.ShouldEqual, codes.PermissionDenied)
+
+	// exempt methods are not verified.
+	ss.AddExemptMethod("/some.Service/Exempt")
+	test.That(t, callUnary(context.Background(), "/some.Service/Exempt"), test.ShouldBeNil)
+}
+
+type multiValueAuthHandler struct {
+	AuthHandler
+	verified chan Claims
+}
+
+func (h *multiValueAuthHandler) AuthenticateMultiValue(ctx context.Context, entity, payload string) (map[string][]string, error) {
+	if _, err := h.Authenticate(ctx, entity, payload); err != nil {
+		return nil, err
+	}
+	return map[string][]string{"roles": {"reader", "writer"}}, nil
+}
+
+func (h *multiValueAuthHandler) VerifyEntity(ctx context.Context, entity string) (interface{}, error) {
+	h.verified <- ContextAuthClaims(ctx)
+	return h.AuthHandler.VerifyEntity(ctx, entity)
+}
+
+func TestServerAuthMultiValueAuthMetadata(t *testing.T) {
+	handler := &multiValueAuthHandler{
+		AuthHandler: MakeSimpleAuthHandler([]string{"foo"}, "bar"),
+		verified:    make(chan Claims, 1),
+	}
+	ss := newAuthTestServer(t, WithAuthHandler("fake", handler))
+
+	resp, err := ss.Authenticate(metadata.NewIncomingContext(context.Background(), metadata.MD{}), &rpcpb.AuthenticateRequest{
+		Entity:      "foo",
+		Credentials: &rpcpb.Credentials{Type: "fake", Payload: "bar"},
+	})
+	test.That(t, err, test.ShouldBeNil)
+
+	entity, err := ss.ensureAuthed(metadata.NewIncomingContext(context.Background(),
+		metadata.Pairs("authorization", "Bearer "+resp.AccessToken)))
+	test.That(t, err, test.ShouldBeNil)
+	test.That(t, entity, test.ShouldEqual, "foo")
+
+	claims := <-handler.verified
+	multiClaims, ok := claims.(MultiValueClaims)
+	test.That(t, ok, test.ShouldBeTrue)
+	test.That(t, multiClaims.GetAuthMetadataMulti(), test.ShouldResemble, map[string][]string{"roles": {"reader", "writer"}})
+	test.That(t, claims.GetAuthMetadata(), test.ShouldResemble, map[string]string{"roles": "reader,writer"})
+
+	mixed := JWTClaims{
+		AuthMetadata:      map[string]string{"single": "a"},
+		AuthMetadataMulti: map[string][]string{"multi": {"b", "c"}},
+	}
+	test.That(t, mixed.GetAuthMetadata(), test.ShouldResemble, map[string]string{"single": "a", "multi": "b,c"})
+	test.That(t, mixed.GetAuthMetadataMulti(), test.ShouldResemble, map[string][]string{"single": {"a"}, "multi": {"b", "c"}})
+}
+
+type wrappedTLSAuthInfo struct {
+	cert *x509.Certificate
+}
+
+func (info wrappedTLSAuthInfo) AuthType() string {
+	return "wrapped-tls"
+}
+
+func TestServerAuthTLSInfoExtractor(t *testing.T) {
+	logger := golog.NewTestLogger(t)
+	cert := &x509.Certificate{DNSNames: []string{"foo"}}
+
+	for _, withExtractor := range []bool{false, true} {
+		t.Run(fmt.Sprintf("withExtractor=%t", withExtractor), func(t *testing.T) {
+			opts := []ServerOption{WithTLSAuthHandler([]string{"foo"}, nil)}
+			if withExtractor {
+				opts = append(opts, WithTLSInfoExtractor(func(authInfo credentials.AuthInfo) (*x509.Certificate, bool) {
+					wrapped, ok := authInfo.(wrappedTLSAuthInfo)
+					if !ok {
+						return nil, false
+					}
+					return wrapped.cert, true
+				}))
+			}
+			ss := newAuthTestServer(t, opts...)
+
+			// the default extractor understands credentials.TLSInfo only.
+			tlsInfoCtx := peer.NewContext(context.Background(), &peer.Peer{AuthInfo: credentials.TLSInfo{
+				State: tls.ConnectionState{VerifiedChains: [][]*x509.Certificate{{cert}}},
+			}})
+			entity, err := ss.ensureAuthed(tlsInfoCtx)
+			if withExtractor {
+				test.That(t, err, test.ShouldNotBeNil)
+			} else {
+				test.That(t, err, test.ShouldBeNil)
+				test.That(t, entity, test.ShouldResemble, []string{"foo"})
+			}
+
+			wrappedCtx := peer.NewContext(context.Background(), &peer.Peer{AuthInfo: wrappedTLSAuthInfo{cert}})
+			entity, err = ss.ensureAuthed(wrappedCtx)
+			if withExtractor {
+				test.That(t, err, test.ShouldBeNil)
+				test.That(t, entity, test.ShouldResemble, []string{"foo"})
+			} else {
+				test.That(t, err, test.ShouldNotBeNil)
+			}
+		})
+	}
+
+	_, err := NewServer(logger, WithTLSInfoExtractor(nil))
+	test.That(t, err, test.ShouldNotBeNil)
+}
+
+func TestServerAuthTLSAuthMetadata(t *testing.T) {
+	ss := newAuthTestServer(t,
+		WithTLSAuthMetadataHandler([]string{"foo"}, func(ctx context.Context, entities ...string) (interface{}, map[string]string, error) {
+			return entities[0], map[string]string{"tenant": "a", "role": "device"}, nil
+		}),
+		WithAuthenticateToHandler("fake", func(ctx context.Context, entity string) (map[string]string, error) {
+			return map[string]string{"role": "delegate"}, nil
+		}),
+	)
+
+	cert := &x509.Certificate{DNSNames: []string{"foo"}}
+	tlsCtx := peer.NewContext(context.Background(), &peer.Peer{AuthInfo: credentials.TLSInfo{
+		State: tls.ConnectionState{VerifiedChains: [][]*x509.Certificate{{cert}}},
+	}})
+	authCtx, _, err := ss.ensureAuthedForMethod(tlsCtx, "/proto.rpc.examples.echo.v1.EchoService/Echo")
+	test.That(t, err, test.ShouldBeNil)
+	test.That(t, MustContextAuthEntity(authCtx), test.ShouldEqual, "foo")
+	test.That(t, ContextAuthMetadata(authCtx), test.ShouldResemble, map[string]string{"tenant": "a", "role": "device"})
+
+	// the metadata is baked into tokens obtained via AuthenticateTo, under that of its handler.
+	authToResp, err := ss.AuthenticateTo(authCtx, &rpcpb.AuthenticateToRequest{Entity: "bar"})
+	test.That(t, err, test.ShouldBeNil)
+	var claims JWTClaims
+	_, _, err = jwt.NewParser().ParseUnverified(authToResp.GetAccessToken(), &claims)
+	test.That(t, err, test.ShouldBeNil)
+	test.That(t, claims.GetAuthMetadata(), test.ShouldResemble, map[string]string{"tenant": "a", "role": "delegate"})
+
+	// requests not authenticated via TLS have nothing to bake in.
+	authToResp, err = ss.AuthenticateTo(context.Background(), &rpcpb.AuthenticateToRequest{Entity: "bar"})
+	test.That(t, err, test.ShouldBeNil)
+	claims = JWTClaims{}
+	_, _, err = jwt.NewParser().ParseUnverified(authToResp.GetAccessToken(), &claims)
+	test.That(t, err, test.ShouldBeNil)
+	test.That(t, claims.GetAuthMetadata(), test.ShouldResemble, map[string]string{"role": "delegate"})
+}
+
+type tokenExtractorCtxKey struct{}
+
+func TestServerAuthTokenExtractor(t *testing.T) {
+	logger := golog.NewTestLogger(t)
+	ss := newAuthTestServer(t,
+		WithAuthHandler("fake", MakeSimpleAuthHandler([]string{"foo"}, "something")),
+		WithTokenExtractor(func(ctx context.Context) (string, bool) {
+			token, ok := ctx.Value(tokenExtractorCtxKey{}).(string)
+			return token, ok
+		}),
+	)
+
+	authResp, err := ss.Authenticate(metadata.NewIncomingContext(context.Background(), metadata.MD{}), &rpcpb.AuthenticateRequest{
+		Entity:      "foo",
+		Credentials: &rpcpb.Credentials{Type: "fake", Payload: "something"},
+	})
+	test.That(t, err, test.ShouldBeNil)
+	token := authResp.GetAccessToken()
+
+	// the extractor is consulted first.
+	extractedCtx := context.WithValue(context.Background(), tokenExtractorCtxKey{}, token)
+	entity, err := ss.ensureAuthed(extractedCtx)
+	test.That(t, err, test.ShouldBeNil)
+	test.That(t, entity, test.ShouldEqual, "foo")
+
+	// an extracted token takes precedence over the authorization metadata.
+	badMDCtx := metadata.NewIncomingContext(extractedCtx, metadata.Pairs("authorization", "Bearer notatoken"))
+	entity, err = ss.ensureAuthed(badMDCtx)
+	test.That(t, err, test.ShouldBeNil)
+	test.That(t, entity, test.ShouldEqual, "foo")
+
+	// requests without an extracted token fall back to the authorization metadata.
+	mdCtx := metadata.NewIncomingContext(context.Background(), metadata.Pairs("authorization", "Bearer "+token))
+	entity, err = ss.ensureAuthed(mdCtx)
+	test.That(t, err, test.ShouldBeNil)
+	test.That(t, entity, test.ShouldEqual, "foo")
+
+	_, err = ss.ensureAuthed(context.Background())
+	test.That(t, status.Code(err), test.ShouldEqual, codes.Unauthenticated)
+
+	_, err = NewServer(logger, WithTokenExtractor(nil))
+	test.That(t, err, test.ShouldNotBeNil)
+}
+
+func TestServerAuthRecentAuthRequired(t *testing.T) {
+	logger := golog.NewTestLogger(t)
+	const sensitiveMethod = "/some.Service/DeleteOrg"
+	const otherMethod = "/some.Service/GetOrg"
+	ss := newAuthTestServer(t,
+		WithAuthHandler("fake", MakeSimpleAuthHandler([]string{"foo"}, "something")),
+		WithRecentAuthRequired(time.Minute, sensitiveMethod),
+	)
+
+	tokenCtx := func(token string) context.Context {
+		return metadata.NewIncomingContext(context.Background(), metadata.Pairs("authorization", "Bearer "+token))
+	}
+	signedToken := func(issuedAt *jwt.NumericDate) string {
+		token, err := jwt.NewWithClaims(jwt.SigningMethodRS256, JWTClaims{
+			RegisteredClaims: jwt.RegisteredClaims{
+				Audience: jwt.ClaimStrings{"foo"},
+				IssuedAt: issuedAt,
+			},
+			CredentialsType: CredentialsType("fake"),
+		}).SignedString(ss.authRSAPrivKey)
+		test.That(t, err, test.ShouldBeNil)
+		return token
+	}
+
+	// tokens from Authenticate say when they were issued.
+	authResp, err := ss.Authenticate(metadata.NewIncomingContext(context.Background(), metadata.MD{}), &rpcpb.AuthenticateRequest{
+		Entity:      "foo",
+		Credentials: &rpcpb.Credentials{Type: "fake", Payload: "something"},
+	})
+	test.That(t, err, test.ShouldBeNil)
+	_, _, err = ss.ensureAuthedForMethod(tokenCtx(authResp.GetAccessToken()), sensitiveMethod)
+	test.That(t, err, test.ShouldBeNil)
+
+	for _, staleToken := range []string{
+		signedToken(jwt.NewNumericDate(time.Now().Add(-time.Hour))),
+		signedToken(nil),
+	} {
+		_, _, err = ss.ensureAuthedForMethod(tokenCtx(staleToken), otherMethod)
+		test.That(t, err, test.ShouldBeNil)
+
+		_, reason, err := ss.ensureAuthedForMethod(tokenCtx(staleToken), sensitiveMethod)
+		test.That(t, status.Code(err), test.ShouldEqual, codes.Unauthenticated)
+		test.That(t, reason, test.ShouldEqual, AuthFailureReauthenticationRequired)
+	}
+
+	_, err = NewServer(logger, WithRecentAuthRequired(0, sensitiveMethod))
+	test.That(t, err, test.ShouldNotBeNil)
+	_, err = NewServer(logger, WithRecentAuthRequired(time.Minute))
+	test.That(t, err, test.ShouldNotBeNil)
+}
+
+func TestServerAuthMaxTokenAge(t *testing.T) {
+	logger := golog.NewTestLogger(t)
+	ss := newAuthTestServer(t,
+		WithAuthHandler("fake", MakeSimpleAuthHandler([]string{"foo"}, "something")),
+		WithMaxTokenAge(time.Hour),
+	)
+
+	tokenCtx := func(token string) context.Context {
+		return metadata.NewIncomingContext(context.Background(), metadata.Pairs("authorization", "Bearer "+token))
+	}
+	signedToken := func(issuedAt *jwt.NumericDate) string {
+		token, err := jwt.NewWithClaims(jwt.SigningMethodRS256, JWTClaims{
+			RegisteredClaims: jwt.RegisteredClaims{
+				Audience:  jwt.ClaimStrings{"foo"},
+				IssuedAt:  issuedAt,
+				ExpiresAt: jwt.NewNumericDate(time.Now().Add(24 * time.Hour)),
+			},
+			CredentialsType: CredentialsType("fake"),
+		}).SignedString(ss.authRSAPrivKey)
+		test.That(t, err, test.ShouldBeNil)
+		return token
+	}
+
+	_, err := ss.ensureAuthed(tokenCtx(signedToken(jwt.NewNumericDate(time.Now().Add(-time.Minute)))))
+	test.That(t, err, test.ShouldBeNil)
+
+	for _, oldToken := range []string{
+		signedToken(jwt.NewNumericDate(time.Now().Add(-2 * time.Hour))),
+		signedToken(nil),
+	} {
+		_, reason, err := ss.ensureAuthedWithReason(tokenCtx(oldToken))
+		test.That(t, status.Code(err), test.ShouldEqual, codes.Unauthenticated)
+		test.That(t, reason, test.ShouldEqual, AuthFailureTokenExpired)
+	}
+
+	_, err = NewServer(logger, WithMaxTokenAge(0))
+	test.That(t, err, test.ShouldNotBeNil)
+}
+
+func TestServerAuthIssuedAtTolerance(t *testing.T) {
+	logger := golog.NewTestLogger(t)
+	ss := newAuthTestServer(t,
+		WithAuthHandler("fake", MakeSimpleAuthHandler([]string{"foo"}, "something")),
+		WithIssuedAtTolerance(time.Minute),
+	)
+
+	tokenCtx := func(issuedAt *jwt.NumericDate) context.Context {
+		token, err := jwt.NewWithClaims(jwt.SigningMethodRS256, JWTClaims{
+			RegisteredClaims: jwt.RegisteredClaims{
+				Audience:  jwt.ClaimStrings{"foo"},
+				IssuedAt:  issuedAt,
+				ExpiresAt: jwt.NewNumericDate(time.Now().Add(24 * time.Hour)),
+			},
+			CredentialsType: CredentialsType("fake"),
+		}).SignedString(ss.authRSAPrivKey)
+		test.That(t, err, test.ShouldBeNil)
+		return metadata.NewIncomingContext(context.Background(), metadata.Pairs("authorization", "Bearer "+token))
+	}
+
+	for _, issuedAt := range []*jwt.NumericDate{
+		jwt.NewNumericDate(time.Now()),
+		jwt.NewNumericDate(time.Now().Add(30 * time.Second)),
+		nil,
+	} {
+		_, err := ss.ensureAuthed(tokenCtx(issuedAt))
+		test.That(t, err, test.ShouldBeNil)
+	}
+
+	_, reason, err := ss.ensureAuthedWithReason(tokenCtx(jwt.NewNumericDate(time.Now().Add(time.Hour))))
+	test.That(t, status.Code(err), test.ShouldEqual, codes.Unauthenticated)
+	test.That(t, err.Error(), test.ShouldContainSubstring, "issued more than 1m0s in the future")
+	test.That(t, reason, test.ShouldEqual, AuthFailureInvalidClaims)
+
+	_, err = NewServer(logger, WithIssuedAtTolerance(0))
+	test.That(t, err, test.ShouldNotBeNil)
+}
+
+func TestServerAuthTLSTokenBinding(t *testing.T) {
+	for _, withBinding := range []bool{false, true} {
+		t.Run(fmt.Sprintf("withBinding=%t", withBinding), func(t *testing.T) {
+			opts := []ServerOption{WithAuthHandler("fake", MakeSimpleAuthHandler([]string{"foo", "bar"}, "something"))}
+			if withBinding {
+				opts = append(opts, WithTLSTokenBinding())
+			}
+			ss := newAuthTestServer(t, opts...)
+
+			tokenCtx := func(entity string, cert *x509.Certificate) context.Context {
+				authResp, err := ss.Authenticate(metadata.NewIncomingContext(context.Background(), metadata.MD{}), &rpcpb.AuthenticateRequest{
+					Entity:      entity,
+					Credentials: &rpcpb.Credentials{Type: "fake", Payload: "something"},
+				})
+				test.That(t, err, test.ShouldBeNil)
+				ctx := metadata.NewIncomingContext(context.Background(), metadata.Pairs("authorization", "Bearer "+authResp.GetAccessToken()))
+				if cert == nil {
+					return ctx
+				}
+				return peer.NewContext(ctx, &peer.Peer{AuthInfo: credentials.TLSInfo{
+					State: tls.ConnectionState{VerifiedChains: [][]*x509.Certificate{{cert}}},
+				}})
+			}
+			cert := &x509.Certificate{DNSNames: []string{"foo"}}
+
+			_, err := ss.ensureAuthed(tokenCtx("foo", nil))
+			test.That(t, err, test.ShouldBeNil)
+			_, err = ss.ensureAuthed(tokenCtx("foo", cert))
+			test.That(t, err, test.ShouldBeNil)
+
+			_, reason, err := ss.ensureAuthedWithReason(tokenCtx("bar", cert))
+			if withBinding {
+				test.That(t, status.Code(err), test.ShouldEqual, codes.Unauthenticated)
+				test.That(t, reason, test.ShouldEqual, AuthFailureTokenBinding)
+			} else {
+				test.That(t, err, test.ShouldBeNil)
+			}
+		})
+	}
+}
+
+func TestServerAuthUnixSocketAuthEntity(t *testing.T) {
+	logger := golog.NewTestLogger(t)
+	unaryInterceptor, _, err := NewAuthInterceptors(
+		logger,
+		WithAuthHandler("fake", MakeSimpleAuthHandler([]string{"foo"}, "bar")),
+		WithUnixSocketAuthEntity("sidecar"),
+	)
+	test.That(t, err, test.ShouldBeNil)
+	callEntity := func(ctx context.Context) (interface{}, error) {
+		var entity interface{}
+		_, err := unaryInterceptor(ctx, nil, &grpc.UnaryServerInfo{FullMethod: "/some.Service/Method"},
+			func(ctx context.Context, req interface{}) (interface{}, error) {
+				entity = MustContextAuthEntity(ctx)
+				return nil, nil
+			})
+		return entity, err
+	}
+
+	unixCtx := peer.NewContext(context.Background(), &peer.Peer{Addr: &net.UnixAddr{Name: "@", Net: "unix"}})
+	entity, err := callEntity(unixCtx)
 	test.That(t, err, test.ShouldBeNil)
+	test.That(t, entity, test.ShouldEqual, "sidecar")
 
-	errChan := make(chan error)
-	go func() {
-		errChan <- rpcServer.Serve(httpListener)
-	}()
-
-	conn, err := grpc.DialContext(
-		context.Background(),
-		httpListener.Addr().String(),
-		grpc.WithTransportCredentials(insecure.NewCredentials()),
-		grpc.WithBlock(),
-	)
+	// even with an invalid token.
+	entity, err = callEntity(metadata.NewIncomingContext(unixCtx, metadata.Pairs("authorization", "Bearer notatoken")))
 	test.That(t, err, test.ShouldBeNil)
-	defer func() {
-		test.That(t, conn.Close(), test.ShouldBeNil)
-	}()
-	client := pb.NewEchoServiceClient(conn)
+	test.That(t, entity, test.ShouldEqual, "sidecar")
 
-	t.Cleanup(func() {
-		test.That(t, rpcServer.Stop(), test.ShouldBeNil)
-		err = <-errChan
-		test.That(t, err, test.ShouldBeNil)
-	})
+	// loopback TCP peers are not trusted.
+	loopbackCtx := peer.NewContext(context.Background(), &peer.Peer{Addr: &net.TCPAddr{IP: net.IPv4(127, 0, 0, 1), Port: 5555}})
+	_, err = callEntity(loopbackCtx)
+	test.That(t, status.Code(err), test.ShouldEqual, codes.Unauthenticated)
+	_, err = callEntity(context.Background())
+	test.That(t, status.Code(err), test.ShouldEqual, codes.Unauthenticated)
 
-	for _, correctEntity := range []bool{false, true} {
-		t.Run(fmt.Sprintf("correctEntity=%t", correctEntity), func(t *testing.T) {
-			var aud string
-			if correctEntity {
-				aud = expectedEntity
-			} else {
-				aud = "actually matters"
-			}
-			token := jwt.NewWithClaims(jwt.SigningMethodRS256, JWTClaims{
-				RegisteredClaims: jwt.RegisteredClaims{
-					Audience: jwt.ClaimStrings{aud},
-				},
-				CredentialsType: CredentialsType("fake"),
-			})
+	// the listener of NewServer is never a unix socket.
+	_, err = NewServer(
+		logger,
+		WithAuthHandler("fake", MakeSimpleAuthHandler([]string{"foo"}, "bar")),
+		WithUnixSocketAuthEntity("sidecar"),
+		WithDisableMulticastDNS(),
+	)
+	test.That(t, err, test.ShouldNotBeNil)
+	test.That(t, err.Error(), test.ShouldContainSubstring, "unix socket")
 
-			tokenString, err := token.SignedString(privKey)
-			test.That(t, err, test.ShouldBeNil)
+	_, _, err = NewAuthInterceptors(logger, WithUnixSocketAuthEntity(nil))
+	test.That(t, err, test.ShouldNotBeNil)
+	_, err = NewServer(logger, WithUnauthenticated(), WithUnixSocketAuthEntity("sidecar"), WithDisableMulticastDNS())
+	test.That(t, err, test.ShouldEqual, errMixedUnauthAndAuth)
+}
 
-			md := make(metadata.MD)
-			bearer := fmt.Sprintf("Bearer %s", tokenString)
-			md.Set("authorization", bearer)
-			ctx := metadata.NewOutgoingContext(context.Background(), md)
+func TestServerAuthPeerTokenBinding(t *testing.T) {
+	ss := newAuthTestServer(t,
+		WithAuthHandler("fake", MakeSimpleAuthHandler([]string{"foo"}, "something")),
+		WithPeerTokenBinding(),
+	)
 
-			echoResp, err := client.Echo(ctx, &pb.EchoRequest{Message: "hello"})
-			if correctEntity {
-				test.That(t, err, test.ShouldBeNil)
-				test.That(t, echoResp.GetMessage(), test.ShouldEqual, "hello")
-			} else {
-				test.That(t, err, test.ShouldNotBeNil)
-				gStatus, ok := status.FromError(err)
-				test.That(t, ok, test.ShouldBeTrue)
-				test.That(t, gStatus.Code(), test.ShouldEqual, codes.Unauthenticated)
-				test.That(t, gStatus.Message(), test.ShouldContainSubstring, "cannot authenticate")
-			}
+	fromAddr := func(ctx context.Context, ip string, port int) context.Context {
+		return peer.NewContext(ctx, &peer.Peer{Addr: &net.TCPAddr{IP: net.ParseIP(ip), Port: port}})
+	}
+	fromCert := func(ctx context.Context, cert *x509.Certificate) context.Context {
+		return peer.NewContext(ctx, &peer.Peer{
+			Addr: &net.TCPAddr{IP: net.ParseIP("10.0.0.1"), Port: 1234},
+			AuthInfo: credentials.TLSInfo{
+				State: tls.ConnectionState{VerifiedChains: [][]*x509.Certificate{{cert}}},
+			},
+		})
+	}
+	authenticate := func(peerCtx func(ctx context.Context) context.Context) string {
+		authResp, err := ss.Authenticate(peerCtx(metadata.NewIncomingContext(context.Background(), metadata.MD{})), &rpcpb.AuthenticateRequest{
+			Entity:      "foo",
+			Credentials: &rpcpb.Credentials{Type: "fake", Payload: "something"},
 		})
+		test.That(t, err, test.ShouldBeNil)
+		return authResp.GetAccessToken()
 	}
+	tokenCtx := func(token string) context.Context {
+		return metadata.NewIncomingContext(context.Background(), metadata.Pairs("authorization", "Bearer "+token))
+	}
+
+	ipToken := authenticate(func(ctx context.Context) context.Context { return fromAddr(ctx, "10.0.0.1", 1234) })
+	var claims JWTClaims
+	_, _, err := jwt.NewParser().ParseUnverified(ipToken, &claims)
+	test.That(t, err, test.ShouldBeNil)
+	test.That(t, claims.PeerIdentity, test.ShouldEqual, "ip:10.0.0.1")
+
+	// the port of the peer may change between connections but its address may not.
+	_, err = ss.ensureAuthed(fromAddr(tokenCtx(ipToken), "10.0.0.1", 5678))
+	test.That(t, err, test.ShouldBeNil)
+	_, reason, err := ss.ensureAuthedWithReason(fromAddr(tokenCtx(ipToken), "10.0.0.2", 1234))
+	test.That(t, status.Code(err), test.ShouldEqual, codes.Unauthenticated)
+	test.That(t, err.Error(), test.ShouldContainSubstring, "bound to a different peer")
+	test.That(t, reason, test.ShouldEqual, AuthFailureTokenBinding)
+
+	// peers with a client certificate are bound to it rather than to their address.
+	cert := &x509.Certificate{Raw: []byte("cert"), DNSNames: []string{"foo"}}
+	certToken := authenticate(func(ctx context.Context) context.Context { return fromCert(ctx, cert) })
+	_, err = ss.ensureAuthed(fromCert(tokenCtx(certToken), cert))
+	test.That(t, err, test.ShouldBeNil)
+	_, err = ss.ensureAuthed(fromAddr(tokenCtx(certToken), "10.0.0.1", 1234))
+	test.That(t, status.Code(err), test.ShouldEqual, codes.Unauthenticated)
+	_, err = ss.ensureAuthed(fromCert(tokenCtx(certToken), &x509.Certificate{Raw: []byte("other"), DNSNames: []string{"foo"}}))
+	test.That(t, status.Code(err), test.ShouldEqual, codes.Unauthenticated)
+
+	// tokens that are not bound to a peer are unaffected.
+	unbound, err := jwt.NewWithClaims(jwt.SigningMethodRS256, JWTClaims{
+		RegisteredClaims: jwt.RegisteredClaims{Audience: jwt.ClaimStrings{"foo"}},
+		CredentialsType:  CredentialsType("fake"),
+	}).SignedString(ss.authRSAPrivKey)
+	test.That(t, err, test.ShouldBeNil)
+	_, err = ss.ensureAuthed(fromAddr(tokenCtx(unbound), "10.0.0.2", 1234))
+	test.That(t, err, test.ShouldBeNil)
 }
 
 func TestServerAuthKeyFunc(t *testing.T) {
@@ -676,3 +2206,343 @@ type customClaims struct {
 	JWTClaims
 	CustomClaim string `json:"custom-claim"`
 }
+
+func TestServerAuthUnaryInterceptorsRunAfterAuth(t *testing.T) {
+	testutils.SkipUnlessInternet(t)
+	logger := golog.NewTestLogger(t)
+
+	var testMu sync.Mutex
+	var calls []string
+	makeInterceptor := func(name string) grpc.UnaryServerInterceptor {
+		return func(
+			ctx context.Context,
+			req interface{},
+			info *grpc.UnaryServerInfo,
+			handler grpc.UnaryHandler,
+		) (interface{}, error) {
+			authEntity, err := contextAuthEntity(ctx)
+			if err != nil {
+				return nil, err
+			}
+			testMu.Lock()
+			calls = append(calls, fmt.Sprintf("%s:%v", name, authEntity))
+			testMu.Unlock()
+			return handler(ctx, req)
+		}
+	}
+
+	rpcServer, err := NewServer(
+		logger,
+		WithAuthHandler("fake", MakeSimpleAuthHandler([]string{"foo"}, "something")),
+		WithUnaryServerInterceptor(makeInterceptor("first")),
+		WithUnaryServerInterceptors(makeInterceptor("second"), makeInterceptor("third")),
+	)
+	test.That(t, err, test.ShouldBeNil)
+
+	err = rpcServer.RegisterServiceServer(
+		context.Background(),
+		&pb.EchoService_ServiceDesc,
+		&echoserver.Server{},
+		pb.RegisterEchoServiceHandlerFromEndpoint,
+	)
+	test.That(t, err, test.ShouldBeNil)
+
+	httpListener, err := net.Listen("tcp", "localhost:0")
+	test.That(t, err, test.ShouldBeNil)
+
+	errChan := make(chan error)
+	go func() {
+		errChan <- rpcServer.Serve(httpListener)
+	}()
+
+	conn, err := grpc.DialContext(
+		context.Background(),
+		httpListener.Addr().String(),
+		grpc.WithTransportCredentials(insecure.NewCredentials()),
+		grpc.WithBlock(),
+	)
+	test.That(t, err, test.ShouldBeNil)
+	defer func() {
+		test.That(t, conn.Close(), test.ShouldBeNil)
+	}()
+	client := pb.NewEchoServiceClient(conn)
+
+	// unauthenticated requests never reach the downstream interceptors
+	_, err = client.Echo(context.Background(), &pb.EchoRequest{Message: "hello"})
+	test.That(t, err, test.ShouldNotBeNil)
+	gStatus, ok := status.FromError(err)
+	test.That(t, ok, test.ShouldBeTrue)
+	test.That(t, gStatus.Code(), test.ShouldEqual, codes.Unauthenticated)
+
+	// exempt methods skip the downstream interceptors
+	authClient := rpcpb.NewAuthServiceClient(conn)
+	authResp, err := authClient.Authenticate(context.Background(), &rpcpb.AuthenticateRequest{Entity: "foo", Credentials: &rpcpb.Credentials{
+		Type:    "fake",
+		Payload: "something",
+	}})
+	test.That(t, err, test.ShouldBeNil)
+
+	testMu.Lock()
+	test.That(t, calls, test.ShouldBeEmpty)
+	testMu.Unlock()
+
+	md := make(metadata.MD)
+	md.Set("authorization", fmt.Sprintf("Bearer %s", authResp.AccessToken))
+	ctx := metadata.NewOutgoingContext(context.Background(), md)
+
+	_, err = client.Echo(ctx, &pb.EchoRequest{Message: "hello"})
+	test.That(t, err, test.ShouldBeNil)
+
+	testMu.Lock()
+	test.That(t, calls, test.ShouldResemble, []string{"first:foo", "second:foo", "third:foo"})
+	testMu.Unlock()
+
+	test.That(t, rpcServer.Stop(), test.ShouldBeNil)
+	err = <-errChan
+	test.That(t, err, test.ShouldBeNil)
+}
+
+func TestServerAuthStreamInterceptorsRunAfterAuth(t *testing.T) {
+	testutils.SkipUnlessInternet(t)
+	logger := golog.NewTestLogger(t)
+
+	var testMu sync.Mutex
+	var calls []string
+	makeInterceptor := func(name string) grpc.StreamServerInterceptor {
+		return func(
+			srv interface{},
+			serverStream grpc.ServerStream,
+			info *grpc.StreamServerInfo,
+			handler grpc.StreamHandler,
+		) error {
+			authEntity, err := contextAuthEntity(serverStream.Context())
+			if err != nil {
+				return err
+			}
+			testMu.Lock()
+			calls = append(calls, fmt.Sprintf("%s:%v", name, authEntity))
+			testMu.Unlock()
+			return handler(srv, serverStream)
+		}
+	}
+
+	rpcServer, err := NewServer(
+		logger,
+		WithAuthHandler("fake", MakeSimpleAuthHandler([]string{"foo"}, "something")),
+		WithStreamServerInterceptor(makeInterceptor("first")),
+		WithStreamServerInterceptors(makeInterceptor("second"), makeInterceptor("third")),
+	)
+	test.That(t, err, test.ShouldBeNil)
+
+	err = rpcServer.RegisterServiceServer(
+		context.Background(),
+		&pb.EchoService_ServiceDesc,
+		&echoserver.Server{},
+		pb.RegisterEchoServiceHandlerFromEndpoint,
+	)
+	test.That(t, err, test.ShouldBeNil)
+
+	httpListener, err := net.Listen("tcp", "localhost:0")
+	test.That(t, err, test.ShouldBeNil)
+
+	errChan := make(chan error)
+	go func() {
+		errChan <- rpcServer.Serve(httpListener)
+	}()
+
+	conn, err := grpc.DialContext(
+		context.Background(),
+		httpListener.Addr().String(),
+		grpc.WithTransportCredentials(insecure.NewCredentials()),
+		grpc.WithBlock(),
+	)
+	test.That(t, err, test.ShouldBeNil)
+	defer func() {
+		test.That(t, conn.Close(), test.ShouldBeNil)
+	}()
+	client := pb.NewEchoServiceClient(conn)
+
+	echoMultiple := func(ctx context.Context) error {
+		stream, err := client.EchoMultiple(ctx, &pb.EchoMultipleRequest{Message: "hi"})
+		if err != nil {
+			return err
+		}
+		for {
+			if _, err := stream.Recv(); err != nil {
+				if errors.Is(err, io.EOF) {
+					return nil
+				}
+				return err
+			}
+		}
+	}
+
+	// unauthenticated requests never reach the downstream interceptors
+	err = echoMultiple(context.Background())
+	test.That(t, err, test.ShouldNotBeNil)
+	gStatus, ok := status.FromError(err)
+	test.That(t, ok, test.ShouldBeTrue)
+	test.That(t, gStatus.Code(), test.ShouldEqual, codes.Unauthenticated)
+
+	testMu.Lock()
+	test.That(t, calls, test.ShouldBeEmpty)
+	testMu.Unlock()
+
+	authClient := rpcpb.NewAuthServiceClient(conn)
+	authResp, err := authClient.Authenticate(context.Background(), &rpcpb.AuthenticateRequest{Entity: "foo", Credentials: &rpcpb.Credentials{
+		Type:    "fake",
+		Payload: "something",
+	}})
+	test.That(t, err, test.ShouldBeNil)
+
+	md := make(metadata.MD)
+	md.Set("authorization", fmt.Sprintf("Bearer %s", authResp.AccessToken))
+	ctx := metadata.NewOutgoingContext(context.Background(), md)
+
+	test.That(t, echoMultiple(ctx), test.ShouldBeNil)
+
+	testMu.Lock()
+	test.That(t, calls, test.ShouldResemble, []string{"first:foo", "second:foo", "third:foo"})
+	calls = nil
+	testMu.Unlock()
+
+	// exempt methods skip the downstream interceptors
+	rpcServer.(*simpleServer).AddExemptMethod("/proto.rpc.examples.echo.v1.EchoService/EchoMultiple")
+	test.That(t, echoMultiple(context.Background()), test.ShouldBeNil)
+
+	testMu.Lock()
+	test.That(t, calls, test.ShouldBeEmpty)
+	testMu.Unlock()
+
+	test.That(t, rpcServer.Stop(), test.ShouldBeNil)
+	err = <-errChan
+	test.That(t, err, test.ShouldBeNil)
+}
+
+func TestAllowlistAuthMetadataRedactor(t *testing.T) {
+	redactor := AllowlistAuthMetadataRedactor("keep")
+
+	claims := &JWTClaims{
+		RegisteredClaims: jwt.RegisteredClaims{Audience: jwt.ClaimStrings{"someent"}},
+		CredentialsType:  "fake",
+		AuthMetadata:     map[string]string{"keep": "this", "secret": "value"},
+	}
+	redacted := redactor(claims)
+	test.That(t, redacted.GetAuthMetadata(), test.ShouldResemble, map[string]string{"keep": "this"})
+	test.That(t, redacted.GetCredentialsType(), test.ShouldEqual, CredentialsType("fake"))
+	entity, err := redacted.Entity()
+	test.That(t, err, test.ShouldBeNil)
+	test.That(t, entity, test.ShouldEqual, "someent")
+
+	// original is untouched
+	test.That(t, claims.AuthMetadata, test.ShouldResemble, map[string]string{"keep": "this", "secret": "value"})
+
+	multi := &JWTClaims{
+		AuthMetadataMulti: map[string][]string{"keep": {"a", "b"}, "secret": {"c"}},
+	}
+	redactedMulti, ok := redactor(multi).(MultiValueClaims)
+	test.That(t, ok, test.ShouldBeTrue)
+	test.That(t, redactedMulti.GetAuthMetadataMulti(), test.ShouldResemble, map[string][]string{"keep": {"a", "b"}})
+
+	custom := &customClaims{CustomClaim: "custom"}
+	test.That(t, redactor(custom), test.ShouldEqual, custom)
+}
+
+func TestServerAuthErrorMapper(t *testing.T) {
+	logger := golog.NewTestLogger(t)
+
+	errAccountLocked := errors.New("account locked")
+	errRateLimited := errors.New("rate limited")
+	ss := newAuthTestServer(t,
+		WithAuthHandler("fake", MakeFuncAuthHandler(func(ctx context.Context, entity, payload string) (map[string]string, error) {
+			switch payload {
+			case "locked":
+				return nil, errAccountLocked
+			case "limited":
+				return nil, errRateLimited
+			default:
+				return nil, errors.New("bad payload")
+			}
+		}, func(ctx context.Context, entity string) (interface{}, error) {
+			return entity, nil
+		})),
+		WithAuthErrorMapper(func(err error) error {
+			switch {
+			case errors.Is(err, errAccountLocked):
+				return status.Error(codes.FailedPrecondition, "account is locked")
+			case errors.Is(err, errRateLimited):
+				return errors.New("slow down")
+			default:
+				return nil
+			}
+		}),
+	)
+
+	authenticate := func(payload string) error {
+		_, err := ss.Authenticate(metadata.NewIncomingContext(context.Background(), metadata.MD{}), &rpcpb.AuthenticateRequest{
+			Entity:      "foo",
+			Credentials: &rpcpb.Credentials{Type: "fake", Payload: payload},
+		})
+		return err
+	}
+
+	err := authenticate("locked")
+	test.That(t, status.Code(err), test.ShouldEqual, codes.FailedPrecondition)
+	test.That(t, status.Convert(err).Message(), test.ShouldEqual, "account is locked")
+
+	err = authenticate("limited")
+	test.That(t, status.Code(err), test.ShouldEqual, codes.PermissionDenied)
+	test.That(t, status.Convert(err).Message(), test.ShouldEqual, "failed to authenticate: slow down")
+
+	err = authenticate("other")
+	test.That(t, status.Code(err), test.ShouldEqual, codes.PermissionDenied)
+	test.That(t, status.Convert(err).Message(), test.ShouldEqual, "failed to authenticate: bad payload")
+
+	_, err = NewServer(logger, WithAuthErrorMapper(nil))
+	test.That(t, err, test.ShouldNotBeNil)
+}
+
+func TestServerDebugClaimsLogging(t *testing.T) {
+	for _, enabled := range []bool{false, true} {
+		t.Run(fmt.Sprintf("enabled=%t", enabled), func(t *testing.T) {
+			logger, observedLogs := golog.NewObservedTestLogger(t)
+			ss := newAuthTestServerWithLogger(t, logger,
+				WithAuthHandler("fake", MakeSimpleAuthHandler([]string{"foo"}, "bar")),
+				WithAuthClaimsRedactor(func(claims Claims) Claims {
+					jwtClaims := *claims.(*JWTClaims)
+					jwtClaims.Audience = nil
+					return &jwtClaims
+				}),
+				WithDebugClaimsLogging(enabled),
+			)
+			warnings := observedLogs.FilterMessageSnippet("DEBUG CLAIMS LOGGING IS ENABLED").Len()
+			if enabled {
+				test.That(t, warnings, test.ShouldEqual, 1)
+			} else {
+				test.That(t, warnings, test.ShouldEqual, 0)
+			}
+
+			authResp, err := ss.Authenticate(metadata.NewIncomingContext(context.Background(), metadata.MD{}), &rpcpb.AuthenticateRequest{
+				Entity:      "foo",
+				Credentials: &rpcpb.Credentials{Type: "fake", Payload: "bar"},
+			})
+			test.That(t, err, test.ShouldBeNil)
+			ctx := metadata.NewIncomingContext(context.Background(), metadata.Pairs("authorization", "Bearer "+authResp.GetAccessToken()))
+			_, err = ss.ensureAuthed(ctx)
+			test.That(t, err, test.ShouldBeNil)
+
+			entries := observedLogs.FilterMessage("authenticated request").All()
+			if !enabled {
+				test.That(t, entries, test.ShouldBeEmpty)
+				return
+			}
+			test.That(t, entries, test.ShouldHaveLength, 1)
+			fields := entries[0].ContextMap()
+			test.That(t, fields["entity"], test.ShouldEqual, "foo")
+			claims, ok := fields["claims"].(*JWTClaims)
+			test.That(t, ok, test.ShouldBeTrue)
+			test.That(t, claims.CredentialsType, test.ShouldEqual, CredentialsType("fake"))
+			test.That(t, claims.Audience, test.ShouldBeNil)
+		})
+	}
+}
@@ -4,22 +4,30 @@ import (
 	"context"
 	"crypto/rsa"
 	"crypto/tls"
+	"crypto/x509"
 	"net"
+	"time"
 
 	"github.com/pion/webrtc/v3"
 	"github.com/pkg/errors"
 	"google.golang.org/grpc"
+	"google.golang.org/grpc/credentials"
 	"google.golang.org/grpc/stats"
 )
 
 // serverOptions change the runtime behavior of the server.
 type serverOptions struct {
-	bindAddress       string
-	listenerAddress   *net.TCPAddr
-	tlsConfig         *tls.Config
-	webrtcOpts        WebRTCServerOptions
-	unaryInterceptor  grpc.UnaryServerInterceptor
-	streamInterceptor grpc.StreamServerInterceptor
+	bindAddress     string
+	listenerAddress *net.TCPAddr
+	tlsConfig       *tls.Config
+	webrtcOpts      WebRTCServerOptions
+
+	// unaryInterceptor and streamInterceptor run after authentication, followed by
+	// unaryInterceptors and streamInterceptors in order.
+	unaryInterceptor   grpc.UnaryServerInterceptor
+	streamInterceptor  grpc.StreamServerInterceptor
+	unaryInterceptors  []grpc.UnaryServerInterceptor
+	streamInterceptors []grpc.StreamServerInterceptor
 
 	// instanceNames are the name of this server and will be used
 	// to report itself over mDNS.
@@ -31,17 +39,115 @@ type serverOptions struct {
 	// authRSAPrivateKey is used to sign JWTs for authentication
 	authRSAPrivateKey *rsa.PrivateKey
 
+	// authRSAMinKeyBits is the minimum size of authRSAPrivateKey. Zero means defaultAuthRSAMinKeyBits.
+	authRSAMinKeyBits int
+
+	// authMDMaxEntries and authMDMaxBytes limit the auth metadata of issued tokens. Zero means
+	// defaultAuthMDMaxEntries and defaultAuthMDMaxBytes.
+	authMDMaxEntries int
+	authMDMaxBytes   int
+
+	// reservedAuthMDPolicy is what to do with auth metadata keys named like claims. Empty means
+	// ReservedAuthMetadataAllow.
+	reservedAuthMDPolicy ReservedAuthMetadataPolicy
+
+	// authMDCodec, if set, encodes the auth metadata of issued tokens.
+	authMDCodec AuthMetadataCodec
+
+	// authRSAVerificationKeys are additional keys accepted for internally signed tokens.
+	authRSAVerificationKeys []*rsa.PublicKey
+
+	// requiredClaims are claims that every token must have.
+	requiredClaims []string
+
+	// entityFallbackClaim is the claim used as the entity of tokens without an audience.
+	entityFallbackClaim string
+
+	// tokenAudiences are added after the entity to the audience of issued tokens.
+	tokenAudiences []string
+
+	// acceptedAudiences, if set, are the audiences of which every token must have at least one.
+	acceptedAudiences []string
+
 	// debug is helpful to turn on when the library isn't working quite right.
 	// It will output much more logs.
 	debug bool
 
-	tlsAuthHandler func(ctx context.Context, entities ...string) (interface{}, error)
-	authHandlers   map[CredentialsType]AuthHandler
+	tlsAuthHandler   func(ctx context.Context, entities ...string) (interface{}, map[string]string, error)
+	tlsInfoExtractor func(authInfo credentials.AuthInfo) (*x509.Certificate, bool)
+	tokenExtractor   TokenExtractor
+	authHandlers     map[CredentialsType]AuthHandler
+
+	// unixSocketAuthEntity, if set, is the auth entity of every request from a unix socket peer.
+	unixSocketAuthEntity interface{}
+
+	// fallbackAuthHandler, if set, provides handlers for credential types without one registered.
+	fallbackAuthHandler func(forType CredentialsType) (AuthHandler, error)
 
 	authToType    CredentialsType
 	authToHandler AuthenticateToHandler
 	disableMDNS   bool
 
+	// authenticateToExempt determines if AuthenticateTo is exempt from authentication.
+	authenticateToExempt bool
+
+	// tokenStore, if set, is consulted by Authenticate before signing a new token.
+	tokenStore TokenStore
+
+	// authMetrics determines if auth related metrics are recorded.
+	authMetrics bool
+
+	// methodLatencyMetrics determines if unary handler latencies are recorded per method.
+	methodLatencyMetrics bool
+
+	// messageSizeMetrics determines if request and response message sizes are recorded per method.
+	messageSizeMetrics bool
+
+	// authErrorMapper maps errors from AuthHandler.Authenticate to client facing errors.
+	authErrorMapper func(err error) error
+
+	// authResponseHook, if set, is applied to the access token Authenticate and AuthenticateTo respond with.
+	authResponseHook func(ctx context.Context, entity, accessToken string) (string, error)
+
+	// proofOfPossessionSkew, if set, binds tokens to client keys (see WithProofOfPossession).
+	proofOfPossessionSkew time.Duration
+
+	// tlsTokenBinding determines if tokens must match the client certificate they are sent with.
+	tlsTokenBinding bool
+
+	// peerTokenBinding determines if issued tokens are bound to the peer they are issued to.
+	peerTokenBinding bool
+
+	// expiryGracePeriod, if set, is how long after expiring tokens are still accepted.
+	expiryGracePeriod time.Duration
+
+	// recentAuthMethods maps methods to the maximum age of tokens they accept.
+	recentAuthMethods map[string]time.Duration
+
+	// maxTokenAge, if set, is the maximum age of tokens accepted by any method.
+	maxTokenAge time.Duration
+
+	// issuedAtTolerance, if set, is how far in the future the "iat" of accepted tokens may be.
+	issuedAtTolerance time.Duration
+
+	// authPolicy, if set, authorizes authenticated requests (see WithAuthPolicy).
+	authPolicy *AuthPolicy
+
+	// perEntityConcurrency, if set, is the most in-flight requests each auth entity may have.
+	perEntityConcurrency int
+
+	// authFailureTrailers determines if rejected requests get a trailer describing why.
+	authFailureTrailers bool
+
+	// authFailureDetails determines if rejected requests get status details describing why.
+	authFailureDetails bool
+
+	// authClaimsRedactor is applied to validated claims before they are put in the context.
+	authClaimsRedactor func(claims Claims) Claims
+
+	// debugClaimsLogging determines if the claims of authenticated requests are logged.
+	debugClaimsLogging bool
+
 	// stats monitoring on the connections.
 	statsHandler stats.Handler
 
@@ -168,6 +274,25 @@ func WithUnaryServerInterceptor(unaryInterceptor grpc.UnaryServerInterceptor) Se
 	})
 }
 
+// WithUnaryServerInterceptors returns a ServerOption that adds a chain of interceptors
+// for all unary grpc methods registered. They run, in order, after the interceptor set
+// by WithUnaryServerInterceptor and prior to the registered method. When authentication
+// is enabled, they always run after the auth interceptor and can rely on the authenticated
+// context (see MustContextAuthEntity); methods exempt from authentication skip them. When
+// the server is unauthenticated (see WithUnauthenticated), there is no auth entity in
+// the context.
+func WithUnaryServerInterceptors(unaryInterceptors ...grpc.UnaryServerInterceptor) ServerOption {
+	return newFuncServerOption(func(o *serverOptions) error {
+		for _, interceptor := range unaryInterceptors {
+			if interceptor == nil {
+				return errors.New("unary interceptor cannot be nil")
+			}
+		}
+		o.unaryInterceptors = append(o.unaryInterceptors, unaryInterceptors...)
+		return nil
+	})
+}
+
 // WithStreamServerInterceptor returns a ServerOption that sets a interceptor for
 // all stream grpc methods registered. It will run after authentication and prior
 // to the registered method.
@@ -178,6 +303,25 @@ func WithStreamServerInterceptor(streamInterceptor grpc.StreamServerInterceptor)
 	})
 }
 
+// WithStreamServerInterceptors returns a ServerOption that adds a chain of interceptors
+// for all stream grpc methods registered. They run, in order, after the interceptor set
+// by WithStreamServerInterceptor and prior to the registered method. When authentication
+// is enabled, they always run after the auth interceptor and can rely on the authenticated
+// context (see MustContextAuthEntity); methods exempt from authentication skip them. When
+// the server is unauthenticated (see WithUnauthenticated), there is no auth entity in
+// the context.
+func WithStreamServerInterceptors(streamInterceptors ...grpc.StreamServerInterceptor) ServerOption {
+	return newFuncServerOption(func(o *serverOptions) error {
+		for _, interceptor := range streamInterceptors {
+			if interceptor == nil {
+				return errors.New("stream interceptor cannot be nil")
+			}
+		}
+		o.streamInterceptors = append(o.streamInterceptors, streamInterceptors...)
+		return nil
+	})
+}
+
 // WithInstanceNames returns a ServerOption which sets the names for this
 // server instance. These names will be used for mDNS service discovery to
 // report the server itself. If unset the value is the address set by
@@ -208,6 +352,82 @@ func WithAuthRSAPrivateKey(authRSAPrivateKey *rsa.PrivateKey) ServerOption {
 	})
 }
 
+// WithAuthRSAVerificationKeys returns a ServerOption which adds public keys that internally
+// signed tokens are also verified against, which allows rotating the auth RSA private key
+// without invalidating tokens that were already issued, even those without a kid header.
+// A token is first verified against the public key of the server's own private key and then
+// against each of these keys in the order given; it is rejected if none of them verify it.
+// New tokens are always signed with the server's own private key.
+func WithAuthRSAVerificationKeys(keys ...*rsa.PublicKey) ServerOption {
+	return newFuncServerOption(func(o *serverOptions) error {
+		for _, key := range keys {
+			if key == nil {
+				return errors.New("auth RSA verification key cannot be nil")
+			}
+		}
+		o.authRSAVerificationKeys = append(o.authRSAVerificationKeys, keys...)
+		return nil
+	})
+}
+
+// WithAuthRSAMinimumKeyBits returns a ServerOption which sets the minimum size, in bits, that
+// the private key set by WithAuthRSAPrivateKey must have. It defaults to 2048 bits.
+func WithAuthRSAMinimumKeyBits(bits int) ServerOption {
+	return newFuncServerOption(func(o *serverOptions) error {
+		if bits <= 0 {
+			return errors.New("minimum key bits must be positive")
+		}
+		o.authRSAMinKeyBits = bits
+		return nil
+	})
+}
+
+// WithAuthMetadataLimits returns a ServerOption which limits the auth metadata an AuthHandler
+// may return to maxEntries keys and maxBytes total bytes of keys and values, in order to bound
+// the size of issued tokens. Authenticate fails when a handler exceeds them. They default to
+// 64 keys and 4096 bytes.
+func WithAuthMetadataLimits(maxEntries, maxBytes int) ServerOption {
+	return newFuncServerOption(func(o *serverOptions) error {
+		if maxEntries <= 0 || maxBytes <= 0 {
+			return errors.New("auth metadata limits must be positive")
+		}
+		o.authMDMaxEntries = maxEntries
+		o.authMDMaxBytes = maxBytes
+		return nil
+	})
+}
+
+// WithReservedAuthMetadataPolicy returns a ServerOption which sets what Authenticate does when an
+// AuthHandler returns auth metadata keys named like a claim of the tokens it issues, such as
+// "exp" or "aud". Auth metadata is nested under its own claim so such keys never override the
+// claims of the token; the default, ReservedAuthMetadataAllow, issues the token as is. Warning
+// or rejecting guards against consumers that flatten auth metadata into the claims.
+func WithReservedAuthMetadataPolicy(policy ReservedAuthMetadataPolicy) ServerOption {
+	return newFuncServerOption(func(o *serverOptions) error {
+		switch policy {
+		case ReservedAuthMetadataAllow, ReservedAuthMetadataWarn, ReservedAuthMetadataReject:
+		default:
+			return errors.Errorf("unknown reserved auth metadata policy %q", policy)
+		}
+		o.reservedAuthMDPolicy = policy
+		return nil
+	})
+}
+
+// WithAuthMetadataCodec returns a ServerOption which encodes the auth metadata of the tokens the
+// server issues with codec, such as DeflateAuthMetadataCodec, to reduce their size. The server
+// decodes auth metadata encoded with the codec when verifying tokens, so every server verifying
+// the tokens must use the same codec. Auth metadata is plain JSON by default for interoperability.
+func WithAuthMetadataCodec(codec AuthMetadataCodec) ServerOption {
+	return newFuncServerOption(func(o *serverOptions) error {
+		if codec == nil {
+			return errors.New("auth metadata codec cannot be nil")
+		}
+		o.authMDCodec = codec
+		return nil
+	})
+}
+
 // WithDebug returns a ServerOption which informs the server to be in a
 // debug mode as much as possible.
 func WithDebug() ServerOption {
@@ -217,20 +437,161 @@ func WithDebug() ServerOption {
 	})
 }
 
+// WithTLSTokenBinding returns a ServerOption which binds bearer tokens to the mTLS identity they
+// are presented with. When a request carries both a token and a verified client certificate, the
+// token's entity must be one of the certificate's DNS SANs or the request is rejected. This keeps a
+// stolen token from being used by a client with a different certificate. Requests without a
+// client certificate are unaffected.
+func WithTLSTokenBinding() ServerOption {
+	return newFuncServerOption(func(o *serverOptions) error {
+		o.tlsTokenBinding = true
+		return nil
+	})
+}
+
+// WithPeerTokenBinding returns a ServerOption which binds the tokens the server issues to the
+// peer that requested them: the fingerprint of its verified client certificate if it has one or
+// else its IP address. Bound tokens presented by any other peer are rejected. This is a lighter
+// weight alternative to WithProofOfPossession for clients that do not move between addresses;
+// clients behind changing NATs or load balancers that do not preserve addresses will need to
+// re-authenticate. Tokens issued elsewhere or before enabling it are unaffected.
+func WithPeerTokenBinding() ServerOption {
+	return newFuncServerOption(func(o *serverOptions) error {
+		o.peerTokenBinding = true
+		return nil
+	})
+}
+
+// WithExpiryGracePeriod returns a ServerOption which accepts tokens that expired less than d ago
+// instead of rejecting them. Such requests are logged, counted when WithAuthMetrics is set, and
+// get a MetadataFieldTokenExpiredGrace trailer so that clients can tell they need to refresh.
+// This is a safety valve for rolling out token expiry to clients that do not refresh yet.
+func WithExpiryGracePeriod(d time.Duration) ServerOption {
+	return newFuncServerOption(func(o *serverOptions) error {
+		if d <= 0 {
+			return errors.New("expiry grace period must be positive")
+		}
+		o.expiryGracePeriod = d
+		return nil
+	})
+}
+
+// WithMaxTokenAge returns a ServerOption which rejects tokens issued (per their "iat" claim) d or
+// more ago, regardless of when they expire, as well as tokens without an "iat" claim. This lets
+// operators invalidate old tokens after a policy change. Rejected requests fail as Unauthenticated
+// with AuthFailureTokenExpired and are not subject to WithExpiryGracePeriod.
+func WithMaxTokenAge(d time.Duration) ServerOption {
+	return newFuncServerOption(func(o *serverOptions) error {
+		if d <= 0 {
+			return errors.New("max token age must be positive")
+		}
+		o.maxTokenAge = d
+		return nil
+	})
+}
+
+// WithIssuedAtTolerance returns a ServerOption which sets how far in the future the "iat" claim
+// of a token may be, to allow for clock differences with its issuer, independent of any "nbf"
+// claim. Tokens issued further in the future, such as by clients with badly set clocks, are
+// rejected as Unauthenticated with AuthFailureInvalidClaims. It defaults to 30 seconds.
+func WithIssuedAtTolerance(d time.Duration) ServerOption {
+	return newFuncServerOption(func(o *serverOptions) error {
+		if d <= 0 {
+			return errors.New("issued at tolerance must be positive")
+		}
+		o.issuedAtTolerance = d
+		return nil
+	})
+}
+
+// WithAuthPolicy returns a ServerOption which authorizes every authenticated request with policy
+// after any RequestAwareVerifier, rejecting those it denies as PermissionDenied with
+// AuthFailureRequestRejected. Reloading the policy applies to subsequent requests. Methods exempt
+// from authentication are not authorized.
+func WithAuthPolicy(policy *AuthPolicy) ServerOption {
+	return newFuncServerOption(func(o *serverOptions) error {
+		if policy == nil {
+			return errors.New("auth policy cannot be nil")
+		}
+		o.authPolicy = policy
+		return nil
+	})
+}
+
+// WithRecentAuthRequired returns a ServerOption which requires the tokens of requests to the given
+// methods to have been issued (per their "iat" claim) less than maxAge ago, no matter when they
+// expire. This is for sensitive operations that warrant a fresh presentation of credentials. Other
+// requests, including those authenticated without a token (e.g. via TLS) and those with tokens
+// lacking an "iat" claim, are rejected as Unauthenticated with AuthFailureReauthenticationRequired
+// (see WithAuthFailureDetails) so that clients know to call Authenticate again before retrying.
+// Methods are full gRPC method names.
+func WithRecentAuthRequired(maxAge time.Duration, methods ...string) ServerOption {
+	return newFuncServerOption(func(o *serverOptions) error {
+		if maxAge <= 0 {
+			return errors.New("recent auth max age must be positive")
+		}
+		if len(methods) == 0 {
+			return errors.New("recent auth requires at least one method")
+		}
+		if o.recentAuthMethods == nil {
+			o.recentAuthMethods = make(map[string]time.Duration, len(methods))
+		}
+		for _, method := range methods {
+			if method == "" {
+				return errors.New("recent auth method cannot be empty")
+			}
+			o.recentAuthMethods[method] = maxAge
+		}
+		return nil
+	})
+}
+
+// WithPerEntityConcurrencyLimit returns a ServerOption which limits each authenticated entity to
+// n concurrent in-flight requests so that a single tenant cannot monopolize the server. Requests
+// beyond the limit are rejected with ResourceExhausted. A stream holds its slot until it ends.
+// Entities are told apart by their string form. Exempt methods are not limited and it has no
+// effect on unauthenticated servers.
+func WithPerEntityConcurrencyLimit(n int) ServerOption {
+	return newFuncServerOption(func(o *serverOptions) error {
+		if n <= 0 {
+			return errors.New("per entity concurrency limit must be positive")
+		}
+		o.perEntityConcurrency = n
+		return nil
+	})
+}
+
 // WithTLSAuthHandler returns a ServerOption which when TLS info is available to a connection, it will
 // authenticate the given entities in the event that no other authentication has been established via
 // the standard auth handler. Optionally, verifyEntity may be specified which can do further entity
 // checking and return return opaque info about the entity that will be bound to the context accessible
 // via ContextAuthEntity.
 func WithTLSAuthHandler(entities []string, verifyEntity func(ctx context.Context, entities ...string) (interface{}, error)) ServerOption {
+	if verifyEntity == nil {
+		return WithTLSAuthMetadataHandler(entities, nil)
+	}
+	return WithTLSAuthMetadataHandler(entities, func(ctx context.Context, entities ...string) (interface{}, map[string]string, error) {
+		entity, err := verifyEntity(ctx, entities...)
+		return entity, nil, err
+	})
+}
+
+// WithTLSAuthMetadataHandler returns a ServerOption like WithTLSAuthHandler whose verifyEntity
+// can also return auth metadata for the entity. The metadata is bound to the context accessible
+// via ContextAuthMetadata, like that of a token, and is included in the tokens the entity
+// obtains via AuthenticateTo, where the metadata of the AuthenticateToHandler takes precedence.
+func WithTLSAuthMetadataHandler(
+	entities []string,
+	verifyEntity func(ctx context.Context, entities ...string) (interface{}, map[string]string, error),
+) ServerOption {
 	return newFuncServerOption(func(o *serverOptions) error {
 		entityChecker := MakeEntitiesChecker(entities)
-		o.tlsAuthHandler = func(ctx context.Context, recvEntities ...string) (interface{}, error) {
+		o.tlsAuthHandler = func(ctx context.Context, recvEntities ...string) (interface{}, map[string]string, error) {
 			if err := entityChecker(ctx, recvEntities...); err != nil {
-				return nil, errNotTLSAuthed
+				return nil, nil, errNotTLSAuthed
 			}
 			if verifyEntity == nil {
-				return recvEntities, nil
+				return recvEntities, nil, nil
 			}
 			return verifyEntity(ctx, recvEntities...)
 		}
@@ -238,6 +599,50 @@ func WithTLSAuthHandler(entities []string, verifyEntity func(ctx context.Context
 	})
 }
 
+// WithUnixSocketAuthEntity returns a ServerOption which trusts requests from peers connected over
+// a unix socket, such as sidecars, as the given entity without verifying any credentials. The
+// entity is bound to the context accessible via MustContextAuthEntity. Only unix socket peers
+// are trusted, never loopback TCP peers: the gRPC gateway proxies external requests to the
+// server over loopback and any local process can connect to it. It is only for grpc.Servers
+// listening on a unix socket, those using NewAuthInterceptors or from NewAuthenticatedServer;
+// NewServer listens on TCP and fails if it is given.
+func WithUnixSocketAuthEntity(entity interface{}) ServerOption {
+	return newFuncServerOption(func(o *serverOptions) error {
+		if entity == nil {
+			return errors.New("unix socket auth entity cannot be nil")
+		}
+		o.unixSocketAuthEntity = entity
+		return nil
+	})
+}
+
+// WithTLSInfoExtractor returns a ServerOption which sets how the verified client certificate
+// used by TLS authentication (see WithTLSAuthHandler) is extracted from a peer's AuthInfo. This
+// is useful for custom transport credentials that wrap TLS differently. By default, only
+// credentials.TLSInfo is understood.
+func WithTLSInfoExtractor(extractor func(authInfo credentials.AuthInfo) (*x509.Certificate, bool)) ServerOption {
+	return newFuncServerOption(func(o *serverOptions) error {
+		if extractor == nil {
+			return errors.New("TLS info extractor cannot be nil")
+		}
+		o.tlsInfoExtractor = extractor
+		return nil
+	})
+}
+
+// WithTokenExtractor returns a ServerOption which sets a TokenExtractor that is consulted for
+// the access token of a request before the authorization metadata. Requests for which it finds
+// no token fall back to the authorization metadata.
+func WithTokenExtractor(extractor TokenExtractor) ServerOption {
+	return newFuncServerOption(func(o *serverOptions) error {
+		if extractor == nil {
+			return errors.New("token extractor cannot be nil")
+		}
+		o.tokenExtractor = extractor
+		return nil
+	})
+}
+
 // WithAuthHandler returns a ServerOption which adds an auth handler associated
 // to the given type to use for authentication requests.
 func WithAuthHandler(forType CredentialsType, handler AuthHandler) ServerOption {
@@ -260,6 +665,22 @@ func WithAuthHandler(forType CredentialsType, handler AuthHandler) ServerOption
 	})
 }
 
+// WithFallbackAuthHandler returns a ServerOption which sets a catch-all used for credential types
+// that have no AuthHandler registered with WithAuthHandler, such as by a gateway with a generic
+// verification path. The fallback is called with the original credential type of every Authenticate
+// call and token that has no registered handler, and returns the AuthHandler to use for it or an
+// error to reject it. Since it is called for every such request, it should be cheap. Registered
+// handlers, including case insensitive matches of their types, always take precedence.
+func WithFallbackAuthHandler(fallback func(forType CredentialsType) (AuthHandler, error)) ServerOption {
+	return newFuncServerOption(func(o *serverOptions) error {
+		if fallback == nil {
+			return errors.New("fallback auth handler cannot be nil")
+		}
+		o.fallbackAuthHandler = fallback
+		return nil
+	})
+}
+
 // WithAuthenticateToHandler returns a ServerOption which adds an authentication
 // handler designed to allow the caller to authenticate itself to some other entity.
 // This is useful when externally authenticating as one entity for the purpose of
@@ -282,6 +703,19 @@ func WithAuthenticateToHandler(forType CredentialsType, handler AuthenticateToHa
 	})
 }
 
+// WithAuthenticateToExempt returns a ServerOption which exempts AuthenticateTo from
+// authentication, like Authenticate always is, so that callers without a token can use it. By
+// default AuthenticateTo requires an existing token since the AuthenticateToHandler decides
+// based on the calling entity; when exempt, MustContextAuthEntity panics in the handler and it
+// must authenticate the caller by other means, such as the incoming metadata. It requires
+// WithAuthenticateToHandler.
+func WithAuthenticateToExempt() ServerOption {
+	return newFuncServerOption(func(o *serverOptions) error {
+		o.authenticateToExempt = true
+		return nil
+	})
+}
+
 // WithDisableMulticastDNS returns a ServerOption which disables
 // using mDNS to broadcast how to connect to this host.
 func WithDisableMulticastDNS() ServerOption {
@@ -319,3 +753,196 @@ func WithStatsHandler(handler stats.Handler) ServerOption {
 		return nil
 	})
 }
+
+// WithAuthClaimsRedactor returns a ServerOption which sets a function applied to the validated
+// claims of a request before they and their auth metadata are bound to the context (see
+// ContextAuthClaims and ContextAuthMetadata). It can be used to drop sensitive or large claims
+// so they are not retained for the life of the call. The redactor must not return nil.
+func WithAuthClaimsRedactor(redactor func(claims Claims) Claims) ServerOption {
+	return newFuncServerOption(func(o *serverOptions) error {
+		o.authClaimsRedactor = redactor
+		return nil
+	})
+}
+
+// WithAuthMetrics returns a ServerOption which turns on recording of auth related
+// metrics (see the rpc/auth/ statz metrics).
+func WithAuthMetrics() ServerOption {
+	return newFuncServerOption(func(o *serverOptions) error {
+		o.authMetrics = true
+		return nil
+	})
+}
+
+// WithMethodLatencyMetrics returns a ServerOption which records the duration of unary handler calls
+// by method and status code from the auth interceptor (see the rpc/auth/handler_latency statz metric).
+// Requests rejected by authentication are not recorded. It has no effect on unauthenticated servers.
+func WithMethodLatencyMetrics() ServerOption {
+	return newFuncServerOption(func(o *serverOptions) error {
+		o.methodLatencyMetrics = true
+		return nil
+	})
+}
+
+// WithMessageSizeMetrics returns a ServerOption which records the serialized size of the request and
+// response messages of calls by method from the auth interceptor (see the rpc/auth/request_size and
+// rpc/auth/response_size statz metrics). Requests rejected by authentication and messages that are
+// not protobufs are not recorded. It has no effect on unauthenticated servers.
+func WithMessageSizeMetrics() ServerOption {
+	return newFuncServerOption(func(o *serverOptions) error {
+		o.messageSizeMetrics = true
+		return nil
+	})
+}
+
+// WithAuthFailureTrailers returns a ServerOption which sets the auth-failure-reason trailer
+// (see MetadataFieldAuthFailureReason) on requests rejected by authentication with the class
+// of failure, such as missing_credentials or invalid_token. The status returned is unchanged.
+// This is off by default since it tells unauthenticated callers more about why they failed.
+func WithAuthFailureTrailers() ServerOption {
+	return newFuncServerOption(func(o *serverOptions) error {
+		o.authFailureTrailers = true
+		return nil
+	})
+}
+
+// WithAuthFailureDetails returns a ServerOption which attaches an errdetails.ErrorInfo describing
+// the class of failure to the status of requests rejected by authentication. Clients can decode
+// it with AuthFailureReasonFromError. Like WithAuthFailureTrailers, this is off by default.
+func WithAuthFailureDetails() ServerOption {
+	return newFuncServerOption(func(o *serverOptions) error {
+		o.authFailureDetails = true
+		return nil
+	})
+}
+
+// WithDebugClaimsLogging returns a ServerOption which, when enabled, logs the validated claims of
+// every authenticated request at debug level after WithAuthClaimsRedactor is applied. This is
+// meant for local debugging only and must never be enabled in production.
+func WithDebugClaimsLogging(enabled bool) ServerOption {
+	return newFuncServerOption(func(o *serverOptions) error {
+		o.debugClaimsLogging = enabled
+		return nil
+	})
+}
+
+// WithProofOfPossession returns a ServerOption which lets clients bind the tokens issued by
+// Authenticate to an ed25519 key they hold, so that a stolen token cannot be used without the key.
+// A client opts in by setting MetadataFieldProofOfPossessionKey on Authenticate and then must send
+// MetadataFieldProofOfPossession (see SignProofOfPossession) on every request made with the token.
+// Proofs older or further in the future than maxSkew are rejected. Tokens issued without a key
+// are unaffected.
+func WithProofOfPossession(maxSkew time.Duration) ServerOption {
+	return newFuncServerOption(func(o *serverOptions) error {
+		if maxSkew <= 0 {
+			return errors.New("proof of possession max skew must be positive")
+		}
+		o.proofOfPossessionSkew = maxSkew
+		return nil
+	})
+}
+
+// WithTokenStore returns a ServerOption which sets a TokenStore that Authenticate consults
+// after verifying credentials in order to return a previously issued token instead of signing
+// a new one. Stored tokens older than WithMaxTokenAge or the shortest WithRecentAuthRequired
+// max age are not returned; a new token is signed and stored in their place.
+func WithTokenStore(store TokenStore) ServerOption {
+	return newFuncServerOption(func(o *serverOptions) error {
+		if store == nil {
+			return errors.New("token store cannot be nil")
+		}
+		o.tokenStore = store
+		return nil
+	})
+}
+
+// WithRequiredClaims returns a ServerOption which requires every JWT presented to the server to
+// have the given claims, including custom ones (e.g. "tenant"). Tokens missing any of them are
+// rejected as Unauthenticated. Only the presence of the claims is checked.
+func WithRequiredClaims(keys ...string) ServerOption {
+	return newFuncServerOption(func(o *serverOptions) error {
+		for _, key := range keys {
+			if key == "" {
+				return errors.New("required claim cannot be empty")
+			}
+		}
+		o.requiredClaims = append(o.requiredClaims, keys...)
+		return nil
+	})
+}
+
+// WithEntityFallbackClaim returns a ServerOption which makes tokens whose claims have no entity
+// (e.g. no audience) use the given string claim as the entity instead. This allows accepting
+// standard OIDC ID tokens, which use "sub" as the principal. The audience is still preferred
+// when present.
+func WithEntityFallbackClaim(claim string) ServerOption {
+	return newFuncServerOption(func(o *serverOptions) error {
+		if claim == "" {
+			return errors.New("entity fallback claim cannot be empty")
+		}
+		o.entityFallbackClaim = claim
+		return nil
+	})
+}
+
+// WithTokenAudiences returns a ServerOption which adds the given audiences, such as the names of
+// a cluster of services, to the audience of the tokens the server issues. The entity stays the
+// first audience, so JWTClaims.Entity is unchanged. Together with WithAcceptedAudiences this
+// allows a single token to be accepted by each of those services.
+func WithTokenAudiences(audiences ...string) ServerOption {
+	return newFuncServerOption(func(o *serverOptions) error {
+		for _, aud := range audiences {
+			if aud == "" {
+				return errors.New("token audience cannot be empty")
+			}
+		}
+		o.tokenAudiences = append(o.tokenAudiences, audiences...)
+		return nil
+	})
+}
+
+// WithAcceptedAudiences returns a ServerOption which requires every JWT presented to the server
+// to have at least one of the given audiences. Tokens with none of them, e.g. ones issued only
+// for other services, are rejected as Unauthenticated.
+func WithAcceptedAudiences(audiences ...string) ServerOption {
+	return newFuncServerOption(func(o *serverOptions) error {
+		for _, aud := range audiences {
+			if aud == "" {
+				return errors.New("accepted audience cannot be empty")
+			}
+		}
+		o.acceptedAudiences = append(o.acceptedAudiences, audiences...)
+		return nil
+	})
+}
+
+// WithAuthenticateResponseHook returns a ServerOption which sets a hook called just before
+// Authenticate and AuthenticateTo respond, with the authenticated entity and the access token
+// being returned. The hook returns the access token to respond with instead, e.g. a wrapped form
+// of it, and can use ctx to send the client extra headers such as a cookie or server metadata. It
+// must not alter the token in a way the server would no longer accept it. Errors returned by the
+// hook fail the call and should be status errors. Tokens kept by WithTokenStore are unaffected.
+func WithAuthenticateResponseHook(hook func(ctx context.Context, entity, accessToken string) (string, error)) ServerOption {
+	return newFuncServerOption(func(o *serverOptions) error {
+		if hook == nil {
+			return errors.New("authenticate response hook cannot be nil")
+		}
+		o.authResponseHook = hook
+		return nil
+	})
+}
+
+// WithAuthErrorMapper returns a ServerOption which sets a function that maps errors returned by
+// AuthHandler.Authenticate to the error returned to the client, e.g. an account locked error to
+// a FailedPrecondition status. A status error returned by the mapper is returned as is; any
+// other error, or the original one if the mapper returns nil, is returned as PermissionDenied
+// unless it is already a status error.
+func WithAuthErrorMapper(mapper func(err error) error) ServerOption {
+	return newFuncServerOption(func(o *serverOptions) error {
+		if mapper == nil {
+			return errors.New("auth error mapper cannot be nil")
+		}
+		o.authErrorMapper = mapper
+		return nil
+	})
+}
@@ -1,10 +1,14 @@
 package rpc
 
 import (
+	"crypto/rand"
+	"crypto/rsa"
 	"testing"
 
+	"github.com/edaniels/golog"
 	"go.uber.org/multierr"
 	"go.viam.com/test"
+	"google.golang.org/grpc"
 )
 
 func TestWithAuthHandler(t *testing.T) {
@@ -69,3 +73,57 @@ func TestWithAuthToHandler(t *testing.T) {
 	test.That(t, err, test.ShouldNotBeNil)
 	test.That(t, err.Error(), test.ShouldContainSubstring, "empty")
 }
+
+func TestWithServerInterceptors(t *testing.T) {
+	var unaryInterceptor grpc.UnaryServerInterceptor
+	var streamInterceptor grpc.StreamServerInterceptor
+
+	for _, tc := range []struct {
+		name     string
+		opt      ServerOption
+		errorMsg string
+	}{
+		{"nil unary", WithUnaryServerInterceptor(nil), ""},
+		{"nil stream", WithStreamServerInterceptor(nil), ""},
+		{"nil unary chain", WithUnaryServerInterceptors(unaryInterceptor), "unary interceptor cannot be nil"},
+		{"nil stream chain", WithStreamServerInterceptors(streamInterceptor), "stream interceptor cannot be nil"},
+		{"empty unary chain", WithUnaryServerInterceptors(), ""},
+		{"empty stream chain", WithStreamServerInterceptors(), ""},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			logger := golog.NewTestLogger(t)
+			rpcServer, err := NewServer(logger, WithUnauthenticated(), tc.opt)
+			if tc.errorMsg == "" {
+				test.That(t, err, test.ShouldBeNil)
+				test.That(t, rpcServer.Stop(), test.ShouldBeNil)
+				return
+			}
+			test.That(t, err, test.ShouldNotBeNil)
+			test.That(t, err.Error(), test.ShouldContainSubstring, tc.errorMsg)
+		})
+	}
+}
+
+func TestWithAuthRSAMinimumKeyBits(t *testing.T) {
+	logger := golog.NewTestLogger(t)
+
+	smallKey, err := rsa.GenerateKey(rand.Reader, 1024)
+	test.That(t, err, test.ShouldBeNil)
+
+	_, err = NewServer(logger, WithAuthRSAPrivateKey(smallKey))
+	test.That(t, err, test.ShouldNotBeNil)
+	test.That(t, err.Error(), test.ShouldContainSubstring, "must be at least 2048 bits")
+
+	rpcServer, err := NewServer(logger, WithAuthRSAPrivateKey(smallKey), WithAuthRSAMinimumKeyBits(1024))
+	test.That(t, err, test.ShouldBeNil)
+	test.That(t, rpcServer.Stop(), test.ShouldBeNil)
+
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	test.That(t, err, test.ShouldBeNil)
+	_, err = NewServer(logger, WithAuthRSAPrivateKey(key), WithAuthRSAMinimumKeyBits(3072))
+	test.That(t, err, test.ShouldNotBeNil)
+	test.That(t, err.Error(), test.ShouldContainSubstring, "must be at least 3072 bits")
+
+	_, err = NewServer(logger, WithAuthRSAMinimumKeyBits(0))
+	test.That(t, err, test.ShouldNotBeNil)
+}
@@ -0,0 +1,146 @@
+package rpc
+
+import (
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// A TokenStore holds access tokens issued by Authenticate so that repeated Authenticate calls
+// for the same entity, credential type, and auth metadata can return a previously issued token
+// instead of signing a new one. Credentials are always verified before the store is consulted.
+type TokenStore interface {
+	// Get returns the token stored for key if it is still valid.
+	Get(key TokenStoreKey) (string, bool)
+
+	// Put stores token for key.
+	Put(key TokenStoreKey, token string)
+
+	// InvalidateEntity removes all tokens issued to entity so that the next Authenticate
+	// signs a new one.
+	InvalidateEntity(entity string)
+}
+
+// A TokenStoreKey identifies what an access token was issued for.
+type TokenStoreKey struct {
+	CredentialsType CredentialsType
+	Entity          string
+	authMetadata    string
+	proofKey        string
+	peerIdentity    string
+}
+
+func newTokenStoreKey(
+	forType CredentialsType,
+	entity string,
+	authMD map[string]string,
+	authMDMulti map[string][]string,
+) TokenStoreKey {
+	keys := make([]string, 0, len(authMD))
+	for k := range authMD {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	var md strings.Builder
+	for _, k := range keys {
+		md.WriteString(k)
+		md.WriteByte(0)
+		md.WriteString(authMD[k])
+		md.WriteByte(0)
+	}
+
+	keys = keys[:0]
+	for k := range authMDMulti {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	for _, k := range keys {
+		md.WriteByte(1)
+		md.WriteString(k)
+		for _, v := range authMDMulti[k] {
+			md.WriteByte(0)
+			md.WriteString(v)
+		}
+		md.WriteByte(1)
+	}
+	return TokenStoreKey{CredentialsType: forType, Entity: entity, authMetadata: md.String()}
+}
+
+type storedToken struct {
+	token    string
+	storedAt time.Time
+}
+
+// memoryTokenStoreMinSweep is the fewest tokens a MemoryTokenStore holds before sweeping out
+// expired ones.
+const memoryTokenStoreMinSweep = 64
+
+// MemoryTokenStore is an in-memory TokenStore whose tokens are valid for a fixed TTL. Expired
+// tokens are swept out as tokens are stored, so keys that are never looked up again, such as
+// those bound to peers that went away, do not accumulate.
+type MemoryTokenStore struct {
+	ttl time.Duration
+
+	mu     sync.Mutex
+	tokens map[TokenStoreKey]storedToken
+	// sweepAt is how many tokens the store holds before the next sweep. It doubles with the
+	// tokens that survive a sweep so that sweeping stays amortized constant time per Put.
+	sweepAt int
+}
+
+// NewMemoryTokenStore returns a new MemoryTokenStore whose tokens are returned for up
+// to ttl after being stored.
+func NewMemoryTokenStore(ttl time.Duration) *MemoryTokenStore {
+	return &MemoryTokenStore{
+		ttl:     ttl,
+		tokens:  map[TokenStoreKey]storedToken{},
+		sweepAt: memoryTokenStoreMinSweep,
+	}
+}
+
+// Get returns the token stored for key if it was stored within the TTL.
+func (s *MemoryTokenStore) Get(key TokenStoreKey) (string, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	stored, ok := s.tokens[key]
+	if !ok {
+		return "", false
+	}
+	if time.Since(stored.storedAt) >= s.ttl {
+		delete(s.tokens, key)
+		return "", false
+	}
+	return stored.token, true
+}
+
+// Put stores token for key.
+func (s *MemoryTokenStore) Put(key TokenStoreKey, token string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	now := time.Now()
+	s.tokens[key] = storedToken{token: token, storedAt: now}
+	if len(s.tokens) < s.sweepAt {
+		return
+	}
+	for storedKey, stored := range s.tokens {
+		if now.Sub(stored.storedAt) >= s.ttl {
+			delete(s.tokens, storedKey)
+		}
+	}
+	s.sweepAt = 2 * len(s.tokens)
+	if s.sweepAt < memoryTokenStoreMinSweep {
+		s.sweepAt = memoryTokenStoreMinSweep
+	}
+}
+
+// InvalidateEntity removes all tokens issued to entity.
+func (s *MemoryTokenStore) InvalidateEntity(entity string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for key := range s.tokens {
+		if key.Entity == entity {
+			delete(s.tokens, key)
+		}
+	}
+}
@@ -0,0 +1,147 @@
+package rpc
+
+import (
+	"context"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/edaniels/golog"
+	"github.com/golang-jwt/jwt/v4"
+	"go.viam.com/test"
+	"google.golang.org/grpc/metadata"
+
+	rpcpb "go.viam.com/utils/proto/rpc/v1"
+)
+
+func TestMemoryTokenStore(t *testing.T) {
+	store := NewMemoryTokenStore(time.Hour)
+	key1 := newTokenStoreKey("fake", "foo", map[string]string{"a": "1", "b": "2"}, nil)
+	key2 := newTokenStoreKey("fake", "bar", nil, nil)
+
+	_, ok := store.Get(key1)
+	test.That(t, ok, test.ShouldBeFalse)
+
+	store.Put(key1, "token1")
+	store.Put(key2, "token2")
+	token, ok := store.Get(newTokenStoreKey("fake", "foo", map[string]string{"b": "2", "a": "1"}, nil))
+	test.That(t, ok, test.ShouldBeTrue)
+	test.That(t, token, test.ShouldEqual, "token1")
+
+	_, ok = store.Get(newTokenStoreKey("fake", "foo", map[string]string{"a": "1"}, nil))
+	test.That(t, ok, test.ShouldBeFalse)
+	_, ok = store.Get(newTokenStoreKey("other", "foo", map[string]string{"a": "1", "b": "2"}, nil))
+	test.That(t, ok, test.ShouldBeFalse)
+	_, ok = store.Get(newTokenStoreKey("fake", "foo", map[string]string{"a": "1", "b": "2"}, map[string][]string{"c": {"3"}}))
+	test.That(t, ok, test.ShouldBeFalse)
+
+	multiKey := newTokenStoreKey("fake", "foo", nil, map[string][]string{"roles": {"a", "b"}})
+	store.Put(multiKey, "token3")
+	_, ok = store.Get(newTokenStoreKey("fake", "foo", nil, map[string][]string{"roles": {"a,b"}}))
+	test.That(t, ok, test.ShouldBeFalse)
+	token, ok = store.Get(newTokenStoreKey("fake", "foo", nil, map[string][]string{"roles": {"a", "b"}}))
+	test.That(t, ok, test.ShouldBeTrue)
+	test.That(t, token, test.ShouldEqual, "token3")
+
+	store.InvalidateEntity("foo")
+	_, ok = store.Get(key1)
+	test.That(t, ok, test.ShouldBeFalse)
+	token, ok = store.Get(key2)
+	test.That(t, ok, test.ShouldBeTrue)
+	test.That(t, token, test.ShouldEqual, "token2")
+
+	expiring := NewMemoryTokenStore(time.Millisecond)
+	expiring.Put(key1, "token1")
+	time.Sleep(2 * time.Millisecond)
+	_, ok = expiring.Get(key1)
+	test.That(t, ok, test.ShouldBeFalse)
+
+	// expired tokens that are never looked up again are swept out as tokens are stored.
+	for i := 0; i < memoryTokenStoreMinSweep-1; i++ {
+		expiring.Put(TokenStoreKey{Entity: fmt.Sprintf("peer%d", i)}, "token")
+	}
+	time.Sleep(2 * time.Millisecond)
+	expiring.Put(key2, "token2")
+	test.That(t, expiring.tokens, test.ShouldHaveLength, 1)
+}
+
+func TestServerAuthTokenStore(t *testing.T) {
+	store := NewMemoryTokenStore(time.Hour)
+	ss := newAuthTestServer(t,
+		WithAuthHandler("fake", MakeSimpleAuthHandler([]string{"foo"}, "something")),
+		WithTokenStore(store),
+	)
+	ctx := metadata.NewIncomingContext(context.Background(), metadata.MD{})
+
+	authenticate := func(payload string) (string, error) {
+		resp, err := ss.Authenticate(ctx, &rpcpb.AuthenticateRequest{Entity: "foo", Credentials: &rpcpb.Credentials{
+			Type:    "fake",
+			Payload: payload,
+		}})
+		if err != nil {
+			return "", err
+		}
+		return resp.AccessToken, nil
+	}
+
+	token1, err := authenticate("something")
+	test.That(t, err, test.ShouldBeNil)
+	token2, err := authenticate("something")
+	test.That(t, err, test.ShouldBeNil)
+	test.That(t, token2, test.ShouldEqual, token1)
+
+	// credentials are still verified.
+	_, err = authenticate("wrong")
+	test.That(t, err, test.ShouldNotBeNil)
+
+	store.InvalidateEntity("foo")
+	token3, err := authenticate("something")
+	test.That(t, err, test.ShouldBeNil)
+	stored, ok := store.Get(newTokenStoreKey("fake", "foo", map[string]string{}, nil))
+	test.That(t, ok, test.ShouldBeTrue)
+	test.That(t, stored, test.ShouldEqual, token3)
+
+	_, err = NewServer(golog.NewTestLogger(t), WithTokenStore(nil))
+	test.That(t, err, test.ShouldNotBeNil)
+}
+
+func TestServerAuthTokenStoreFreshness(t *testing.T) {
+	for _, opt := range []ServerOption{
+		WithRecentAuthRequired(time.Minute, "/some.Service/Sensitive"),
+		WithMaxTokenAge(time.Minute),
+	} {
+		store := NewMemoryTokenStore(time.Hour)
+		ss := newAuthTestServer(t,
+			WithAuthHandler("fake", MakeSimpleAuthHandler([]string{"foo"}, "something")),
+			WithTokenStore(store),
+			opt,
+		)
+
+		// a token issued before the freshness window is not handed back.
+		key := newTokenStoreKey("fake", "foo", map[string]string{}, nil)
+		stale, err := jwt.NewWithClaims(jwt.SigningMethodRS256, JWTClaims{
+			RegisteredClaims: jwt.RegisteredClaims{
+				Audience:  jwt.ClaimStrings{"foo"},
+				IssuedAt:  jwt.NewNumericDate(time.Now().Add(-time.Hour)),
+				ExpiresAt: jwt.NewNumericDate(time.Now().Add(time.Hour)),
+			},
+			CredentialsType: "fake",
+		}).SignedString(ss.authRSAPrivKey)
+		test.That(t, err, test.ShouldBeNil)
+		store.Put(key, stale)
+
+		resp, err := ss.Authenticate(metadata.NewIncomingContext(context.Background(), metadata.MD{}),
+			&rpcpb.AuthenticateRequest{Entity: "foo", Credentials: &rpcpb.Credentials{Type: "fake", Payload: "something"}})
+		test.That(t, err, test.ShouldBeNil)
+		test.That(t, resp.AccessToken, test.ShouldNotEqual, stale)
+		stored, ok := store.Get(key)
+		test.That(t, ok, test.ShouldBeTrue)
+		test.That(t, stored, test.ShouldEqual, resp.AccessToken)
+
+		// fresh tokens still are.
+		again, err := ss.Authenticate(metadata.NewIncomingContext(context.Background(), metadata.MD{}),
+			&rpcpb.AuthenticateRequest{Entity: "foo", Credentials: &rpcpb.Credentials{Type: "fake", Payload: "something"}})
+		test.That(t, err, test.ShouldBeNil)
+		test.That(t, again.AccessToken, test.ShouldEqual, resp.AccessToken)
+	}
+}